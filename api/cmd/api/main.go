@@ -8,8 +8,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -23,6 +31,9 @@ import (
 	"github.com/brando1998/docubot-api/services"
 )
 
+// minURLSigningKeyLength longitud mínima de DOCUMENT_URL_SECRET
+const minURLSigningKeyLength = 32
+
 var (
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
@@ -33,6 +44,7 @@ var (
 func initDependencies() (*controllers.WebSocketHub, *gin.Engine) {
 	// 1. Configuración inicial
 	config.LoadEnv()
+	requireProductionSecrets()
 
 	// 2. Conexiones a bases de datos
 	if err := database.ConnectPostgres(); err != nil {
@@ -42,24 +54,45 @@ func initDependencies() (*controllers.WebSocketHub, *gin.Engine) {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
 
-	// 3. Migraciones
+	// 3. Migraciones e índices
 	runMigrations()
+	runMongoMigrations()
 
 	// 4. 🔥 NUEVO: Crear usuario administrador por defecto
 	if err := services.EnsureDefaultAdminUser(database.GetDB()); err != nil {
 		log.Fatalf("Failed to ensure default admin user: %v", err)
 	}
 
-	// 5. Inicialización de repositorios
+	// 5. Inicialización de repositorios y claves de los tokens
 	initRepositories()
+	initTokenKeys()
+	controllers.SetBaileysSecret(os.Getenv("BAILEYS_WS_SECRET"))
+
+	// 6. Motores NLU (Rasa, reglas, memoria)
+	controllers.SetNLURegistry(services.NewNLURegistryFromEnv())
+
+	// 7. Almacenamiento de archivos (local o S3) y generación de documentos (Playwright)
+	initDocumentPipeline()
 
-	// 6. WebSocket Hub
+	// 8. WebSocket Hub
 	wsHub := controllers.NewWebSocketHub()
+	wsHub.SetOutbox(repositories.NewOutboxRepository(database.DB))
+	go wsHub.RunOutboxWorker(context.Background())
+	go controllers.RunSessionReaper(context.Background())
+	go controllers.RunRefreshTokenReaper(context.Background())
 
-	// 7. Configuración de Gin
+	// 9. Cola de trabajos (generación de documentos y campañas)
+	jobRepo := repositories.NewJobRepository(database.DB)
+	jobQueue := services.NewJobQueue(jobRepo, getJobWorkers())
+	controllers.SetJobRepo(jobRepo)
+	controllers.SetJobQueue(jobQueue, wsHub)
+	go jobQueue.Run(context.Background())
+
+	// 10. Configuración de Gin
 	routerConfig := &routes.RouterConfig{
-		WSHub:    wsHub,
-		Upgrader: &upgrader,
+		WSHub:          wsHub,
+		Upgrader:       &upgrader,
+		AllowedOrigins: getAllowedOrigins(),
 	}
 	r := gin.Default()
 
@@ -74,16 +107,54 @@ func runMigrations() {
 	err := database.DB.AutoMigrate(
 		&models.Client{},
 		&models.Bot{},
-		&models.WhatsAppSession{},
 		&models.SystemUser{}, // 🔥 NUEVO: Agregar migración del SystemUser
+		&models.OutboundMessage{},
+		&models.Job{},
+		&models.JobLog{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+		&models.MessageTemplate{},
+		&models.AuditLog{},
+		&models.RefreshToken{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
+	if activated, err := repositories.ActivateLegacyBots(database.DB); err != nil {
+		log.Fatalf("Failed to activate legacy bots: %v", err)
+	} else if activated > 0 {
+		log.Printf("🤖 %d bots creados automáticamente quedaron registrados como activos", activated)
+	}
+	if assigned, err := repositories.AssignLegacyRoles(database.DB); err != nil {
+		log.Fatalf("Failed to assign legacy roles: %v", err)
+	} else {
+		for _, user := range assigned {
+			log.Printf("👤 Usuario %s (id %d) con rol anterior %q quedó como %s; un admin debe asignarle su rol", user.Username, user.ID, user.Role, models.RoleViewer)
+		}
+	}
 
 	log.Println("✅ Migraciones completadas exitosamente")
 }
 
+func runMongoMigrations() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := repositories.MigrateConversations(ctx, database.MongoClient); err != nil {
+		log.Fatalf("Failed to migrate conversations: %v", err)
+	}
+	if err := repositories.EnsureConversationIndexes(ctx, database.MongoClient); err != nil {
+		log.Fatalf("Failed to create MongoDB indexes: %v", err)
+	}
+	if err := repositories.EnsureDocumentIndexes(ctx, database.MongoClient); err != nil {
+		log.Fatalf("Failed to create MongoDB indexes: %v", err)
+	}
+	if err := repositories.EnsureMediaIndexes(ctx, database.MongoClient); err != nil {
+		log.Fatalf("Failed to create MongoDB indexes: %v", err)
+	}
+	log.Println("✅ Índices de MongoDB listos")
+}
+
 func initRepositories() {
 	conversationRepo := repositories.NewConversationRepository(database.MongoClient)
 	clientRepo := repositories.NewClientRepository(database.DB)
@@ -92,6 +163,172 @@ func initRepositories() {
 	controllers.SetConversationRepo(conversationRepo)
 	controllers.SetClientRepo(clientRepo)
 	controllers.SetBotRepo(botRepo)
+	controllers.SetSessionRepo(repositories.NewSessionRepository(database.MongoClient))
+	controllers.SetSessionTimeout(getSessionTimeout())
+	controllers.SetDocumentRepo(repositories.NewDocumentRepository(database.MongoClient))
+	controllers.SetMediaRepo(repositories.NewMediaRepository(database.MongoClient))
+	controllers.SetCampaignRepo(repositories.NewCampaignRepository(database.DB))
+	controllers.SetCampaignRate(getCampaignRate())
+	controllers.SetTemplateRepo(repositories.NewTemplateRepository(database.DB))
+	controllers.SetSystemUserRepo(repositories.NewSystemUserRepository(database.DB))
+	controllers.SetAuditRepo(repositories.NewAuditRepository(database.DB))
+	controllers.SetRefreshTokenRepo(repositories.NewRefreshTokenRepository(database.DB))
+	controllers.SetTokenLifetimes(
+		getTokenTTL("ACCESS_TOKEN_TTL", controllers.DefaultAccessTokenTTL),
+		getTokenTTL("REFRESH_TOKEN_TTL", controllers.DefaultRefreshTokenTTL),
+	)
+}
+
+// initTokenKeys carga las claves de los access tokens. En producción (GIN_MODE=release)
+// no arranca sin clave de firma.
+func initTokenKeys() {
+	tokens, err := services.NewTokenServiceFromEnv(isProduction())
+	if err != nil {
+		log.Fatalf("Failed to load token keys: %v", err)
+	}
+	controllers.SetTokenService(tokens)
+}
+
+func initDocumentPipeline() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	storage, err := services.NewFileStorageFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("Failed to initialize document storage: %v", err)
+	}
+	controllers.SetMediaStorage(storage)
+	if err := controllers.SetMediaSource(getEnvOrDefault("BAILEYS_URL", "http://baileys:3000")); err != nil {
+		log.Fatalf("Failed to configure media source: %v", err)
+	}
+	controllers.SetDocumentPipeline(
+		services.NewPlaywrightClient(getEnvOrDefault("PLAYWRIGHT_URL", "http://playwright:3001")),
+		storage,
+		services.NewURLSigner(getURLSigningKey()),
+		getEnvOrDefault("API_URL", "http://api:8080"),
+	)
+}
+
+// getURLSigningKey clave con la que se firman los enlaces de descarga (DOCUMENT_URL_SECRET).
+// Es obligatoria en producción; en desarrollo se usa una temporal y los enlaces dejan de
+// valer al reiniciar.
+func getURLSigningKey() []byte {
+	secret := os.Getenv("DOCUMENT_URL_SECRET")
+	switch {
+	case len(secret) >= minURLSigningKeyLength:
+		return []byte(secret)
+	case secret != "":
+		log.Fatalf("DOCUMENT_URL_SECRET must be at least %d characters long", minURLSigningKeyLength)
+	case isProduction():
+		log.Fatal("DOCUMENT_URL_SECRET is required in production to sign download URLs")
+	}
+
+	key := make([]byte, minURLSigningKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate download URL key: %v", err)
+	}
+	log.Println("⚠️  DOCUMENT_URL_SECRET no definida: se usa una clave temporal y los enlaces de descarga dejarán de valer al reiniciar")
+	return key
+}
+
+// requireProductionSecrets detiene el arranque en producción si falta alguna de las claves
+// sin las que se podrían falsificar tokens o enlaces de descarga o suplantar a Baileys
+func requireProductionSecrets() {
+	if !isProduction() {
+		return
+	}
+	var missing []string
+	if os.Getenv("PASETO_SIGNING_KEY") == "" && os.Getenv("PASETO_SIGNING_KEY_FILE") == "" {
+		missing = append(missing, "PASETO_SIGNING_KEY")
+	}
+	if os.Getenv("DOCUMENT_URL_SECRET") == "" {
+		missing = append(missing, "DOCUMENT_URL_SECRET")
+	}
+	if os.Getenv("BAILEYS_WS_SECRET") == "" {
+		missing = append(missing, "BAILEYS_WS_SECRET")
+	}
+	if len(missing) > 0 {
+		log.Fatalf("Refusing to start in release mode without %s", strings.Join(missing, ", "))
+	}
+}
+
+// isProduction indica si el API corre en producción (GIN_MODE=release)
+func isProduction() bool {
+	return os.Getenv("GIN_MODE") == gin.ReleaseMode
+}
+
+// getJobWorkers cantidad de workers de la cola de trabajos (JOB_WORKERS)
+func getJobWorkers() int {
+	raw := os.Getenv("JOB_WORKERS")
+	if raw == "" {
+		return services.DefaultJobWorkers
+	}
+	workers, err := strconv.Atoi(raw)
+	if err != nil || workers <= 0 {
+		log.Printf("⚠️  JOB_WORKERS inválido (%q), usando %d", raw, services.DefaultJobWorkers)
+		return services.DefaultJobWorkers
+	}
+	return workers
+}
+
+// getCampaignRate mensajes por minuto de cada bot en las campañas (CAMPAIGN_RATE_PER_MINUTE)
+func getCampaignRate() int {
+	raw := os.Getenv("CAMPAIGN_RATE_PER_MINUTE")
+	if raw == "" {
+		return controllers.DefaultCampaignRatePerMinute
+	}
+	rate, err := strconv.Atoi(raw)
+	if err != nil || rate <= 0 {
+		log.Printf("⚠️  CAMPAIGN_RATE_PER_MINUTE inválido (%q), usando %d", raw, controllers.DefaultCampaignRatePerMinute)
+		return controllers.DefaultCampaignRatePerMinute
+	}
+	return rate
+}
+
+// getAllowedOrigins orígenes del dashboard separados por coma (CORS_ALLOWED_ORIGINS)
+func getAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(getEnvOrDefault("CORS_ALLOWED_ORIGINS", "http://localhost:3002"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getSessionTimeout ventana de inactividad de las sesiones (CONVERSATION_SESSION_TIMEOUT, ej: 30m, 2h)
+func getSessionTimeout() time.Duration {
+	raw := os.Getenv("CONVERSATION_SESSION_TIMEOUT")
+	if raw == "" {
+		return controllers.DefaultSessionTimeout
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 {
+		log.Printf("⚠️  CONVERSATION_SESSION_TIMEOUT inválido (%q), usando %s", raw, controllers.DefaultSessionTimeout)
+		return controllers.DefaultSessionTimeout
+	}
+	return timeout
+}
+
+// getTokenTTL duración de un tipo de token (ACCESS_TOKEN_TTL, REFRESH_TOKEN_TTL; ej: 15m, 720h)
+func getTokenTTL(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		log.Printf("⚠️  %s inválido (%q), usando %s", name, raw, fallback)
+		return fallback
+	}
+	return ttl
 }
 
 func getServerPort() string {
@@ -104,14 +341,35 @@ func getServerPort() string {
 func main() {
 	log.Println("🤖 Docubot API - Iniciando...")
 
-	_, router := initDependencies()
+	wsHub, router := initDependencies()
 
 	port := getServerPort()
 	log.Printf("🚀 Server starting on port %s", port)
 	log.Printf("📊 Health endpoint: http://localhost:%s/health", port)
 	log.Printf("📚 API docs: http://localhost:%s/docs/index.html", port)
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Cierre ordenado: primero los WebSocket de los bots, luego el servidor HTTP
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("🛑 Apagando servidor...")
+	wsHub.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error during server shutdown: %v", err)
 	}
 }
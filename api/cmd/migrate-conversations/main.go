@@ -0,0 +1,52 @@
+// migrate-conversations mueve los mensajes embebidos en cada documento de
+// conversations a la colección messages, dejando solo el resumen de la conversación.
+// Puede ejecutarse varias veces: los mensajes ya copiados se omiten.
+//
+//	go run ./cmd/migrate-conversations [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/brando1998/docubot-api/config"
+	database "github.com/brando1998/docubot-api/databases"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "solo contar conversaciones y mensajes a migrar")
+	flag.Parse()
+
+	log.Println("🔄 Docubot - Migración de conversaciones a la colección messages")
+
+	config.LoadEnv()
+	if err := database.ConnectMongoDB(); err != nil {
+		log.Fatalf("❌ Error al conectar a MongoDB: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Normalizar documentos antiguos (user_id → client_id) antes de copiar
+	if !*dryRun {
+		if err := repositories.MigrateConversations(ctx, database.MongoClient); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	stats, err := repositories.SplitConversationMessages(ctx, database.MongoClient, *dryRun)
+	if err != nil {
+		log.Fatalf("❌ Migración interrumpida tras %d conversaciones: %v", stats.Conversations, err)
+	}
+
+	if *dryRun {
+		log.Printf("🔍 Se migrarían %d mensajes de %d conversaciones", stats.Messages, stats.Conversations)
+		return
+	}
+
+	if err := repositories.EnsureConversationIndexes(ctx, database.MongoClient); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Printf("✅ %d conversaciones migradas: %d mensajes copiados, %d ya existían", stats.Conversations, stats.Messages, stats.Skipped)
+}
@@ -1,32 +1,32 @@
 package controllers
 
 import (
-	"errors"
-	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/gin-gonic/gin"
-	"github.com/o1egl/paseto"
 
 	database "github.com/brando1998/docubot-api/databases"
 	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
 )
 
-const (
-	tokenDuration = 24 * time.Hour
-)
+// TokenIssuer emisor (claim "iss") de los access tokens
+const TokenIssuer = "docubot-api"
 
-// ✅ SIMPLIFICAR: Token solo con lo esencial
+// ✅ SIMPLIFICAR: Token solo con lo esencial. Usa los claims estándar de PASETO (iss,
+// iat, exp, jti) para que otros servicios puedan validarlo con cualquier librería.
 type PasetoPayload struct {
+	Issuer    string    `json:"iss"`
 	UserID    uint      `json:"user_id"`
 	Username  string    `json:"username"`
 	Role      string    `json:"role"`
-	IssuedAt  time.Time `json:"issued_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+	TokenID   string    `json:"jti"` // permite rechazarlo si se revoca la sesión
+	SessionID string    `json:"sid"` // sesión (cadena de refresh tokens) a la que pertenece
 	// ✅ NO incluir email - lo obtendremos del endpoint /auth/me
 }
 
@@ -38,16 +38,23 @@ type LoginRequest struct {
 
 // LoginResponse define la estructura de respuesta para el login
 type LoginResponse struct {
-	AccessToken string    `json:"access_token"`
-	ExpiresAt   time.Time `json:"expires_at"`
-	User        struct {
-		ID       uint   `json:"id"`
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Role     string `json:"role"`
+	TokenResponse
+	User struct {
+		ID                    uint                `json:"id"`
+		Username              string              `json:"username"`
+		Email                 string              `json:"email"`
+		Role                  string              `json:"role"`
+		Permissions           []models.Permission `json:"permissions"` // permisos del rol, para mostrar u ocultar acciones
+		PasswordResetRequired bool                `json:"password_reset_required"`
 	} `json:"user"`
 }
 
+// ChangePasswordRequest cambio de contraseña del usuario autenticado
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 // LoginWithPaseto maneja el inicio de sesión
 func LoginWithPaseto(c *gin.Context) {
 	var req LoginRequest
@@ -82,115 +89,58 @@ func LoginWithPaseto(c *gin.Context) {
 	user.LastLogin = &now
 	db.Save(&user)
 
-	// ✅ SIMPLIFICAR: Token sin email
-	token, expiresAt, err := generatePasetoToken(user.ID, user.Username, user.Role)
+	// ✅ SIMPLIFICAR: Token sin email. Cada login abre una sesión nueva.
+	tokens, refreshToken, err := issueSessionTokens(c, &user, newTokenID())
+	if err == nil {
+		err = refreshTokenRepo.CreateRefreshToken(refreshToken)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generando token"})
 		return
 	}
 
-	response := LoginResponse{
-		AccessToken: token,
-		ExpiresAt:   expiresAt,
-	}
+	response := LoginResponse{TokenResponse: tokens}
 	response.User.ID = user.ID
 	response.User.Username = user.Username
 	response.User.Email = user.Email
 	response.User.Role = user.Role
+	response.User.Permissions = models.RolePermissions(user.Role)
+	response.User.PasswordResetRequired = user.PasswordResetRequired
 
 	c.JSON(http.StatusOK, response)
 }
 
-// generatePasetoToken genera un nuevo token PASETO
-func generatePasetoToken(userID uint, username, role string) (string, time.Time, error) {
-	fmt.Printf("🔍 DEBUG generatePasetoToken - UserID: %d, Username: %s, Role: %s\n", userID, username, role)
-
+// generatePasetoToken genera un access token PASETO v4.public firmado con la clave vigente
+func generatePasetoToken(userID uint, username, role, sessionID string) (string, string, time.Time, error) {
 	now := time.Now()
-	expiresAt := now.Add(tokenDuration)
-
 	payload := PasetoPayload{
+		Issuer:    TokenIssuer,
 		UserID:    userID,
 		Username:  username,
 		Role:      role,
 		IssuedAt:  now,
-		ExpiresAt: expiresAt,
-	}
-
-	fmt.Printf("🔍 DEBUG - Payload creado: %+v\n", payload)
-
-	secretKeyFromEnv := os.Getenv("PASETO_SECRET_KEY")
-	fmt.Printf("🔍 DEBUG - Variable PASETO_SECRET_KEY completa: '%s'\n", secretKeyFromEnv)
-	fmt.Printf("🔍 DEBUG - Longitud exacta: %d caracteres\n", len(secretKeyFromEnv))
-
-	secretKey := []byte(secretKeyFromEnv)
-
-	fmt.Printf("🔍 DEBUG - Longitud después de []byte: %d\n", len(secretKey))
-
-	if len(secretKey) == 0 {
-		fmt.Printf("🔍 DEBUG - Variable vacía, usando clave por defecto\n")
-		secretKey = []byte("default-secret-key-change-in-production-32-chars")
-		fmt.Printf("🔍 DEBUG - Nueva longitud: %d\n", len(secretKey))
-	}
-
-	if len(secretKey) != 32 {
-		fmt.Printf("❌ DEBUG - Longitud de clave incorrecta: %d, se requieren 32\n", len(secretKey))
-		return "", time.Time{}, errors.New("PASETO_SECRET_KEY debe tener exactamente 32 caracteres")
+		ExpiresAt: now.Add(accessTokenTTL),
+		TokenID:   newTokenID(),
+		SessionID: sessionID,
 	}
 
-	fmt.Printf("🔍 DEBUG - Intentando encriptar token...\n")
-	v2 := paseto.NewV2()
-	token, err := v2.Encrypt(secretKey, payload, nil)
+	token, err := tokenService.Sign(payload)
 	if err != nil {
-		fmt.Printf("❌ DEBUG - Error en v2.Encrypt: %v\n", err)
-		return "", time.Time{}, err
+		return "", "", time.Time{}, err
 	}
-
-	fmt.Printf("✅ DEBUG - Token generado exitosamente, longitud: %d\n", len(token))
-	return token, expiresAt, nil
+	return token, payload.TokenID, payload.ExpiresAt, nil
 }
 
-// RefreshPasetoToken maneja la renovación de tokens
-func RefreshPasetoToken(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "token no proporcionado"})
-		return
-	}
-
-	tokenString := authHeader
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		tokenString = authHeader[7:]
-	}
-
-	payload, err := verifyPasetoToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "token inválido"})
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error de conexión a base de datos"})
-		return
-	}
-
-	var user models.SystemUser
-	if err := db.First(&user, payload.UserID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no encontrado"})
-		return
+// VerifyAccessToken verifica la firma y la vigencia de un access token y devuelve su payload
+func VerifyAccessToken(token string) (*PasetoPayload, error) {
+	var payload PasetoPayload
+	if err := tokenService.Verify(token, &payload, time.Now()); err != nil {
+		return nil, err
 	}
-
-	// ✅ SIMPLIFICAR: Nuevo token sin email
-	newToken, expiresAt, err := generatePasetoToken(user.ID, user.Username, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generando nuevo token"})
-		return
+	if payload.Issuer != TokenIssuer {
+		return nil, services.ErrTokenInvalid
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"access_token": newToken,
-		"expires_at":   expiresAt,
-	})
+	return &payload, nil
 }
 
 // ✅ NUEVO: Endpoint para obtener usuario actual del sistema
@@ -223,36 +173,57 @@ func GetCurrentSystemUser(c *gin.Context) {
 
 	// ✅ RESPUESTA: Solo datos necesarios, sin password
 	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"role":       user.Role,
-		"is_active":  user.IsActive,
-		"last_login": user.LastLogin,
+		"id":                      user.ID,
+		"username":                user.Username,
+		"email":                   user.Email,
+		"role":                    user.Role,
+		"permissions":             models.RolePermissions(user.Role),
+		"is_active":               user.IsActive,
+		"last_login":              user.LastLogin,
+		"password_reset_required": user.PasswordResetRequired,
 	})
 }
 
-// verifyPasetoToken verifica y decodifica el token
-func verifyPasetoToken(token string) (*PasetoPayload, error) {
-	v2 := paseto.NewV2()
-	var payload PasetoPayload
-
-	secretKey := []byte(os.Getenv("PASETO_SECRET_KEY"))
-	if len(secretKey) == 0 {
-		secretKey = []byte("default-secret-key-change-in-production-32-chars")
+// ChangePassword cambia la contraseña del usuario autenticado y cierra sus demás
+// sesiones. Es la única ruta disponible mientras tenga un cambio de contraseña pendiente.
+// @Summary Cambiar mi contraseña
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param data body ChangePasswordRequest true "Contraseña actual y nueva"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/password [post]
+func ChangePassword(c *gin.Context) {
+	var request ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
 	}
-
-	if len(secretKey) != 32 {
-		return nil, errors.New("PASETO_SECRET_KEY debe tener exactamente 32 caracteres")
+	user, err := systemUserRepo.GetSystemUser(currentOperatorID(c), false)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no encontrado"})
+		return
 	}
-
-	if err := v2.Decrypt(token, secretKey, &payload, nil); err != nil {
-		return nil, errors.New("token inválido")
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Contraseña actual incorrecta"})
+		return
 	}
-
-	if time.Now().After(payload.ExpiresAt) {
-		return nil, errors.New("token expirado")
+	if request.NewPassword == request.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La nueva contraseña debe ser distinta de la actual"})
+		return
+	}
+	if !setPassword(c, user, request.NewPassword) {
+		return
 	}
+	user.PasswordResetRequired = false
 
-	return &payload, nil
+	if err := systemUserRepo.UpdateSystemUser(user, userAudit(c, models.AuditUserPasswordChanged, user.ID, nil)); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	revokeUserSessions(user.ID, c.GetString("current_session_id"), models.TokenRevokedPasswordChange)
+	c.JSON(http.StatusOK, gin.H{"message": "Contraseña actualizada"})
 }
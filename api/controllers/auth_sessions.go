@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+const (
+	// DefaultAccessTokenTTL duración de los access tokens si no se configura otra
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL duración de los refresh tokens si no se configura otra
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// refreshTokenReapInterval cada cuánto se borran los refresh tokens vencidos
+	refreshTokenReapInterval = 6 * time.Hour
+)
+
+var (
+	tokenService     *services.TokenService
+	refreshTokenRepo repositories.RefreshTokenRepository
+	accessTokenTTL   = DefaultAccessTokenTTL
+	refreshTokenTTL  = DefaultRefreshTokenTTL
+)
+
+// SetTokenService configura las claves con que se firman y verifican los access tokens
+func SetTokenService(service *services.TokenService) {
+	tokenService = service
+}
+
+func SetRefreshTokenRepo(repo repositories.RefreshTokenRepository) {
+	refreshTokenRepo = repo
+}
+
+// SetTokenLifetimes configura la duración de los access y refresh tokens
+func SetTokenLifetimes(access, refresh time.Duration) {
+	accessTokenTTL = access
+	refreshTokenTTL = refresh
+}
+
+// TokenResponse access token de corta duración y refresh token para renovarlo
+type TokenResponse struct {
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"` // de un solo uso: cada renovación entrega otro
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshRequest datos para renovar el access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// issueSessionTokens emite un access token y un refresh token de la sesión. Devuelve
+// también la fila del refresh token, que quien llama debe guardar.
+func issueSessionTokens(c *gin.Context, user *models.SystemUser, sessionID string) (TokenResponse, *models.RefreshToken, error) {
+	accessToken, accessTokenID, expiresAt, err := generatePasetoToken(user.ID, user.Username, user.Role, sessionID)
+	if err != nil {
+		return TokenResponse{}, nil, err
+	}
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return TokenResponse{}, nil, err
+	}
+
+	stored := &models.RefreshToken{
+		UserID:        user.ID,
+		SessionID:     sessionID,
+		TokenHash:     hashRefreshToken(refreshToken),
+		AccessTokenID: accessTokenID,
+		ExpiresAt:     time.Now().Add(refreshTokenTTL),
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+	}
+	return TokenResponse{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, stored, nil
+}
+
+// RefreshPasetoToken cambia un refresh token por un access token nuevo y otro refresh
+// token. Presentar un refresh token ya usado revoca toda la sesión: indica que pudo
+// haberse filtrado.
+// @Summary Renovar token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param data body RefreshRequest true "Refresh token vigente"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func RefreshPasetoToken(c *gin.Context) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token requerido"})
+		return
+	}
+
+	stored, err := refreshTokenRepo.GetRefreshTokenByHash(hashRefreshToken(request.RefreshToken))
+	if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token inválido"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando el token"})
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case stored.RevokedAt != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sesión cerrada"})
+		return
+	case stored.UsedAt != nil:
+		revokeReusedSession(stored, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reutilizado; la sesión fue cerrada"})
+		return
+	case now.After(stored.ExpiresAt):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token vencido"})
+		return
+	}
+
+	user, err := systemUserRepo.GetSystemUser(stored.UserID, false)
+	if err != nil || !user.IsActive {
+		revokeSession(stored.SessionID, models.TokenRevokedUserDisabled)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario inactivo o inexistente"})
+		return
+	}
+
+	tokens, next, err := issueSessionTokens(c, user, stored.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generando nuevo token"})
+		return
+	}
+	rotated, err := refreshTokenRepo.RotateRefreshToken(stored, next, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generando nuevo token"})
+		return
+	}
+	if !rotated {
+		// Otra solicitud usó el mismo token al mismo tiempo
+		revokeReusedSession(stored, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reutilizado; la sesión fue cerrada"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout cierra la sesión del token actual: su refresh token y sus access tokens dejan
+// de aceptarse
+// @Summary Cerrar sesión
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	if _, err := refreshTokenRepo.RevokeSession(c.GetString("current_session_id"), models.TokenRevokedLogout, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error cerrando la sesión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada"})
+}
+
+// LogoutAll cierra todas las sesiones del usuario, incluida la actual
+// @Summary Cerrar todas las sesiones
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout-all [post]
+func LogoutAll(c *gin.Context) {
+	revoked, err := refreshTokenRepo.RevokeUserSessions(currentOperatorID(c), "", models.TokenRevokedLogoutAll, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error cerrando las sesiones"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sesiones cerradas", "revoked_tokens": revoked})
+}
+
+// GetTokenKeys claves públicas con que se verifican los access tokens, para que otros
+// servicios (Baileys, Playwright) los validen sin ningún secreto. Durante una rotación
+// incluye la clave nueva y las anteriores.
+// @Summary Claves públicas de los tokens
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/keys [get]
+func GetTokenKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"version":    "v4.public",
+		"issuer":     TokenIssuer,
+		"signing_id": tokenService.KeyID(),
+		"keys":       tokenService.PublicKeys(),
+	})
+}
+
+// AccessTokenRevoked indica si el access token pertenece a una sesión cerrada. Los
+// tokens sin ID (emitidos antes de las sesiones revocables) cuentan como revocados.
+func AccessTokenRevoked(tokenID string) (bool, error) {
+	if tokenID == "" {
+		return true, nil
+	}
+	return refreshTokenRepo.IsAccessTokenRevoked(tokenID)
+}
+
+// revokeReusedSession cierra la sesión de un refresh token presentado por segunda vez
+func revokeReusedSession(token *models.RefreshToken, at time.Time) {
+	log.Printf("🚨 Refresh token reutilizado en la sesión %s del usuario %d; se revoca la sesión", token.SessionID, token.UserID)
+	if _, err := refreshTokenRepo.RevokeSession(token.SessionID, models.TokenRevokedReuseDetected, at); err != nil {
+		log.Printf("Failed to revoke session %s: %v", token.SessionID, err)
+	}
+}
+
+func revokeSession(sessionID, reason string) {
+	if _, err := refreshTokenRepo.RevokeSession(sessionID, reason, time.Now()); err != nil {
+		log.Printf("Failed to revoke session %s: %v", sessionID, err)
+	}
+}
+
+// revokeUserSessions cierra las sesiones del usuario salvo exceptSessionID. El cambio
+// que la motiva ya se guardó, así que un error solo se registra.
+func revokeUserSessions(userID uint, exceptSessionID, reason string) {
+	if refreshTokenRepo == nil {
+		return
+	}
+	revoked, err := refreshTokenRepo.RevokeUserSessions(userID, exceptSessionID, reason, time.Now())
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+		return
+	}
+	if revoked > 0 {
+		log.Printf("🔒 %d tokens del usuario %d revocados (%s)", revoked, userID, reason)
+	}
+}
+
+// RunRefreshTokenReaper borra periódicamente los refresh tokens vencidos
+func RunRefreshTokenReaper(ctx context.Context) {
+	if refreshTokenRepo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(refreshTokenReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := refreshTokenRepo.DeleteExpiredRefreshTokens(time.Now())
+			if err != nil {
+				log.Printf("Failed to delete expired refresh tokens: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("🧹 %d refresh tokens vencidos eliminados", deleted)
+			}
+		}
+	}
+}
+
+// newTokenID identificador aleatorio (128 bits) de un access token o de una sesión
+func newTokenID() string {
+	return rand.Text()
+}
+
+// newRefreshToken refresh token opaco de 256 bits
+func newRefreshToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashRefreshToken forma en que se guarda el refresh token. SHA-256 basta: el token es
+// aleatorio y largo, no una contraseña.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+// useTestTokenService firma los tokens de la prueba con una clave temporal
+func useTestTokenService(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	SetTokenService(services.NewTokenService(key))
+	t.Cleanup(func() { SetTokenService(nil) })
+}
+
+// memoryRefreshTokenRepo guarda refresh tokens en memoria sobre MockRefreshTokenRepo
+func memoryRefreshTokenRepo() (*mocks.MockRefreshTokenRepo, *[]*models.RefreshToken) {
+	var tokens []*models.RefreshToken
+	revoke := func(match func(*models.RefreshToken) bool, reason string, at time.Time) int64 {
+		var count int64
+		for _, token := range tokens {
+			if token.RevokedAt == nil && match(token) {
+				token.RevokedAt = &at
+				token.RevokedReason = reason
+				count++
+			}
+		}
+		return count
+	}
+
+	return &mocks.MockRefreshTokenRepo{
+		CreateRefreshTokenFunc: func(token *models.RefreshToken) error {
+			token.ID = uint(len(tokens) + 1)
+			tokens = append(tokens, token)
+			return nil
+		},
+		GetRefreshTokenByHashFunc: func(hash string) (*models.RefreshToken, error) {
+			for _, token := range tokens {
+				if token.TokenHash == hash {
+					copied := *token
+					return &copied, nil
+				}
+			}
+			return nil, repositories.ErrRefreshTokenNotFound
+		},
+		RotateRefreshTokenFunc: func(used *models.RefreshToken, next *models.RefreshToken, at time.Time) (bool, error) {
+			stored := tokens[used.ID-1]
+			if stored.UsedAt != nil || stored.RevokedAt != nil {
+				return false, nil
+			}
+			stored.UsedAt = &at
+			next.ID = uint(len(tokens) + 1)
+			tokens = append(tokens, next)
+			return true, nil
+		},
+		RevokeSessionFunc: func(sessionID string, reason string, at time.Time) (int64, error) {
+			return revoke(func(token *models.RefreshToken) bool { return token.SessionID == sessionID }, reason, at), nil
+		},
+		RevokeUserSessionsFunc: func(userID uint, exceptSessionID string, reason string, at time.Time) (int64, error) {
+			return revoke(func(token *models.RefreshToken) bool {
+				return token.UserID == userID && token.SessionID != exceptSessionID
+			}, reason, at), nil
+		},
+		IsAccessTokenRevokedFunc: func(tokenID string) (bool, error) {
+			for _, token := range tokens {
+				if token.AccessTokenID == tokenID {
+					return token.RevokedAt != nil, nil
+				}
+			}
+			return true, nil
+		},
+	}, &tokens
+}
+
+func TestRefreshTokenRotationAndReuseDetection(t *testing.T) {
+	useTestTokenService(t)
+	repo, tokens := memoryRefreshTokenRepo()
+	SetRefreshTokenRepo(repo)
+	SetSystemUserRepo(&mocks.MockSystemUserRepo{
+		GetSystemUserFunc: func(id uint, includeDeleted bool) (*models.SystemUser, error) {
+			return &models.SystemUser{ID: id, Username: "ana", Role: models.RoleOperator, IsActive: true}, nil
+		},
+	})
+	t.Cleanup(func() {
+		SetRefreshTokenRepo(nil)
+		SetSystemUserRepo(nil)
+	})
+
+	router := gin.New()
+	router.POST("/auth/refresh", RefreshPasetoToken)
+	refresh := func(token string) (*httptest.ResponseRecorder, TokenResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "`+token+`"}`)))
+		var response TokenResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Login: se guarda solo el hash del refresh token
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	login, stored, err := issueSessionTokens(c, &models.SystemUser{ID: 7, Username: "ana", Role: models.RoleOperator}, newTokenID())
+	assert.NoError(t, err)
+	assert.NoError(t, repo.CreateRefreshToken(stored))
+	assert.NotEqual(t, login.RefreshToken, stored.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(DefaultAccessTokenTTL), login.ExpiresAt, time.Minute)
+
+	// Cada renovación entrega un refresh token distinto de la misma sesión
+	w, rotated := refresh(login.RefreshToken)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+	payload, err := VerifyAccessToken(rotated.AccessToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, (*tokens)[1].AccessTokenID, payload.TokenID)
+		assert.Equal(t, uint(7), payload.UserID)
+	}
+	assert.Len(t, *tokens, 2)
+	assert.Equal(t, (*tokens)[0].SessionID, (*tokens)[1].SessionID)
+	revoked, _ := AccessTokenRevoked((*tokens)[1].AccessTokenID)
+	assert.False(t, revoked)
+
+	// Reusar el refresh token anterior cierra toda la sesión
+	w, _ = refresh(login.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	for _, token := range *tokens {
+		assert.Equal(t, models.TokenRevokedReuseDetected, token.RevokedReason)
+	}
+	revoked, _ = AccessTokenRevoked((*tokens)[1].AccessTokenID)
+	assert.True(t, revoked)
+	w, _ = refresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = refresh("desconocido")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	revoked, _ = AccessTokenRevoked("")
+	assert.True(t, revoked, "tokens sin ID no se aceptan")
+}
+
+func TestLogoutRevokesOnlyCurrentSession(t *testing.T) {
+	useTestTokenService(t)
+	repo, tokens := memoryRefreshTokenRepo()
+	SetRefreshTokenRepo(repo)
+	t.Cleanup(func() { SetRefreshTokenRepo(nil) })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	user := &models.SystemUser{ID: 7, Username: "ana", Role: models.RoleOperator}
+	for i := 0; i < 3; i++ {
+		_, stored, err := issueSessionTokens(c, user, newTokenID())
+		assert.NoError(t, err)
+		assert.NoError(t, repo.CreateRefreshToken(stored))
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("current_user_id", uint(7))
+		c.Set("current_session_id", (*tokens)[0].SessionID)
+	})
+	router.POST("/auth/logout", Logout)
+	router.POST("/auth/logout-all", LogoutAll)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/logout", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, (*tokens)[0].RevokedAt)
+	assert.Nil(t, (*tokens)[1].RevokedAt)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/logout-all", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message": "Sesiones cerradas", "revoked_tokens": 2}`, w.Body.String())
+	for _, token := range *tokens {
+		revoked, _ := AccessTokenRevoked(token.AccessTokenID)
+		assert.True(t, revoked)
+	}
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Tiempo máximo para escribir un frame en el socket
+	writeWait = 10 * time.Second
+	// Tiempo máximo sin recibir nada (pong incluido) antes de dar el socket por muerto
+	pongWait = 60 * time.Second
+	// Frecuencia de los pings; debe ser menor que pongWait
+	pingPeriod = (pongWait * 9) / 10
+	// Tamaño máximo de un frame entrante
+	maxMessageSize = 1 << 20
+	// Frames que pueden esperar en cola antes de considerar al bot saturado
+	sendBufferSize = 256
+	// Mensajes entrantes que pueden esperar a ser procesados antes de rechazar nuevos
+	inboundBufferSize = 256
+)
+
+var (
+	ErrConnectionClosed = errors.New("bot connection closed")
+	ErrSendBufferFull   = errors.New("bot send buffer full")
+	ErrInboundBusy      = errors.New("bot inbound queue full")
+)
+
+// outboundFrame frame pendiente de escritura; result recibe el resultado si no es nil
+type outboundFrame struct {
+	data   []byte
+	result chan error
+}
+
+// BotState último estado de la sesión de WhatsApp reportado por Baileys
+type BotState struct {
+	Connection  string     `json:"connection"` // connecting, open, close
+	Number      string     `json:"number,omitempty"`
+	Name        string     `json:"name,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	QRCode      string     `json:"qr_code,omitempty"`
+	QRImage     string     `json:"qr_image,omitempty"`
+	QRUpdatedAt *time.Time `json:"qr_updated_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// BotConnection envuelve la conexión WebSocket de un bot de Baileys.
+// Todas las escrituras pasan por un único goroutine escritor; las lecturas
+// se hacen desde el goroutine que atiende la conexión, y el trabajo lento de los
+// mensajes entrantes (NLU, media) corre en un goroutine propio para no frenarlas.
+type BotConnection struct {
+	Phone string
+
+	conn      *websocket.Conn
+	send      chan outboundFrame
+	inbound   chan func()
+	done      chan struct{}
+	closeOnce sync.Once
+
+	stateMu sync.RWMutex
+	state   BotState
+}
+
+func newBotConnection(phone string, conn *websocket.Conn) *BotConnection {
+	c := &BotConnection{
+		Phone:   phone,
+		conn:    conn,
+		send:    make(chan outboundFrame, sendBufferSize),
+		inbound: make(chan func(), inboundBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go c.writePump()
+	go c.inboundPump()
+	return c
+}
+
+// SendJSON serializa el mensaje y espera a que el escritor lo entregue al socket
+func (c *BotConnection) SendJSON(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	result := make(chan error, 1)
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	case c.send <- outboundFrame{data: data, result: result}:
+	default:
+		return ErrSendBufferFull
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-c.done:
+		// El frame pudo escribirse justo antes del cierre
+		select {
+		case err := <-result:
+			return err
+		default:
+			return ErrConnectionClosed
+		}
+	}
+}
+
+// Process encola trabajo para el goroutine de entrantes, que lo ejecuta en orden de
+// llegada. No bloquea: si la cola está llena devuelve ErrInboundBusy.
+func (c *BotConnection) Process(job func()) error {
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	case c.inbound <- job:
+		return nil
+	default:
+		return ErrInboundBusy
+	}
+}
+
+// ReadMessage lee el siguiente frame; cualquier frame recibido renueva el plazo de lectura
+func (c *BotConnection) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return data, c.conn.SetReadDeadline(time.Now().Add(pongWait))
+}
+
+// State devuelve una copia del último estado reportado
+func (c *BotConnection) State() BotState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.state
+}
+
+func (c *BotConnection) updateState(update func(state *BotState)) BotState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	update(&c.state)
+	c.state.UpdatedAt = time.Now()
+	return c.state
+}
+
+// Done se cierra cuando la conexión termina
+func (c *BotConnection) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close detiene el escritor, envía un frame de cierre y cierra el socket
+func (c *BotConnection) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// writePump es el único goroutine que escribe en el socket: frames, pings y cierre
+func (c *BotConnection) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case frame := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := c.conn.WriteMessage(websocket.TextMessage, frame.data)
+			if frame.result != nil {
+				frame.result <- err
+			}
+			if err != nil {
+				log.Printf("Error escribiendo al bot %s: %v", c.Phone, err)
+				c.Close()
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("Ping fallido para bot %s, cerrando conexión: %v", c.Phone, err)
+				c.Close()
+				return
+			}
+
+		case <-c.done:
+			c.drain()
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(writeWait))
+			return
+		}
+	}
+}
+
+// inboundPump ejecuta el trabajo de los mensajes entrantes uno a uno. Al cerrar termina
+// lo que ya estaba en cola: las respuestas salen por el outbox aunque el socket no siga.
+func (c *BotConnection) inboundPump() {
+	for {
+		select {
+		case job := <-c.inbound:
+			job()
+		case <-c.done:
+			for {
+				select {
+				case job := <-c.inbound:
+					job()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain rechaza los frames que quedaron en cola al cerrar
+func (c *BotConnection) drain() {
+	for {
+		select {
+		case frame := <-c.send:
+			if frame.result != nil {
+				frame.result <- ErrConnectionClosed
+			}
+		default:
+			return
+		}
+	}
+}
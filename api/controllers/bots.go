@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+var (
+	botNumberPattern   = regexp.MustCompile(`^[0-9]{8,15}$`)
+	botLanguagePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+)
+
+// BotRequest datos para crear o actualizar un bot. En la actualización solo cambian
+// los campos enviados.
+type BotRequest struct {
+	Name            *string               `json:"name"`
+	Type            *string               `json:"type"`
+	Number          *string               `json:"number"`
+	Active          *bool                 `json:"active"`
+	NLUEngine       *string               `json:"nlu_engine"`
+	NLUURL          *string               `json:"nlu_url"`
+	WelcomeMessage  *string               `json:"welcome_message"`
+	WelcomeTemplate *string               `json:"welcome_template"`
+	Language        *string               `json:"language"`
+	BusinessHours   *models.BusinessHours `json:"business_hours"`
+	OperatorIDs     *[]uint               `json:"operator_ids"`
+}
+
+// apply copia al bot los campos enviados
+func (r BotRequest) apply(bot *models.Bot) {
+	if r.Name != nil {
+		bot.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Type != nil {
+		bot.Type = *r.Type
+	}
+	if r.Number != nil {
+		bot.Number = normalizeBotNumber(strings.TrimPrefix(strings.TrimSpace(*r.Number), "+"))
+	}
+	if r.Active != nil {
+		bot.Active = *r.Active
+	}
+	if r.NLUEngine != nil {
+		bot.NLUEngine = strings.ToLower(*r.NLUEngine)
+	}
+	if r.NLUURL != nil {
+		bot.NLUURL = *r.NLUURL
+	}
+	if r.WelcomeMessage != nil {
+		bot.WelcomeMessage = *r.WelcomeMessage
+	}
+	if r.WelcomeTemplate != nil {
+		bot.WelcomeTemplate = strings.TrimSpace(*r.WelcomeTemplate)
+	}
+	if r.Language != nil {
+		bot.Language = *r.Language
+	}
+	if r.BusinessHours != nil {
+		bot.BusinessHours = *r.BusinessHours
+	}
+	if r.OperatorIDs != nil {
+		bot.OperatorIDs = uniqueIDs(*r.OperatorIDs)
+	}
+	if bot.Language == "" {
+		bot.Language = models.DefaultBotLanguage
+	}
+}
+
+// validateBot verifica la configuración completa del bot
+func validateBot(bot *models.Bot) error {
+	if bot.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !botNumberPattern.MatchString(bot.Number) {
+		return fmt.Errorf("number must have 8 to 15 digits with country code")
+	}
+	switch bot.NLUEngine {
+	case "", services.NLUEngineRasa, services.NLUEngineRules: // memory es solo para pruebas
+	default:
+		return fmt.Errorf("unsupported nlu engine %q", bot.NLUEngine)
+	}
+	if bot.NLUURL != "" {
+		if bot.NLUEngine != "" && bot.NLUEngine != services.NLUEngineRasa {
+			return fmt.Errorf("nlu_url is only used by the rasa engine")
+		}
+		parsed, err := url.Parse(bot.NLUURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("nlu_url must be an http(s) URL")
+		}
+	}
+	if !botLanguagePattern.MatchString(bot.Language) {
+		return fmt.Errorf("language must be an ISO 639-1 code (e.g. es or es-CO)")
+	}
+	if err := bot.BusinessHours.Validate(); err != nil {
+		return fmt.Errorf("business_hours: %w", err)
+	}
+	// Las plantillas de las respuestas automáticas deben existir al configurarlas
+	for _, name := range []string{bot.WelcomeTemplate, bot.BusinessHours.OutOfHoursTemplate} {
+		if name == "" || templateRepo == nil {
+			continue
+		}
+		if _, err := templateRepo.GetTemplateByName(name); err != nil {
+			return fmt.Errorf("template %q: %w", name, err)
+		}
+	}
+	for _, id := range bot.OperatorIDs {
+		if id == 0 {
+			return fmt.Errorf("operator_ids must be system user IDs")
+		}
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := []uint{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// ListBots lista los bots registrados
+// @Summary Listar bots
+// @Tags bots
+// @Produce json
+// @Param active query bool false "Solo activos (true) o inactivos (false)"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Bots por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/bots [get]
+func ListBots(c *gin.Context) {
+	var filter models.BotFilter
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro active inválido"})
+			return
+		}
+		filter.Active = &active
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+
+	bots, total, err := botRepo.ListBots(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando bots", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"bots":      bots,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// CreateBot registra un bot. Solo los bots registrados y activos atienden mensajes.
+// @Summary Crear bot
+// @Tags bots
+// @Accept json
+// @Produce json
+// @Param bot body BotRequest true "Configuración del bot (name y number obligatorios)"
+// @Success 201 {object} models.Bot
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/bots [post]
+func CreateBot(c *gin.Context) {
+	var request BotRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+
+	bot := models.Bot{Active: true}
+	request.apply(&bot)
+	if err := validateBot(&bot); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Configuración inválida", "details": err.Error()})
+		return
+	}
+	if err := botRepo.CreateBot(&bot); err != nil {
+		respondBotError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, bot)
+}
+
+// GetBot devuelve la configuración de un bot
+// @Summary Obtener bot
+// @Tags bots
+// @Produce json
+// @Param id path int true "ID del bot"
+// @Success 200 {object} models.Bot
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/bots/{id} [get]
+func GetBot(c *gin.Context) {
+	bot, ok := botParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, bot)
+}
+
+// UpdateBot actualiza la configuración de un bot
+// @Summary Actualizar bot
+// @Description Solo cambian los campos enviados; active=true reactiva un bot desactivado
+// @Tags bots
+// @Accept json
+// @Produce json
+// @Param id path int true "ID del bot"
+// @Param bot body BotRequest true "Campos a cambiar"
+// @Success 200 {object} models.Bot
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/bots/{id} [put]
+func UpdateBot(c *gin.Context) {
+	bot, ok := botParam(c)
+	if !ok {
+		return
+	}
+	var request BotRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+
+	request.apply(bot)
+	if err := validateBot(bot); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Configuración inválida", "details": err.Error()})
+		return
+	}
+	if err := botRepo.UpdateBot(bot); err != nil {
+		respondBotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bot)
+}
+
+// DeactivateBot desactiva un bot: sus mensajes se ignoran hasta reactivarlo
+// @Summary Desactivar bot
+// @Tags bots
+// @Produce json
+// @Param id path int true "ID del bot"
+// @Success 200 {object} models.Bot
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/bots/{id} [delete]
+func DeactivateBot(c *gin.Context) {
+	bot, ok := botParam(c)
+	if !ok {
+		return
+	}
+	bot.Active = false
+	if err := botRepo.UpdateBot(bot); err != nil {
+		respondBotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bot)
+}
+
+func botParam(c *gin.Context) (*models.Bot, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de bot inválido"})
+		return nil, false
+	}
+	bot, err := botRepo.GetBotByID(uint(id))
+	if err != nil {
+		respondBotError(c, err)
+		return nil, false
+	}
+	return bot, true
+}
+
+func respondBotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrBotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bot no encontrado"})
+	case errors.Is(err, repositories.ErrBotNumberTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay un bot registrado con ese número"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando el bot", "details": err.Error()})
+	}
+}
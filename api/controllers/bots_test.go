@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+// memoryBotRepo registra los bots en memoria sobre MockBotRepo
+func memoryBotRepo(bots map[uint]*models.Bot) *mocks.MockBotRepo {
+	byNumber := func(number string) *models.Bot {
+		for _, bot := range bots {
+			if bot.Number == number {
+				return bot
+			}
+		}
+		return nil
+	}
+	return &mocks.MockBotRepo{
+		GetBotByIDFunc: func(id uint) (*models.Bot, error) {
+			if bot, ok := bots[id]; ok {
+				copied := *bot
+				return &copied, nil
+			}
+			return nil, repositories.ErrBotNotFound
+		},
+		GetBotByNumberFunc: func(number string) (*models.Bot, error) {
+			if bot := byNumber(number); bot != nil {
+				copied := *bot
+				return &copied, nil
+			}
+			return nil, repositories.ErrBotNotFound
+		},
+		CreateBotFunc: func(bot *models.Bot) error {
+			if byNumber(bot.Number) != nil {
+				return repositories.ErrBotNumberTaken
+			}
+			bot.ID = uint(len(bots) + 1)
+			copied := *bot
+			bots[bot.ID] = &copied
+			return nil
+		},
+		UpdateBotFunc: func(bot *models.Bot) error {
+			if existing := byNumber(bot.Number); existing != nil && existing.ID != bot.ID {
+				return repositories.ErrBotNumberTaken
+			}
+			copied := *bot
+			bots[bot.ID] = &copied
+			return nil
+		},
+	}
+}
+
+func TestBotAdministration(t *testing.T) {
+	bots := map[uint]*models.Bot{}
+	SetBotRepo(memoryBotRepo(bots))
+
+	router := gin.New()
+	router.POST("/bots", CreateBot)
+	router.PUT("/bots/:id", UpdateBot)
+	router.DELETE("/bots/:id", DeactivateBot)
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := request(http.MethodPost, "/bots", `{
+		"name": "Transporte",
+		"number": "+573009998877",
+		"nlu_engine": "rasa",
+		"nlu_url": "http://rasa-transporte:5005",
+		"welcome_message": "¡Hola! Soy el bot de manifiestos",
+		"business_hours": {"timezone": "America/Bogota", "days": [{"weekday": 1, "open": "08:00", "close": "18:00"}]},
+		"operator_ids": [2, 2, 5]
+	}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var created models.Bot
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, created.Active)
+	assert.Equal(t, "573009998877", created.Number)
+	assert.Equal(t, models.DefaultBotLanguage, created.Language)
+	assert.Equal(t, []uint{2, 5}, created.OperatorIDs)
+
+	// Número repetido y configuraciones inválidas
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "/bots", `{"name": "Otro", "number": "573009998877"}`).Code)
+	for _, body := range []string{
+		`{"name": "Otro", "number": "12ab"}`,
+		`{"name": "Otro", "number": "573001110000", "nlu_engine": "dialogflow"}`,
+		`{"name": "Otro", "number": "573001110000", "nlu_engine": "rules", "nlu_url": "http://rasa:5005"}`,
+		`{"name": "Otro", "number": "573001110000", "language": "español"}`,
+		`{"name": "Otro", "number": "573001110000", "business_hours": {"timezone": "Marte/Olympus"}}`,
+		`{"name": "Otro", "number": "573001110000", "business_hours": {"days": [{"weekday": 1, "open": "18:00", "close": "08:00"}]}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/bots", body).Code, body)
+	}
+
+	// La actualización solo cambia los campos enviados
+	w = request(http.MethodPut, "/bots/1", `{"language": "en", "welcome_message": ""}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "en", bots[1].Language)
+	assert.Empty(t, bots[1].WelcomeMessage)
+	assert.Equal(t, "http://rasa-transporte:5005", bots[1].NLUURL)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/bots/1", "").Code)
+	assert.False(t, bots[1].Active)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, "/bots/9", "").Code)
+}
+
+func TestMessagesForInactiveOrUnregisteredBotsAreIgnored(t *testing.T) {
+	SetBotRepo(memoryBotRepo(map[uint]*models.Bot{
+		1: {ID: 1, Number: "573009998877", Name: "Transporte", Active: false},
+	}))
+	SetClientRepo(&mocks.MockClientRepo{
+		GetOrCreateClientFunc: func(phone, name, email string) (*models.Client, error) {
+			t.Errorf("client %s should not be created", phone)
+			return nil, assert.AnError
+		},
+	})
+	SetConversationRepo(&mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			t.Errorf("message should not be saved")
+			return nil
+		},
+	})
+
+	for _, botNumber := range []string{"573009998877@s.whatsapp.net", "573000000000"} {
+		err := processIncomingMessage(IncomingMessageRequest{Phone: "573001112233", Message: "hola", BotNumber: botNumber}, NewWebSocketHub())
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// outOfHoursReasonPrefix motivo con el que se pasa a un operador una conversación fuera de horario
+const outOfHoursReasonPrefix = "Mensaje fuera del horario de atención"
+
+// outOfHoursSweepInterval cada cuánto se borran las respuestas automáticas ya vencidas
+const outOfHoursSweepInterval = time.Hour
+
+// outOfHoursReplies recuerda hasta cuándo no se repite la respuesta automática
+// a cada conversación: una sola vez por cada periodo cerrado. Las entradas vencidas
+// se borran al registrar una nueva, como máximo una vez por outOfHoursSweepInterval.
+var outOfHoursReplies = struct {
+	sync.Mutex
+	until   map[string]time.Time
+	sweptAt time.Time
+}{until: map[string]time.Time{}}
+
+// shouldSendOutOfHoursReply indica si corresponde responder y registra el envío hasta la próxima apertura
+func shouldSendOutOfHoursReply(clientID, botID uint, now time.Time, state models.BusinessHoursState) bool {
+	key := fmt.Sprintf("%d:%d", clientID, botID)
+	outOfHoursReplies.Lock()
+	defer outOfHoursReplies.Unlock()
+
+	if until, ok := outOfHoursReplies.until[key]; ok && now.Before(until) {
+		return false
+	}
+	if now.Sub(outOfHoursReplies.sweptAt) >= outOfHoursSweepInterval {
+		for other, until := range outOfHoursReplies.until {
+			if !now.Before(until) {
+				delete(outOfHoursReplies.until, other)
+			}
+		}
+		outOfHoursReplies.sweptAt = now
+	}
+
+	// Sin próxima apertura conocida se responde como máximo una vez al día
+	until := now.Add(24 * time.Hour)
+	if state.NextChange != nil {
+		until = *state.NextChange
+	}
+	outOfHoursReplies.until[key] = until
+	return true
+}
+
+// handleOutOfHours responde automáticamente al cliente fuera del horario del bot y,
+// si el bot lo pide, deja la conversación en cola para un operador.
+// Devuelve false si el bot está atendiendo y el mensaje sigue hacia el motor NLU.
+func handleOutOfHours(hub *WebSocketHub, client *models.Client, bot *models.Bot, phone, cleanPhone, sessionID string, now time.Time) bool {
+	hours := bot.BusinessHours
+	state := hours.State(now)
+	if state.Open {
+		return false
+	}
+	log.Printf("🌙 Bot %s fuera de horario, mensaje de %s sin pasar por el motor NLU", bot.Number, cleanPhone)
+
+	if shouldSendOutOfHoursReply(client.ID, bot.ID, now, state) {
+		reply := models.Message{
+			ClientID:  client.ID,
+			BotID:     bot.ID,
+			Sender:    models.MessageSenderBot,
+			Type:      models.MessageTypeText,
+			Text:      autoReplyText(bot, client, hours.OutOfHoursTemplate, firstNonEmpty(hours.OutOfHoursMessage, models.DefaultOutOfHoursMessage)),
+			SessionID: sessionID,
+		}
+		if err := deliverMessage(hub, bot.Number, phone, reply); err != nil {
+			log.Printf("Failed to save out-of-hours reply: %v", err)
+		}
+	}
+
+	if hours.QueueForOperator {
+		reason := outOfHoursReasonPrefix
+		if state.Holiday != "" {
+			reason += " (" + state.Holiday + ")"
+		}
+		if err := escalateConversation(hub, client, bot, cleanPhone, reason); err != nil {
+			log.Printf("Failed to queue out-of-hours conversation: %v", err)
+		}
+	}
+	return true
+}
+
+// GetBotHoursStatus informa si el bot está atendiendo según su horario
+// @Summary Estado del horario de atención
+// @Description Indica si el bot está abierto o cerrado, el feriado del día y la próxima apertura o cierre
+// @Tags bots
+// @Produce json
+// @Param id path int true "ID del bot"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/bots/{id}/hours/status [get]
+func GetBotHoursStatus(c *gin.Context) {
+	bot, ok := botParam(c)
+	if !ok {
+		return
+	}
+	hours := bot.BusinessHours
+	now := time.Now().In(hours.Location())
+	state := hours.State(now)
+
+	response := gin.H{
+		"bot_id":      bot.ID,
+		"open":        state.Open,
+		"timezone":    hours.Location().String(),
+		"local_time":  now.Format(time.RFC3339),
+		"has_hours":   len(hours.Days) > 0,
+		"holiday":     state.Holiday,
+		"next_change": nil,
+	}
+	if state.NextChange != nil {
+		response["next_change"] = state.NextChange.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, response)
+}
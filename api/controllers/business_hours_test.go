@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+)
+
+func TestBusinessHoursState(t *testing.T) {
+	hours := models.BusinessHours{
+		Timezone: "America/Bogota",
+		Days: []models.BusinessDay{
+			{Weekday: time.Monday, Open: "08:00", Close: "12:00"},
+			{Weekday: time.Monday, Open: "12:00", Close: "18:00"},
+			{Weekday: time.Wednesday, Open: "09:00", Close: "13:00"},
+		},
+		Holidays: []models.Holiday{{Date: "2026-10-12", Name: "Día de la Raza"}},
+	}
+	bogota := hours.Location()
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, bogota)
+	}
+
+	tests := []struct {
+		name       string
+		at         time.Time
+		open       bool
+		holiday    string
+		nextChange time.Time
+	}{
+		{"franjas contiguas cierran al final", at(19, 10, 0), true, "", at(19, 18, 0)},
+		{"antes de abrir", at(19, 7, 59), false, "", at(19, 8, 0)},
+		{"después de cerrar abre el miércoles", at(19, 18, 0), false, "", at(21, 9, 0)},
+		{"feriado en horario", at(12, 10, 0), false, "Día de la Raza", at(14, 9, 0)},
+		{"la zona horaria del bot manda", time.Date(2026, time.October, 21, 17, 30, 0, 0, time.UTC), true, "", at(21, 13, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := hours.State(tt.at)
+			assert.Equal(t, tt.open, state.Open)
+			assert.Equal(t, tt.holiday, state.Holiday)
+			if assert.NotNil(t, state.NextChange) {
+				assert.True(t, tt.nextChange.Equal(*state.NextChange), "next change %s", state.NextChange)
+			}
+		})
+	}
+
+	// Sin días configurados el bot atiende siempre
+	assert.True(t, models.BusinessHours{}.IsOpen(at(12, 3, 0)))
+	assert.Error(t, models.BusinessHours{Holidays: []models.Holiday{{Date: "2026-12-25"}}}.Validate())
+	assert.Error(t, models.BusinessHours{
+		Days:     []models.BusinessDay{{Weekday: time.Monday, Open: "08:00", Close: "18:00"}},
+		Holidays: []models.Holiday{{Date: "25/12/2026"}},
+	}.Validate())
+}
+
+// closedToday horario que hoy está cerrado por feriado
+func closedToday(queue bool) models.BusinessHours {
+	days := make([]models.BusinessDay, 0, 7)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		days = append(days, models.BusinessDay{Weekday: weekday, Open: "00:00", Close: "23:59"})
+	}
+	return models.BusinessHours{
+		Timezone:          "UTC",
+		Days:              days,
+		Holidays:          []models.Holiday{{Date: time.Now().UTC().Format("2006-01-02"), Name: "Inventario"}},
+		OutOfHoursMessage: "Hoy no atendemos, volvemos mañana",
+		QueueForOperator:  queue,
+	}
+}
+
+func TestOutOfHoursMessagesGetAutoReplyAndQueue(t *testing.T) {
+	bots := map[uint]*models.Bot{
+		1: {ID: 1, Number: "573009998877", Active: true, BusinessHours: closedToday(false)},
+		2: {ID: 2, Number: "573009998866", Active: true, BusinessHours: closedToday(true)},
+	}
+	SetBotRepo(memoryBotRepo(bots))
+	SetClientRepo(&mocks.MockClientRepo{
+		GetOrCreateClientFunc: func(phone, name, email string) (*models.Client, error) {
+			return &models.Client{ID: 41, Phone: phone}, nil
+		},
+	})
+
+	saved := map[uint][]models.Message{}
+	modes := map[uint]models.ConversationModeChange{}
+	SetConversationRepo(&mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			saved[botID] = append(saved[botID], message)
+			return nil
+		},
+		GetConversationModeFunc: func(ctx context.Context, userID uint, botID uint) (string, error) {
+			if change, ok := modes[botID]; ok {
+				return change.Mode, nil
+			}
+			return models.ConversationModeBot, nil
+		},
+		SetConversationModeFunc: func(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error {
+			modes[botID] = change
+			return nil
+		},
+		UpdateMessageStatusFunc: func(ctx context.Context, update models.MessageStatusUpdate) error {
+			return nil
+		},
+	})
+
+	for _, botNumber := range []string{"573009998877", "573009998877", "573009998866", "573009998866"} {
+		err := processIncomingMessage(IncomingMessageRequest{Phone: "573001112233", Message: "hola", BotNumber: botNumber}, NewWebSocketHub())
+		assert.NoError(t, err)
+	}
+
+	// El motor NLU no responde: la respuesta automática se envía una sola vez por periodo cerrado
+	if assert.Len(t, saved[1], 3) {
+		assert.Equal(t, models.MessageSenderBot, saved[1][1].Sender)
+		assert.Equal(t, "Hoy no atendemos, volvemos mañana", saved[1][1].Text)
+		assert.Equal(t, "573001112233", saved[1][2].Sender)
+	}
+	_, queued := modes[1]
+	assert.False(t, queued)
+
+	// Con queue_for_operator la conversación queda para un operador
+	assert.Len(t, saved[2], 3)
+	assert.Equal(t, models.ConversationModeHuman, modes[2].Mode)
+	assert.Equal(t, "Mensaje fuera del horario de atención (Inventario)", modes[2].Reason)
+
+	router := gin.New()
+	router.GET("/bots/:id/hours/status", GetBotHoursStatus)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bots/2/hours/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, false, status["open"])
+	assert.Equal(t, "Inventario", status["holiday"])
+	assert.NotNil(t, status["next_change"])
+}
+
+func TestOutOfHoursRepliesForgetExpiredConversations(t *testing.T) {
+	now := time.Date(2030, 3, 4, 22, 0, 0, 0, time.UTC)
+	opens := now.Add(10 * time.Hour)
+	state := models.BusinessHoursState{NextChange: &opens}
+
+	assert.True(t, shouldSendOutOfHoursReply(901, 3, now, state))
+	assert.False(t, shouldSendOutOfHoursReply(901, 3, now.Add(time.Hour), state))
+
+	// Pasada la apertura, la respuesta de otra conversación borra la entrada vencida
+	later := opens.Add(2 * outOfHoursSweepInterval)
+	assert.True(t, shouldSendOutOfHoursReply(902, 3, later, models.BusinessHoursState{}))
+	outOfHoursReplies.Lock()
+	_, kept := outOfHoursReplies.until["901:3"]
+	outOfHoursReplies.Unlock()
+	assert.False(t, kept)
+}
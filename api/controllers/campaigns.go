@@ -0,0 +1,594 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+const (
+	// DefaultCampaignRatePerMinute mensajes por minuto de un bot si la campaña no indica otro
+	DefaultCampaignRatePerMinute = 20
+	maxCampaignRatePerMinute     = 60
+	maxCampaignRecipients        = 10000
+	campaignBatchSize            = 50
+	// campaignBatchWindow tiempo que un trabajo envía antes de continuar en otro, por
+	// debajo del tiempo máximo de ejecución de la cola
+	campaignBatchWindow = 3 * time.Minute
+)
+
+var (
+	campaignRepo        repositories.CampaignRepository
+	campaignLimiter     = services.NewSendRateLimiter()
+	campaignDefaultRate = DefaultCampaignRatePerMinute
+)
+
+// CampaignRequest datos para crear una campaña de difusión
+type CampaignRequest struct {
+	Name          string              `json:"name" binding:"required"`
+	BotID         uint                `json:"bot_id" binding:"required"`
+	Message       string              `json:"message"`      // admite {{nombre}}, {{telefono}}, {{empresa}} y {{correo}}
+	Template      string              `json:"template"`     // plantilla en lugar de message
+	Language      string              `json:"language"`     // variante de la plantilla; vacío = idioma del bot
+	Audience      models.ClientFilter `json:"audience"`     // sin criterios = todos los clientes
+	ScheduledAt   *time.Time          `json:"scheduled_at"` // vacío = enviar ya
+	RatePerMinute int                 `json:"rate_per_minute"`
+}
+
+// CampaignSummary campaña con la cantidad de destinatarios por estado
+type CampaignSummary struct {
+	models.Campaign
+	Recipients int64            `json:"recipients"`
+	Stats      map[string]int64 `json:"stats"`
+}
+
+// CampaignJobPayload datos del trabajo que envía una campaña
+type CampaignJobPayload struct {
+	CampaignID uint `json:"campaign_id"`
+}
+
+// CampaignJobResult resultado de un trabajo de campaña
+type CampaignJobResult struct {
+	Sent           int  `json:"sent"`
+	OptedOut       int  `json:"opted_out"`
+	Failed         int  `json:"failed"`
+	ContinuedInJob uint `json:"continued_in_job,omitempty"` // trabajo que sigue con los pendientes
+}
+
+func SetCampaignRepo(repo repositories.CampaignRepository) {
+	campaignRepo = repo
+}
+
+// SetCampaignRate ritmo por defecto de las campañas (mensajes por minuto de cada bot)
+func SetCampaignRate(perMinute int) {
+	switch {
+	case perMinute <= 0:
+		perMinute = DefaultCampaignRatePerMinute
+	case perMinute > maxCampaignRatePerMinute:
+		perMinute = maxCampaignRatePerMinute
+	}
+	campaignDefaultRate = perMinute
+}
+
+// validateCampaignMessage verifica que el mensaje solo use variables del cliente
+func validateCampaignMessage(message string) error {
+	if strings.TrimSpace(message) == "" {
+		return fmt.Errorf("message or template is required")
+	}
+	for _, name := range services.TemplatePlaceholders(message) {
+		if !isClientTemplateVariable(name) {
+			return fmt.Errorf("unknown placeholder {{%s}}", name)
+		}
+	}
+	return nil
+}
+
+func isClientTemplateVariable(name string) bool {
+	for _, variable := range services.ClientTemplateVariables {
+		if variable == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCampaignMessage reemplaza las variables del mensaje con los datos del cliente; si
+// al cliente le falta alguno de los datos que usa el mensaje no se le envía
+func renderCampaignMessage(message string, client *models.Client) (string, error) {
+	text, missing := services.RenderTemplate(message, services.ClientVariables(client))
+	if len(missing) > 0 {
+		return "", fmt.Errorf("client has no value for template variables: %s", strings.Join(missing, ", "))
+	}
+	return text, nil
+}
+
+// enqueueCampaign encola el envío de la campaña para runAt (vacío = de inmediato)
+func enqueueCampaign(campaign *models.Campaign, runAt time.Time) (*models.Job, error) {
+	job, err := jobQueue.Enqueue(models.JobTypeCampaign, CampaignJobPayload{CampaignID: campaign.ID}, services.JobOptions{
+		BotID: campaign.BotID,
+		RunAt: runAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := campaignRepo.SetCampaignJob(campaign.ID, job.ID); err != nil {
+		log.Printf("Failed to link job %d to campaign %d: %v", job.ID, campaign.ID, err)
+	}
+	campaign.JobID = job.ID
+	return job, nil
+}
+
+// campaignJob envía los mensajes de una campaña al ritmo del bot
+type campaignJob struct {
+	hub *WebSocketHub
+}
+
+func (j *campaignJob) RunJob(ctx context.Context, job *models.Job, logger services.JobLogger) (interface{}, error) {
+	var result CampaignJobResult
+	var payload CampaignJobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, services.PermanentJobError(err)
+	}
+	campaign, err := campaignRepo.GetCampaign(payload.CampaignID)
+	if err != nil {
+		return nil, services.PermanentJobError(err)
+	}
+	if campaign.IsFinished() && campaign.Status != models.CampaignStatusFailed {
+		logger.Logf("La campaña %d ya terminó (%s)", campaign.ID, campaign.Status)
+		return result, nil
+	}
+	bot, err := botRepo.GetBotByID(campaign.BotID)
+	if err != nil {
+		return nil, services.PermanentJobError(err)
+	}
+	if !bot.Active {
+		return nil, services.PermanentJobError(fmt.Errorf("bot %s is inactive", bot.Number))
+	}
+
+	from := []string{models.CampaignStatusScheduled, models.CampaignStatusRunning, models.CampaignStatusFailed}
+	started, err := campaignRepo.TransitionCampaign(campaign.ID, from, models.CampaignStatusRunning, time.Now(), "")
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		logger.Logf("La campaña %d fue cancelada", campaign.ID)
+		return result, nil
+	}
+
+	logger.Logf("Enviando campaña %d por el bot %s a %d mensajes por minuto", campaign.ID, bot.Number, campaign.RatePerMinute)
+	deadline := time.Now().Add(campaignBatchWindow)
+	for {
+		recipients, err := campaignRepo.PendingRecipients(campaign.ID, campaignBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(recipients) == 0 {
+			if _, err := campaignRepo.TransitionCampaign(campaign.ID, []string{models.CampaignStatusRunning}, models.CampaignStatusCompleted, time.Now(), ""); err != nil {
+				return nil, err
+			}
+			logger.Logf("Campaña %d completada: %d enviados, %d dados de baja, %d fallidos", campaign.ID, result.Sent, result.OptedOut, result.Failed)
+			return result, nil
+		}
+
+		// Cada lote confirma que la campaña no se canceló mientras tanto
+		current, err := campaignRepo.GetCampaign(campaign.ID)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != models.CampaignStatusRunning {
+			logger.Logf("La campaña %d se detuvo (%s)", campaign.ID, current.Status)
+			return result, nil
+		}
+
+		for i := range recipients {
+			if time.Now().After(deadline) {
+				next, err := enqueueCampaign(campaign, time.Time{})
+				if err != nil {
+					return nil, err
+				}
+				logger.Logf("Quedan destinatarios pendientes, la campaña sigue en el trabajo %d", next.ID)
+				result.ContinuedInJob = next.ID
+				return result, nil
+			}
+			if err := campaignLimiter.Wait(ctx, bot.ID, campaign.RatePerMinute); err != nil {
+				return nil, err
+			}
+			status, err := sendCampaignMessage(j.hub, campaign, bot, &recipients[i])
+			if err != nil {
+				return nil, err
+			}
+			switch status {
+			case models.RecipientStatusOptedOut:
+				result.OptedOut++
+			case models.MessageStatusFailed:
+				result.Failed++
+			default:
+				result.Sent++
+			}
+		}
+	}
+}
+
+// JobFailed marca la campaña como fallida; sus destinatarios pendientes se conservan
+// para re-ejecutar el trabajo
+func (j *campaignJob) JobFailed(job *models.Job, err error) {
+	var payload CampaignJobPayload
+	if jsonErr := json.Unmarshal([]byte(job.Payload), &payload); jsonErr != nil {
+		log.Printf("Failed to mark campaign of job %d as failed: %v", job.ID, jsonErr)
+		return
+	}
+	from := []string{models.CampaignStatusScheduled, models.CampaignStatusRunning}
+	if _, updateErr := campaignRepo.TransitionCampaign(payload.CampaignID, from, models.CampaignStatusFailed, time.Now(), err.Error()); updateErr != nil {
+		log.Printf("Failed to mark campaign %d as failed: %v", payload.CampaignID, updateErr)
+	}
+}
+
+// sendCampaignMessage envía el mensaje a un destinatario, salvo que se haya dado de baja,
+// y devuelve el estado en que queda. Si no se puede guardar ese estado devuelve error: el
+// destinatario seguiría pendiente y se le volvería a enviar.
+func sendCampaignMessage(hub *WebSocketHub, campaign *models.Campaign, bot *models.Bot, recipient *models.CampaignRecipient) (string, error) {
+	client, err := clientRepo.GetClientByID(recipient.ClientID)
+	switch {
+	case err != nil:
+		recipient.Status = models.MessageStatusFailed
+		recipient.Error = err.Error()
+	case client.OptedOut:
+		recipient.Status = models.RecipientStatusOptedOut
+	default:
+		text, err := renderCampaignMessage(campaign.Message, client)
+		if err != nil {
+			recipient.Status = models.MessageStatusFailed
+			recipient.Error = err.Error()
+			break
+		}
+		message := models.Message{
+			ClientID:   client.ID,
+			BotID:      bot.ID,
+			Sender:     models.MessageSenderBot,
+			Type:       models.MessageTypeText,
+			Text:       text,
+			CampaignID: campaign.ID,
+		}
+		// El destinatario queda registrado con el id del frame antes de que llegue el ack
+		var updateErr error
+		saved, err := sendOutgoingMessage(hub, bot.Number, client.Phone, message, func(saved models.Message) {
+			now := time.Now()
+			recipient.Status = models.RecipientStatusQueued
+			recipient.SentAt = &now
+			if len(saved.OutboundIDs) > 0 {
+				recipient.OutboundID = saved.OutboundIDs[0]
+			}
+			updateErr = campaignRepo.UpdateRecipient(recipient)
+		})
+		if err != nil {
+			recipient.Status = models.MessageStatusFailed
+			recipient.Error = err.Error()
+			break
+		}
+		if saved.Status == models.MessageStatusFailed {
+			recipient.Status = models.MessageStatusFailed
+			recipient.Error = saved.StatusError
+		}
+		if updateErr != nil || saved.Status == models.MessageStatusFailed {
+			break
+		}
+		return recipient.Status, nil
+	}
+
+	if err := campaignRepo.UpdateRecipient(recipient); err != nil {
+		return recipient.Status, fmt.Errorf("failed to update campaign recipient %d: %w", recipient.ID, err)
+	}
+	return recipient.Status, nil
+}
+
+// CreateCampaign crea una campaña de difusión y programa su envío
+// @Summary Crear campaña
+// @Description Selecciona los clientes de la audiencia y programa el envío por el bot al ritmo indicado. Los clientes dados de baja (STOP, BAJA) no reciben el mensaje.
+// @Tags campañas
+// @Accept json
+// @Produce json
+// @Param campaign body CampaignRequest true "Datos de la campaña"
+// @Success 201 {object} CampaignSummary
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/campaigns [post]
+func CreateCampaign(c *gin.Context) {
+	var request CampaignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+	if request.Message != "" && request.Template != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mensaje inválido", "details": "message and template cannot be used together"})
+		return
+	}
+	if request.Template == "" {
+		if err := validateCampaignMessage(request.Message); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mensaje inválido", "details": err.Error()})
+			return
+		}
+	}
+	rate := request.RatePerMinute
+	if rate == 0 {
+		rate = campaignDefaultRate
+	}
+	if rate < 1 || rate > maxCampaignRatePerMinute {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rate_per_minute debe estar entre 1 y %d", maxCampaignRatePerMinute)})
+		return
+	}
+	if jobQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cola de trabajos no disponible"})
+		return
+	}
+
+	bot, err := botRepo.GetBotByID(request.BotID)
+	if err != nil {
+		respondBotError(c, err)
+		return
+	}
+	if !bot.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El bot está inactivo"})
+		return
+	}
+
+	// El texto de la plantilla se copia a la campaña: editarla después no cambia lo programado
+	message, language := request.Message, ""
+	var templateID uint
+	if request.Template != "" {
+		template, ok := templateByName(c, request.Template)
+		if !ok {
+			return
+		}
+		language, message = template.Variant(request.Language, bot.Language)
+		templateID = template.ID
+		if err := validateCampaignMessage(message); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La plantilla usa variables que no son del cliente", "details": err.Error()})
+			return
+		}
+	}
+
+	audience := request.Audience
+	for i, phone := range audience.Phones {
+		audience.Phones[i] = normalizeBotNumber(strings.TrimPrefix(strings.TrimSpace(phone), "+"))
+	}
+	clients, err := clientRepo.ListClients(audience, maxCampaignRecipients+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error seleccionando la audiencia", "details": err.Error()})
+		return
+	}
+	if len(clients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La audiencia no tiene clientes"})
+		return
+	}
+	if len(clients) > maxCampaignRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La audiencia supera el máximo de %d clientes", maxCampaignRecipients)})
+		return
+	}
+
+	now := time.Now()
+	campaign := models.Campaign{
+		Name:          strings.TrimSpace(request.Name),
+		BotID:         bot.ID,
+		Message:       message,
+		TemplateID:    templateID,
+		Language:      language,
+		Audience:      audience,
+		Status:        models.CampaignStatusScheduled,
+		ScheduledAt:   now,
+		RatePerMinute: rate,
+		CreatedBy:     currentOperatorID(c),
+	}
+	if request.ScheduledAt != nil && request.ScheduledAt.After(now) {
+		campaign.ScheduledAt = *request.ScheduledAt
+	}
+
+	stats := map[string]int64{}
+	recipients := make([]models.CampaignRecipient, 0, len(clients))
+	for _, client := range clients {
+		recipient := models.CampaignRecipient{ClientID: client.ID, Phone: client.Phone, Status: models.RecipientStatusPending}
+		if client.OptedOut {
+			recipient.Status = models.RecipientStatusOptedOut
+		}
+		stats[recipient.Status]++
+		recipients = append(recipients, recipient)
+	}
+	if err := campaignRepo.CreateCampaign(&campaign, recipients); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando la campaña", "details": err.Error()})
+		return
+	}
+
+	if _, err := enqueueCampaign(&campaign, campaign.ScheduledAt); err != nil {
+		campaignRepo.TransitionCampaign(campaign.ID, []string{models.CampaignStatusScheduled}, models.CampaignStatusFailed, time.Now(), err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error programando la campaña", "details": err.Error()})
+		return
+	}
+	log.Printf("📣 Campaña %d programada para %s: %d destinatarios por el bot %s", campaign.ID, campaign.ScheduledAt.Format(time.RFC3339), len(recipients), bot.Number)
+
+	c.JSON(http.StatusCreated, CampaignSummary{Campaign: campaign, Recipients: int64(len(recipients)), Stats: stats})
+}
+
+// ListCampaigns lista las campañas
+// @Summary Listar campañas
+// @Tags campañas
+// @Produce json
+// @Param status query string false "scheduled, running, completed, canceled o failed"
+// @Param bot_id query int false "ID del bot"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Campañas por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/campaigns [get]
+func ListCampaigns(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.CampaignStatusScheduled, models.CampaignStatusRunning, models.CampaignStatusCompleted, models.CampaignStatusCanceled, models.CampaignStatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Estado inválido"})
+		return
+	}
+	botID, ok := queryUint(c, "bot_id")
+	if !ok {
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	campaigns, total, err := campaignRepo.ListCampaigns(models.CampaignFilter{
+		Status: status,
+		BotID:  botID,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando campañas", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"campaigns": campaigns,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetCampaign devuelve una campaña con el avance de sus destinatarios
+// @Summary Obtener campaña
+// @Tags campañas
+// @Produce json
+// @Param id path int true "ID de la campaña"
+// @Success 200 {object} CampaignSummary
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/campaigns/{id} [get]
+func GetCampaign(c *gin.Context) {
+	campaign, ok := campaignParam(c)
+	if !ok {
+		return
+	}
+	stats, err := campaignRepo.RecipientStats(campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando destinatarios", "details": err.Error()})
+		return
+	}
+	var recipients int64
+	for _, count := range stats {
+		recipients += count
+	}
+	c.JSON(http.StatusOK, CampaignSummary{Campaign: *campaign, Recipients: recipients, Stats: stats})
+}
+
+// ListCampaignRecipients lista los destinatarios de una campaña y el estado de su mensaje
+// @Summary Destinatarios de una campaña
+// @Tags campañas
+// @Produce json
+// @Param id path int true "ID de la campaña"
+// @Param status query string false "pending, queued, sent, delivered, read, failed, opted_out o canceled"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Destinatarios por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/campaigns/{id}/recipients [get]
+func ListCampaignRecipients(c *gin.Context) {
+	campaign, ok := campaignParam(c)
+	if !ok {
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+	recipients, total, err := campaignRepo.ListRecipients(models.RecipientFilter{
+		CampaignID: campaign.ID,
+		Status:     c.Query("status"),
+		Offset:     (page - 1) * pageSize,
+		Limit:      pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando destinatarios", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"recipients": recipients,
+		"total":      total,
+		"page":       page,
+		"page_size":  pageSize,
+	})
+}
+
+// CancelCampaign detiene una campaña programada o en curso; los mensajes ya enviados no se retiran
+// @Summary Cancelar campaña
+// @Tags campañas
+// @Produce json
+// @Param id path int true "ID de la campaña"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/campaigns/{id}/cancel [post]
+func CancelCampaign(c *gin.Context) {
+	campaign, ok := campaignParam(c)
+	if !ok {
+		return
+	}
+	from := []string{models.CampaignStatusScheduled, models.CampaignStatusRunning}
+	canceled, err := campaignRepo.TransitionCampaign(campaign.ID, from, models.CampaignStatusCanceled, time.Now(), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cancelando la campaña", "details": err.Error()})
+		return
+	}
+	if !canceled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Solo se pueden cancelar campañas programadas o en curso"})
+		return
+	}
+
+	skipped, err := campaignRepo.CancelPendingRecipients(campaign.ID)
+	if err != nil {
+		log.Printf("Failed to cancel pending recipients of campaign %d: %v", campaign.ID, err)
+	}
+	if campaign.JobID != 0 && jobQueue != nil {
+		if err := jobQueue.Cancel(campaign.JobID); err != nil && !errors.Is(err, services.ErrJobNotCancelable) {
+			log.Printf("Failed to cancel job %d of campaign %d: %v", campaign.JobID, campaign.ID, err)
+		}
+	}
+	log.Printf("🛑 Campaña %d cancelada, %d destinatarios sin enviar", campaign.ID, skipped)
+	c.JSON(http.StatusOK, gin.H{"message": "Campaña cancelada", "skipped": skipped})
+}
+
+func campaignParam(c *gin.Context) (*models.Campaign, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de campaña inválido"})
+		return nil, false
+	}
+	campaign, err := campaignRepo.GetCampaign(uint(id))
+	if errors.Is(err, repositories.ErrCampaignNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaña no encontrada"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando la campaña", "details": err.Error()})
+		return nil, false
+	}
+	return campaign, true
+}
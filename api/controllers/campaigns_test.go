@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+// memoryCampaignRepo guarda campañas y destinatarios en memoria sobre MockCampaignRepo
+func memoryCampaignRepo() *mocks.MockCampaignRepo {
+	var mu sync.Mutex
+	campaigns := map[uint]*models.Campaign{}
+	var recipients []*models.CampaignRecipient
+
+	return &mocks.MockCampaignRepo{
+		CreateCampaignFunc: func(campaign *models.Campaign, list []models.CampaignRecipient) error {
+			mu.Lock()
+			defer mu.Unlock()
+			campaign.ID = uint(len(campaigns) + 1)
+			copied := *campaign
+			campaigns[campaign.ID] = &copied
+			for i := range list {
+				recipient := list[i]
+				recipient.ID = uint(len(recipients) + 1)
+				recipient.CampaignID = campaign.ID
+				recipients = append(recipients, &recipient)
+			}
+			return nil
+		},
+		GetCampaignFunc: func(id uint) (*models.Campaign, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if campaign, ok := campaigns[id]; ok {
+				copied := *campaign
+				return &copied, nil
+			}
+			return nil, repositories.ErrCampaignNotFound
+		},
+		TransitionCampaignFunc: func(id uint, from []string, to string, at time.Time, lastError string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			campaign := campaigns[id]
+			for _, status := range from {
+				if campaign.Status == status {
+					campaign.Status = to
+					campaign.LastError = lastError
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		SetCampaignJobFunc: func(id uint, jobID uint) error {
+			mu.Lock()
+			defer mu.Unlock()
+			campaigns[id].JobID = jobID
+			return nil
+		},
+		PendingRecipientsFunc: func(campaignID uint, limit int) ([]models.CampaignRecipient, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			pending := []models.CampaignRecipient{}
+			for _, recipient := range recipients {
+				if recipient.CampaignID == campaignID && recipient.Status == models.RecipientStatusPending && len(pending) < limit {
+					pending = append(pending, *recipient)
+				}
+			}
+			return pending, nil
+		},
+		UpdateRecipientFunc: func(recipient *models.CampaignRecipient) error {
+			mu.Lock()
+			defer mu.Unlock()
+			copied := *recipient
+			recipients[recipient.ID-1] = &copied
+			return nil
+		},
+		RecipientStatsFunc: func(campaignID uint) (map[string]int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			stats := map[string]int64{}
+			for _, recipient := range recipients {
+				if recipient.CampaignID == campaignID {
+					stats[recipient.Status]++
+				}
+			}
+			return stats, nil
+		},
+		UpdateRecipientStatusFunc: func(update models.MessageStatusUpdate) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, recipient := range recipients {
+				if update.OutboundID != "" && recipient.OutboundID == update.OutboundID {
+					recipient.Status = update.Status
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type discardJobLogger struct{}
+
+func (discardJobLogger) Logf(format string, args ...interface{})   {}
+func (discardJobLogger) Errorf(format string, args ...interface{}) {}
+
+func TestCampaignSendsToAudienceAndHonorsOptOut(t *testing.T) {
+	SetBotRepo(memoryBotRepo(map[uint]*models.Bot{
+		1: {ID: 1, Number: "573009998877", Name: "Transporte", Active: true},
+	}))
+	clients := map[uint]*models.Client{
+		1: {ID: 1, Name: "Ana", Phone: "573001110001", Company: "Transportes Ruta"},
+		2: {ID: 2, Name: "Beto", Phone: "573001110002", Company: "Transportes Ruta", OptedOut: true},
+		3: {ID: 3, Name: "Carla", Phone: "573001110003", Company: "Transportes Ruta"},
+	}
+	SetClientRepo(&mocks.MockClientRepo{
+		ListClientsFunc: func(filter models.ClientFilter, limit int) ([]models.Client, error) {
+			assert.Equal(t, "Transportes Ruta", filter.Company)
+			return []models.Client{*clients[1], *clients[2], *clients[3]}, nil
+		},
+		GetClientByIDFunc: func(id uint) (*models.Client, error) {
+			copied := *clients[id]
+			return &copied, nil
+		},
+		GetOrCreateClientFunc: func(phone, name, email string) (*models.Client, error) {
+			for _, client := range clients {
+				if client.Phone == phone {
+					copied := *client
+					return &copied, nil
+				}
+			}
+			return nil, assert.AnError
+		},
+		SetClientOptOutFunc: func(id uint, optedOut bool, at time.Time) error {
+			clients[id].OptedOut = optedOut
+			return nil
+		},
+	})
+	var saved []models.Message
+	SetConversationRepo(&mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			saved = append(saved, message)
+			return nil
+		},
+		GetConversationModeFunc: func(ctx context.Context, userID uint, botID uint) (string, error) {
+			return models.ConversationModeBot, nil
+		},
+		UpdateMessageStatusFunc: func(ctx context.Context, update models.MessageStatusUpdate) error {
+			return nil
+		},
+	})
+	campaigns := memoryCampaignRepo()
+	SetCampaignRepo(campaigns)
+
+	var jobs []*models.Job
+	queue := services.NewJobQueue(&mocks.MockJobRepo{
+		CreateJobFunc: func(job *models.Job) error {
+			job.ID = uint(len(jobs) + 1)
+			jobs = append(jobs, job)
+			return nil
+		},
+		AddJobLogFunc: func(entry *models.JobLog) error { return nil },
+	}, 1)
+	hub := NewWebSocketHub()
+	SetJobQueue(queue, hub)
+	t.Cleanup(func() {
+		SetJobQueue(nil, nil)
+		SetCampaignRepo(nil)
+	})
+
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+
+	router := gin.New()
+	router.POST("/campaigns", CreateCampaign)
+	router.GET("/campaigns/:id", GetCampaign)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/campaigns", strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"name": "Aviso", "bot_id": 1, "message": "Hola {{cliente}}"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"name": "Aviso", "bot_id": 1, "message": "Hola", "rate_per_minute": 500}`).Code)
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w := post(`{
+		"name": "Cierre de vía",
+		"bot_id": 1,
+		"message": "Hola {{nombre}}, la vía a Buenaventura estará cerrada mañana",
+		"audience": {"company": "Transportes Ruta"},
+		"scheduled_at": "` + scheduledAt + `",
+		"rate_per_minute": 60
+	}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var created CampaignSummary
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, models.CampaignStatusScheduled, created.Status)
+	assert.Equal(t, int64(3), created.Recipients)
+	assert.Equal(t, map[string]int64{models.RecipientStatusPending: 2, models.RecipientStatusOptedOut: 1}, created.Stats)
+	// El trabajo queda programado para la hora de la campaña
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, created.JobID, jobs[0].ID)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), jobs[0].NextRunAt, time.Minute)
+	}
+
+	// Carla se da de baja antes del envío
+	assert.NoError(t, processIncomingMessage(IncomingMessageRequest{Phone: "573001110003", Message: "Baja!", BotNumber: "573009998877"}, hub))
+	assert.True(t, clients[3].OptedOut)
+	assert.Equal(t, optOutReply, readOutgoing(t, conn).Message)
+
+	result, err := (&campaignJob{hub: hub}).RunJob(context.Background(), jobs[0], discardJobLogger{})
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignJobResult{Sent: 1, OptedOut: 1}, result)
+
+	outgoing := readOutgoing(t, conn)
+	assert.Equal(t, "573001110001", outgoing.To)
+	assert.Equal(t, "Hola Ana, la vía a Buenaventura estará cerrada mañana", outgoing.Message)
+	last := saved[len(saved)-1]
+	assert.Equal(t, created.ID, last.CampaignID)
+
+	// El recibo de entrega avanza el estado del destinatario
+	assert.NoError(t, applyMessageStatus(models.MessageStatusUpdate{OutboundID: last.OutboundIDs[0], Status: models.MessageStatusDelivered}))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/campaigns/1", nil))
+	var summary CampaignSummary
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, models.CampaignStatusCompleted, summary.Status)
+	assert.Equal(t, map[string]int64{models.MessageStatusDelivered: 1, models.RecipientStatusOptedOut: 2}, summary.Stats)
+}
+
+func TestCampaignRecipientWithoutVariableValueFails(t *testing.T) {
+	var updated []models.CampaignRecipient
+	SetClientRepo(&mocks.MockClientRepo{
+		GetClientByIDFunc: func(id uint) (*models.Client, error) {
+			return &models.Client{ID: id, Name: "  ", Phone: "573001110001"}, nil
+		},
+	})
+	SetConversationRepo(&mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			t.Fatal("no se debe enviar un mensaje con variables vacías")
+			return nil
+		},
+	})
+	SetCampaignRepo(&mocks.MockCampaignRepo{
+		UpdateRecipientFunc: func(recipient *models.CampaignRecipient) error {
+			updated = append(updated, *recipient)
+			return nil
+		},
+	})
+	t.Cleanup(func() { SetCampaignRepo(nil) })
+
+	campaign := &models.Campaign{ID: 1, Message: "Hola {{nombre}}, la vía estará cerrada mañana"}
+	bot := &models.Bot{ID: 1, Number: "573009998877", Active: true}
+	status, err := sendCampaignMessage(NewWebSocketHub(), campaign, bot, &models.CampaignRecipient{ID: 5, CampaignID: 1, ClientID: 9})
+	assert.NoError(t, err)
+	assert.Equal(t, models.MessageStatusFailed, status)
+	if assert.Len(t, updated, 1) {
+		assert.Contains(t, updated[0].Error, "nombre")
+	}
+}
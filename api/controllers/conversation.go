@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,22 +15,24 @@ import (
 
 	"github.com/brando1998/docubot-api/models"
 	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
 )
 
 var (
 	conversationRepo repositories.ConversationRepository
 	botRepo          repositories.BotRepository
 	clientRepo       repositories.ClientRepository
+	nluRegistry      *services.NLURegistry
+	// baileysSecret clave compartida con que Baileys se autentica en /ws (BAILEYS_WS_SECRET)
+	baileysSecret string
 )
 
 type IncomingMessageRequest struct {
-	Phone     string `json:"phone"`
-	Message   string `json:"message"`
-	BotNumber string `json:"botNumber"`
-}
-
-type RasaResponseItem struct {
-	Text string `json:"text"`
+	Phone     string        `json:"phone"`
+	Message   string        `json:"message"`
+	BotNumber string        `json:"botNumber"`
+	Payload   string        `json:"payload,omitempty"` // payload del botón/lista seleccionado por el cliente
+	Media     *InboundMedia `json:"media,omitempty"`   // imagen, PDF o nota de voz enviada por el cliente
 }
 
 // Setters para inyección de dependencias
@@ -46,56 +48,106 @@ func SetClientRepo(repo repositories.ClientRepository) {
 	clientRepo = repo
 }
 
-// HandleWebSocket maneja conexiones WebSocket entrantes
+func SetNLURegistry(registry *services.NLURegistry) {
+	nluRegistry = registry
+}
+
+// SetBaileysSecret fija la clave que Baileys envía como "Authorization: Bearer <clave>"
+// al conectarse a /ws. Sin clave (solo en desarrollo) se aceptan conexiones anónimas,
+// pero no pueden reemplazar la de un bot ya conectado.
+func SetBaileysSecret(secret string) {
+	baileysSecret = secret
+}
+
+// baileysAuthenticated indica si la solicitud trae la clave compartida de Baileys
+func baileysAuthenticated(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return baileysSecret != "" && ok &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(baileysSecret)) == 1
+}
+
+// HandleWebSocket maneja conexiones WebSocket entrantes. Cada número de bot tiene su
+// conexión (?phone=), y Baileys abre además una conexión de gestor de sesiones
+// (?role=sessions) por la que recibe las órdenes start de números sin conexión.
 func HandleWebSocket(c *gin.Context, hub *WebSocketHub, upgrader websocket.Upgrader) {
 
 	//Obtener el numero
 	log.Println("Nueva conexión WebSocket intentada")
-	botPhone := c.Query("phone") // Este es el número DEL BOT
-	if botPhone == "" {
+	sessionManager := c.Query("role") == SessionManagerRole
+	botPhone := normalizeBotNumber(c.Query("phone")) // Este es el número DEL BOT
+	name := botPhone
+	if sessionManager {
+		botPhone, name = "", "gestor de sesiones"
+	} else if botPhone == "" {
 		log.Println("Bot phone number missing in WebSocket connection")
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
-	// Verificar si el bot ya está registrado
-	if _, err := hub.GetBotConnection(botPhone); err == nil {
-		log.Printf("Bot %s ya está registrado", botPhone)
-		c.AbortWithStatus(http.StatusConflict)
+	// Con clave configurada solo Baileys puede conectarse; sin ella nadie toma un bot ajeno
+	authenticated := baileysAuthenticated(c.Request)
+	if baileysSecret != "" && !authenticated {
+		log.Printf("🚫 Conexión WebSocket rechazada para %s: clave de Baileys inválida", name)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Clave de Baileys inválida"})
 		return
 	}
 
+	// Una reconexión autenticada reemplaza a la conexión anterior del bot (RegisterBot la
+	// cierra): tras un corte de red el socket viejo puede seguir registrado hasta perder su pong
+	registered := hub.SessionManager() != nil
+	if !sessionManager {
+		_, err := hub.GetBotConnection(botPhone)
+		registered = err == nil
+	}
+	if registered {
+		if !authenticated {
+			log.Printf("%s ya está registrado", name)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "El bot ya está conectado"})
+			return
+		}
+		log.Printf("%s ya está registrado, se reemplaza su conexión", name)
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("WebSocket upgrade failed:", err)
 		return
 	}
 
-	// Registrar la conexión del BOT
-	log.Printf("Registrando bot: %s", botPhone)
-	hub.RegisterBot(botPhone, conn)
+	var botConn *BotConnection
+	if sessionManager {
+		botConn = hub.RegisterSessionManager(conn)
+	} else {
+		// Registrar la conexión del BOT
+		log.Printf("Registrando bot: %s", botPhone)
+		botConn = hub.RegisterBot(botPhone, conn)
+
+		// Entregar lo que quedó en cola mientras el bot estaba desconectado
+		go func() {
+			if err := hub.FlushOutbox(botPhone, true); err != nil {
+				log.Printf("Error flushing outbox for bot %s: %v", botPhone, err)
+			}
+		}()
+	}
 
-	// Manejar mensajes entrantes
+	// Manejar mensajes entrantes. Si el socket queda medio abierto, el plazo de
+	// lectura (renovado con cada pong) vence y la conexión se da de baja.
 	go func() {
 		defer func() {
-			hub.UnregisterBot(botPhone)
-			conn.Close()
-			log.Printf("Conexión cerrada para bot: %s", botPhone)
+			hub.UnregisterConnection(botConn)
+			log.Printf("Conexión cerrada para %s", name)
 		}()
 
 		for {
-			// Procesar mensajes, primero convertir de json a struct
-			var msg IncomingMessageRequest
-			if err := conn.ReadJSON(&msg); err != nil {
+			data, err := botConn.ReadMessage()
+			if err != nil {
 				if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 					log.Printf("Error reading message: %v", err)
 				}
 				break
 			}
-			// Procesar mensaje
-			if err := processIncomingMessage(msg, hub); err != nil {
-				log.Printf("Error processing message: %v", err)
-			}
+			// Despachar el frame según su tipo
+			hub.Dispatch(botConn, data)
 		}
 	}()
 }
@@ -105,103 +157,239 @@ func processIncomingMessage(msg IncomingMessageRequest, hub *WebSocketHub) error
 	// Asegurar formato consistente del botNumber
 	log.Printf("Procesando mensaje de %s a bot %s: %s", msg.Phone, msg.BotNumber, msg.Message)
 
-	// 1. Procesar cliente (guardar en DB)
+	// 1. El bot debe estar registrado y activo; si no, el mensaje se ignora
+	cleanBotNumber := normalizeBotNumber(msg.BotNumber)
+	bot, err := botRepo.GetBotByNumber(cleanBotNumber)
+	if errors.Is(err, repositories.ErrBotNotFound) {
+		log.Printf("🚫 Mensaje para el bot %s ignorado: no está registrado", cleanBotNumber)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get bot: %w", err)
+	}
+	if !bot.Active {
+		log.Printf("🚫 Mensaje para el bot %s ignorado: está inactivo", cleanBotNumber)
+		return nil
+	}
+
+	// 2. Procesar cliente (guardar en DB)
 	cleanPhone := strings.Split(msg.Phone, "@")[0]
 	client, err := clientRepo.GetOrCreateClient(cleanPhone, "", "")
 	if err != nil {
 		return fmt.Errorf("failed to get/create client: %w", err)
 	}
 
-	// 2. Procesar bot (guardar en DB)
-	cleanBotNumber := strings.Split(msg.BotNumber, "@")[0]
-	bot, err := botRepo.GetOrCreateBot(cleanBotNumber, "Default Bot")
-	if err != nil {
-		return fmt.Errorf("failed to get/create bot: %w", err)
+	// 3. Sesión de conversación: el primer mensaje tras la ventana de inactividad abre
+	// una nueva y el motor NLU empieza sin el estado de la anterior
+	now := time.Now()
+	var sessionID string
+	var sessionOpened bool
+	if sessionRepo != nil {
+		session, opened, err := openSession(context.TODO(), client.ID, bot.ID, now)
+		if err != nil {
+			// Sin sesión el mensaje igual se guarda y se responde
+			log.Printf("Failed to resolve session for %s: %v", cleanPhone, err)
+		} else {
+			sessionID = session.SessionID()
+			sessionOpened = opened
+			if opened {
+				resetNLUSession(context.TODO(), bot, msg.Phone)
+			}
+		}
 	}
 
-	// 3. Guardar mensaje del usuario
+	// 4. Guardar mensaje del usuario, con el archivo que haya enviado
 	clientMsg := models.Message{
 		ClientID:  client.ID,
 		BotID:     bot.ID,
 		Sender:    msg.Phone,
+		Type:      models.MessageTypeText,
 		Text:      msg.Message,
-		Timestamp: time.Now(),
+		Timestamp: now,
+		SessionID: sessionID,
+	}
+	var media *models.MediaFile
+	if msg.Media != nil {
+		// Si el archivo no se puede guardar, el mensaje se registra igual con el adjunto
+		// marcado como fallido y la conversación sigue con el texto
+		media, err = ingestMedia(context.TODO(), client, bot, sessionID, msg.Media)
+		if err != nil {
+			log.Printf("Failed to ingest media from %s: %v", cleanPhone, err)
+			clientMsg.Attachments = []models.MessageAttachment{failedMediaAttachment(msg.Media)}
+		} else {
+			clientMsg.Type = media.Type
+			clientMsg.Attachments = []models.MessageAttachment{mediaAttachment(media)}
+		}
 	}
 
 	if err := conversationRepo.SaveMessage(context.TODO(), client.ID, bot.ID, clientMsg); err != nil {
 		return fmt.Errorf("failed to save client message: %w", err)
 	}
+	touchSession(context.TODO(), sessionID, now)
 
-	// 4. Procesar con Rasa
-	rasaResponses, err := sendToRasa(msg.Phone, msg.Message)
+	// 5. Publicar al dashboard y respetar el modo de atención: en modo human
+	// responde un operador y en paused nadie
+	mode, err := conversationRepo.GetConversationMode(context.TODO(), client.ID, bot.ID)
 	if err != nil {
-		return fmt.Errorf("rasa processing failed: %w", err)
+		return fmt.Errorf("failed to get conversation mode: %w", err)
+	}
+	hub.PublishDashboard(DashboardEventInboundMessage, cleanBotNumber, ConversationEvent{
+		ClientID: client.ID,
+		BotID:    bot.ID,
+		Phone:    cleanPhone,
+		Mode:     mode,
+		Message:  &clientMsg,
+	})
+	// Las palabras de baja (STOP, BAJA) se atienden en cualquier modo
+	if handleOptOutKeyword(hub, client, bot, msg.Phone, sessionID, msg.Message) {
+		return nil
+	}
+	switch mode {
+	case models.ConversationModeHuman:
+		log.Printf("👩‍💼 Conversación %s con bot %s atendida por operador, se omite el motor NLU", cleanPhone, cleanBotNumber)
+		return nil
+	case models.ConversationModePaused:
+		log.Printf("⏸️  Conversación %s con bot %s en pausa", cleanPhone, cleanBotNumber)
+		return nil
 	}
-	log.Printf("Respuestas de Rasa recibidas: %+v", rasaResponses)
 
-	// 5. Procesar respuestas
-	for _, response := range rasaResponses {
-		if response.Text == "" {
-			continue
-		}
+	// 6. Fuera del horario del bot solo se envía la respuesta automática
+	if handleOutOfHours(hub, client, bot, msg.Phone, cleanPhone, sessionID, now) {
+		return nil
+	}
 
-		// Guardar respuesta del bot
-		botMsg := models.Message{
+	// 7. Saludo del bot al abrir la sesión, antes de la respuesta del motor
+	if greeting := autoReplyText(bot, client, bot.WelcomeTemplate, bot.WelcomeMessage); sessionOpened && greeting != "" {
+		welcome := models.Message{
 			ClientID:  client.ID,
 			BotID:     bot.ID,
-			Sender:    "bot",
-			Text:      response.Text,
-			Timestamp: time.Now(),
+			Sender:    models.MessageSenderBot,
+			Type:      models.MessageTypeText,
+			Text:      greeting,
+			SessionID: sessionID,
 		}
-		if err := conversationRepo.SaveMessage(context.TODO(), client.ID, bot.ID, botMsg); err != nil {
-			log.Printf("Failed to save bot message: %v", err)
+		if err := deliverMessage(hub, cleanBotNumber, msg.Phone, welcome); err != nil {
+			log.Printf("Failed to save welcome message: %v", err)
 		}
+	}
 
-		// Enviar respuesta al cliente
-		log.Printf("Enviando respuesta a bot %s para cliente %s: %s",
-			msg.BotNumber, msg.Phone, response.Text)
+	// 8. Procesar con el motor NLU configurado para el bot
+	engine, err := nluRegistry.EngineFor(bot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve nlu engine: %w", err)
+	}
+	// Si el cliente pulsó un botón, el motor recibe el payload (ej: /informar_datos{"origen": "Bogotá"});
+	// si envió un archivo, su referencia para llenar el slot pedido (ej: tarjeta)
+	nluInput := msg.Message
+	switch {
+	case msg.Payload != "":
+		nluInput = msg.Payload
+	case media != nil:
+		nluInput = mediaNLUInput(media)
+	}
+	nluResponses, err := engine.Send(context.TODO(), msg.Phone, nluInput)
+	if err != nil {
+		return fmt.Errorf("%s processing failed: %w", engine.Name(), err)
+	}
+	log.Printf("Respuestas de %s recibidas: %+v", engine.Name(), nluResponses)
+
+	// 9. Procesar respuestas
+	var documentRequests []services.DocumentRequest
+	for _, response := range nluResponses {
+		// Rasa puede pedir que un operador tome la conversación
+		if reason, ok := response.HandoffRequest(); ok {
+			if err := escalateConversation(hub, client, bot, cleanPhone, reason); err != nil {
+				log.Printf("Failed to escalate conversation: %v", err)
+			}
+			response = response.WithoutHandoff()
+		}
+		// o que se genere un documento con los datos de un formulario completado
+		if request, ok := response.DocumentRequest(); ok {
+			documentRequests = append(documentRequests, request)
+			response = response.WithoutDocumentRequest()
+		}
+		if response.IsEmpty() {
+			continue
+		}
 
-		if err := hub.SendToBot(msg.BotNumber, map[string]interface{}{
-			"to":      msg.Phone, // Cliente destino
-			"message": response.Text,
-		}); err != nil {
-			log.Printf("Failed to send message to bot: %v", err)
+		// Traducir la respuesta a mensajes de WhatsApp (botones, imágenes y adjuntos incluidos)
+		botMsg := response.ToMessage()
+		botMsg.ClientID = client.ID
+		botMsg.BotID = bot.ID
+		botMsg.Sender = models.MessageSenderBot
+		botMsg.SessionID = sessionID
+
+		if err := deliverMessage(hub, cleanBotNumber, msg.Phone, botMsg); err != nil {
+			log.Printf("Failed to save bot message: %v", err)
 		}
 	}
 
+	// 10. Generar los documentos solicitados, después de las respuestas del bot
+	for _, request := range documentRequests {
+		handleDocumentRequest(hub, client, bot, msg.Phone, sessionID, request)
+	}
+
 	return nil
 }
 
-// sendToRasa envía mensajes al servidor Rasa
-func sendToRasa(sender, message string) ([]RasaResponseItem, error) {
-	payload := map[string]interface{}{
-		"sender":  sender,
-		"message": message,
-	}
+// deliverMessage guarda un mensaje saliente y envía sus frames al bot. El mensaje se
+// guarda antes de enviar para que los recibos lo encuentren; los fallos de envío
+// quedan registrados en su estado. Sin SessionID se usa la sesión vigente, si la hay.
+func deliverMessage(hub *WebSocketHub, botNumber, recipient string, message models.Message) error {
+	_, err := sendOutgoingMessage(hub, botNumber, recipient, message, nil)
+	return err
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+// sendOutgoingMessage igual que deliverMessage, pero devuelve el mensaje guardado con sus
+// ids de frame (con estado failed si el bot no aceptó algún frame). onSaved, si se indica,
+// se llama con el mensaje guardado antes de enviar los frames, para que quien lo siga
+// registre los ids antes de que lleguen los recibos.
+func sendOutgoingMessage(hub *WebSocketHub, botNumber, recipient string, message models.Message, onSaved func(models.Message)) (models.Message, error) {
+	message.Timestamp = time.Now()
+	message.Status = models.MessageStatusPending
+	if message.SessionID == "" {
+		message.SessionID = activeSessionID(context.TODO(), message.ClientID, message.BotID, message.Timestamp)
 	}
 
-	// req, err := http.NewRequest("POST", "http://localhost:5005/webhooks/rest/webhook", bytes.NewBuffer(body))
-	req, err := http.NewRequest("POST", "http://rasa:5005/webhooks/rest/webhook", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var frames []Envelope
+	for _, outgoing := range buildOutgoingMessages(recipient, message) {
+		env, err := NewEnvelope(FrameOutboundMessage, outgoing)
+		if err != nil {
+			log.Printf("Failed to build outbound frame: %v", err)
+			continue
+		}
+		frames = append(frames, env)
+		message.OutboundIDs = append(message.OutboundIDs, env.ID)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if err := conversationRepo.SaveMessage(context.TODO(), message.ClientID, message.BotID, message); err != nil {
+		return message, err
 	}
-	defer resp.Body.Close()
-
-	var responses []RasaResponseItem
-	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	touchSession(context.TODO(), message.SessionID, message.Timestamp)
+	if onSaved != nil {
+		onSaved(message)
 	}
+	hub.PublishDashboard(DashboardEventOutboundMessage, botNumber, ConversationEvent{
+		ClientID: message.ClientID,
+		BotID:    message.BotID,
+		Phone:    normalizeBotNumber(recipient),
+		Message:  &message,
+	})
+
+	for _, env := range frames {
+		log.Printf("Enviando respuesta %s a bot %s para cliente %s", env.ID, botNumber, recipient)
 
-	return responses, nil
+		if err := hub.SendEnvelope(botNumber, recipient, env); err != nil {
+			log.Printf("Failed to send message to bot: %v", err)
+			updateMessageStatus(models.MessageStatusUpdate{
+				OutboundID: env.ID,
+				Status:     models.MessageStatusFailed,
+				At:         time.Now(),
+				Error:      err.Error(),
+			})
+			message.Status = models.MessageStatusFailed
+			message.StatusError = err.Error()
+		}
+	}
+	return message, nil
 }
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+const (
+	defaultPageSize  = 50
+	maxPageSize      = 200
+	maxPreviewLength = 80
+)
+
+// ConversationHistoryResponse página del historial de una conversación
+type ConversationHistoryResponse struct {
+	ClientID   uint             `json:"client_id"`
+	BotID      uint             `json:"bot_id"`
+	Messages   []models.Message `json:"messages"`              // en orden cronológico
+	NextCursor string           `json:"next_cursor,omitempty"` // para pedir mensajes más antiguos (?before=)
+	HasMore    bool             `json:"has_more"`
+}
+
+// RecentConversation conversación del listado con los datos del cliente
+type RecentConversation struct {
+	models.ConversationSummary
+	Phone string `json:"phone,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// GetConversationHistory devuelve el historial paginado de un cliente con un bot
+// @Summary Historial de conversación
+// @Description Mensajes de un cliente con un bot en orden cronológico, desde los más recientes. Para cargar los anteriores se envía next_cursor en before. Sin bot_id se usa la conversación más reciente del cliente.
+// @Tags conversaciones
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param bot_id query int false "ID del bot"
+// @Param before query string false "Cursor devuelto en next_cursor"
+// @Param limit query int false "Mensajes por página (por defecto 50, máximo 200)"
+// @Success 200 {object} ConversationHistoryResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/history/{client_id} [get]
+func GetConversationHistory(c *gin.Context) {
+	clientID, err := strconv.ParseUint(c.Param("client_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de cliente inválido"})
+		return
+	}
+	botID, ok := queryUint(c, "bot_id")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(c, "limit", defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+
+	if botID == 0 {
+		latest, err := conversationRepo.GetRecentConversations(c.Request.Context(), models.ConversationFilter{ClientID: uint(clientID), Limit: 1})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando conversaciones", "details": err.Error()})
+			return
+		}
+		if len(latest) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "El cliente no tiene conversaciones"})
+			return
+		}
+		botID = latest[0].BotID
+	}
+
+	messages, next, err := conversationRepo.GetMessages(c.Request.Context(), uint(clientID), botID, c.Query("before"), limit)
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor inválido"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el historial", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConversationHistoryResponse{
+		ClientID:   uint(clientID),
+		BotID:      botID,
+		Messages:   messages,
+		NextCursor: next,
+		HasMore:    next != "",
+	})
+}
+
+// GetRecentConversations lista las conversaciones con su último mensaje y mensajes sin leer
+// @Summary Conversaciones recientes
+// @Description Conversaciones ordenadas por el último mensaje, con vista previa y cantidad de mensajes sin leer
+// @Tags conversaciones
+// @Produce json
+// @Param bot_id query int false "Filtrar por bot"
+// @Param unread query bool false "Solo conversaciones con mensajes sin leer"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Conversaciones por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/recent [get]
+func GetRecentConversations(c *gin.Context) {
+	botID, ok := queryUint(c, "bot_id")
+	if !ok {
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	summaries, err := conversationRepo.GetRecentConversations(c.Request.Context(), models.ConversationFilter{
+		BotID:      botID,
+		UnreadOnly: c.Query("unread") == "true",
+		Offset:     (page - 1) * pageSize,
+		Limit:      pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando conversaciones", "details": err.Error()})
+		return
+	}
+
+	conversations := make([]RecentConversation, 0, len(summaries))
+	for _, summary := range summaries {
+		summary.Preview = messagePreview(summary.LastMessage)
+		conversation := RecentConversation{ConversationSummary: summary}
+		if client, err := clientRepo.GetClientByID(summary.ClientID); err == nil {
+			conversation.Phone = client.Phone
+			conversation.Name = client.Name
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"page":          page,
+		"page_size":     pageSize,
+	})
+}
+
+// SearchConversations busca texto en los mensajes de las conversaciones
+// @Summary Buscar en conversaciones
+// @Description Búsqueda de texto completo en los mensajes; devuelve las conversaciones que coinciden con los mensajes encontrados
+// @Tags conversaciones
+// @Produce json
+// @Param q query string true "Texto a buscar"
+// @Param bot_id query int false "Filtrar por bot"
+// @Param limit query int false "Máximo de conversaciones (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/search [get]
+func SearchConversations(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Texto de búsqueda requerido"})
+		return
+	}
+	botID, ok := queryUint(c, "bot_id")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(c, "limit", defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+
+	results, err := conversationRepo.SearchMessages(c.Request.Context(), query, botID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error en la búsqueda", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   query,
+		"results": results,
+		"total":   len(results),
+	})
+}
+
+// MarkConversationRead marca como leídos los mensajes del cliente
+// @Summary Marcar conversación como leída
+// @Tags conversaciones
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param bot_id path int true "ID del bot"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/{client_id}/bots/{bot_id}/read [post]
+func MarkConversationRead(c *gin.Context) {
+	clientID, err := strconv.ParseUint(c.Param("client_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de cliente inválido"})
+		return
+	}
+	botID, err := strconv.ParseUint(c.Param("bot_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de bot inválido"})
+		return
+	}
+
+	err = conversationRepo.MarkConversationRead(c.Request.Context(), uint(clientID), uint(botID), time.Now())
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversación no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando la conversación", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversación marcada como leída"})
+}
+
+// messagePreview texto corto del último mensaje para el listado
+func messagePreview(message *models.Message) string {
+	if message == nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(message.Text), " ")
+	if text == "" {
+		switch {
+		case len(message.Attachments) > 0:
+			return "[" + message.Attachments[0].Type + "]"
+		case message.Type != "" && message.Type != models.MessageTypeText:
+			return "[" + message.Type + "]"
+		}
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= maxPreviewLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxPreviewLength-1]) + "…"
+}
+
+// pagination lee page y page_size; responde 400 si son inválidos
+func pagination(c *gin.Context) (int, int, bool) {
+	page := 1
+	if raw := c.Query("page"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Página inválida"})
+			return 0, 0, false
+		}
+		page = parsed
+	}
+	pageSize, ok := queryLimit(c, "page_size", defaultPageSize, maxPageSize)
+	return page, pageSize, ok
+}
+
+// queryLimit lee un límite positivo acotado a max
+func queryLimit(c *gin.Context, name string, def, max int) (int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Límite inválido"})
+		return 0, false
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit, true
+}
+
+// queryUint lee un ID opcional de la query (0 si no viene)
+func queryUint(c *gin.Context, name string) (uint, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, true
+	}
+	value, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro " + name + " inválido"})
+		return 0, false
+	}
+	return uint(value), true
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+)
+
+func TestGetConversationHistoryPaginatesWithCursor(t *testing.T) {
+	var gotBefore string
+	var gotLimit int
+	SetConversationRepo(&mocks.MockConversationRepo{
+		GetRecentConversationsFunc: func(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error) {
+			assert.Equal(t, uint(7), filter.ClientID)
+			return []models.ConversationSummary{{ClientID: 7, BotID: 3}}, nil
+		},
+		GetMessagesFunc: func(ctx context.Context, userID uint, botID uint, before string, limit int) ([]models.Message, string, error) {
+			assert.Equal(t, uint(3), botID)
+			gotBefore, gotLimit = before, limit
+			return []models.Message{{Text: "hola"}}, "next-page", nil
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/conversations/history/:client_id", GetConversationHistory)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/history/7?before=abc&limit=20", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response ConversationHistoryResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "abc", gotBefore)
+	assert.Equal(t, 20, gotLimit)
+	assert.Equal(t, uint(3), response.BotID)
+	assert.Equal(t, "next-page", response.NextCursor)
+	assert.True(t, response.HasMore)
+}
+
+func TestGetRecentConversationsAddsPreviewAndClient(t *testing.T) {
+	now := time.Now()
+	long := "Necesito el manifiesto de carga para el viaje de mañana de Bogotá a Medellín con la tractomula placa ABC123"
+	SetConversationRepo(&mocks.MockConversationRepo{
+		GetRecentConversationsFunc: func(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error) {
+			assert.True(t, filter.UnreadOnly)
+			return []models.ConversationSummary{
+				{ClientID: 7, BotID: 3, LastMessageAt: &now, LastMessage: &models.Message{Text: long}, UnreadCount: 2},
+				{ClientID: 8, BotID: 3, LastMessage: &models.Message{Type: models.MessageTypeDocument, Attachments: []models.MessageAttachment{{Type: models.MessageTypeDocument}}}},
+			}, nil
+		},
+	})
+	SetClientRepo(&mocks.MockClientRepo{
+		GetClientByIDFunc: func(id uint) (*models.Client, error) {
+			return &models.Client{ID: id, Phone: "573001112233", Name: "Transportes Andinos"}, nil
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/conversations/recent", GetRecentConversations)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/recent?unread=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Conversations []RecentConversation `json:"conversations"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Conversations, 2)
+	assert.Equal(t, "Transportes Andinos", response.Conversations[0].Name)
+	assert.Equal(t, 2, response.Conversations[0].UnreadCount)
+	assert.Equal(t, maxPreviewLength, len([]rune(response.Conversations[0].Preview)))
+	assert.Equal(t, "[document]", response.Conversations[1].Preview)
+}
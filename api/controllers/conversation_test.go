@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+func TestProcessIncomingMessageWithMemoryEngine(t *testing.T) {
+	m := setupInboundMocks(t)
+	m.Engine.Script("hola", services.NLUResponse{Text: "¡Hola!"}, services.NLUResponse{Text: "¿En qué te ayudo?"})
+
+	err := processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233@s.whatsapp.net",
+		Message:   "hola",
+		BotNumber: "573009998877@s.whatsapp.net",
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []services.MemoryEngineCall{{Sender: "573001112233@s.whatsapp.net", Message: "hola"}}, m.Engine.Received())
+	saved := m.Saved
+	assert.Len(t, saved, 3)
+	assert.Equal(t, "hola", saved[0].Text)
+	assert.Equal(t, "bot", saved[1].Sender)
+	assert.Equal(t, "¿En qué te ayudo?", saved[2].Text)
+
+	// Sin bot conectado ni outbox, las respuestas quedan marcadas como fallidas
+	assert.Equal(t, models.MessageStatusPending, saved[1].Status)
+	assert.Len(t, saved[1].OutboundIDs, 1)
+	assert.Len(t, m.Updates, 2)
+	assert.Equal(t, saved[1].OutboundIDs[0], m.Updates[0].OutboundID)
+	assert.Equal(t, models.MessageStatusFailed, m.Updates[0].Status)
+}
+
+func TestHumanModeSkipsNLUAndNotifiesDashboard(t *testing.T) {
+	m := setupInboundMocks(t)
+	m.Mode = models.ConversationModeHuman
+
+	hub := NewWebSocketHub()
+	dashboard := hub.SubscribeDashboard("573009998877")
+	defer hub.UnsubscribeDashboard(dashboard)
+
+	err := processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233@s.whatsapp.net",
+		Message:   "quiero hablar con alguien",
+		BotNumber: "573009998877@s.whatsapp.net",
+	}, hub)
+	assert.NoError(t, err)
+
+	assert.Empty(t, m.Engine.Received())
+	assert.Len(t, m.Saved, 1)
+
+	event := <-dashboard.Events()
+	assert.Equal(t, DashboardEventInboundMessage, event.Type)
+	assert.Equal(t, "573009998877", event.BotNumber)
+	data := event.Data.(ConversationEvent)
+	assert.Equal(t, models.ConversationModeHuman, data.Mode)
+	assert.Equal(t, "quiero hablar con alguien", data.Message.Text)
+}
+
+func TestHandoffPayloadEscalatesConversation(t *testing.T) {
+	var changes []models.ConversationModeChange
+
+	m := setupInboundMocks(t)
+	m.Conversations.SetConversationModeFunc = func(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error {
+		changes = append(changes, change)
+		return nil
+	}
+	m.Engine.Script("asesor", services.NLUResponse{
+		Text:   "Te comunico con un asesor",
+		Custom: map[string]interface{}{"handoff": map[string]interface{}{"reason": "solicitud del cliente"}},
+	})
+
+	err := processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "asesor",
+		BotNumber: "573009998877",
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, models.ConversationModeHuman, changes[0].Mode)
+		assert.Equal(t, "solicitud del cliente", changes[0].Reason)
+	}
+	saved := m.Saved
+	assert.Len(t, saved, 2)
+	assert.Equal(t, "Te comunico con un asesor", saved[1].Text)
+	assert.Equal(t, models.MessageTypeText, saved[1].Type)
+	assert.Nil(t, saved[1].Custom)
+}
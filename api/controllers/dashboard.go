@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tipos de eventos publicados al dashboard
+const (
+	DashboardEventSnapshot        = "snapshot"          // estado de los bots al suscribirse
+	DashboardEventInboundMessage  = "message.inbound"   // mensaje de un cliente
+	DashboardEventOutboundMessage = "message.outbound"  // respuesta del bot o de un operador
+	DashboardEventModeChanged     = "conversation.mode" // cambio de modo de atención (bot, human, paused)
+	DashboardEventBotConnected    = "bot.connected"     // un bot de Baileys abrió su WebSocket
+	DashboardEventBotDisconnected = "bot.disconnected"  // el WebSocket del bot se cerró
+	DashboardEventBotState        = "bot.state"         // cambio del estado de la sesión de WhatsApp
+	DashboardEventQRUpdated       = "bot.qr"            // nuevo QR para vincular la sesión
+	DashboardEventDocumentCreated = "document.created"  // documento generado para un cliente
+)
+
+// Eventos que pueden esperar en cola por suscriptor antes de descartarse
+const dashboardBufferSize = 64
+
+// DashboardEvent evento en tiempo real para los operadores
+type DashboardEvent struct {
+	Type      string      `json:"type"`
+	BotNumber string      `json:"bot_number"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// DashboardClient suscriptor a los eventos del hub. Si bots está vacío recibe
+// los eventos de todos los bots.
+type DashboardClient struct {
+	events chan DashboardEvent
+
+	mu   sync.RWMutex
+	bots map[string]bool
+}
+
+// Events canal por el que llegan los eventos suscritos
+func (c *DashboardClient) Events() <-chan DashboardEvent {
+	return c.events
+}
+
+// Subscribe agrega bots a la suscripción
+func (c *DashboardClient) Subscribe(bots ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, bot := range bots {
+		c.bots[normalizeBotNumber(bot)] = true
+	}
+}
+
+// Unsubscribe quita bots de la suscripción; sin bots vuelve a recibir los de todos
+func (c *DashboardClient) Unsubscribe(bots ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, bot := range bots {
+		delete(c.bots, normalizeBotNumber(bot))
+	}
+}
+
+// Bots bots suscritos (vacío = todos)
+func (c *DashboardClient) Bots() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bots := make([]string, 0, len(c.bots))
+	for bot := range c.bots {
+		bots = append(bots, bot)
+	}
+	return bots
+}
+
+func (c *DashboardClient) wants(botNumber string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.bots) == 0 || c.bots[botNumber]
+}
+
+// SubscribeDashboard registra un suscriptor para los bots indicados (todos si no se indica ninguno)
+func (h *WebSocketHub) SubscribeDashboard(bots ...string) *DashboardClient {
+	client := &DashboardClient{
+		events: make(chan DashboardEvent, dashboardBufferSize),
+		bots:   make(map[string]bool),
+	}
+	client.Subscribe(bots...)
+
+	h.clientsMu.Lock()
+	h.clients[client] = struct{}{}
+	h.clientsMu.Unlock()
+	return client
+}
+
+// UnsubscribeDashboard da de baja al suscriptor y cierra su canal
+func (h *WebSocketHub) UnsubscribeDashboard(client *DashboardClient) {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.events)
+	}
+}
+
+// PublishDashboard entrega el evento a los suscriptores interesados sin bloquear:
+// si un suscriptor tiene la cola llena, el evento se descarta para él
+func (h *WebSocketHub) PublishDashboard(eventType, botNumber string, data interface{}) {
+	event := DashboardEvent{
+		Type:      eventType,
+		BotNumber: normalizeBotNumber(botNumber),
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	for client := range h.clients {
+		if !client.wants(event.BotNumber) {
+			continue
+		}
+		select {
+		case client.events <- event:
+		default:
+			log.Printf("⚠️  Suscriptor del dashboard saturado, evento %s descartado", event.Type)
+		}
+	}
+}
+
+// dashboardSnapshot estado actual de los bots conectados que le interesan al suscriptor
+func (h *WebSocketHub) dashboardSnapshot(client *DashboardClient) DashboardEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	states := make(map[string]BotState)
+	for phone, botConn := range h.bots {
+		if client.wants(normalizeBotNumber(phone)) {
+			states[phone] = botConn.State()
+		}
+	}
+	return DashboardEvent{
+		Type:      DashboardEventSnapshot,
+		Timestamp: time.Now(),
+		Data:      gin.H{"bots": states, "subscribed": client.Bots()},
+	}
+}
+
+// normalizeBotNumber quita el sufijo de WhatsApp (@s.whatsapp.net) y el dispositivo
+// (573001112233:12@s.whatsapp.net) del número
+func normalizeBotNumber(number string) string {
+	number = strings.Split(number, "@")[0]
+	return strings.Split(number, ":")[0]
+}
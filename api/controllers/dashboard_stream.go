@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Tamaño máximo de los comandos que envía el dashboard
+	maxDashboardCommandSize = 4 << 10
+	// DashboardTokenProtocol subprotocolo con que el navegador envía el token al abrir el
+	// WebSocket del dashboard: new WebSocket(url, ["bearer", token])
+	DashboardTokenProtocol = "bearer"
+)
+
+// DashboardCommand comando del dashboard para cambiar su suscripción
+type DashboardCommand struct {
+	Action string   `json:"action"` // subscribe o unsubscribe
+	Bots   []string `json:"bots"`
+}
+
+// HandleDashboardWebSocket abre el canal de eventos en tiempo real del dashboard
+// @Summary Eventos del dashboard (WebSocket)
+// @Description Publica mensajes entrantes/salientes, conexión y desconexión de bots y cambios de QR. El token va en Authorization o en Sec-WebSocket-Protocol ("bearer, <token>") y los bots en ?bots=573001,573002. Por el mismo socket se envían comandos {"action": "subscribe"|"unsubscribe", "bots": [...]}.
+// @Tags dashboard
+// @Param bots query string false "Números de bots separados por coma (vacío = todos)"
+// @Success 101 {object} DashboardEvent
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/dashboard/ws [get]
+func HandleDashboardWebSocket(c *gin.Context, hub *WebSocketHub, upgrader websocket.Upgrader) {
+	upgrader = dashboardUpgrader(upgrader)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("Dashboard WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+
+	client := hub.SubscribeDashboard(parseBotsQuery(c)...)
+	defer hub.UnsubscribeDashboard(client)
+	log.Printf("🖥️  Dashboard conectado (operador %d)", currentOperatorID(c))
+
+	// Lector: comandos de suscripción y detección de cierre
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	conn.SetReadLimit(maxDashboardCommandSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	commands := make(chan DashboardCommand, 8)
+	go func() {
+		defer close(closed)
+		for {
+			var command DashboardCommand
+			if err := conn.ReadJSON(&command); err != nil {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+			select {
+			case commands <- command:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Escritor: único goroutine que escribe en el socket
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	write := func(payload interface{}) bool {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(payload) == nil
+	}
+
+	if !write(hub.dashboardSnapshot(client)) {
+		return
+	}
+	for {
+		select {
+		case event, ok := <-client.Events():
+			if !ok || !write(event) {
+				return
+			}
+		case command := <-commands:
+			switch command.Action {
+			case "subscribe":
+				client.Subscribe(command.Bots...)
+			case "unsubscribe":
+				client.Unsubscribe(command.Bots...)
+			default:
+				continue
+			}
+			if !write(hub.dashboardSnapshot(client)) {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			log.Printf("🖥️  Dashboard desconectado (operador %d)", currentOperatorID(c))
+			return
+		}
+	}
+}
+
+// StreamDashboardEvents publica los eventos del dashboard como Server-Sent Events
+// @Summary Eventos del dashboard (SSE)
+// @Description Misma información que el WebSocket del dashboard. Requiere el token en Authorization (fetch con lectura del stream). La suscripción se fija con ?bots=.
+// @Tags dashboard
+// @Produce text/event-stream
+// @Param bots query string false "Números de bots separados por coma (vacío = todos)"
+// @Success 200 {object} DashboardEvent
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/dashboard/events [get]
+func StreamDashboardEvents(c *gin.Context, hub *WebSocketHub) {
+	client := hub.SubscribeDashboard(parseBotsQuery(c)...)
+	defer hub.UnsubscribeDashboard(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	snapshot := hub.dashboardSnapshot(client)
+	c.SSEvent(snapshot.Type, snapshot)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(pingPeriod)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-client.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			// Comentario SSE para que los proxies no cierren la conexión
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// parseBotsQuery lee ?bots=573001,573002
+func parseBotsQuery(c *gin.Context) []string {
+	var bots []string
+	for _, bot := range strings.Split(c.Query("bots"), ",") {
+		if bot = strings.TrimSpace(bot); bot != "" {
+			bots = append(bots, bot)
+		}
+	}
+	return bots
+}
+
+// dashboardUpgrader responde con el subprotocolo del token, sin el cual el navegador
+// rechaza el socket. El origen lo valida el CheckOrigin recibido.
+func dashboardUpgrader(base websocket.Upgrader) websocket.Upgrader {
+	base.Subprotocols = []string{DashboardTokenProtocol}
+	return base
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func readDashboardEvent(t *testing.T, conn *websocket.Conn) DashboardEvent {
+	var event DashboardEvent
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+func TestDashboardWebSocketFiltersByBot(t *testing.T) {
+	hub := NewWebSocketHub()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/dashboard", func(c *gin.Context) {
+		HandleDashboardWebSocket(c, hub, websocket.Upgrader{})
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{DashboardTokenProtocol, "v4.public.token"}}
+	dashboard, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/dashboard?bots=573009998877", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dashboard.Close()
+	assert.Equal(t, DashboardTokenProtocol, dashboard.Subprotocol(), "el navegador exige el subprotocolo en la respuesta")
+	assert.Equal(t, DashboardEventSnapshot, readDashboardEvent(t, dashboard).Type)
+
+	// Un bot al que no está suscrito no genera eventos
+	otherServer, other := dialTestBot(t, hub, "573000000000")
+	defer otherServer.Close()
+	defer other.Close()
+
+	botServer, bot := dialTestBot(t, hub, "573009998877")
+	defer botServer.Close()
+
+	event := readDashboardEvent(t, dashboard)
+	assert.Equal(t, DashboardEventBotConnected, event.Type)
+	assert.Equal(t, "573009998877", event.BotNumber)
+
+	qr, _ := NewEnvelope(FrameQRUpdate, QRUpdatePayload{QRCode: "2@abc"})
+	assert.NoError(t, bot.WriteJSON(qr))
+	event = readDashboardEvent(t, dashboard)
+	assert.Equal(t, DashboardEventQRUpdated, event.Type)
+
+	bot.Close()
+	event = readDashboardEvent(t, dashboard)
+	assert.Equal(t, DashboardEventBotDisconnected, event.Type)
+	assert.Equal(t, "573009998877", event.BotNumber)
+
+	// Al ampliar la suscripción llega un snapshot con el otro bot
+	assert.NoError(t, dashboard.WriteJSON(DashboardCommand{Action: "subscribe", Bots: []string{"573000000000"}}))
+	event = readDashboardEvent(t, dashboard)
+	assert.Equal(t, DashboardEventSnapshot, event.Type)
+	bots := event.Data.(map[string]interface{})["bots"].(map[string]interface{})
+	assert.Contains(t, bots, "573000000000")
+}
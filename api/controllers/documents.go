@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+const (
+	documentLinkTTL       = 2 * time.Hour      // enlace que recibe WhatsApp; cubre los reintentos del outbox
+	defaultDocumentURLTTL = 15 * time.Minute   // enlaces pedidos desde el dashboard
+	maxDocumentURLTTL     = 24 * time.Hour     // máximo permitido por GET /documents/:id/url
+	maxStoredFileSize     = 25 << 20
+)
+
+var (
+	documentRepo      repositories.DocumentRepository
+	manifestGenerator services.ManifestGenerator
+	documentStorage   services.FileStorage
+	documentSigner    *services.URLSigner
+	documentBaseURL   string // URL del API accesible desde Baileys (ej: http://api:8080)
+)
+
+// DocumentEvent documento publicado al dashboard
+type DocumentEvent struct {
+	ClientID uint             `json:"client_id"`
+	BotID    uint             `json:"bot_id"`
+	Phone    string           `json:"phone"`
+	Document *models.Document `json:"document"`
+}
+
+func SetDocumentRepo(repo repositories.DocumentRepository) {
+	documentRepo = repo
+}
+
+// SetDocumentPipeline configura la generación de documentos: el generador (Playwright),
+// dónde se guardan los archivos, el firmador de enlaces de descarga y la URL base con la
+// que WhatsApp los descarga
+func SetDocumentPipeline(generator services.ManifestGenerator, storage services.FileStorage, signer *services.URLSigner, baseURL string) {
+	manifestGenerator = generator
+	documentStorage = storage
+	documentSigner = signer
+	documentBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// handleDocumentRequest atiende la solicitud de documento de una acción de Rasa. Con la
+// cola de trabajos activa la generación corre en un worker y no bloquea la conexión del bot.
+func handleDocumentRequest(hub *WebSocketHub, client *models.Client, bot *models.Bot, recipient, sessionID string, request services.DocumentRequest) {
+	switch request.Type {
+	case models.DocumentTypeManifiesto:
+		data := services.ManifestDataFromSlots(request.Data)
+		if jobQueue != nil {
+			job, err := enqueueManifest(client, bot, recipient, sessionID, data)
+			if err != nil {
+				log.Printf("❌ Error encolando manifiesto para %s: %v", client.Phone, err)
+				notifyDocumentFailure(hub, client, bot, recipient, sessionID)
+				return
+			}
+			log.Printf("📥 Manifiesto para %s en cola (trabajo %d)", client.Phone, job.ID)
+			return
+		}
+		if _, err := generateManifest(context.TODO(), hub, client, bot, recipient, sessionID, 0, data); err != nil {
+			log.Printf("❌ Error generando manifiesto para %s: %v", client.Phone, err)
+			notifyDocumentFailure(hub, client, bot, recipient, sessionID)
+		}
+	default:
+		log.Printf("⚠️  Tipo de documento no soportado: %q", request.Type)
+	}
+}
+
+// generateManifest genera el PDF del manifiesto, lo guarda y se lo envía al cliente.
+// jobID es el trabajo que lo generó (0 si se generó en el momento); si un intento
+// anterior del trabajo ya guardó el documento, se reutiliza y solo se vuelve a enviar.
+func generateManifest(ctx context.Context, hub *WebSocketHub, client *models.Client, bot *models.Bot, recipient, sessionID string, jobID uint, data services.ManifestData) (*models.Document, error) {
+	if manifestGenerator == nil || documentStorage == nil || documentSigner == nil || documentRepo == nil {
+		return nil, errors.New("document pipeline not configured")
+	}
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	document, err := jobDocument(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		log.Printf("📄 Generando manifiesto para %s (%s → %s)", client.Phone, data.Origen, data.Destino)
+		file, err := manifestGenerator.GenerateManifest(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("generation failed: %w", err)
+		}
+
+		document, err = storeDocument(ctx, client, bot, sessionID, jobID, models.DocumentTypeManifiesto, file, data.Map())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("📄 Manifiesto %s del trabajo %d ya generado, se reenvía", document.ID.Hex(), jobID)
+	}
+
+	url, _ := signDocumentURL(document, time.Now().Add(documentLinkTTL))
+	message := models.Message{
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		Sender:    models.MessageSenderBot,
+		Type:      models.MessageTypeDocument,
+		Text:      "📄 Tu manifiesto está listo",
+		SessionID: sessionID,
+		Attachments: []models.MessageAttachment{{
+			Type:     models.MessageTypeDocument,
+			URL:      url,
+			FileName: document.FileName,
+			MimeType: document.MimeType,
+		}},
+	}
+	if err := deliverMessage(hub, bot.Number, recipient, message); err != nil {
+		return document, fmt.Errorf("failed to deliver document: %w", err)
+	}
+
+	hub.PublishDashboard(DashboardEventDocumentCreated, bot.Number, DocumentEvent{
+		ClientID: client.ID,
+		BotID:    bot.ID,
+		Phone:    client.Phone,
+		Document: document,
+	})
+	return document, nil
+}
+
+// jobDocument documento que ya guardó el trabajo jobID; nil si aún no tiene o no hay trabajo
+func jobDocument(ctx context.Context, jobID uint) (*models.Document, error) {
+	if jobID == 0 {
+		return nil, nil
+	}
+	document, err := documentRepo.GetDocumentByJobID(ctx, jobID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up job document: %w", err)
+	}
+	return document, nil
+}
+
+// storeDocument guarda el archivo y registra el documento con su checksum
+func storeDocument(ctx context.Context, client *models.Client, bot *models.Bot, sessionID string, jobID uint, docType string, file *services.GeneratedFile, data map[string]string) (*models.Document, error) {
+	document := &models.Document{
+		ID:        primitive.NewObjectID(),
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		SessionID: sessionID,
+		JobID:     jobID,
+		FileName:  file.FileName,
+		MimeType:  file.MimeType,
+		Size:      int64(len(file.Content)),
+		Checksum:  services.Checksum(file.Content),
+		Type:      docType,
+		Data:      data,
+		Storage:   documentStorage.Name(),
+		CreatedAt: time.Now(),
+	}
+	document.StorageKey = fmt.Sprintf("%s/%d/%s.pdf", docType, client.ID, document.ID.Hex())
+
+	if err := documentStorage.Put(ctx, document.StorageKey, file.Content, file.MimeType); err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+	if err := documentRepo.CreateDocument(ctx, document); err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
+	}
+	return document, nil
+}
+
+// notifyDocumentFailure avisa al cliente que el documento no se pudo generar
+func notifyDocumentFailure(hub *WebSocketHub, client *models.Client, bot *models.Bot, recipient, sessionID string) {
+	message := models.Message{
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		Sender:    models.MessageSenderBot,
+		Type:      models.MessageTypeText,
+		Text:      "😕 No pudimos generar tu documento en este momento. Intenta de nuevo más tarde.",
+		SessionID: sessionID,
+	}
+	if err := deliverMessage(hub, bot.Number, recipient, message); err != nil {
+		log.Printf("Failed to notify document failure: %v", err)
+	}
+}
+
+// documentDownloadPath ruta pública de descarga; es lo que cubre la firma
+func documentDownloadPath(id string) string {
+	return "/documents/" + id + "/download"
+}
+
+// signDocumentURL arma el enlace de descarga firmado que vence en expiresAt
+func signDocumentURL(document *models.Document, expiresAt time.Time) (string, time.Time) {
+	return signDownloadURL(documentDownloadPath(document.ID.Hex()), document.Checksum, expiresAt)
+}
+
+// signDownloadURL firma una ruta pública de descarga junto con el checksum del archivo;
+// vacío si no hay firmador configurado
+func signDownloadURL(path, checksum string, expiresAt time.Time) (string, time.Time) {
+	if documentSigner == nil {
+		return "", time.Time{}
+	}
+	return documentBaseURL + documentSigner.SignFor(path, checksum, expiresAt), expiresAt
+}
+
+// verifyDownloadURL comprueba la firma del enlace de la petición para el archivo con ese
+// checksum; responde con error si no es válida
+func verifyDownloadURL(c *gin.Context, path, checksum string) bool {
+	if documentSigner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return false
+	}
+	err := documentSigner.VerifyFor(path, checksum, c.Query("expires"), c.Query("signature"), time.Now())
+	if errors.Is(err, services.ErrURLExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": "El enlace de descarga venció"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Enlace de descarga inválido"})
+		return false
+	}
+	return true
+}
+
+// ListClientDocuments lista los documentos generados para un cliente
+// @Summary Documentos de un cliente
+// @Description Documentos generados para el cliente, los más recientes primero
+// @Tags documentos
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Documentos por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/documents/client/{client_id} [get]
+func ListClientDocuments(c *gin.Context) {
+	clientID, err := strconv.ParseUint(c.Param("client_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de cliente inválido"})
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	documents, err := documentRepo.ListDocumentsByClient(c.Request.Context(), uint(clientID), (page-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando documentos", "details": err.Error()})
+		return
+	}
+	expiresAt := time.Now().Add(defaultDocumentURLTTL)
+	for i := range documents {
+		documents[i].URL, _ = signDocumentURL(&documents[i], expiresAt)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"documents": documents,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetDocument devuelve los datos de un documento con un enlace de descarga de 15 minutos
+// @Summary Obtener documento
+// @Tags documentos
+// @Produce json
+// @Param id path string true "ID del documento"
+// @Success 200 {object} models.Document
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/documents/{id} [get]
+func GetDocument(c *gin.Context) {
+	document, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	document.URL, _ = signDocumentURL(document, time.Now().Add(defaultDocumentURLTTL))
+	c.JSON(http.StatusOK, document)
+}
+
+// GetDocumentURL emite un enlace de descarga firmado que vence
+// @Summary Enlace de descarga firmado
+// @Description El enlace no requiere token de sesión y deja de funcionar al vencer
+// @Tags documentos
+// @Produce json
+// @Param id path string true "ID del documento"
+// @Param ttl query string false "Vigencia como duración (ej: 30m, 2h; por defecto 15m, máximo 24h)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/documents/{id}/url [get]
+func GetDocumentURL(c *gin.Context) {
+	ttl := defaultDocumentURLTTL
+	if raw := c.Query("ttl"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxDocumentURLTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vigencia inválida, usa una duración entre 1s y 24h (ej: 30m)"})
+			return
+		}
+		ttl = parsed
+	}
+
+	document, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	url, expiresAt := signDocumentURL(document, time.Now().Add(ttl))
+	if url == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Firmado de enlaces no configurado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": expiresAt.UTC().Truncate(time.Second),
+	})
+}
+
+// DownloadDocument descarga el archivo de un documento desde el dashboard, verificando su checksum
+// @Summary Descargar documento
+// @Tags documentos
+// @Produce application/pdf
+// @Param id path string true "ID del documento"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/documents/{id}/file [get]
+func DownloadDocument(c *gin.Context) {
+	document, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	serveDocument(c, document)
+}
+
+// DownloadPublicDocument descarga un documento con un enlace firmado; lo usa Baileys
+// para adjuntar el archivo en WhatsApp. La firma cubre la ruta, el vencimiento y el
+// checksum del archivo.
+// @Summary Descarga con enlace firmado
+// @Tags documentos
+// @Produce application/pdf
+// @Param id path string true "ID del documento"
+// @Param expires query int true "Vencimiento del enlace (unix)"
+// @Param signature query string true "Firma del enlace"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /documents/{id}/download [get]
+func DownloadPublicDocument(c *gin.Context) {
+	document, err := documentRepo.GetDocumentByID(c.Request.Context(), c.Param("id"))
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el documento", "details": err.Error()})
+		return
+	}
+	// La firma cubre el checksum; sin documento se verifica igual para no revelar qué ids existen
+	var checksum string
+	if document != nil {
+		checksum = document.Checksum
+	}
+	if !verifyDownloadURL(c, documentDownloadPath(c.Param("id")), checksum) {
+		return
+	}
+	if document == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Documento no encontrado"})
+		return
+	}
+	serveDocument(c, document)
+}
+
+// loadDocument carga el documento de la ruta; responde con error si no existe
+func loadDocument(c *gin.Context) (*models.Document, bool) {
+	document, err := documentRepo.GetDocumentByID(c.Request.Context(), c.Param("id"))
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Documento no encontrado"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el documento", "details": err.Error()})
+		return nil, false
+	}
+	return document, true
+}
+
+// serveDocument envía el archivo solo si coincide con el checksum registrado al generarlo
+func serveDocument(c *gin.Context, document *models.Document) {
+	serveStoredFile(c, documentStorage, document.StorageKey, document.Checksum, document.FileName, document.MimeType)
+}
+
+// serveStoredFile lee el archivo del almacenamiento, verifica su checksum y lo envía como adjunto
+func serveStoredFile(c *gin.Context, storage services.FileStorage, key, checksum, fileName, mimeType string) {
+	content, err := services.ReadVerified(c.Request.Context(), storage, key, checksum, maxStoredFileSize)
+	switch {
+	case errors.Is(err, services.ErrObjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "El archivo no existe en el almacenamiento"})
+		return
+	case errors.Is(err, services.ErrChecksumMismatch):
+		log.Printf("🚨 %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "El archivo no pasó la verificación de integridad"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo el archivo", "details": err.Error()})
+		return
+	}
+
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, mimeType, content)
+}
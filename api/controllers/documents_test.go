@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+type fakeManifestGenerator struct {
+	received []services.ManifestData
+}
+
+func (g *fakeManifestGenerator) GenerateManifest(ctx context.Context, data services.ManifestData) (*services.GeneratedFile, error) {
+	g.received = append(g.received, data)
+	return &services.GeneratedFile{FileName: "manifiesto.pdf", MimeType: "application/pdf", Content: []byte("%PDF-1.4")}, nil
+}
+
+func TestCompletedFormGeneratesAndSendsManifest(t *testing.T) {
+	documents := map[string]*models.Document{}
+
+	m := setupInboundMocks(t)
+	SetDocumentRepo(&mocks.MockDocumentRepo{
+		CreateDocumentFunc: func(ctx context.Context, document *models.Document) error {
+			documents[document.ID.Hex()] = document
+			return nil
+		},
+		GetDocumentByIDFunc: func(ctx context.Context, id string) (*models.Document, error) {
+			if document, ok := documents[id]; ok {
+				return document, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+	})
+	storage, err := services.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	generator := &fakeManifestGenerator{}
+	SetDocumentPipeline(generator, storage, services.NewURLSigner([]byte("secreto-de-prueba")), "http://api:8080/")
+	t.Cleanup(func() { SetDocumentPipeline(nil, nil, nil, "") })
+
+	m.Engine.Script("cali", services.NLUResponse{
+		Text: "🔄 Procesando manifiesto...",
+		Custom: map[string]interface{}{"document": map[string]interface{}{
+			"type": "manifiesto",
+			"data": map[string]interface{}{
+				"flete": "150000", "peso": "500 kg", "fecha_cargue": "2024-05-01", "fecha_descargue": "2024-05-02",
+				"tarjeta": "ABC123", "licencia": "987", "origen": "Bogotá", "destino": "Cali",
+			},
+		}},
+	})
+	err = processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "cali",
+		BotNumber: "573009998877",
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	if !assert.Len(t, generator.received, 1) || !assert.Len(t, documents, 1) || !assert.Len(t, m.Saved, 3) {
+		return
+	}
+	assert.Equal(t, "Bogotá", generator.received[0].Origen)
+	assert.Equal(t, "🔄 Procesando manifiesto...", m.Saved[1].Text)
+	assert.Nil(t, m.Saved[1].Custom)
+
+	var document *models.Document
+	for _, d := range documents {
+		document = d
+	}
+	assert.Equal(t, models.DocumentTypeManifiesto, document.Type)
+	assert.Equal(t, uint(7), document.ClientID)
+	assert.Equal(t, services.Checksum([]byte("%PDF-1.4")), document.Checksum)
+	assert.Equal(t, services.StorageBackendLocal, document.Storage)
+
+	sent := m.Saved[2]
+	assert.Equal(t, models.MessageTypeDocument, sent.Type)
+	if !assert.Len(t, sent.Attachments, 1) {
+		return
+	}
+	link := sent.Attachments[0].URL
+	assert.True(t, strings.HasPrefix(link, "http://api:8080/documents/"+document.ID.Hex()+"/download?expires="))
+
+	// El enlace firmado funciona sin sesión; uno alterado no
+	router := gin.New()
+	router.GET("/documents/:id/download", DownloadPublicDocument)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(link, "http://api:8080"), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Equal(t, "%PDF-1.4", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/documents/"+document.ID.Hex()+"/download?expires=9999999999&signature=00", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// La firma cubre el checksum: un enlace firmado para otro contenido no sirve, y un id
+	// inexistente responde igual que un enlace inválido
+	signer := services.NewURLSigner([]byte("secreto-de-prueba"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signer.SignFor(documentDownloadPath(document.ID.Hex()), services.Checksum([]byte("otro")), time.Now().Add(time.Hour)), nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/documents/"+primitive.NewObjectID().Hex()+"/download?expires=9999999999&signature=00", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Un archivo modificado en el almacenamiento no se sirve
+	assert.NoError(t, storage.Put(context.Background(), document.StorageKey, []byte("%PDF-alterado"), "application/pdf"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(link, "http://api:8080"), nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetDocumentURLIssuesExpiringLink(t *testing.T) {
+	document := &models.Document{ID: primitive.NewObjectID(), ClientID: 7, FileName: "manifiesto.pdf"}
+	SetDocumentRepo(&mocks.MockDocumentRepo{
+		GetDocumentByIDFunc: func(ctx context.Context, id string) (*models.Document, error) {
+			if id == document.ID.Hex() {
+				return document, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+	})
+	signer := services.NewURLSigner([]byte("secreto-de-prueba"))
+	SetDocumentPipeline(nil, nil, signer, "http://api:8080")
+	t.Cleanup(func() { SetDocumentPipeline(nil, nil, nil, "") })
+
+	router := gin.New()
+	router.GET("/api/v1/documents/:id/url", GetDocumentURL)
+	router.GET("/documents/:id/download", DownloadPublicDocument)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/documents/"+document.ID.Hex()+"/url?ttl=2h", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), body.ExpiresAt, time.Minute)
+	assert.True(t, strings.HasPrefix(body.URL, "http://api:8080/documents/"+document.ID.Hex()+"/download?"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/documents/"+document.ID.Hex()+"/url?ttl=720h", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Un enlace vencido responde 410
+	expired := signer.Sign("/documents/"+document.ID.Hex()+"/download", time.Now().Add(-time.Minute))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expired, nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+func TestManifestJobRetryReusesStoredDocument(t *testing.T) {
+	var saved []models.Message
+	stored := &models.Document{ID: primitive.NewObjectID(), ClientID: 7, JobID: 42, FileName: "manifiesto.pdf", MimeType: "application/pdf"}
+	SetConversationRepo(&mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			saved = append(saved, message)
+			return nil
+		},
+		UpdateMessageStatusFunc: func(ctx context.Context, update models.MessageStatusUpdate) error {
+			return nil
+		},
+	})
+	SetDocumentRepo(&mocks.MockDocumentRepo{
+		GetDocumentByJobIDFunc: func(ctx context.Context, jobID uint) (*models.Document, error) {
+			if jobID == stored.JobID {
+				return stored, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+		CreateDocumentFunc: func(ctx context.Context, document *models.Document) error {
+			t.Fatal("el reintento no debe guardar otro documento")
+			return nil
+		},
+	})
+	storage, err := services.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	generator := &fakeManifestGenerator{}
+	SetDocumentPipeline(generator, storage, services.NewURLSigner([]byte("secreto-de-prueba")), "http://api:8080")
+	t.Cleanup(func() { SetDocumentPipeline(nil, nil, nil, "") })
+
+	data := services.ManifestData{
+		Flete: "150000", Peso: "500 kg", FechaCargue: "2024-05-01", FechaDescargue: "2024-05-02",
+		Tarjeta: "ABC123", Licencia: "987", Origen: "Bogotá", Destino: "Cali",
+	}
+	client := &models.Client{ID: 7, Phone: "573001112233"}
+	bot := &models.Bot{ID: 3, Number: "573009998877"}
+	document, err := generateManifest(context.Background(), NewWebSocketHub(), client, bot, client.Phone, "", stored.JobID, data)
+	assert.NoError(t, err)
+	assert.Equal(t, stored.ID, document.ID)
+	assert.Empty(t, generator.received, "no se vuelve a generar el PDF")
+	if assert.Len(t, saved, 1) && assert.Len(t, saved[0].Attachments, 1) {
+		assert.Contains(t, saved[0].Attachments[0].URL, "/documents/"+stored.ID.Hex()+"/download")
+	}
+}
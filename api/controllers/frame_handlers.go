@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// FrameHandler procesa un frame recibido desde la conexión de un bot
+type FrameHandler func(hub *WebSocketHub, botConn *BotConnection, env Envelope) error
+
+// HandleFrame registra (o reemplaza) el manejador de un tipo de frame
+func (h *WebSocketHub) HandleFrame(frameType string, handler FrameHandler) {
+	h.handlersMu.Lock()
+	defer h.handlersMu.Unlock()
+	h.handlers[frameType] = handler
+}
+
+// Dispatch decodifica un frame crudo y lo entrega al manejador de su tipo.
+// Los frames del protocolo actual reciben ack o error; los del formato anterior no.
+func (h *WebSocketHub) Dispatch(botConn *BotConnection, data []byte) {
+	env, legacy, err := decodeFrame(data)
+	if err != nil {
+		log.Printf("Frame inválido del bot %s: %v", botConn.Phone, err)
+		h.replyError(botConn, "", ErrorCodeInvalidFrame, err.Error())
+		return
+	}
+
+	if env.Version > ProtocolVersion {
+		h.replyError(botConn, env.ID, ErrorCodeUnsupportedVersion,
+			fmt.Sprintf("version %d not supported (max %d)", env.Version, ProtocolVersion))
+		return
+	}
+
+	h.handlersMu.RLock()
+	handler, ok := h.handlers[env.Type]
+	h.handlersMu.RUnlock()
+	if !ok {
+		h.replyError(botConn, env.ID, ErrorCodeUnsupportedType, fmt.Sprintf("frame type %q not supported", env.Type))
+		return
+	}
+
+	// Los mensajes entrantes pasan por el NLU y la descarga de media: se procesan fuera
+	// del goroutine lector para que no frenen los pongs ni los acks del bot
+	if env.Type == FrameInboundMessage {
+		if err := botConn.Process(func() { h.handle(handler, botConn, env, legacy) }); err != nil {
+			log.Printf("Failed to queue %s frame from bot %s: %v", env.Type, botConn.Phone, err)
+			if !legacy {
+				h.replyError(botConn, env.ID, ErrorCodeProcessingFailed, err.Error())
+			}
+		}
+		return
+	}
+	h.handle(handler, botConn, env, legacy)
+}
+
+// handle ejecuta el manejador del frame y responde con ack o error
+func (h *WebSocketHub) handle(handler FrameHandler, botConn *BotConnection, env Envelope, legacy bool) {
+	if err := handler(h, botConn, env); err != nil {
+		log.Printf("Error processing %s frame from bot %s: %v", env.Type, botConn.Phone, err)
+		if !legacy {
+			h.replyError(botConn, env.ID, ErrorCodeProcessingFailed, err.Error())
+		}
+		return
+	}
+
+	if !legacy && env.Type != FrameAck && env.Type != FrameError {
+		h.reply(botConn, FrameAck, AckPayload{Ref: env.ID})
+	}
+}
+
+func (h *WebSocketHub) reply(botConn *BotConnection, frameType string, payload interface{}) {
+	env, err := NewEnvelope(frameType, payload)
+	if err != nil {
+		log.Printf("Failed to build %s frame: %v", frameType, err)
+		return
+	}
+	if err := botConn.SendJSON(env); err != nil {
+		log.Printf("Failed to send %s frame to bot %s: %v", frameType, botConn.Phone, err)
+	}
+}
+
+func (h *WebSocketHub) replyError(botConn *BotConnection, ref, code, message string) {
+	h.reply(botConn, FrameError, ErrorPayload{Ref: ref, Code: code, Message: message})
+}
+
+// registerDefaultFrameHandlers registra los manejadores del protocolo
+func registerDefaultFrameHandlers(h *WebSocketHub) {
+	h.HandleFrame(FrameInboundMessage, handleInboundMessageFrame)
+	h.HandleFrame(FrameAck, handleAckFrame)
+	h.HandleFrame(FrameError, handleErrorFrame)
+	h.HandleFrame(FrameReceipt, handleReceiptFrame)
+	h.HandleFrame(FramePresence, handlePresenceFrame)
+	h.HandleFrame(FrameQRUpdate, handleQRUpdateFrame)
+	h.HandleFrame(FrameConnectionState, handleConnectionStateFrame)
+}
+
+func handleInboundMessageFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload InboundMessagePayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+
+	botNumber := payload.BotNumber
+	if botNumber == "" {
+		botNumber = botConn.Phone
+	}
+
+	return processIncomingMessage(IncomingMessageRequest{
+		Phone:     payload.From,
+		Message:   payload.Text,
+		BotNumber: botNumber,
+		Payload:   payload.Payload,
+		Media:     payload.Media,
+	}, hub)
+}
+
+func handleAckFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload AckPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	updateMessageStatus(models.MessageStatusUpdate{
+		OutboundID: payload.Ref,
+		WhatsAppID: payload.WhatsAppID,
+		Status:     models.MessageStatusSent,
+		At:         time.Now(),
+	})
+	return hub.ackOutbound(payload)
+}
+
+func handleErrorFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload ErrorPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	log.Printf("⚠️  Bot %s reportó error [%s] para frame %s: %s", botConn.Phone, payload.Code, payload.Ref, payload.Message)
+	if payload.Ref == "" {
+		return nil
+	}
+	return hub.failOutbound(payload.Ref, payload.Message)
+}
+
+func handleReceiptFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload ReceiptPayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	switch payload.Status {
+	case models.MessageStatusDelivered, models.MessageStatusRead, models.MessageStatusFailed:
+	default:
+		return fmt.Errorf("unsupported receipt status %q", payload.Status)
+	}
+
+	at := payload.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	log.Printf("📬 Recibo de bot %s: mensaje %s (%s) %s", botConn.Phone, payload.Ref, payload.WhatsAppID, payload.Status)
+	return applyMessageStatus(models.MessageStatusUpdate{
+		OutboundID: payload.Ref,
+		WhatsAppID: payload.WhatsAppID,
+		Status:     payload.Status,
+		At:         at,
+		Error:      payload.Error,
+	})
+}
+
+func handlePresenceFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload PresencePayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	log.Printf("👤 Presencia en bot %s: %s %s", botConn.Phone, payload.Jid, payload.Presence)
+	return nil
+}
+
+func handleQRUpdateFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload QRUpdatePayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	botConn.updateState(func(state *BotState) {
+		now := time.Now()
+		state.QRCode = payload.QRCode
+		state.QRImage = payload.QRImage
+		state.QRUpdatedAt = &now
+	})
+	log.Printf("📱 Nuevo QR para bot %s", botConn.Phone)
+	hub.PublishDashboard(DashboardEventQRUpdated, botConn.Phone, payload)
+	return nil
+}
+
+func handleConnectionStateFrame(hub *WebSocketHub, botConn *BotConnection, env Envelope) error {
+	var payload ConnectionStatePayload
+	if err := env.DecodePayload(&payload); err != nil {
+		return err
+	}
+	botConn.updateState(func(state *BotState) {
+		state.Connection = payload.State
+		state.Number = payload.Number
+		state.Name = payload.Name
+		state.Reason = payload.Reason
+		if payload.State == "open" {
+			// Sesión vinculada: el QR ya no sirve
+			state.QRCode = ""
+			state.QRImage = ""
+			state.QRUpdatedAt = nil
+		}
+	})
+	log.Printf("📡 Bot %s: conexión %s", botConn.Phone, payload.State)
+	hub.PublishDashboard(DashboardEventBotState, botConn.Phone, botConn.State())
+	return nil
+}
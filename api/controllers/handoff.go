@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// ConversationEvent datos de una conversación publicados al dashboard
+type ConversationEvent struct {
+	ClientID   uint            `json:"client_id"`
+	BotID      uint            `json:"bot_id"`
+	Phone      string          `json:"phone"`
+	Mode       string          `json:"mode,omitempty"`
+	OperatorID uint            `json:"operator_id,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Message    *models.Message `json:"message,omitempty"`
+}
+
+// ConversationModeRequest cuerpo para cambiar el modo de atención
+type ConversationModeRequest struct {
+	Mode   string `json:"mode" binding:"required"` // bot, human o paused
+	Reason string `json:"reason"`
+}
+
+// OperatorReplyRequest cuerpo para responder como operador
+type OperatorReplyRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// escalateConversation pasa la conversación a modo human a pedido del bot
+func escalateConversation(hub *WebSocketHub, client *models.Client, bot *models.Bot, phone, reason string) error {
+	change := models.ConversationModeChange{
+		Mode:   models.ConversationModeHuman,
+		Reason: reason,
+		At:     time.Now(),
+	}
+	if err := conversationRepo.SetConversationMode(context.TODO(), client.ID, bot.ID, change); err != nil {
+		return err
+	}
+
+	log.Printf("🙋 Bot %s escaló la conversación con %s a un operador: %s", bot.Number, phone, reason)
+	hub.PublishDashboard(DashboardEventModeChanged, bot.Number, ConversationEvent{
+		ClientID: client.ID,
+		BotID:    bot.ID,
+		Phone:    phone,
+		Mode:     change.Mode,
+		Reason:   reason,
+	})
+	return nil
+}
+
+// UpdateConversationMode cambia el modo de atención de una conversación
+// @Summary Cambiar modo de atención
+// @Description Pasa la conversación a bot, human (atiende un operador) o paused (nadie responde)
+// @Tags conversaciones
+// @Accept json
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param bot_id path int true "ID del bot"
+// @Param data body ConversationModeRequest true "Nuevo modo"
+// @Success 200 {object} ConversationEvent
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/{client_id}/bots/{bot_id}/mode [put]
+func UpdateConversationMode(c *gin.Context, hub *WebSocketHub) {
+	var req ConversationModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+	if !models.IsValidConversationMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Modo inválido, use bot, human o paused"})
+		return
+	}
+
+	client, bot, ok := conversationParties(c)
+	if !ok {
+		return
+	}
+
+	operatorID := currentOperatorID(c)
+	change := models.ConversationModeChange{Mode: req.Mode, Reason: req.Reason, At: time.Now()}
+	if req.Mode == models.ConversationModeHuman {
+		change.OperatorID = operatorID
+	}
+	if err := conversationRepo.SetConversationMode(c.Request.Context(), client.ID, bot.ID, change); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando la conversación", "details": err.Error()})
+		return
+	}
+
+	event := ConversationEvent{
+		ClientID:   client.ID,
+		BotID:      bot.ID,
+		Phone:      client.Phone,
+		Mode:       change.Mode,
+		OperatorID: operatorID,
+		Reason:     change.Reason,
+	}
+	log.Printf("🔀 Operador %d cambió la conversación con %s a modo %s", operatorID, client.Phone, change.Mode)
+	hub.PublishDashboard(DashboardEventModeChanged, bot.Number, event)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// ReplyToConversation envía al cliente un mensaje escrito por un operador.
+// Si la conversación estaba en modo bot, el operador la toma para que el bot no responda en paralelo.
+// @Summary Responder como operador
+// @Description Envía un mensaje al cliente a través del bot conectado y lo guarda en la conversación
+// @Tags conversaciones
+// @Accept json
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param bot_id path int true "ID del bot"
+// @Param data body OperatorReplyRequest true "Mensaje del operador"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/{client_id}/bots/{bot_id}/reply [post]
+func ReplyToConversation(c *gin.Context, hub *WebSocketHub) {
+	var req OperatorReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+
+	client, bot, ok := conversationParties(c)
+	if !ok {
+		return
+	}
+	operatorID := currentOperatorID(c)
+
+	mode, err := conversationRepo.GetConversationMode(c.Request.Context(), client.ID, bot.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando la conversación", "details": err.Error()})
+		return
+	}
+	if mode == models.ConversationModeBot {
+		change := models.ConversationModeChange{
+			Mode:       models.ConversationModeHuman,
+			OperatorID: operatorID,
+			Reason:     "respuesta de operador",
+			At:         time.Now(),
+		}
+		if err := conversationRepo.SetConversationMode(c.Request.Context(), client.ID, bot.ID, change); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando la conversación", "details": err.Error()})
+			return
+		}
+		mode = change.Mode
+		hub.PublishDashboard(DashboardEventModeChanged, bot.Number, ConversationEvent{
+			ClientID:   client.ID,
+			BotID:      bot.ID,
+			Phone:      client.Phone,
+			Mode:       mode,
+			OperatorID: operatorID,
+			Reason:     change.Reason,
+		})
+	}
+
+	message := models.Message{
+		ClientID:   client.ID,
+		BotID:      bot.ID,
+		Sender:     models.MessageSenderOperator,
+		OperatorID: operatorID,
+		Type:       models.MessageTypeText,
+		Text:       req.Message,
+	}
+	if err := deliverMessage(hub, bot.Number, client.Phone, message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando el mensaje", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Mensaje enviado",
+		"mode":    mode,
+	})
+}
+
+// conversationParties carga el cliente y el bot de la ruta; responde con error si no existen
+func conversationParties(c *gin.Context) (*models.Client, *models.Bot, bool) {
+	clientID, err := strconv.ParseUint(c.Param("client_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de cliente inválido"})
+		return nil, nil, false
+	}
+	botID, err := strconv.ParseUint(c.Param("bot_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de bot inválido"})
+		return nil, nil, false
+	}
+
+	client, err := clientRepo.GetClientByID(uint(clientID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cliente no encontrado"})
+		return nil, nil, false
+	}
+	bot, err := botRepo.GetBotByID(uint(botID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bot no encontrado"})
+		return nil, nil, false
+	}
+	return client, bot, true
+}
+
+// currentOperatorID id del usuario del sistema autenticado (0 si no hay)
+func currentOperatorID(c *gin.Context) uint {
+	userID, _ := c.Get("current_user_id")
+	id, _ := userID.(uint)
+	return id
+}
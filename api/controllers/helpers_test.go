@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+// inboundMocks repositorios en memoria por los que pasa un mensaje entrante o saliente.
+// Client y Bot son los que devuelven los repositorios (con el teléfono y el número de la
+// solicitud) y Mode el modo de la conversación; se pueden ajustar antes de procesar.
+type inboundMocks struct {
+	Client        models.Client
+	Bot           models.Bot
+	Mode          string
+	Saved         []models.Message
+	Updates       []models.MessageStatusUpdate
+	Conversations *mocks.MockConversationRepo
+	Engine        *services.MemoryEngine
+}
+
+// setupInboundMocks configura el cliente 7, el bot 3 con el motor NLU en memoria y una
+// conversación en modo bot que guarda los mensajes y estados en memoria
+func setupInboundMocks(t *testing.T) *inboundMocks {
+	t.Helper()
+	m := &inboundMocks{
+		Client: models.Client{ID: 7},
+		Bot:    models.Bot{ID: 3, Active: true, NLUEngine: services.NLUEngineMemory},
+		Mode:   models.ConversationModeBot,
+		Engine: services.NewMemoryEngine(),
+	}
+	m.Conversations = &mocks.MockConversationRepo{
+		SaveMessageFunc: func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+			m.Saved = append(m.Saved, message)
+			return nil
+		},
+		GetConversationModeFunc: func(ctx context.Context, userID uint, botID uint) (string, error) {
+			return m.Mode, nil
+		},
+		UpdateMessageStatusFunc: func(ctx context.Context, update models.MessageStatusUpdate) error {
+			m.Updates = append(m.Updates, update)
+			return nil
+		},
+	}
+
+	SetClientRepo(&mocks.MockClientRepo{
+		GetOrCreateClientFunc: func(phone, name, email string) (*models.Client, error) {
+			client := m.Client
+			client.Phone = phone
+			return &client, nil
+		},
+	})
+	SetBotRepo(&mocks.MockBotRepo{
+		GetBotByNumberFunc: func(number string) (*models.Bot, error) {
+			bot := m.Bot
+			bot.Number = number
+			return &bot, nil
+		},
+	})
+	SetConversationRepo(m.Conversations)
+	registry := services.NewNLURegistry(services.NLUEngineMemory)
+	registry.Register(m.Engine)
+	SetNLURegistry(registry)
+	return m
+}
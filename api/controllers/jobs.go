@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+var (
+	jobRepo  repositories.JobRepository
+	jobQueue *services.JobQueue
+)
+
+// ManifestJobPayload datos del trabajo que genera y envía un manifiesto
+type ManifestJobPayload struct {
+	ClientID  uint                  `json:"client_id"`
+	BotID     uint                  `json:"bot_id"`
+	Recipient string                `json:"recipient"` // JID o teléfono del cliente en WhatsApp
+	SessionID string                `json:"session_id,omitempty"`
+	Data      services.ManifestData `json:"data"`
+}
+
+// ManifestJobResult resultado de un trabajo de manifiesto
+type ManifestJobResult struct {
+	DocumentID string `json:"document_id"`
+}
+
+func SetJobRepo(repo repositories.JobRepository) {
+	jobRepo = repo
+}
+
+// SetJobQueue activa la cola de trabajos y registra sus manejadores. Sin cola, los
+// documentos se generan en el momento y no se pueden enviar campañas.
+func SetJobQueue(queue *services.JobQueue, hub *WebSocketHub) {
+	jobQueue = queue
+	if queue != nil {
+		queue.Register(models.JobTypeManifest, &manifestJob{hub: hub})
+		queue.Register(models.JobTypeCampaign, &campaignJob{hub: hub})
+	}
+}
+
+// enqueueManifest encola la generación del manifiesto
+func enqueueManifest(client *models.Client, bot *models.Bot, recipient, sessionID string, data services.ManifestData) (*models.Job, error) {
+	return jobQueue.Enqueue(models.JobTypeManifest, ManifestJobPayload{
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		Recipient: recipient,
+		SessionID: sessionID,
+		Data:      data,
+	}, services.JobOptions{ClientID: client.ID, BotID: bot.ID})
+}
+
+// manifestJob genera el manifiesto en un worker y se lo envía al cliente
+type manifestJob struct {
+	hub *WebSocketHub
+}
+
+func (m *manifestJob) RunJob(ctx context.Context, job *models.Job, logger services.JobLogger) (interface{}, error) {
+	payload, client, bot, err := loadManifestJob(job)
+	if err != nil {
+		return nil, services.PermanentJobError(err)
+	}
+	if err := payload.Data.Validate(); err != nil {
+		return nil, services.PermanentJobError(err)
+	}
+
+	logger.Logf("Generando manifiesto para %s (%s → %s)", client.Phone, payload.Data.Origen, payload.Data.Destino)
+	document, err := generateManifest(ctx, m.hub, client, bot, payload.Recipient, payload.SessionID, job.ID, payload.Data)
+	if err != nil {
+		return nil, err
+	}
+	logger.Logf("Documento %s enviado a %s", document.ID.Hex(), client.Phone)
+
+	return ManifestJobResult{DocumentID: document.ID.Hex()}, nil
+}
+
+// JobFailed avisa al cliente cuando se agotaron los intentos
+func (m *manifestJob) JobFailed(job *models.Job, err error) {
+	payload, client, bot, loadErr := loadManifestJob(job)
+	if loadErr != nil {
+		log.Printf("Failed to notify failure of job %d: %v", job.ID, loadErr)
+		return
+	}
+	notifyDocumentFailure(m.hub, client, bot, payload.Recipient, payload.SessionID)
+}
+
+func loadManifestJob(job *models.Job) (*ManifestJobPayload, *models.Client, *models.Bot, error) {
+	var payload ManifestJobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, nil, nil, err
+	}
+	client, err := clientRepo.GetClientByID(payload.ClientID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bot, err := botRepo.GetBotByID(payload.BotID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &payload, client, bot, nil
+}
+
+// ListJobs lista los trabajos en segundo plano
+// @Summary Listar trabajos
+// @Description Trabajos más recientes primero; se pueden filtrar por estado, tipo y cliente
+// @Tags trabajos
+// @Produce json
+// @Param status query string false "queued, running, succeeded, failed o canceled"
+// @Param type query string false "Tipo de trabajo (ej: document.manifiesto)"
+// @Param client_id query int false "ID del cliente"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Trabajos por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/jobs [get]
+func ListJobs(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.JobStatusQueued, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusCanceled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Estado inválido"})
+		return
+	}
+	clientID, ok := queryUint(c, "client_id")
+	if !ok {
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	jobs, total, err := jobRepo.ListJobs(models.JobFilter{
+		Status:   status,
+		Type:     c.Query("type"),
+		ClientID: clientID,
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando trabajos", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":      jobs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetJob devuelve un trabajo con su registro de ejecución
+// @Summary Obtener trabajo
+// @Tags trabajos
+// @Produce json
+// @Param id path int true "ID del trabajo"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/jobs/{id} [get]
+func GetJob(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := jobRepo.GetJob(id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancela un trabajo en cola o en ejecución
+// @Summary Cancelar trabajo
+// @Tags trabajos
+// @Produce json
+// @Param id path int true "ID del trabajo"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/jobs/{id}/cancel [post]
+func CancelJob(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	if err := jobQueue.Cancel(id); err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Trabajo cancelado"})
+}
+
+// RerunJob vuelve a encolar un trabajo terminado
+// @Summary Re-ejecutar trabajo
+// @Description Vuelve a encolar un trabajo exitoso, fallido o cancelado con los intentos en cero
+// @Tags trabajos
+// @Produce json
+// @Param id path int true "ID del trabajo"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/jobs/{id}/rerun [post]
+func RerunJob(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := jobQueue.Rerun(id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func jobIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de trabajo inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trabajo no encontrado"})
+	case errors.Is(err, services.ErrJobNotCancelable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Solo se pueden cancelar trabajos en cola o en ejecución"})
+	case errors.Is(err, services.ErrJobNotRerunnable):
+		c.JSON(http.StatusConflict, gin.H{"error": "El trabajo aún no ha terminado"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error procesando el trabajo", "details": err.Error()})
+	}
+}
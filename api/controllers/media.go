@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+const (
+	maxMediaSize      = 16 << 20 // WhatsApp limita imágenes y audios a 16MB
+	mediaFetchTimeout = 2 * time.Minute
+
+	// Intención con la que el motor NLU recibe un archivo; en Rasa la entidad "archivo"
+	// llena el slot que el formulario esté pidiendo (ej: tarjeta, licencia)
+	mediaIntent = "enviar_archivo"
+)
+
+var (
+	mediaRepo    repositories.MediaRepository
+	mediaStorage services.FileStorage
+	// mediaSource origen (esquema y host) de Baileys, el único del que se descargan adjuntos
+	mediaSource     *url.URL
+	mediaHTTPClient = &http.Client{Timeout: mediaFetchTimeout, CheckRedirect: checkMediaRedirect}
+)
+
+func SetMediaRepo(repo repositories.MediaRepository) {
+	mediaRepo = repo
+}
+
+// SetMediaStorage fija dónde se guardan los archivos que envían los clientes y los que se suben para enviar
+func SetMediaStorage(storage services.FileStorage) {
+	mediaStorage = storage
+}
+
+// SetMediaSource fija la dirección de Baileys (BAILEYS_URL). Los adjuntos que no vienen
+// en línea solo se descargan de ese origen; sin ella solo se aceptan en línea.
+func SetMediaSource(baseURL string) error {
+	if baseURL == "" {
+		mediaSource = nil
+		return nil
+	}
+	source, err := url.Parse(baseURL)
+	if err != nil || (source.Scheme != "http" && source.Scheme != "https") || source.Host == "" {
+		return fmt.Errorf("invalid media source %q", baseURL)
+	}
+	mediaSource = source
+	return nil
+}
+
+// ingestMedia descarga el archivo del mensaje entrante, lo guarda y lo registra
+func ingestMedia(ctx context.Context, client *models.Client, bot *models.Bot, sessionID string, media *InboundMedia) (*models.MediaFile, error) {
+	if mediaRepo == nil || mediaStorage == nil {
+		return nil, errors.New("media storage not configured")
+	}
+	switch media.Type {
+	case models.MessageTypeImage, models.MessageTypeDocument, models.MessageTypeAudio:
+	default:
+		return nil, fmt.Errorf("unsupported media type %q", media.Type)
+	}
+
+	content, err := fetchMediaContent(ctx, media)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &models.MediaFile{
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		SessionID: sessionID,
+		Type:      media.Type,
+		MimeType:  media.MimeType,
+	}
+	if err := saveMedia(ctx, file, media.FileName, content); err != nil {
+		return nil, err
+	}
+	log.Printf("📎 Archivo %s (%s, %d bytes) recibido de %s", file.ID.Hex(), file.MimeType, file.Size, client.Phone)
+	return file, nil
+}
+
+// saveMedia guarda el contenido en el almacenamiento y registra el archivo con su checksum
+func saveMedia(ctx context.Context, file *models.MediaFile, fileName string, content []byte) error {
+	file.ID = primitive.NewObjectID()
+	file.Size = int64(len(content))
+	file.Checksum = services.Checksum(content)
+	file.Storage = mediaStorage.Name()
+	file.CreatedAt = time.Now()
+	if file.MimeType == "" {
+		file.MimeType = http.DetectContentType(content)
+	}
+	file.FileName = mediaFileName(fileName, file)
+
+	folder := "uploads"
+	if file.ClientID != 0 {
+		folder = fmt.Sprint(file.ClientID)
+	}
+	file.StorageKey = fmt.Sprintf("media/%s/%s%s", folder, file.ID.Hex(), filepath.Ext(file.FileName))
+
+	if err := mediaStorage.Put(ctx, file.StorageKey, content, file.MimeType); err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+	if err := mediaRepo.CreateMedia(ctx, file); err != nil {
+		return fmt.Errorf("failed to save media: %w", err)
+	}
+	return nil
+}
+
+// fetchMediaContent obtiene los bytes del adjunto: en línea (base64) o descargándolos de Baileys
+func fetchMediaContent(ctx context.Context, media *InboundMedia) ([]byte, error) {
+	if media.Size > maxMediaSize {
+		return nil, fmt.Errorf("media exceeds %d bytes", maxMediaSize)
+	}
+
+	if media.Data != "" {
+		content, err := base64.StdEncoding.DecodeString(media.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid media data: %w", err)
+		}
+		if len(content) > maxMediaSize {
+			return nil, fmt.Errorf("media exceeds %d bytes", maxMediaSize)
+		}
+		return content, nil
+	}
+
+	source, err := url.Parse(media.URL)
+	if err != nil || (source.Scheme != "http" && source.Scheme != "https") {
+		return nil, errors.New("media without data or valid url")
+	}
+	if !isMediaSource(source) {
+		return nil, fmt.Errorf("media url host %q is not the media source", source.Host)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media request: %w", err)
+	}
+	resp, err := mediaHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media download returned status %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	if len(content) > maxMediaSize {
+		return nil, fmt.Errorf("media exceeds %d bytes", maxMediaSize)
+	}
+	return content, nil
+}
+
+// isMediaSource indica si la dirección pertenece al origen configurado de Baileys
+func isMediaSource(target *url.URL) bool {
+	return mediaSource != nil &&
+		strings.EqualFold(target.Scheme, mediaSource.Scheme) &&
+		strings.EqualFold(target.Host, mediaSource.Host)
+}
+
+// checkMediaRedirect vuelve a validar el origen en cada redirección, para que Baileys
+// (o quien responda por él) no lleve la descarga a otro host
+func checkMediaRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !isMediaSource(req.URL) {
+		return fmt.Errorf("media redirect to %q is not the media source", req.URL.Host)
+	}
+	return nil
+}
+
+// mediaFileName nombre con el que se descarga el archivo; las fotos y notas de voz
+// de WhatsApp no traen nombre
+func mediaFileName(fileName string, file *models.MediaFile) string {
+	if name := filepath.Base(fileName); fileName != "" && name != "." && name != "/" {
+		return name
+	}
+	ext := ""
+	if mediaType, _, err := mime.ParseMediaType(file.MimeType); err == nil {
+		switch mediaType {
+		case "image/jpeg":
+			ext = ".jpg"
+		case "image/png":
+			ext = ".png"
+		case "image/webp":
+			ext = ".webp"
+		case "application/pdf":
+			ext = ".pdf"
+		case "audio/ogg":
+			ext = ".ogg"
+		case "audio/mpeg":
+			ext = ".mp3"
+		}
+	}
+	return file.Type + "-" + file.ID.Hex() + ext
+}
+
+// mediaAttachment adjunto del mensaje del cliente; la URL es la descarga autenticada del dashboard
+func mediaAttachment(file *models.MediaFile) models.MessageAttachment {
+	return models.MessageAttachment{
+		Type:     file.Type,
+		URL:      "/api/v1/media/" + file.ID.Hex() + "/file",
+		FileName: file.FileName,
+		MimeType: file.MimeType,
+		Size:     file.Size,
+		MediaID:  file.ID.Hex(),
+	}
+}
+
+// failedMediaAttachment adjunto de un archivo que no se pudo recibir; queda en el
+// historial para que el operador sepa que el cliente envió algo
+func failedMediaAttachment(media *InboundMedia) models.MessageAttachment {
+	return models.MessageAttachment{
+		Type:     media.Type,
+		FileName: media.FileName,
+		MimeType: media.MimeType,
+		Size:     media.Size,
+		Failed:   true,
+	}
+}
+
+// mediaNLUInput mensaje con el que el motor NLU recibe el archivo, en el formato de
+// intención con entidades de Rasa: /enviar_archivo{"archivo": "media:<id>", ...}
+func mediaNLUInput(file *models.MediaFile) string {
+	entities, _ := json.Marshal(map[string]string{
+		"archivo":      file.SlotValue(),
+		"archivo_tipo": file.Type,
+	})
+	return "/" + mediaIntent + string(entities)
+}
+
+// GetMedia devuelve los datos de un archivo enviado por un cliente
+// @Summary Obtener archivo recibido
+// @Tags archivos
+// @Produce json
+// @Param id path string true "ID del archivo"
+// @Success 200 {object} models.MediaFile
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/media/{id} [get]
+func GetMedia(c *gin.Context) {
+	file, ok := loadMedia(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// DownloadMedia descarga un archivo enviado por un cliente, verificando su checksum
+// @Summary Descargar archivo recibido
+// @Tags archivos
+// @Produce octet-stream
+// @Param id path string true "ID del archivo"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/media/{id}/file [get]
+func DownloadMedia(c *gin.Context) {
+	file, ok := loadMedia(c)
+	if !ok {
+		return
+	}
+	serveStoredFile(c, mediaStorage, file.StorageKey, file.Checksum, file.FileName, file.MimeType)
+}
+
+// UploadMedia sube un archivo para enviarlo después con POST /api/v1/whatsapp/send (media_id)
+// @Summary Subir archivo
+// @Description Imágenes, audios o documentos de hasta 16MB. El tipo se deduce del MIME.
+// @Tags archivos
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Archivo"
+// @Success 201 {object} models.MediaFile
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/media [post]
+func UploadMedia(c *gin.Context) {
+	if mediaRepo == nil || mediaStorage == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Almacenamiento de archivos no configurado"})
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Archivo requerido en el campo file", "details": err.Error()})
+		return
+	}
+	if header.Size > maxMediaSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "El archivo supera el máximo de 16MB"})
+		return
+	}
+	upload, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo", "details": err.Error()})
+		return
+	}
+	defer upload.Close()
+	content, err := io.ReadAll(io.LimitReader(upload, maxMediaSize+1))
+	if err != nil || len(content) > maxMediaSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(content)
+	}
+	file := &models.MediaFile{
+		Type:       mediaTypeForMime(mimeType),
+		MimeType:   mimeType,
+		UploadedBy: currentOperatorID(c),
+	}
+	if err := saveMedia(c.Request.Context(), file, header.Filename, content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando el archivo", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, file)
+}
+
+// DownloadSignedMedia descarga un archivo con un enlace firmado; lo usa Baileys para
+// adjuntar los archivos que se envían por WhatsApp. La firma cubre la ruta, el
+// vencimiento y el checksum del archivo.
+// @Summary Descarga de archivo con enlace firmado
+// @Tags archivos
+// @Produce octet-stream
+// @Param id path string true "ID del archivo"
+// @Param expires query int true "Vencimiento del enlace (unix)"
+// @Param signature query string true "Firma del enlace"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /media/{id}/download [get]
+func DownloadSignedMedia(c *gin.Context) {
+	file, err := mediaRepo.GetMediaByID(c.Request.Context(), c.Param("id"))
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el archivo", "details": err.Error()})
+		return
+	}
+	// La firma cubre el checksum; sin archivo se verifica igual para no revelar qué ids existen
+	var checksum string
+	if file != nil {
+		checksum = file.Checksum
+	}
+	if !verifyDownloadURL(c, mediaDownloadPath(c.Param("id")), checksum) {
+		return
+	}
+	if file == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+	serveStoredFile(c, mediaStorage, file.StorageKey, file.Checksum, file.FileName, file.MimeType)
+}
+
+// mediaDownloadPath ruta pública de descarga de un archivo; es lo que cubre la firma
+func mediaDownloadPath(id string) string {
+	return "/media/" + id + "/download"
+}
+
+// mediaTypeForMime tipo de mensaje de WhatsApp con el que se envía un archivo
+func mediaTypeForMime(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.MessageTypeImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return models.MessageTypeAudio
+	default:
+		return models.MessageTypeDocument
+	}
+}
+
+func loadMedia(c *gin.Context) (*models.MediaFile, bool) {
+	file, err := mediaRepo.GetMediaByID(c.Request.Context(), c.Param("id"))
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el archivo", "details": err.Error()})
+		return nil, false
+	}
+	return file, true
+}
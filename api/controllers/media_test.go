@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+func TestInboundMediaIsStoredAndSentToNLUAsSlotValue(t *testing.T) {
+	files := map[string]*models.MediaFile{}
+
+	m := setupInboundMocks(t)
+	SetMediaRepo(&mocks.MockMediaRepo{
+		CreateMediaFunc: func(ctx context.Context, media *models.MediaFile) error {
+			files[media.ID.Hex()] = media
+			return nil
+		},
+		GetMediaByIDFunc: func(ctx context.Context, id string) (*models.MediaFile, error) {
+			if media, ok := files[id]; ok {
+				return media, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+	})
+	storage, err := services.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	SetMediaStorage(storage)
+	t.Cleanup(func() { SetMediaStorage(nil) })
+
+	// Foto pequeña en línea
+	photo := []byte("\xff\xd8\xff\xe0 foto de la tarjeta")
+	err = processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		BotNumber: "573009998877",
+		Media: &InboundMedia{
+			Type:     models.MessageTypeImage,
+			MimeType: "image/jpeg",
+			Data:     base64.StdEncoding.EncodeToString(photo),
+		},
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	// PDF grande que el API descarga de Baileys
+	baileys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4 licencia"))
+	}))
+	defer baileys.Close()
+	assert.NoError(t, SetMediaSource(baileys.URL))
+	t.Cleanup(func() { SetMediaSource("") })
+	err = processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "mi licencia",
+		BotNumber: "573009998877",
+		Media: &InboundMedia{
+			Type:     models.MessageTypeDocument,
+			MimeType: "application/pdf",
+			FileName: "../licencia.pdf",
+			URL:      baileys.URL + "/media/abc",
+		},
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	if !assert.Len(t, files, 2) || !assert.Len(t, m.Saved, 4) {
+		return
+	}
+
+	image := m.Saved[0]
+	assert.Equal(t, models.MessageTypeImage, image.Type)
+	if !assert.Len(t, image.Attachments, 1) {
+		return
+	}
+	file := files[image.Attachments[0].MediaID]
+	assert.Equal(t, services.Checksum(photo), file.Checksum)
+	assert.Equal(t, "/api/v1/media/"+file.ID.Hex()+"/file", image.Attachments[0].URL)
+
+	document := m.Saved[2]
+	assert.Equal(t, models.MessageTypeDocument, document.Type)
+	assert.Equal(t, "mi licencia", document.Text)
+	assert.Equal(t, "licencia.pdf", document.Attachments[0].FileName)
+
+	// El motor recibe la referencia del archivo como entidad
+	received := m.Engine.Received()
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, `/enviar_archivo{"archivo":"media:`+file.ID.Hex()+`","archivo_tipo":"image"}`, received[0].Message)
+	}
+
+	// El dashboard descarga el archivo verificado
+	router := gin.New()
+	router.GET("/api/v1/media/:id/file", DownloadMedia)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, image.Attachments[0].URL, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, photo, w.Body.Bytes())
+}
+
+func TestInboundMediaRejectsUnsupportedOrOversized(t *testing.T) {
+	_, err := fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeImage, Size: maxMediaSize + 1})
+	assert.Error(t, err)
+
+	_, err = fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeImage, URL: "file:///etc/passwd"})
+	assert.Error(t, err)
+
+	storage, _ := services.NewLocalStorage(t.TempDir())
+	SetMediaRepo(&mocks.MockMediaRepo{})
+	SetMediaStorage(storage)
+	t.Cleanup(func() { SetMediaStorage(nil) })
+	_, err = ingestMedia(context.Background(), &models.Client{ID: 1}, &models.Bot{ID: 1}, "", &InboundMedia{Type: "sticker", Data: "eA=="})
+	assert.Error(t, err)
+}
+
+func TestInboundMediaOnlyDownloadsFromBaileys(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secreto interno"))
+	}))
+	defer internal.Close()
+	baileys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/media/redirige" {
+			http.Redirect(w, r, internal.URL+"/metadata", http.StatusFound)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer baileys.Close()
+
+	// Sin BAILEYS_URL solo se aceptan adjuntos en línea
+	_, err := fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeDocument, URL: baileys.URL + "/media/abc"})
+	assert.Error(t, err)
+
+	assert.NoError(t, SetMediaSource(baileys.URL))
+	t.Cleanup(func() { SetMediaSource("") })
+	content, err := fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeDocument, URL: baileys.URL + "/media/abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("%PDF-1.4"), content)
+
+	// Otro host, directo o por redirección, no se descarga
+	_, err = fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeDocument, URL: internal.URL + "/metadata"})
+	assert.Error(t, err)
+	_, err = fetchMediaContent(context.Background(), &InboundMedia{Type: models.MessageTypeDocument, URL: baileys.URL + "/media/redirige"})
+	assert.Error(t, err)
+
+	assert.Error(t, SetMediaSource("ftp://baileys"))
+}
+
+func TestInboundMediaFailureStillSavesMessage(t *testing.T) {
+	m := setupInboundMocks(t)
+	SetMediaRepo(&mocks.MockMediaRepo{})
+	storage, err := services.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	SetMediaStorage(storage)
+	t.Cleanup(func() { SetMediaStorage(nil) })
+
+	// La dirección no es de Baileys: el mensaje se guarda con el adjunto fallido y el
+	// motor recibe el texto
+	err = processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "mi licencia",
+		BotNumber: "573009998877",
+		Media: &InboundMedia{
+			Type:     models.MessageTypeDocument,
+			MimeType: "application/pdf",
+			FileName: "licencia.pdf",
+			URL:      "http://169.254.169.254/latest/meta-data",
+		},
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	if !assert.NotEmpty(t, m.Saved) || !assert.Len(t, m.Saved[0].Attachments, 1) {
+		return
+	}
+	assert.Equal(t, "mi licencia", m.Saved[0].Text)
+	assert.True(t, m.Saved[0].Attachments[0].Failed)
+	assert.Equal(t, "licencia.pdf", m.Saved[0].Attachments[0].FileName)
+	assert.Empty(t, m.Saved[0].Attachments[0].MediaID)
+	if received := m.Engine.Received(); assert.Len(t, received, 1) {
+		assert.Equal(t, "mi licencia", received[0].Message)
+	}
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// Palabras con las que el cliente deja de recibir campañas o vuelve a recibirlas.
+// Solo cuentan si son el mensaje completo.
+var (
+	optOutKeywords = map[string]bool{"STOP": true, "BAJA": true, "UNSUBSCRIBE": true, "CANCELAR SUSCRIPCION": true}
+	optInKeywords  = map[string]bool{"START": true, "ALTA": true}
+)
+
+const (
+	optOutReply = "Listo, no volverás a recibir nuestras campañas. Si cambias de opinión escribe ALTA."
+	optInReply  = "¡Listo! Volverás a recibir nuestras campañas. Para dejar de recibirlas escribe BAJA."
+)
+
+// optKeyword normaliza el mensaje para compararlo con las palabras de baja y alta
+func optKeyword(text string) string {
+	keyword := strings.ToUpper(strings.Trim(strings.TrimSpace(text), ".!¡ "))
+	return strings.NewReplacer("Ó", "O", "Í", "I").Replace(strings.Join(strings.Fields(keyword), " "))
+}
+
+// handleOptOutKeyword registra la baja (o el alta) de campañas que pidió el cliente y se
+// lo confirma. Devuelve false si el mensaje no es una de esas palabras.
+func handleOptOutKeyword(hub *WebSocketHub, client *models.Client, bot *models.Bot, phone, sessionID, text string) bool {
+	keyword := optKeyword(text)
+	optedOut := optOutKeywords[keyword]
+	// El alta solo se atiende si el cliente estaba de baja; si no, es un mensaje más
+	if !optedOut && (!optInKeywords[keyword] || !client.OptedOut) {
+		return false
+	}
+
+	if client.OptedOut != optedOut {
+		if err := clientRepo.SetClientOptOut(client.ID, optedOut, time.Now()); err != nil {
+			log.Printf("Failed to record opt-out of client %d: %v", client.ID, err)
+			return false
+		}
+		client.OptedOut = optedOut
+		log.Printf("📵 Cliente %s cambió su suscripción a campañas con %s", client.Phone, keyword)
+	}
+
+	reply := optInReply
+	if optedOut {
+		reply = optOutReply
+	}
+	confirmation := models.Message{
+		ClientID:  client.ID,
+		BotID:     bot.ID,
+		Sender:    models.MessageSenderBot,
+		Type:      models.MessageTypeText,
+		Text:      reply,
+		SessionID: sessionID,
+	}
+	if err := deliverMessage(hub, bot.Number, phone, confirmation); err != nil {
+		log.Printf("Failed to save opt-out confirmation: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+const (
+	outboxPollInterval = 10 * time.Second
+	outboxBatchSize    = 100
+	outboxMaxAttempts  = 8
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = 10 * time.Minute
+	// Espera por el ack de Baileys antes de dar por perdido un frame ya escrito
+	outboxAckTimeout = 2 * time.Minute
+)
+
+// errOutboxAckTimeout el frame se escribió pero Baileys no lo confirmó a tiempo
+var errOutboxAckTimeout = errors.New("no ack from baileys")
+
+// SetOutbox activa la cola persistente de mensajes salientes
+func (h *WebSocketHub) SetOutbox(repo repositories.OutboxRepository) {
+	h.outbox = repo
+}
+
+// enqueueOutbound persiste el frame y trata de entregar la cola del bot. Una vez en la
+// cola el mensaje no falla aquí: si la entrega no resulta (o falla otro mensaje anterior)
+// se reintenta, y solo el último intento lo marca como fallido (scheduleRetry).
+func (h *WebSocketHub) enqueueOutbound(botPhone, recipient string, env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbound message: %w", err)
+	}
+
+	record := &models.OutboundMessage{
+		EnvelopeID:    env.ID,
+		BotNumber:     botPhone,
+		Recipient:     recipient,
+		Payload:       string(payload),
+		Status:        models.OutboundStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+
+	if err := h.outbox.Enqueue(record); err != nil {
+		return fmt.Errorf("failed to enqueue outbound message: %w", err)
+	}
+
+	if _, err := h.GetBotConnection(botPhone); err != nil {
+		log.Printf("📥 Bot %s desconectado, mensaje %d en cola", botPhone, record.ID)
+		return nil
+	}
+	if err := h.FlushOutbox(botPhone, false); err != nil {
+		log.Printf("📥 Mensaje %d para bot %s en cola para reintento: %v", record.ID, botPhone, err)
+	}
+	return nil
+}
+
+// FlushOutbox entrega en orden los mensajes pendientes del bot. Un mensaje queda sin
+// confirmar hasta que llega su ack: si se escribió pero el socket se cortó antes, con
+// force (cuando el bot se vuelve a registrar) se reenvía, y si vence el plazo del ack se
+// reintenta como cualquier fallo. Con force también se ignora el backoff.
+// Ante el primer fallo, o el primer mensaje que espera su reintento, se detiene
+// para no alterar el orden de entrega.
+func (h *WebSocketHub) FlushOutbox(botPhone string, force bool) error {
+	if h.outbox == nil {
+		return nil
+	}
+
+	h.outboxMu.Lock()
+	defer h.outboxMu.Unlock()
+
+	now := time.Now()
+	var lastID uint
+	for {
+		unacked, err := h.outbox.GetUnackedByBot(botPhone, lastID, outboxBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to load outbox: %w", err)
+		}
+
+		for _, message := range unacked {
+			lastID = message.ID
+			due := force || !message.NextAttemptAt.After(now)
+			if message.Status == models.OutboundStatusSent {
+				if !due {
+					continue // ya escrito, espera su ack
+				}
+				if !force {
+					h.scheduleRetry(message, errOutboxAckTimeout)
+					return nil // los siguientes esperan a que este se entregue
+				}
+				log.Printf("🔁 Reenviando mensaje %d sin ack al bot %s", message.ID, botPhone)
+			} else if !due {
+				return nil // los siguientes esperan a que este se entregue
+			}
+			if err := h.writeToBot(botPhone, json.RawMessage(message.Payload)); err != nil {
+				h.scheduleRetry(message, err)
+				return fmt.Errorf("delivery of outbound message %d failed: %w", message.ID, err)
+			}
+			sentAt := time.Now()
+			if err := h.outbox.MarkSent(message.ID, sentAt, sentAt.Add(outboxAckTimeout)); err != nil {
+				log.Printf("Failed to mark outbound message %d as sent: %v", message.ID, err)
+			}
+		}
+
+		if len(unacked) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// scheduleRetry programa el siguiente intento con backoff exponencial o marca el mensaje como fallido
+func (h *WebSocketHub) scheduleRetry(message models.OutboundMessage, cause error) {
+	attempts := message.Attempts + 1
+	if attempts >= outboxMaxAttempts {
+		log.Printf("❌ Mensaje %d para bot %s descartado tras %d intentos: %v", message.ID, message.BotNumber, attempts, cause)
+		if err := h.outbox.MarkFailed(message.ID, attempts, cause.Error()); err != nil {
+			log.Printf("Failed to mark outbound message %d as failed: %v", message.ID, err)
+		}
+		updateMessageStatus(models.MessageStatusUpdate{
+			OutboundID: message.EnvelopeID,
+			Status:     models.MessageStatusFailed,
+			At:         time.Now(),
+			Error:      cause.Error(),
+		})
+		return
+	}
+
+	next := time.Now().Add(outboxBackoff(attempts))
+	if err := h.outbox.MarkRetry(message.ID, attempts, next, cause.Error()); err != nil {
+		log.Printf("Failed to schedule retry for outbound message %d: %v", message.ID, err)
+	}
+}
+
+// ackOutbound registra la confirmación de Baileys para un frame outbound_message
+func (h *WebSocketHub) ackOutbound(ack AckPayload) error {
+	if h.outbox == nil {
+		return nil
+	}
+	return h.outbox.MarkAcked(ack.Ref, ack.WhatsAppID, time.Now())
+}
+
+// failOutbound reprograma un mensaje que Baileys no pudo enviar
+func (h *WebSocketHub) failOutbound(ref, reason string) error {
+	if h.outbox == nil {
+		return nil
+	}
+	message, err := h.outbox.GetByEnvelopeID(ref)
+	if err != nil {
+		return fmt.Errorf("outbound message %s not found: %w", ref, err)
+	}
+	h.scheduleRetry(*message, errors.New(reason))
+	return nil
+}
+
+// outboxBackoff calcula la espera antes del intento número attempts
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= outboxMaxBackoff {
+			return outboxMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// RunOutboxWorker reintenta periódicamente los mensajes vencidos de los bots conectados
+func (h *WebSocketHub) RunOutboxWorker(ctx context.Context) {
+	if h.outbox == nil {
+		return
+	}
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bots, err := h.outbox.GetBotsWithDueMessages(time.Now())
+			if err != nil {
+				log.Printf("Failed to poll outbox: %v", err)
+				continue
+			}
+			for _, botPhone := range bots {
+				if _, err := h.GetBotConnection(botPhone); err != nil {
+					continue
+				}
+				if err := h.FlushOutbox(botPhone, false); err != nil {
+					log.Printf("Outbox retry for bot %s failed: %v", botPhone, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"github.com/brando1998/docubot-api/models"
+)
+
+// OutgoingMessage mensaje que el API envía a Baileys por WebSocket para entregarlo al cliente.
+// Se conservan las claves "to" y "message" para los mensajes de texto simples.
+type OutgoingMessage struct {
+	To       string                  `json:"to"`
+	Type     string                  `json:"type"`
+	Message  string                  `json:"message,omitempty"` // texto, cuerpo de botones/lista o caption
+	Buttons  []models.MessageButton  `json:"buttons,omitempty"`
+	Location *models.MessageLocation `json:"location,omitempty"`
+	MediaURL string                  `json:"media_url,omitempty"`
+	FileName string                  `json:"file_name,omitempty"`
+	MimeType string                  `json:"mime_type,omitempty"`
+	Custom   map[string]interface{}  `json:"custom,omitempty"`
+}
+
+// buildOutgoingMessages traduce un mensaje almacenado a los mensajes de WhatsApp a enviar.
+// Los adjuntos se envían primero; si hay botones, el texto viaja con ellos y no como caption.
+func buildOutgoingMessages(to string, msg models.Message) []OutgoingMessage {
+	var outgoing []OutgoingMessage
+
+	caption := msg.Text
+	if len(msg.Buttons) > 0 {
+		caption = ""
+	}
+
+	for i, attachment := range msg.Attachments {
+		out := OutgoingMessage{
+			To:       to,
+			Type:     attachment.Type,
+			MediaURL: attachment.URL,
+			FileName: attachment.FileName,
+			MimeType: attachment.MimeType,
+		}
+		if i == 0 {
+			out.Message = caption
+		}
+		outgoing = append(outgoing, out)
+	}
+
+	switch {
+	case msg.Location != nil:
+		outgoing = append(outgoing, OutgoingMessage{
+			To:       to,
+			Type:     models.MessageTypeLocation,
+			Location: msg.Location,
+		})
+	case len(msg.Buttons) > 0:
+		kind := models.MessageTypeButtons
+		if len(msg.Buttons) > models.MaxWhatsAppButtons || msg.Type == models.MessageTypeList {
+			kind = models.MessageTypeList
+		}
+		outgoing = append(outgoing, OutgoingMessage{
+			To:      to,
+			Type:    kind,
+			Message: msg.Text,
+			Buttons: msg.Buttons,
+		})
+	case len(msg.Attachments) > 0:
+		// El texto ya viajó como caption del primer adjunto
+	case len(msg.Custom) > 0 && msg.Text == "":
+		outgoing = append(outgoing, OutgoingMessage{
+			To:     to,
+			Type:   models.MessageTypeCustom,
+			Custom: msg.Custom,
+		})
+	case msg.Text != "":
+		outgoing = append(outgoing, OutgoingMessage{
+			To:      to,
+			Type:    models.MessageTypeText,
+			Message: msg.Text,
+		})
+	}
+
+	return outgoing
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProtocolVersion versión del protocolo WebSocket entre el API y Baileys.
+// Los frames sin "type" se interpretan como mensajes entrantes del protocolo
+// anterior ({"phone", "message", "botNumber"}).
+const ProtocolVersion = 1
+
+// Tipos de frame
+const (
+	FrameInboundMessage  = "inbound_message"  // Baileys → API: mensaje de un cliente
+	FrameOutboundMessage = "outbound_message" // API → Baileys: mensaje para un cliente
+	FrameReceipt         = "receipt"          // Baileys → API: entregado/leído/fallido
+	FramePresence        = "presence"         // Baileys → API: presencia de un contacto
+	FrameQRUpdate        = "qr_update"        // Baileys → API: nuevo código QR
+	FrameConnectionState = "connection_state" // Baileys → API: estado de la sesión de WhatsApp
+	FrameSessionCommand  = "session_command"  // API → Baileys: iniciar, reiniciar o cerrar una sesión
+	FrameAck             = "ack"              // ambos sentidos: confirma un frame por su id
+	FrameError           = "error"            // ambos sentidos: error procesando un frame
+)
+
+// Códigos de error del protocolo
+const (
+	ErrorCodeInvalidFrame       = "invalid_frame"
+	ErrorCodeUnsupportedType    = "unsupported_type"
+	ErrorCodeUnsupportedVersion = "unsupported_version"
+	ErrorCodeProcessingFailed   = "processing_failed"
+)
+
+// Envelope sobre común a todos los frames
+type Envelope struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id"`
+	Version   int             `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// InboundMessagePayload mensaje recibido por el bot desde un cliente
+type InboundMessagePayload struct {
+	MessageID string        `json:"message_id,omitempty"` // id del mensaje en WhatsApp
+	From      string        `json:"from"`
+	BotNumber string        `json:"bot_number,omitempty"` // por defecto, el número de la conexión
+	Text      string        `json:"text"`                 // texto o caption del adjunto
+	Payload   string        `json:"payload,omitempty"`    // botón/lista seleccionado
+	Media     *InboundMedia `json:"media,omitempty"`
+}
+
+// InboundMedia archivo adjunto a un mensaje entrante (imagen, PDF, nota de voz). Baileys
+// envía el contenido en base64 en Data o, si es grande, una URL desde la que el API lo descarga.
+type InboundMedia struct {
+	Type     string `json:"type"` // image, document o audio
+	MimeType string `json:"mime_type,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Data     string `json:"data,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ReceiptPayload actualización de estado de un mensaje enviado
+type ReceiptPayload struct {
+	Ref        string    `json:"ref,omitempty"` // id del frame outbound_message
+	WhatsAppID string    `json:"whatsapp_id,omitempty"`
+	Status     string    `json:"status"` // delivered, read, failed
+	Timestamp  time.Time `json:"timestamp"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// PresencePayload presencia de un contacto (available, unavailable, composing...)
+type PresencePayload struct {
+	Jid      string `json:"jid"`
+	Presence string `json:"presence"`
+}
+
+// QRUpdatePayload nuevo código QR para vincular la sesión
+type QRUpdatePayload struct {
+	QRCode  string `json:"qr_code"`
+	QRImage string `json:"qr_image,omitempty"`
+}
+
+// ConnectionStatePayload estado de la sesión de WhatsApp en Baileys
+type ConnectionStatePayload struct {
+	State  string `json:"state"` // connecting, open, close
+	Number string `json:"number,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SessionManagerRole valor de ?role= en /ws para la conexión del gestor de sesiones de Baileys
+const SessionManagerRole = "sessions"
+
+// Acciones de session_command
+const (
+	SessionActionStart   = "start"   // abre la sesión (muestra QR si no está vinculada)
+	SessionActionRestart = "restart" // cierra el socket y vuelve a abrirlo con las mismas credenciales
+	SessionActionLogout  = "logout"  // cierra sesión en WhatsApp y borra las credenciales
+)
+
+// SessionCommandPayload orden para la sesión de WhatsApp de un número de bot
+type SessionCommandPayload struct {
+	Action    string `json:"action"`
+	BotNumber string `json:"bot_number"`
+}
+
+// AckPayload confirma la recepción de un frame
+type AckPayload struct {
+	Ref        string `json:"ref"`
+	WhatsAppID string `json:"whatsapp_id,omitempty"` // id asignado por WhatsApp al mensaje enviado
+}
+
+// ErrorPayload informa un error al procesar un frame
+type ErrorPayload struct {
+	Ref     string `json:"ref,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewEnvelope arma un frame con id nuevo
+func NewEnvelope(frameType string, payload interface{}) (Envelope, error) {
+	env := Envelope{
+		Type:      frameType,
+		ID:        newFrameID(),
+		Version:   ProtocolVersion,
+		Timestamp: time.Now().UTC(),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("failed to marshal %s payload: %w", frameType, err)
+		}
+		env.Payload = data
+	}
+	return env, nil
+}
+
+// DecodePayload decodifica el payload del frame
+func (e Envelope) DecodePayload(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("%s frame without payload", e.Type)
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+// decodeFrame interpreta un frame crudo. Los frames del formato anterior se
+// convierten en inbound_message y se marcan como legacy (no reciben ack).
+func decodeFrame(data []byte) (env Envelope, legacy bool, err error) {
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, false, err
+	}
+	if env.Type != "" {
+		return env, false, nil
+	}
+
+	var request IncomingMessageRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return Envelope{}, true, err
+	}
+	env, err = NewEnvelope(FrameInboundMessage, InboundMessagePayload{
+		From:      request.Phone,
+		BotNumber: request.BotNumber,
+		Text:      request.Message,
+		Payload:   request.Payload,
+		Media:     request.Media,
+	})
+	return env, true, err
+}
+
+func newFrameID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+)
+
+// setupEchoPipeline configura repositorios en memoria y el motor NLU de eco
+func setupEchoPipeline(t *testing.T) chan models.MessageStatusUpdate {
+	updates := make(chan models.MessageStatusUpdate, 16)
+	m := setupInboundMocks(t)
+	m.Conversations.SaveMessageFunc = func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+		return nil
+	}
+	m.Conversations.UpdateMessageStatusFunc = func(ctx context.Context, update models.MessageStatusUpdate) error {
+		updates <- update
+		return nil
+	}
+	return updates
+}
+
+func readEnvelope(t *testing.T, conn *websocket.Conn) Envelope {
+	var env Envelope
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&env))
+	return env
+}
+
+func TestInboundFrameIsProcessedAndAcked(t *testing.T) {
+	setupEchoPipeline(t)
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	inbound, err := NewEnvelope(FrameInboundMessage, InboundMessagePayload{From: "573001112233", Text: "hola"})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.WriteJSON(inbound))
+
+	reply := readEnvelope(t, conn)
+	assert.Equal(t, FrameOutboundMessage, reply.Type)
+	var outgoing OutgoingMessage
+	assert.NoError(t, reply.DecodePayload(&outgoing))
+	assert.Equal(t, "573001112233", outgoing.To)
+	assert.Equal(t, "hola", outgoing.Message)
+
+	ack := readEnvelope(t, conn)
+	assert.Equal(t, FrameAck, ack.Type)
+	var payload AckPayload
+	assert.NoError(t, ack.DecodePayload(&payload))
+	assert.Equal(t, inbound.ID, payload.Ref)
+}
+
+func TestLegacyFrameIsStillAccepted(t *testing.T) {
+	setupEchoPipeline(t)
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteJSON(IncomingMessageRequest{Phone: "573001112233", Message: "hola", BotNumber: "573009998877"}))
+
+	reply := readEnvelope(t, conn)
+	assert.Equal(t, FrameOutboundMessage, reply.Type)
+}
+
+func TestUnknownFrameTypeReturnsError(t *testing.T) {
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	frame, _ := NewEnvelope("typing_indicator", nil)
+	assert.NoError(t, conn.WriteJSON(frame))
+
+	reply := readEnvelope(t, conn)
+	assert.Equal(t, FrameError, reply.Type)
+	var payload ErrorPayload
+	assert.NoError(t, reply.DecodePayload(&payload))
+	assert.Equal(t, ErrorCodeUnsupportedType, payload.Code)
+	assert.Equal(t, frame.ID, payload.Ref)
+}
+
+func TestReceiptFrameUpdatesMessageStatus(t *testing.T) {
+	updates := setupEchoPipeline(t)
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	readAt := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	frame, _ := NewEnvelope(FrameReceipt, ReceiptPayload{Ref: "abc123", WhatsAppID: "3EB0C767D26A", Status: models.MessageStatusRead, Timestamp: readAt})
+	assert.NoError(t, conn.WriteJSON(frame))
+
+	ack := readEnvelope(t, conn)
+	assert.Equal(t, FrameAck, ack.Type)
+
+	update := <-updates
+	assert.Equal(t, "abc123", update.OutboundID)
+	assert.Equal(t, "3EB0C767D26A", update.WhatsAppID)
+	assert.Equal(t, models.MessageStatusRead, update.Status)
+	assert.True(t, readAt.Equal(update.At))
+
+	invalid, _ := NewEnvelope(FrameReceipt, ReceiptPayload{Ref: "abc123", Status: "bogus"})
+	assert.NoError(t, conn.WriteJSON(invalid))
+	reply := readEnvelope(t, conn)
+	assert.Equal(t, FrameError, reply.Type)
+}
+
+func TestAckFrameMarksOutboundAsAcked(t *testing.T) {
+	acked := make(chan AckPayload, 1)
+	hub := NewWebSocketHub()
+	hub.SetOutbox(&mocks.MockOutboxRepo{
+		GetUnackedByBotFunc: func(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error) {
+			return nil, nil
+		},
+		MarkAckedFunc: func(envelopeID, whatsAppID string, ackedAt time.Time) error {
+			acked <- AckPayload{Ref: envelopeID, WhatsAppID: whatsAppID}
+			return nil
+		},
+	})
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	frame, _ := NewEnvelope(FrameAck, AckPayload{Ref: "abc123", WhatsAppID: "3EB0C767D26A"})
+	assert.NoError(t, conn.WriteJSON(frame))
+
+	select {
+	case payload := <-acked:
+		assert.Equal(t, AckPayload{Ref: "abc123", WhatsAppID: "3EB0C767D26A"}, payload)
+	case <-time.After(2 * time.Second):
+		t.Fatal("ack not processed")
+	}
+}
+
+func TestSlowInboundMessageDoesNotBlockOtherFrames(t *testing.T) {
+	m := setupInboundMocks(t)
+	release := make(chan struct{})
+	m.Conversations.SaveMessageFunc = func(ctx context.Context, userID uint, botID uint, message models.Message) error {
+		<-release
+		return nil
+	}
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	// El mensaje entrante queda detenido guardándose; el recibo que llega después se atiende igual
+	inbound, _ := NewEnvelope(FrameInboundMessage, InboundMessagePayload{From: "573001112233", Text: "hola"})
+	assert.NoError(t, conn.WriteJSON(inbound))
+	receipt, _ := NewEnvelope(FrameReceipt, ReceiptPayload{Ref: "abc123", Status: models.MessageStatusRead})
+	assert.NoError(t, conn.WriteJSON(receipt))
+
+	ack := readEnvelope(t, conn)
+	assert.Equal(t, FrameAck, ack.Type)
+	var payload AckPayload
+	assert.NoError(t, ack.DecodePayload(&payload))
+	assert.Equal(t, receipt.ID, payload.Ref)
+
+	close(release)
+	assert.Equal(t, FrameOutboundMessage, readEnvelope(t, conn).Type)
+	ack = readEnvelope(t, conn)
+	assert.NoError(t, ack.DecodePayload(&payload))
+	assert.Equal(t, inbound.ID, payload.Ref)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+const (
+	defaultUndeliveredLimit = 50
+	maxUndeliveredLimit     = 500
+)
+
+// applyMessageStatus actualiza el estado de entrega del mensaje almacenado y del
+// destinatario de campaña que lo recibió, si lo hay
+func applyMessageStatus(update models.MessageStatusUpdate) error {
+	if campaignRepo != nil {
+		if err := campaignRepo.UpdateRecipientStatus(update); err != nil {
+			log.Printf("Failed to update campaign recipient status to %s: %v", update.Status, err)
+		}
+	}
+	if conversationRepo == nil {
+		return nil
+	}
+	return conversationRepo.UpdateMessageStatus(context.TODO(), update)
+}
+
+// updateMessageStatus igual que applyMessageStatus, pero solo registra el error
+func updateMessageStatus(update models.MessageStatusUpdate) {
+	if err := applyMessageStatus(update); err != nil {
+		log.Printf("Failed to update message status to %s: %v", update.Status, err)
+	}
+}
+
+// GetUndeliveredMessages lista los mensajes del bot que no han llegado al cliente
+// @Summary Mensajes no entregados de un bot
+// @Description Retorna los mensajes enviados por el bot que siguen pendientes, enviados sin confirmar entrega o fallidos
+// @Tags whatsapp
+// @Produce json
+// @Param bot_id path int true "ID del bot"
+// @Param limit query int false "Máximo de mensajes (por defecto 50, máximo 500)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/whatsapp/bots/{bot_id}/undelivered [get]
+func GetUndeliveredMessages(c *gin.Context) {
+	botID, err := strconv.ParseUint(c.Param("bot_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de bot inválido"})
+		return
+	}
+
+	limit, ok := queryLimit(c, "limit", defaultUndeliveredLimit, maxUndeliveredLimit)
+	if !ok {
+		return
+	}
+
+	messages, err := conversationRepo.GetUndeliveredMessages(c.Request.Context(), uint(botID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando mensajes", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bot_id":   botID,
+		"messages": messages,
+		"total":    len(messages),
+	})
+}
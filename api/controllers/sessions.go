@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+const (
+	DefaultSessionTimeout = 60 * time.Minute
+	sessionReapInterval   = time.Minute
+	defaultSessionsLimit  = 20
+	maxSessionsLimit      = 100
+)
+
+var (
+	sessionRepo    repositories.SessionRepository
+	sessionTimeout = DefaultSessionTimeout
+)
+
+// SetSessionRepo activa las sesiones de conversación; sin repositorio los mensajes no llevan sesión
+func SetSessionRepo(repo repositories.SessionRepository) {
+	sessionRepo = repo
+}
+
+// SetSessionTimeout fija la ventana de inactividad tras la que se cierra una sesión
+func SetSessionTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		sessionTimeout = timeout
+	}
+}
+
+// openSession devuelve la sesión activa del cliente con el bot o abre una nueva si no
+// hay o si la anterior venció. opened indica que la sesión empezó con este mensaje.
+func openSession(ctx context.Context, clientID, botID uint, at time.Time) (*models.ConversationSession, bool, error) {
+	session, err := sessionRepo.GetActiveSession(ctx, clientID, botID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get active session: %w", err)
+	}
+	if session != nil && !session.ExpiredAt(at, sessionTimeout) {
+		return session, false, nil
+	}
+	if session != nil {
+		// El reaper aún no la cerró
+		if err := sessionRepo.CloseSession(ctx, session.SessionID(), at, models.SessionCloseInactivity); err != nil {
+			return nil, false, fmt.Errorf("failed to close expired session: %w", err)
+		}
+	}
+
+	session, err = sessionRepo.StartSession(ctx, clientID, botID, at)
+	if mongo.IsDuplicateKeyError(err) {
+		// Otro mensaje del mismo cliente abrió la sesión en paralelo
+		session, err = sessionRepo.GetActiveSession(ctx, clientID, botID)
+		if err == nil && session == nil {
+			err = fmt.Errorf("active session disappeared")
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get concurrent session: %w", err)
+		}
+		return session, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to start session: %w", err)
+	}
+	return session, true, nil
+}
+
+// activeSessionID id de la sesión vigente del cliente con el bot, sin abrir una nueva
+// (los mensajes salientes no abren sesión). Vacío si no hay sesión o si venció.
+func activeSessionID(ctx context.Context, clientID, botID uint, at time.Time) string {
+	if sessionRepo == nil {
+		return ""
+	}
+	session, err := sessionRepo.GetActiveSession(ctx, clientID, botID)
+	if err != nil {
+		log.Printf("Failed to get active session: %v", err)
+		return ""
+	}
+	if session == nil || session.ExpiredAt(at, sessionTimeout) {
+		return ""
+	}
+	return session.SessionID()
+}
+
+// touchSession registra el mensaje en su sesión
+func touchSession(ctx context.Context, sessionID string, at time.Time) {
+	if sessionRepo == nil || sessionID == "" {
+		return
+	}
+	if err := sessionRepo.TouchSession(ctx, sessionID, at); err != nil {
+		log.Printf("Failed to update session %s: %v", sessionID, err)
+	}
+}
+
+// resetNLUSession descarta el estado que el motor NLU guarda del cliente (tracker de
+// Rasa) para que una sesión nueva no herede formularios a medio llenar
+func resetNLUSession(ctx context.Context, bot *models.Bot, sender string) {
+	engine, err := nluRegistry.EngineFor(bot)
+	if err != nil {
+		log.Printf("Failed to resolve nlu engine: %v", err)
+		return
+	}
+	resetter, ok := engine.(services.NLUSessionResetter)
+	if !ok {
+		return
+	}
+	if err := resetter.ResetSession(ctx, sender); err != nil {
+		log.Printf("Failed to reset %s session for %s: %v", engine.Name(), sender, err)
+		return
+	}
+	log.Printf("🔄 Nueva sesión para %s: estado de %s reiniciado", sender, engine.Name())
+}
+
+// RunSessionReaper cierra periódicamente las sesiones que superaron la ventana de inactividad
+func RunSessionReaper(ctx context.Context) {
+	if sessionRepo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(sessionReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			closed, err := sessionRepo.CloseInactiveSessions(ctx, now.Add(-sessionTimeout), now)
+			if err != nil {
+				log.Printf("Failed to close inactive sessions: %v", err)
+				continue
+			}
+			if closed > 0 {
+				log.Printf("💤 %d sesiones cerradas por inactividad", closed)
+			}
+		}
+	}
+}
+
+// GetConversationSessions lista las sesiones de un cliente con un bot
+// @Summary Sesiones de una conversación
+// @Description Sesiones de la conversación, las más recientes primero. Los mensajes de cada sesión llevan su id en session_id.
+// @Tags conversaciones
+// @Produce json
+// @Param client_id path int true "ID del cliente"
+// @Param bot_id path int true "ID del bot"
+// @Param limit query int false "Máximo de sesiones (por defecto 20, máximo 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/conversations/{client_id}/bots/{bot_id}/sessions [get]
+func GetConversationSessions(c *gin.Context) {
+	limit, ok := queryLimit(c, "limit", defaultSessionsLimit, maxSessionsLimit)
+	if !ok {
+		return
+	}
+	client, bot, ok := conversationParties(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := sessionRepo.ListSessions(c.Request.Context(), client.ID, bot.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando sesiones", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client_id":       client.ID,
+		"bot_id":          bot.ID,
+		"timeout_minutes": int(sessionTimeout / time.Minute),
+		"sessions":        sessions,
+	})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+func TestExpiredSessionOpensNewSessionAndResetsNLU(t *testing.T) {
+	var closed []string
+	var touched []string
+
+	expired := &models.ConversationSession{
+		ID:             primitive.NewObjectID(),
+		ClientID:       7,
+		BotID:          3,
+		Active:         true,
+		LastActivityAt: time.Now().Add(-2 * time.Hour),
+	}
+	var started *models.ConversationSession
+
+	m := setupInboundMocks(t)
+	m.Bot.WelcomeMessage = "Bienvenido a Docubot"
+	SetSessionRepo(&mocks.MockSessionRepo{
+		GetActiveSessionFunc: func(ctx context.Context, clientID uint, botID uint) (*models.ConversationSession, error) {
+			if started != nil {
+				return started, nil
+			}
+			return expired, nil
+		},
+		CloseSessionFunc: func(ctx context.Context, sessionID string, at time.Time, reason string) error {
+			assert.Equal(t, models.SessionCloseInactivity, reason)
+			closed = append(closed, sessionID)
+			return nil
+		},
+		StartSessionFunc: func(ctx context.Context, clientID uint, botID uint, at time.Time) (*models.ConversationSession, error) {
+			started = &models.ConversationSession{ID: primitive.NewObjectID(), ClientID: clientID, BotID: botID, Active: true, StartedAt: at, LastActivityAt: at}
+			return started, nil
+		},
+		TouchSessionFunc: func(ctx context.Context, sessionID string, at time.Time) error {
+			touched = append(touched, sessionID)
+			return nil
+		},
+	})
+	SetSessionTimeout(time.Hour)
+	t.Cleanup(func() { SetSessionRepo(nil) })
+
+	m.Engine.Script("hola", services.NLUResponse{Text: "¡Hola!"})
+
+	err := processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "hola",
+		BotNumber: "573009998877",
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{expired.SessionID()}, closed)
+	assert.Equal(t, []string{"573001112233"}, m.Engine.Resets())
+	// La sesión nueva empieza con el saludo del bot antes de la respuesta del motor
+	if assert.NotNil(t, started) && assert.Len(t, m.Saved, 3) {
+		assert.Equal(t, "Bienvenido a Docubot", m.Saved[1].Text)
+		assert.Equal(t, "¡Hola!", m.Saved[2].Text)
+		for _, message := range m.Saved {
+			assert.Equal(t, started.SessionID(), message.SessionID)
+		}
+		assert.Equal(t, []string{started.SessionID(), started.SessionID(), started.SessionID()}, touched)
+	}
+
+	// Un segundo mensaje dentro de la ventana sigue en la misma sesión sin reiniciar el motor
+	err = processIncomingMessage(IncomingMessageRequest{
+		Phone:     "573001112233",
+		Message:   "hola",
+		BotNumber: "573009998877",
+	}, NewWebSocketHub())
+	assert.NoError(t, err)
+	assert.Len(t, m.Engine.Resets(), 1)
+	assert.Len(t, m.Saved, 5)
+	assert.Equal(t, started.SessionID(), m.Saved[len(m.Saved)-1].SessionID)
+}
@@ -0,0 +1,549 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+var (
+	systemUserRepo repositories.SystemUserRepository
+	auditRepo      repositories.AuditRepository
+)
+
+func SetSystemUserRepo(repo repositories.SystemUserRepository) {
+	systemUserRepo = repo
+}
+
+func SetAuditRepo(repo repositories.AuditRepository) {
+	auditRepo = repo
+}
+
+// SystemUserRequest datos para crear o actualizar un usuario del sistema. En la
+// actualización solo cambian los campos enviados; la contraseña solo se indica al crear.
+type SystemUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"` // admin, operator o viewer
+}
+
+// apply copia al usuario los campos enviados, salvo la contraseña
+func (r SystemUserRequest) apply(user *models.SystemUser) {
+	if r.Username != nil {
+		user.Username = strings.TrimSpace(*r.Username)
+	}
+	if r.Email != nil {
+		user.Email = strings.ToLower(strings.TrimSpace(*r.Email))
+	}
+	if r.Role != nil {
+		user.Role = strings.ToLower(strings.TrimSpace(*r.Role))
+	}
+}
+
+// PasswordResetRequest contraseña temporal que fija un administrador
+type PasswordResetRequest struct {
+	Password string `json:"password"` // vacío = se genera una al azar
+}
+
+// ListSystemUsers lista los usuarios del sistema
+// @Summary Listar usuarios del sistema
+// @Tags administración
+// @Produce json
+// @Param role query string false "Rol (admin, operator o viewer)"
+// @Param active query bool false "Solo activos (true) o inactivos (false)"
+// @Param deleted query bool false "Solo los eliminados"
+// @Param q query string false "Parte del usuario o del correo"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Usuarios por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users [get]
+func ListSystemUsers(c *gin.Context) {
+	filter := models.SystemUserFilter{
+		Role:   c.Query("role"),
+		Search: strings.TrimSpace(c.Query("q")),
+	}
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro active inválido"})
+			return
+		}
+		filter.Active = &active
+	}
+	if raw := c.Query("deleted"); raw != "" {
+		deleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro deleted inválido"})
+			return
+		}
+		filter.Deleted = deleted
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+
+	users, total, err := systemUserRepo.ListSystemUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando usuarios", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// CreateSystemUser crea un usuario del sistema
+// @Summary Crear usuario del sistema
+// @Tags administración
+// @Accept json
+// @Produce json
+// @Param user body SystemUserRequest true "Usuario (username, email, password y role obligatorios)"
+// @Success 201 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users [post]
+func CreateSystemUser(c *gin.Context) {
+	var request SystemUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+
+	user := models.SystemUser{IsActive: true}
+	request.apply(&user)
+	if err := user.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuario inválido", "details": err.Error()})
+		return
+	}
+	if request.Password == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuario inválido", "details": "password is required"})
+		return
+	}
+	if !setPassword(c, &user, *request.Password) {
+		return
+	}
+
+	audit := userAudit(c, models.AuditUserCreated, 0, map[string]models.AuditChange{
+		"username": {To: user.Username},
+		"email":    {To: user.Email},
+		"role":     {To: user.Role},
+	})
+	if err := systemUserRepo.CreateSystemUser(&user, audit); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// GetSystemUser devuelve un usuario del sistema, aunque esté eliminado
+// @Summary Obtener usuario del sistema
+// @Tags administración
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/users/{id} [get]
+func GetSystemUser(c *gin.Context) {
+	user, ok := systemUserParam(c, true)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateSystemUser actualiza el usuario, el correo o el rol
+// @Summary Actualizar usuario del sistema
+// @Description Solo cambian los campos enviados. La contraseña se cambia con password-reset.
+// @Tags administración
+// @Accept json
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Param user body SystemUserRequest true "Campos a cambiar"
+// @Success 200 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id} [put]
+func UpdateSystemUser(c *gin.Context) {
+	user, ok := systemUserParam(c, false)
+	if !ok {
+		return
+	}
+	var request SystemUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+	if request.Password != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La contraseña se cambia con /password-reset"})
+		return
+	}
+
+	before := *user
+	request.apply(user)
+	if err := user.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuario inválido", "details": err.Error()})
+		return
+	}
+
+	var audit []models.AuditLog
+	if user.Role != before.Role {
+		if !ensureNotSelf(c, user, "cambiar tu propio rol") || !ensureAdminRemains(c, &before) {
+			return
+		}
+		audit = append(audit, userAudit(c, models.AuditUserRoleChanged, user.ID, map[string]models.AuditChange{
+			"role": {From: before.Role, To: user.Role},
+		}))
+	}
+	changes := map[string]models.AuditChange{}
+	if user.Username != before.Username {
+		changes["username"] = models.AuditChange{From: before.Username, To: user.Username}
+	}
+	if user.Email != before.Email {
+		changes["email"] = models.AuditChange{From: before.Email, To: user.Email}
+	}
+	if len(changes) > 0 {
+		audit = append(audit, userAudit(c, models.AuditUserUpdated, user.ID, changes))
+	}
+	if len(audit) == 0 {
+		c.JSON(http.StatusOK, user)
+		return
+	}
+
+	if err := systemUserRepo.UpdateSystemUser(user, audit...); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ActivateSystemUser reactiva un usuario desactivado
+// @Summary Activar usuario del sistema
+// @Tags administración
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/activate [post]
+func ActivateSystemUser(c *gin.Context) {
+	setSystemUserActive(c, true)
+}
+
+// DeactivateSystemUser desactiva un usuario: no puede iniciar sesión y sus sesiones
+// quedan revocadas
+// @Summary Desactivar usuario del sistema
+// @Tags administración
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/deactivate [post]
+func DeactivateSystemUser(c *gin.Context) {
+	setSystemUserActive(c, false)
+}
+
+func setSystemUserActive(c *gin.Context, active bool) {
+	user, ok := systemUserParam(c, false)
+	if !ok {
+		return
+	}
+	if user.IsActive == active {
+		c.JSON(http.StatusOK, user)
+		return
+	}
+	action := models.AuditUserActivated
+	if !active {
+		if !ensureNotSelf(c, user, "desactivar tu propio usuario") || !ensureAdminRemains(c, user) {
+			return
+		}
+		action = models.AuditUserDeactivated
+	}
+
+	audit := userAudit(c, action, user.ID, map[string]models.AuditChange{
+		"is_active": {From: user.IsActive, To: active},
+	})
+	user.IsActive = active
+	if err := systemUserRepo.UpdateSystemUser(user, audit); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	if !active {
+		revokeUserSessions(user.ID, "", models.TokenRevokedUserDisabled)
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteSystemUser elimina un usuario del sistema; se puede restaurar
+// @Summary Eliminar usuario del sistema
+// @Tags administración
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id} [delete]
+func DeleteSystemUser(c *gin.Context) {
+	user, ok := systemUserParam(c, false)
+	if !ok {
+		return
+	}
+	if !ensureNotSelf(c, user, "eliminar tu propio usuario") || !ensureAdminRemains(c, user) {
+		return
+	}
+	if err := systemUserRepo.DeleteSystemUser(user.ID, userAudit(c, models.AuditUserDeleted, user.ID, nil)); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	revokeUserSessions(user.ID, "", models.TokenRevokedUserDisabled)
+	c.JSON(http.StatusOK, gin.H{"message": "Usuario eliminado"})
+}
+
+// RestoreSystemUser restaura un usuario eliminado con su rol y estado anteriores
+// @Summary Restaurar usuario del sistema
+// @Tags administración
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Success 200 {object} models.SystemUser
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/restore [post]
+func RestoreSystemUser(c *gin.Context) {
+	id, ok := systemUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := systemUserRepo.RestoreSystemUser(id, userAudit(c, models.AuditUserRestored, id, nil)); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	user, err := systemUserRepo.GetSystemUser(id, false)
+	if err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetSystemUserPassword fija una contraseña temporal que el usuario debe cambiar
+// (POST /auth/password) antes de volver a usar el API. Cierra todas sus sesiones.
+// @Summary Forzar cambio de contraseña
+// @Tags administración
+// @Accept json
+// @Produce json
+// @Param id path int true "ID del usuario"
+// @Param data body PasswordResetRequest false "Contraseña temporal; si no se envía se genera una"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/users/{id}/password-reset [post]
+func ResetSystemUserPassword(c *gin.Context) {
+	user, ok := systemUserParam(c, false)
+	if !ok {
+		return
+	}
+	var request PasswordResetRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+			return
+		}
+	}
+	password := request.Password
+	if password == "" {
+		generated, err := temporaryPassword()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generando la contraseña", "details": err.Error()})
+			return
+		}
+		password = generated
+	}
+	if !setPassword(c, user, password) {
+		return
+	}
+	user.PasswordResetRequired = true
+
+	if err := systemUserRepo.UpdateSystemUser(user, userAudit(c, models.AuditUserPasswordReset, user.ID, nil)); err != nil {
+		respondSystemUserError(c, err)
+		return
+	}
+	revokeUserSessions(user.ID, "", models.TokenRevokedPasswordReset)
+	response := gin.H{"user": user}
+	if request.Password == "" {
+		response["temporary_password"] = password // solo se muestra esta vez
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListAuditLogs lista los cambios administrativos
+// @Summary Auditoría de cambios
+// @Tags administración
+// @Produce json
+// @Param actor_id query int false "Usuario que hizo el cambio"
+// @Param target_type query string false "Tipo de registro (system_user)"
+// @Param target_id query int false "ID del registro afectado"
+// @Param action query string false "Acción (ej: user.role_changed)"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Registros por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/audit [get]
+func ListAuditLogs(c *gin.Context) {
+	actorID, ok := queryUint(c, "actor_id")
+	if !ok {
+		return
+	}
+	targetID, ok := queryUint(c, "target_id")
+	if !ok {
+		return
+	}
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	logs, total, err := auditRepo.ListAuditLogs(models.AuditFilter{
+		ActorID:    actorID,
+		TargetType: c.Query("target_type"),
+		TargetID:   targetID,
+		Action:     c.Query("action"),
+		Offset:     (page - 1) * pageSize,
+		Limit:      pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando la auditoría", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"logs":      logs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// userAudit registro de auditoría de un cambio sobre un usuario del sistema
+func userAudit(c *gin.Context, action string, userID uint, changes map[string]models.AuditChange) models.AuditLog {
+	return models.AuditLog{
+		ActorID:    currentOperatorID(c),
+		Action:     action,
+		TargetType: models.AuditTargetSystemUser,
+		TargetID:   userID,
+		Changes:    changes,
+		IP:         c.ClientIP(),
+	}
+}
+
+// setPassword valida la contraseña y guarda su hash en el usuario
+func setPassword(c *gin.Context, user *models.SystemUser, password string) bool {
+	if err := models.ValidatePassword(password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Contraseña inválida", "details": err.Error()})
+		return false
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error protegiendo la contraseña", "details": err.Error()})
+		return false
+	}
+	user.PasswordHash = string(hash)
+	return true
+}
+
+// temporaryPassword contraseña al azar de 16 caracteres
+func temporaryPassword() (string, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// ensureNotSelf evita que un administrador se quite el acceso a sí mismo
+func ensureNotSelf(c *gin.Context, user *models.SystemUser, action string) bool {
+	if user.ID == currentOperatorID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No puedes " + action})
+		return false
+	}
+	return true
+}
+
+// ensureAdminRemains evita dejar el sistema sin administradores activos cuando el
+// usuario deja de serlo
+func ensureAdminRemains(c *gin.Context, user *models.SystemUser) bool {
+	if user.Role != models.RoleAdmin || !user.IsActive {
+		return true
+	}
+	others, err := systemUserRepo.CountActiveAdmins(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando administradores", "details": err.Error()})
+		return false
+	}
+	if others == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Debe quedar al menos un administrador activo"})
+		return false
+	}
+	return true
+}
+
+func systemUserIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func systemUserParam(c *gin.Context, includeDeleted bool) (*models.SystemUser, bool) {
+	id, ok := systemUserIDParam(c)
+	if !ok {
+		return nil, false
+	}
+	user, err := systemUserRepo.GetSystemUser(id, includeDeleted)
+	if err != nil {
+		respondSystemUserError(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+func respondSystemUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrSystemUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+	case errors.Is(err, repositories.ErrSystemUserTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay un usuario con ese nombre o correo (puede estar eliminado)"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando el usuario", "details": err.Error()})
+	}
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+// memorySystemUserRepo guarda usuarios y auditoría en memoria sobre MockSystemUserRepo
+func memorySystemUserRepo(users map[uint]*models.SystemUser, audit *[]models.AuditLog) *mocks.MockSystemUserRepo {
+	deleted := map[uint]bool{}
+	record := func(id uint, entries []models.AuditLog) {
+		for _, entry := range entries {
+			if entry.TargetID == 0 {
+				entry.TargetID = id
+			}
+			*audit = append(*audit, entry)
+		}
+	}
+
+	return &mocks.MockSystemUserRepo{
+		GetSystemUserFunc: func(id uint, includeDeleted bool) (*models.SystemUser, error) {
+			user, ok := users[id]
+			if !ok || (deleted[id] && !includeDeleted) {
+				return nil, repositories.ErrSystemUserNotFound
+			}
+			copied := *user
+			return &copied, nil
+		},
+		CreateSystemUserFunc: func(user *models.SystemUser, entries ...models.AuditLog) error {
+			for _, existing := range users {
+				if existing.Username == user.Username || existing.Email == user.Email {
+					return repositories.ErrSystemUserTaken
+				}
+			}
+			user.ID = uint(len(users) + 1)
+			copied := *user
+			users[user.ID] = &copied
+			record(user.ID, entries)
+			return nil
+		},
+		UpdateSystemUserFunc: func(user *models.SystemUser, entries ...models.AuditLog) error {
+			copied := *user
+			users[user.ID] = &copied
+			record(user.ID, entries)
+			return nil
+		},
+		DeleteSystemUserFunc: func(id uint, entries ...models.AuditLog) error {
+			deleted[id] = true
+			record(id, entries)
+			return nil
+		},
+		RestoreSystemUserFunc: func(id uint, entries ...models.AuditLog) error {
+			if !deleted[id] {
+				return repositories.ErrSystemUserNotFound
+			}
+			delete(deleted, id)
+			record(id, entries)
+			return nil
+		},
+		CountActiveAdminsFunc: func(excludeID uint) (int64, error) {
+			var count int64
+			for id, user := range users {
+				if id != excludeID && !deleted[id] && user.Role == models.RoleAdmin && user.IsActive {
+					count++
+				}
+			}
+			return count, nil
+		},
+	}
+}
+
+func TestSystemUserAdministration(t *testing.T) {
+	users := map[uint]*models.SystemUser{
+		1: {ID: 1, Username: "admin", Email: "admin@docubot.local", Role: models.RoleAdmin, IsActive: true},
+	}
+	var audit []models.AuditLog
+	SetSystemUserRepo(memorySystemUserRepo(users, &audit))
+	t.Cleanup(func() { SetSystemUserRepo(nil) })
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("current_user_id", uint(1)) })
+	router.POST("/users", CreateSystemUser)
+	router.PUT("/users/:id", UpdateSystemUser)
+	router.DELETE("/users/:id", DeleteSystemUser)
+	router.POST("/users/:id/restore", RestoreSystemUser)
+	router.POST("/users/:id/deactivate", DeactivateSystemUser)
+	router.POST("/users/:id/password-reset", ResetSystemUserPassword)
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/users", `{"username": "ana", "email": "ana@ruta.co", "password": "corta", "role": "operator"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/users", `{"username": "ana", "email": "ana@ruta.co", "password": "segura-123", "role": "superuser"}`).Code)
+
+	w := call(http.MethodPost, "/users", `{"username": "ana", "email": "Ana@Ruta.co", "password": "segura-123", "role": "operator"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	assert.NotContains(t, w.Body.String(), "segura-123")
+	assert.Equal(t, "ana@ruta.co", users[2].Email)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(users[2].PasswordHash), []byte("segura-123")))
+	assert.Equal(t, http.StatusConflict, call(http.MethodPost, "/users", `{"username": "ana", "email": "otra@ruta.co", "password": "segura-123", "role": "viewer"}`).Code)
+
+	// Cambio de rol y de correo quedan en registros separados
+	w = call(http.MethodPut, "/users/2", `{"role": "admin", "email": "ana.gomez@ruta.co"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPut, "/users/2", `{"password": "otra-clave-1"}`).Code)
+
+	// El administrador no puede quitarse el acceso a sí mismo
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPut, "/users/1", `{"role": "viewer"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodDelete, "/users/1", "").Code)
+
+	// Con Ana desactivada, el único administrador activo es el actual
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "/users/2/deactivate", "").Code)
+	assert.False(t, users[2].IsActive)
+	users[1].IsActive = false // simula que el actual no cuenta como activo
+	users[2].IsActive = true
+	assert.Equal(t, http.StatusConflict, call(http.MethodDelete, "/users/2", "").Code)
+	users[1].IsActive = true
+
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/users/2", "").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPut, "/users/2", `{"role": "viewer"}`).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "/users/2/restore", "").Code)
+
+	// Contraseña temporal generada: se muestra una vez y obliga a cambiarla
+	w = call(http.MethodPost, "/users/2/password-reset", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var reset struct {
+		TemporaryPassword string `json:"temporary_password"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reset))
+	assert.Len(t, reset.TemporaryPassword, 16)
+	assert.True(t, users[2].PasswordResetRequired)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(users[2].PasswordHash), []byte(reset.TemporaryPassword)))
+
+	var actions []string
+	for _, entry := range audit {
+		assert.Equal(t, uint(1), entry.ActorID)
+		assert.Equal(t, uint(2), entry.TargetID)
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{
+		models.AuditUserCreated,
+		models.AuditUserRoleChanged,
+		models.AuditUserUpdated,
+		models.AuditUserDeactivated,
+		models.AuditUserDeleted,
+		models.AuditUserRestored,
+		models.AuditUserPasswordReset,
+	}, actions)
+	assert.Equal(t, models.AuditChange{From: models.RoleOperator, To: models.RoleAdmin}, audit[1].Changes["role"])
+
+	// Ana cambia la contraseña temporal y queda habilitada
+	router = gin.New()
+	router.Use(func(c *gin.Context) { c.Set("current_user_id", uint(2)) })
+	router.POST("/auth/password", ChangePassword)
+	w = call(http.MethodPost, "/auth/password", `{"current_password": "equivocada", "new_password": "nueva-clave-1"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = call(http.MethodPost, "/auth/password", `{"current_password": "`+reset.TemporaryPassword+`", "new_password": "nueva-clave-1"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, users[2].PasswordResetRequired)
+	assert.Equal(t, models.AuditUserPasswordChanged, audit[len(audit)-1].Action)
+	assert.Equal(t, uint(2), audit[len(audit)-1].ActorID)
+}
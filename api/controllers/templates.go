@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+var templateRepo repositories.TemplateRepository
+
+func SetTemplateRepo(repo repositories.TemplateRepository) {
+	templateRepo = repo
+}
+
+// TemplateRequest datos para crear o actualizar una plantilla. En la actualización solo
+// cambian los campos enviados; variants reemplaza todas las variantes.
+type TemplateRequest struct {
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	Variants    *map[string]string `json:"variants"` // idioma → texto, ej: {"es": "Hola {{nombre}}"}
+}
+
+// apply copia a la plantilla los campos enviados
+func (r TemplateRequest) apply(template *models.MessageTemplate) {
+	if r.Name != nil {
+		template.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Description != nil {
+		template.Description = *r.Description
+	}
+	if r.Variants != nil {
+		template.Variants = *r.Variants
+	}
+}
+
+// TemplatePreviewRequest datos con los que se prueba una plantilla
+type TemplatePreviewRequest struct {
+	Language   string            `json:"language"`    // vacío = idioma por defecto
+	ClientID   uint              `json:"client_id"`   // llena nombre, telefono, empresa y correo
+	DocumentID string            `json:"document_id"` // llena documento.*
+	Variables  map[string]string `json:"variables"`   // valores propios; tienen prioridad
+}
+
+// TemplateRender texto de una plantilla con sus variables reemplazadas
+type TemplateRender struct {
+	Template  string   `json:"template"`
+	Language  string   `json:"language"`
+	Text      string   `json:"text"`
+	Variables []string `json:"variables"`         // variables que usa la variante
+	Missing   []string `json:"missing,omitempty"` // variables sin valor
+}
+
+// renderTemplate arma el texto de la plantilla en el idioma pedido (o el de respaldo)
+func renderTemplate(template *models.MessageTemplate, language, fallback string, variables map[string]string) TemplateRender {
+	chosen, body := template.Variant(language, fallback)
+	text, missing := services.RenderTemplate(body, variables)
+	return TemplateRender{
+		Template:  template.Name,
+		Language:  chosen,
+		Text:      text,
+		Variables: services.TemplatePlaceholders(body),
+		Missing:   missing,
+	}
+}
+
+// templateVariables variables disponibles para un cliente y, si se indica, un documento
+func templateVariables(ctx context.Context, client *models.Client, documentID string) (map[string]string, error) {
+	variables := map[string]string{}
+	if client != nil {
+		variables = services.ClientVariables(client)
+	}
+	if documentID == "" {
+		return variables, nil
+	}
+	document, err := documentRepo.GetDocumentByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	url, _ := signDocumentURL(document, time.Now().Add(documentLinkTTL))
+	return services.MergeVariables(variables, services.DocumentVariables(document, url)), nil
+}
+
+// autoReplyText texto de una respuesta automática del bot: la plantilla configurada, en el
+// idioma del bot y con los datos del cliente, o el texto fijo si no hay plantilla o falla
+func autoReplyText(bot *models.Bot, client *models.Client, templateName, text string) string {
+	if templateName == "" || templateRepo == nil {
+		return text
+	}
+	template, err := templateRepo.GetTemplateByName(templateName)
+	if err != nil {
+		log.Printf("⚠️  Plantilla %q del bot %s no disponible, se usa el texto fijo: %v", templateName, bot.Number, err)
+		return text
+	}
+	rendered := renderTemplate(template, bot.Language, models.DefaultBotLanguage, services.ClientVariables(client))
+	if len(rendered.Missing) > 0 {
+		log.Printf("⚠️  Plantilla %q sin valores para %v, se usa el texto fijo", templateName, rendered.Missing)
+		return text
+	}
+	return rendered.Text
+}
+
+// ListTemplates lista las plantillas de mensajes
+// @Summary Listar plantillas
+// @Tags plantillas
+// @Produce json
+// @Param q query string false "Texto a buscar en el nombre o la descripción"
+// @Param page query int false "Página (por defecto 1)"
+// @Param page_size query int false "Plantillas por página (por defecto 50, máximo 200)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/templates [get]
+func ListTemplates(c *gin.Context) {
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+	templates, total, err := templateRepo.ListTemplates(models.TemplateFilter{
+		Search: strings.TrimSpace(c.Query("q")),
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando plantillas", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// CreateTemplate crea una plantilla de mensaje
+// @Summary Crear plantilla
+// @Tags plantillas
+// @Accept json
+// @Produce json
+// @Param template body TemplateRequest true "Plantilla (name y variants obligatorios)"
+// @Success 201 {object} models.MessageTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/templates [post]
+func CreateTemplate(c *gin.Context) {
+	var request TemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+	var template models.MessageTemplate
+	request.apply(&template)
+	if err := template.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plantilla inválida", "details": err.Error()})
+		return
+	}
+	if err := templateRepo.CreateTemplate(&template); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+// GetTemplate devuelve una plantilla
+// @Summary Obtener plantilla
+// @Tags plantillas
+// @Produce json
+// @Param id path int true "ID de la plantilla"
+// @Success 200 {object} models.MessageTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/templates/{id} [get]
+func GetTemplate(c *gin.Context) {
+	template, ok := templateParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdateTemplate actualiza una plantilla
+// @Summary Actualizar plantilla
+// @Description Solo cambian los campos enviados; variants reemplaza todas las variantes
+// @Tags plantillas
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la plantilla"
+// @Param template body TemplateRequest true "Campos a cambiar"
+// @Success 200 {object} models.MessageTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/templates/{id} [put]
+func UpdateTemplate(c *gin.Context) {
+	template, ok := templateParam(c)
+	if !ok {
+		return
+	}
+	var request TemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+		return
+	}
+	request.apply(template)
+	if err := template.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plantilla inválida", "details": err.Error()})
+		return
+	}
+	if err := templateRepo.UpdateTemplate(template); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate elimina una plantilla. Las campañas ya creadas conservan su texto.
+// @Summary Eliminar plantilla
+// @Tags plantillas
+// @Produce json
+// @Param id path int true "ID de la plantilla"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/templates/{id} [delete]
+func DeleteTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+	if err := templateRepo.DeleteTemplate(id); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Plantilla eliminada"})
+}
+
+// PreviewTemplate muestra cómo queda una plantilla con los datos de un cliente, un
+// documento o variables propias, sin enviar nada
+// @Summary Previsualizar plantilla
+// @Tags plantillas
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la plantilla"
+// @Param data body TemplatePreviewRequest false "Idioma y datos para las variables"
+// @Success 200 {object} TemplateRender
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/templates/{id}/preview [post]
+func PreviewTemplate(c *gin.Context) {
+	template, ok := templateParam(c)
+	if !ok {
+		return
+	}
+	var request TemplatePreviewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos", "details": err.Error()})
+			return
+		}
+	}
+
+	var client *models.Client
+	if request.ClientID != 0 {
+		found, err := clientRepo.GetClientByID(request.ClientID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cliente no encontrado"})
+			return
+		}
+		client = found
+	}
+	variables, err := templateVariables(c.Request.Context(), client, request.DocumentID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Documento no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el documento", "details": err.Error()})
+		return
+	}
+
+	variables = services.MergeVariables(variables, request.Variables)
+	c.JSON(http.StatusOK, renderTemplate(template, request.Language, models.DefaultBotLanguage, variables))
+}
+
+func templateIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de plantilla inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func templateParam(c *gin.Context) (*models.MessageTemplate, bool) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return nil, false
+	}
+	template, err := templateRepo.GetTemplateByID(id)
+	if err != nil {
+		respondTemplateError(c, err)
+		return nil, false
+	}
+	return template, true
+}
+
+// templateByName busca la plantilla indicada en una solicitud y responde si no existe
+func templateByName(c *gin.Context, name string) (*models.MessageTemplate, bool) {
+	if templateRepo == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Plantillas no disponibles"})
+		return nil, false
+	}
+	template, err := templateRepo.GetTemplateByName(name)
+	if errors.Is(err, repositories.ErrTemplateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plantilla no encontrada", "details": fmt.Sprintf("template %q does not exist", name)})
+		return nil, false
+	}
+	if err != nil {
+		respondTemplateError(c, err)
+		return nil, false
+	}
+	return template, true
+}
+
+func respondTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plantilla no encontrada"})
+	case errors.Is(err, repositories.ErrTemplateNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay una plantilla con ese nombre"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando la plantilla", "details": err.Error()})
+	}
+}
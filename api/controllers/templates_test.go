@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+	"github.com/brando1998/docubot-api/services"
+)
+
+// memoryTemplateRepo guarda plantillas en memoria sobre MockTemplateRepo
+func memoryTemplateRepo() *mocks.MockTemplateRepo {
+	templates := map[uint]*models.MessageTemplate{}
+	byName := func(name string) *models.MessageTemplate {
+		for _, template := range templates {
+			if template.Name == name {
+				return template
+			}
+		}
+		return nil
+	}
+
+	return &mocks.MockTemplateRepo{
+		GetTemplateByIDFunc: func(id uint) (*models.MessageTemplate, error) {
+			if template, ok := templates[id]; ok {
+				copied := *template
+				return &copied, nil
+			}
+			return nil, repositories.ErrTemplateNotFound
+		},
+		GetTemplateByNameFunc: func(name string) (*models.MessageTemplate, error) {
+			if template := byName(name); template != nil {
+				copied := *template
+				return &copied, nil
+			}
+			return nil, repositories.ErrTemplateNotFound
+		},
+		CreateTemplateFunc: func(template *models.MessageTemplate) error {
+			if byName(template.Name) != nil {
+				return repositories.ErrTemplateNameTaken
+			}
+			template.ID = uint(len(templates) + 1)
+			copied := *template
+			templates[template.ID] = &copied
+			return nil
+		},
+		UpdateTemplateFunc: func(template *models.MessageTemplate) error {
+			if other := byName(template.Name); other != nil && other.ID != template.ID {
+				return repositories.ErrTemplateNameTaken
+			}
+			copied := *template
+			templates[template.ID] = &copied
+			return nil
+		},
+		DeleteTemplateFunc: func(id uint) error {
+			if _, ok := templates[id]; !ok {
+				return repositories.ErrTemplateNotFound
+			}
+			delete(templates, id)
+			return nil
+		},
+	}
+}
+
+func TestTemplateCRUDAndPreview(t *testing.T) {
+	SetTemplateRepo(memoryTemplateRepo())
+	SetClientRepo(&mocks.MockClientRepo{
+		GetClientByIDFunc: func(id uint) (*models.Client, error) {
+			return &models.Client{ID: id, Name: "Ana", Phone: "573001110001", Company: "Transportes Ruta"}, nil
+		},
+	})
+	t.Cleanup(func() { SetTemplateRepo(nil) })
+
+	router := gin.New()
+	router.POST("/templates", CreateTemplate)
+	router.PUT("/templates/:id", UpdateTemplate)
+	router.DELETE("/templates/:id", DeleteTemplate)
+	router.POST("/templates/:id/preview", PreviewTemplate)
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/templates", `{"name": "Aviso Cierre", "variants": {"es": "Hola"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/templates", `{"name": "aviso_cierre", "variants": {"español": "Hola"}}`).Code)
+
+	w := call(http.MethodPost, "/templates", `{
+		"name": "aviso_cierre",
+		"variants": {
+			"es": "Hola {{nombre}}, la vía de {{empresa}} estará cerrada el {{fecha}}",
+			"en": "Hi {{nombre}}, the road will be closed on {{fecha}}"
+		}
+	}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, http.StatusConflict, call(http.MethodPost, "/templates", `{"name": "aviso_cierre", "variants": {"es": "Otro"}}`).Code)
+
+	// es-CO cae en la variante base es; fecha queda pendiente
+	var render TemplateRender
+	w = call(http.MethodPost, "/templates/1/preview", `{"language": "es-CO", "client_id": 1}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &render))
+	assert.Equal(t, "es", render.Language)
+	assert.Equal(t, "Hola Ana, la vía de Transportes Ruta estará cerrada el {{fecha}}", render.Text)
+	assert.Equal(t, []string{"empresa", "fecha", "nombre"}, render.Variables)
+	assert.Equal(t, []string{"fecha"}, render.Missing)
+
+	// Un idioma sin variante usa la del idioma por defecto
+	w = call(http.MethodPost, "/templates/1/preview", `{"language": "pt", "variables": {"nombre": "Beto", "empresa": "Ruta", "fecha": "lunes"}}`)
+	render = TemplateRender{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &render))
+	assert.Equal(t, "es", render.Language)
+	assert.Equal(t, "Hola Beto, la vía de Ruta estará cerrada el lunes", render.Text)
+	assert.Empty(t, render.Missing)
+
+	w = call(http.MethodPut, "/templates/1", `{"description": "Cierres de vía"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated models.MessageTemplate
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "Cierres de vía", updated.Description)
+	assert.Len(t, updated.Variants, 2)
+
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/templates/1", "").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/templates/1/preview", "").Code)
+}
+
+func TestSendTemplateThroughHub(t *testing.T) {
+	templates := memoryTemplateRepo()
+	assert.NoError(t, templates.CreateTemplate(&models.MessageTemplate{
+		Name:     "recordatorio",
+		Variants: map[string]string{"es": "Hola {{nombre}}, tu cita es el {{fecha}}", "en": "Hi {{nombre}}, your appointment is on {{fecha}}"},
+	}))
+	SetTemplateRepo(templates)
+	m := setupInboundMocks(t)
+	m.Client.Name = "Ana"
+	m.Bot.Language = "en"
+	t.Cleanup(func() { SetTemplateRepo(nil) })
+
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+
+	router := gin.New()
+	router.POST("/send", func(c *gin.Context) { SendWhatsAppMessage(c, hub) })
+	send := func(payload string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(payload)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(`{"to": "573001112233", "template": "no_existe"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(`{"to": "573001112233", "template": "recordatorio", "message": "Hola"}`).Code)
+
+	w := send(`{"to": "573001112233", "template": "recordatorio"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"missing":["fecha"]`)
+
+	// Sin idioma en la solicitud se usa el del bot
+	w = send(`{"to": "573001112233", "template": "recordatorio", "variables": {"fecha": "martes"}}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, "Hi Ana, your appointment is on martes", readOutgoing(t, conn).Message)
+
+	w = send(`{"to": "573001112233", "template": "recordatorio", "language": "es", "variables": {"fecha": "martes"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hola Ana, tu cita es el martes", readOutgoing(t, conn).Message)
+}
+
+func TestAutoReplyTextFallsBackToFixedText(t *testing.T) {
+	templates := memoryTemplateRepo()
+	assert.NoError(t, templates.CreateTemplate(&models.MessageTemplate{
+		Name:     "saludo",
+		Variants: map[string]string{"es": "¡Hola {{nombre}}! Soy el asistente de {{empresa}}"},
+	}))
+	assert.NoError(t, templates.CreateTemplate(&models.MessageTemplate{
+		Name:     "saludo_documento",
+		Variants: map[string]string{"es": "Hola {{nombre}}, tu {{documento.tipo}} está listo"},
+	}))
+	SetTemplateRepo(templates)
+	t.Cleanup(func() { SetTemplateRepo(nil) })
+
+	bot := &models.Bot{Number: "573009998877", Language: "es-CO"}
+	client := &models.Client{Name: "Ana", Company: "Transportes Ruta"}
+	assert.Equal(t, "¡Hola Ana! Soy el asistente de Transportes Ruta", autoReplyText(bot, client, "saludo", "Hola"))
+	assert.Equal(t, "Hola", autoReplyText(bot, client, "saludo_documento", "Hola"))
+	assert.Equal(t, "Hola", autoReplyText(bot, client, "borrada", "Hola"))
+	assert.Equal(t, "Hola", autoReplyText(bot, client, "", "Hola"))
+
+	// Al configurar el bot la plantilla debe existir
+	assert.Error(t, validateBot(&models.Bot{Name: "Transporte", Number: "573009998877", Language: "es", WelcomeTemplate: "borrada"}))
+	assert.NoError(t, validateBot(&models.Bot{Name: "Transporte", Number: "573009998877", Language: "es", WelcomeTemplate: "saludo"}))
+	// El motor en memoria es solo para pruebas
+	assert.Error(t, validateBot(&models.Bot{Name: "Transporte", Number: "573009998877", Language: "es", NLUEngine: services.NLUEngineMemory}))
+}
@@ -54,7 +54,7 @@ func setupMockRouter() *gin.Engine {
 
 	r := gin.Default()
 	r.POST("/users", CreateClient)
-	r.GET("/users/profile", GetClientByID)
+	r.GET("/users/id/:id", GetClientByID)
 	r.POST("/users/get-or-create", GetOrCreateClient)
 	r.GET("/users/:phone", GetClientByPhone)
 	return r
@@ -86,7 +86,7 @@ func TestGetProfile(t *testing.T) {
 	r := setupMockRouter()
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/users/profile", nil)
+	req, _ := http.NewRequest("GET", "/users/id/1", nil)
 
 	r.ServeHTTP(w, req)
 
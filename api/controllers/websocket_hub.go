@@ -1,33 +1,47 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/brando1998/docubot-api/repositories"
 )
 
+// ErrNoBaileysConnection no hay ninguna conexión de Baileys que pueda atender la orden
+var ErrNoBaileysConnection = errors.New("no baileys connection available")
+
 type WebSocketHub struct {
-	mu      sync.RWMutex
-	bots    map[string]*websocket.Conn // Conexiones de bots (key: bot phone number)
-	clients map[string]*websocket.Conn // Conexiones de clientes (key: client phone number)
-}
+	mu   sync.RWMutex
+	bots map[string]*BotConnection // Conexiones de bots (key: bot phone number)
+	// Conexión de Baileys designada para abrir sesiones de números que aún no tienen la suya
+	sessionManager *BotConnection
+
+	clientsMu sync.RWMutex
+	clients   map[*DashboardClient]struct{} // Suscriptores del dashboard (operadores)
+
+	outbox   repositories.OutboxRepository // Cola persistente de mensajes salientes (opcional)
+	outboxMu sync.Mutex                    // Serializa las entregas para conservar el orden
 
-type Client struct {
-	Phone string
-	Conn  *websocket.Conn
+	handlersMu sync.RWMutex
+	handlers   map[string]FrameHandler // Manejadores por tipo de frame
 }
 
 func NewWebSocketHub() *WebSocketHub {
-	return &WebSocketHub{
-		bots:    make(map[string]*websocket.Conn),
-		clients: make(map[string]*websocket.Conn),
+	hub := &WebSocketHub{
+		bots:     make(map[string]*BotConnection),
+		clients:  make(map[*DashboardClient]struct{}),
+		handlers: make(map[string]FrameHandler),
 	}
+	registerDefaultFrameHandlers(hub)
+	return hub
 }
 
 // Métodos para Bots
-func (h *WebSocketHub) RegisterBot(botPhone string, conn *websocket.Conn) {
+func (h *WebSocketHub) RegisterBot(botPhone string, conn *websocket.Conn) *BotConnection {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -35,29 +49,101 @@ func (h *WebSocketHub) RegisterBot(botPhone string, conn *websocket.Conn) {
 		log.Printf("Conexión existente para bot %s, cerrando...", botPhone)
 		existing.Close()
 	}
-	print("REGISTRANDO")
-	println(botPhone)
-	h.bots[botPhone] = conn
+	botConn := newBotConnection(botPhone, conn)
+	h.bots[botPhone] = botConn
 	log.Printf("Bot %s registrado exitosamente", botPhone)
+	h.PublishDashboard(DashboardEventBotConnected, botPhone, botConn.State())
+	return botConn
 }
 
 func (h *WebSocketHub) UnregisterBot(botPhone string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	if conn, ok := h.bots[botPhone]; ok {
-		conn.Close()
+	if botConn, ok := h.bots[botPhone]; ok {
+		botConn.Close()
 		delete(h.bots, botPhone)
+		h.PublishDashboard(DashboardEventBotDisconnected, botPhone, nil)
 	}
 }
 
-func (h *WebSocketHub) SendToBot(botPhone string, message interface{}) error {
+// RegisterSessionManager registra la conexión que atiende las órdenes start de números
+// sin conexión propia; reemplaza (y cierra) la anterior
+func (h *WebSocketHub) RegisterSessionManager(conn *websocket.Conn) *BotConnection {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sessionManager != nil {
+		log.Println("Conexión existente del gestor de sesiones, cerrando...")
+		h.sessionManager.Close()
+	}
+	h.sessionManager = newBotConnection("", conn)
+	log.Println("Gestor de sesiones de Baileys registrado")
+	return h.sessionManager
+}
+
+// SessionManager devuelve la conexión del gestor de sesiones o nil si no hay
+func (h *WebSocketHub) SessionManager() *BotConnection {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	println(botPhone)
-	if conn, ok := h.bots[botPhone]; ok {
-		return conn.WriteJSON(message)
+	return h.sessionManager
+}
+
+// UnregisterConnection cierra la conexión y la quita del hub solo si sigue siendo
+// la registrada para ese bot (una reconexión pudo haberla reemplazado)
+func (h *WebSocketHub) UnregisterConnection(botConn *BotConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	botConn.Close()
+	if h.sessionManager == botConn {
+		h.sessionManager = nil
+		return
+	}
+	if current, ok := h.bots[botConn.Phone]; ok && current == botConn {
+		delete(h.bots, botConn.Phone)
+		h.PublishDashboard(DashboardEventBotDisconnected, botConn.Phone, nil)
 	}
-	return fmt.Errorf("bot not found")
+}
+
+// Shutdown cierra ordenadamente todas las conexiones de bots
+func (h *WebSocketHub) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for botPhone, botConn := range h.bots {
+		botConn.Close()
+		delete(h.bots, botPhone)
+	}
+	if h.sessionManager != nil {
+		h.sessionManager.Close()
+		h.sessionManager = nil
+	}
+}
+
+// SendToBot envía un frame outbound_message al bot y devuelve su id. Si hay outbox
+// configurado, el frame se persiste primero y se entrega en orden; si el bot está
+// desconectado queda en cola.
+func (h *WebSocketHub) SendToBot(botPhone string, message OutgoingMessage) (string, error) {
+	env, err := NewEnvelope(FrameOutboundMessage, message)
+	if err != nil {
+		return "", err
+	}
+	return env.ID, h.SendEnvelope(botPhone, message.To, env)
+}
+
+// SendEnvelope envía un frame ya armado (con su id) al bot, pasando por el outbox si existe
+func (h *WebSocketHub) SendEnvelope(botPhone, recipient string, env Envelope) error {
+	if h.outbox != nil {
+		return h.enqueueOutbound(botPhone, recipient, env)
+	}
+	return h.writeToBot(botPhone, env)
+}
+
+// writeToBot entrega el mensaje al escritor de la conexión y espera el resultado
+func (h *WebSocketHub) writeToBot(botPhone string, message interface{}) error {
+	botConn, err := h.GetBotConnection(botPhone)
+	if err != nil {
+		return err
+	}
+	return botConn.SendJSON(message)
 }
 
 func (h *WebSocketHub) ListBots() []string {
@@ -71,12 +157,34 @@ func (h *WebSocketHub) ListBots() []string {
 }
 
 // Método para obtener conexión de bot
-func (h *WebSocketHub) GetBotConnection(botPhone string) (*websocket.Conn, error) {
+func (h *WebSocketHub) GetBotConnection(botPhone string) (*BotConnection, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if conn, ok := h.bots[botPhone]; ok {
-		return conn, nil
+	if botConn, ok := h.bots[botPhone]; ok {
+		return botConn, nil
 	}
 	return nil, fmt.Errorf("bot not found")
 }
+
+// SendSessionCommand envía una orden para la sesión de WhatsApp de un bot y devuelve el
+// id del frame. Si el bot no tiene conexión (número nuevo), la orden start va al gestor
+// de sesiones de Baileys, que abre la sesión y su propia conexión; sin gestor devuelve
+// ErrNoBaileysConnection.
+func (h *WebSocketHub) SendSessionCommand(botPhone, action string) (string, error) {
+	env, err := NewEnvelope(FrameSessionCommand, SessionCommandPayload{Action: action, BotNumber: botPhone})
+	if err != nil {
+		return "", err
+	}
+
+	botConn, err := h.GetBotConnection(botPhone)
+	if err != nil {
+		if action != SessionActionStart {
+			return "", err
+		}
+		if botConn = h.SessionManager(); botConn == nil {
+			return "", ErrNoBaileysConnection
+		}
+	}
+	return env.ID, botConn.SendJSON(env)
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+)
+
+// newMemoryOutbox arma un outbox en memoria sobre el mock del repositorio
+func newMemoryOutbox() (*mocks.MockOutboxRepo, func() []models.OutboundMessage) {
+	var mu sync.Mutex
+	var stored []models.OutboundMessage
+
+	repo := &mocks.MockOutboxRepo{
+		EnqueueFunc: func(message *models.OutboundMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			message.ID = uint(len(stored) + 1)
+			stored = append(stored, *message)
+			return nil
+		},
+		GetUnackedByBotFunc: func(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			var unacked []models.OutboundMessage
+			for _, message := range stored {
+				unackedStatus := message.Status == models.OutboundStatusPending || message.Status == models.OutboundStatusSent
+				if message.BotNumber == botNumber && unackedStatus && message.ID > afterID && len(unacked) < limit {
+					unacked = append(unacked, message)
+				}
+			}
+			return unacked, nil
+		},
+		MarkSentFunc: func(id uint, sentAt time.Time, ackDeadline time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stored[id-1].Status = models.OutboundStatusSent
+			stored[id-1].SentAt = &sentAt
+			stored[id-1].NextAttemptAt = ackDeadline
+			return nil
+		},
+		MarkAckedFunc: func(envelopeID, whatsAppID string, ackedAt time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := range stored {
+				if stored[i].EnvelopeID == envelopeID {
+					stored[i].Status = models.OutboundStatusAcked
+					stored[i].AckedAt = &ackedAt
+					stored[i].WhatsAppID = whatsAppID
+				}
+			}
+			return nil
+		},
+		MarkRetryFunc: func(id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stored[id-1].Status = models.OutboundStatusPending
+			stored[id-1].Attempts = attempts
+			stored[id-1].NextAttemptAt = nextAttemptAt
+			stored[id-1].LastError = lastError
+			return nil
+		},
+	}
+
+	snapshot := func() []models.OutboundMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]models.OutboundMessage(nil), stored...)
+	}
+	return repo, snapshot
+}
+
+// dialTestBot levanta el endpoint /ws y conecta un bot de prueba
+func dialTestBot(t *testing.T, hub *WebSocketHub, botPhone string) (*httptest.Server, *websocket.Conn) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) {
+		HandleWebSocket(c, hub, websocket.Upgrader{})
+	})
+	server := httptest.NewServer(r)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?phone="+botPhone, baileysHeader())
+	assert.NoError(t, err)
+	return server, conn
+}
+
+// baileysHeader encabezado con la clave de Baileys configurada en la prueba (si hay)
+func baileysHeader() http.Header {
+	if baileysSecret == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + baileysSecret}}
+}
+
+// readOutgoing lee un frame outbound_message y devuelve su payload
+func readOutgoing(t *testing.T, conn *websocket.Conn) OutgoingMessage {
+	var env Envelope
+	assert.NoError(t, conn.ReadJSON(&env))
+	assert.Equal(t, FrameOutboundMessage, env.Type)
+	assert.Equal(t, ProtocolVersion, env.Version)
+
+	var message OutgoingMessage
+	assert.NoError(t, env.DecodePayload(&message))
+	return message
+}
+
+func TestOutboxFlushesQueuedMessagesOnRegister(t *testing.T) {
+	outbox, snapshot := newMemoryOutbox()
+	hub := NewWebSocketHub()
+	hub.SetOutbox(outbox)
+
+	// El bot está desconectado: los mensajes quedan en cola
+	for _, text := range []string{"uno", "dos"} {
+		_, err := hub.SendToBot("573009998877", OutgoingMessage{To: "573001112233", Type: models.MessageTypeText, Message: text})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, models.OutboundStatusPending, snapshot()[0].Status)
+	assert.Equal(t, "573001112233", snapshot()[0].Recipient)
+
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, expected := range []string{"uno", "dos"} {
+		assert.Equal(t, expected, readOutgoing(t, conn).Message)
+	}
+
+	assert.Eventually(t, func() bool {
+		for _, message := range snapshot() {
+			if message.Status != models.OutboundStatusSent || message.SentAt == nil {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOutboxResendsUnackedMessagesOnReconnect(t *testing.T) {
+	SetConversationRepo(&mocks.MockConversationRepo{
+		UpdateMessageStatusFunc: func(ctx context.Context, update models.MessageStatusUpdate) error { return nil },
+	})
+	outbox, snapshot := newMemoryOutbox()
+	hub := NewWebSocketHub()
+	hub.SetOutbox(outbox)
+
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+	id, err := hub.SendToBot("573009998877", OutgoingMessage{To: "573001112233", Type: models.MessageTypeText, Message: "uno"})
+	assert.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.Equal(t, "uno", readOutgoing(t, conn).Message)
+	assert.Equal(t, models.OutboundStatusSent, snapshot()[0].Status)
+
+	// El socket se corta antes del ack: el frame se reenvía al reconectar
+	conn.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 0 }, 2*time.Second, 10*time.Millisecond)
+	conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?phone=573009998877", baileysHeader())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.Equal(t, "uno", readOutgoing(t, conn).Message)
+
+	// Con el ack deja de reenviarse
+	ack, _ := NewEnvelope(FrameAck, AckPayload{Ref: id, WhatsAppID: "3EB0C767D26A"})
+	assert.NoError(t, conn.WriteJSON(ack))
+	assert.Eventually(t, func() bool { return snapshot()[0].Status == models.OutboundStatusAcked }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, hub.FlushOutbox("573009998877", true))
+	assert.Equal(t, models.OutboundStatusAcked, snapshot()[0].Status)
+}
+
+func TestOutboxRetriesMessageWhoseAckTimedOut(t *testing.T) {
+	outbox, snapshot := newMemoryOutbox()
+	hub := NewWebSocketHub()
+	hub.SetOutbox(outbox)
+
+	sentAt := time.Now().Add(-time.Hour)
+	assert.NoError(t, outbox.Enqueue(&models.OutboundMessage{BotNumber: "573009998877", Status: models.OutboundStatusSent, SentAt: &sentAt, NextAttemptAt: sentAt.Add(outboxAckTimeout)}))
+	assert.NoError(t, outbox.Enqueue(&models.OutboundMessage{BotNumber: "573009998877", Status: models.OutboundStatusSent, SentAt: &sentAt, NextAttemptAt: time.Now().Add(time.Minute)}))
+
+	assert.NoError(t, hub.FlushOutbox("573009998877", false))
+	stored := snapshot()
+	assert.Equal(t, models.OutboundStatusPending, stored[0].Status)
+	assert.Equal(t, 1, stored[0].Attempts)
+	assert.Equal(t, errOutboxAckTimeout.Error(), stored[0].LastError)
+	assert.Equal(t, models.OutboundStatusSent, stored[1].Status, "el segundo sigue esperando su ack")
+}
+
+func TestOutboxKeepsOrderWhileMessageWaitsForRetry(t *testing.T) {
+	outbox, snapshot := newMemoryOutbox()
+	hub := NewWebSocketHub()
+	hub.SetOutbox(outbox)
+
+	// El primer mensaje espera su reintento; el segundo ya vencería, pero no debe adelantarse
+	assert.NoError(t, outbox.Enqueue(&models.OutboundMessage{BotNumber: "573009998877", Status: models.OutboundStatusPending, Attempts: 1, NextAttemptAt: time.Now().Add(time.Minute)}))
+	assert.NoError(t, outbox.Enqueue(&models.OutboundMessage{BotNumber: "573009998877", Status: models.OutboundStatusPending, NextAttemptAt: time.Now()}))
+
+	assert.NoError(t, hub.FlushOutbox("573009998877", false))
+	assert.Equal(t, 0, snapshot()[1].Attempts, "no se intentó entregar el segundo mensaje")
+	assert.Equal(t, models.OutboundStatusPending, snapshot()[1].Status)
+}
+
+func TestQueuedMessageIsNotFailedWhenDeliveryWillBeRetried(t *testing.T) {
+	m := setupInboundMocks(t)
+	outbox, snapshot := newMemoryOutbox()
+	hub := NewWebSocketHub()
+	hub.SetOutbox(outbox)
+
+	// El bot figura conectado pero su socket ya se cerró: la escritura falla
+	closed := &BotConnection{Phone: "573009998877", done: make(chan struct{})}
+	close(closed.done)
+	hub.mu.Lock()
+	hub.bots[closed.Phone] = closed
+	hub.mu.Unlock()
+
+	message, err := sendOutgoingMessage(hub, "573009998877", "573001112233", models.Message{ClientID: 7, BotID: 3, Type: models.MessageTypeText, Text: "hola"}, nil)
+	assert.NoError(t, err)
+	// Queda en cola para reintento y no se marca como fallido: el reintento, el ack o el
+	// recibo todavía pueden avanzar su estado
+	assert.Equal(t, models.MessageStatusPending, message.Status)
+	assert.Empty(t, m.Updates)
+	if stored := snapshot(); assert.Len(t, stored, 1) {
+		assert.Equal(t, models.OutboundStatusPending, stored[0].Status)
+		assert.Equal(t, 1, stored[0].Attempts)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, outboxBaseBackoff, outboxBackoff(1))
+	assert.Equal(t, 4*outboxBaseBackoff, outboxBackoff(3))
+	assert.Equal(t, outboxMaxBackoff, outboxBackoff(20))
+}
+
+func TestConcurrentSendsAreSerialized(t *testing.T) {
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+
+	const total = 50
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := hub.SendToBot("573009998877", OutgoingMessage{To: "573001112233", Type: models.MessageTypeText, Message: "hola"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < total; i++ {
+		assert.Equal(t, "hola", readOutgoing(t, conn).Message)
+	}
+	wg.Wait()
+}
+
+func TestReconnectingBotReplacesPreviousConnection(t *testing.T) {
+	SetBaileysSecret("clave-compartida-de-baileys")
+	t.Cleanup(func() { SetBaileysSecret("") })
+	hub := NewWebSocketHub()
+	server, stale := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+	previous, _ := hub.GetBotConnection("573009998877")
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?phone=573009998877"
+
+	// Sin la clave de Baileys no se puede tomar el bot
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer otra-clave"}})
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+	_, resp, err = websocket.DefaultDialer.Dial(url, nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, baileysHeader())
+	if !assert.NoError(t, err, "la reconexión no debe rechazarse") {
+		return
+	}
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		current, err := hub.GetBotConnection("573009998877")
+		return err == nil && current != previous
+	}, time.Second, 10*time.Millisecond)
+	stale.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = stale.ReadMessage()
+	assert.Error(t, err, "la conexión anterior se cierra")
+	assert.Len(t, hub.ListBots(), 1)
+
+	_, err = hub.SendToBot("573009998877", OutgoingMessage{To: "573001112233", Type: models.MessageTypeText, Message: "hola"})
+	assert.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.Equal(t, "hola", readOutgoing(t, conn).Message)
+}
+
+func TestClosedBotSocketIsUnregistered(t *testing.T) {
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+	conn.Close()
+
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 0 }, 2*time.Second, 10*time.Millisecond)
+	_, err := hub.SendToBot("573009998877", OutgoingMessage{To: "573001112233", Message: "hola"})
+	assert.Error(t, err)
+}
+
+func TestAnonymousBotCannotReplaceConnection(t *testing.T) {
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+	previous, _ := hub.GetBotConnection("573009998877")
+
+	// Sin clave configurada, una segunda conexión para el mismo bot se rechaza
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?phone=573009998877", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	}
+	current, err := hub.GetBotConnection("573009998877")
+	assert.NoError(t, err)
+	assert.Same(t, previous, current)
+}
@@ -1,19 +1,34 @@
-// api/controllers/whatsapp.go - Versión corregida y completa
+// api/controllers/whatsapp.go - Sesiones de WhatsApp por número de bot
 package controllers
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+// Estados de sesión que ve el dashboard
+const (
+	WhatsAppStatusConnected      = "connected"        // sesión vinculada y abierta
+	WhatsAppStatusWaitingForScan = "waiting_for_scan" // hay un QR pendiente de escanear
+	WhatsAppStatusInitializing   = "initializing"     // Baileys está abriendo la sesión
+	WhatsAppStatusDisconnected   = "disconnected"     // Baileys conectado, sesión de WhatsApp cerrada
+	WhatsAppStatusOffline        = "offline"          // Baileys no tiene conexión para el número
 )
 
 // WhatsAppQRResponse estructura para la respuesta del QR
 type WhatsAppQRResponse struct {
 	Status      string `json:"status"`
 	Message     string `json:"message"`
+	BotID       uint   `json:"bot_id"`
+	BotNumber   string `json:"bot_number"`
 	QRCode      string `json:"qr_code,omitempty"`
 	QRImage     string `json:"qr_image,omitempty"`
 	Connected   bool   `json:"connected"`
@@ -30,7 +45,9 @@ type WhatsAppStatusResponse struct {
 	Status      string    `json:"status"`
 	Message     string    `json:"message,omitempty"`
 	Connected   bool      `json:"connected"`
+	BotID       uint      `json:"bot_id"`
 	BotNumber   string    `json:"bot_number,omitempty"`
+	Reason      string    `json:"reason,omitempty"` // motivo del último cierre de sesión
 	LastSeen    time.Time `json:"last_seen,omitempty"`
 	SessionInfo struct {
 		Name   string `json:"name,omitempty"`
@@ -38,158 +55,153 @@ type WhatsAppStatusResponse struct {
 	} `json:"session_info"`
 }
 
-// SendMessageRequest estructura para enviar mensajes
-type SendMessageRequest struct {
-	To      string `json:"to" binding:"required"`
-	Message string `json:"message" binding:"required"`
-}
-
-// BaileysRequest estructura para comunicación con Baileys
-type BaileysRequest struct {
-	Action string `json:"action"`
+// CreateSessionRequest bot (número o ID) para el que se abre la sesión
+type CreateSessionRequest struct {
+	BotNumber string `json:"bot_number" binding:"required"`
 }
 
 // GetWhatsAppQR obtiene el código QR o estado de sesión
 // @Summary Obtener QR de WhatsApp o estado de sesión
-// @Description Retorna el código QR para conectar WhatsApp o información de sesión activa
+// @Description Retorna el código QR para vincular el número del bot o la información de la sesión activa. Si la sesión no está abierta, pide a Baileys iniciarla.
 // @Tags whatsapp
 // @Produce json
+// @Param bot query string false "Número o ID del bot (opcional si hay un solo bot conectado)"
 // @Success 200 {object} WhatsAppQRResponse
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /api/v1/whatsapp/qr [get]
-func GetWhatsAppQR(c *gin.Context) {
-	// URL del servicio Baileys
-	baileysURL := "http://baileys:3000/qr" // Ajusta según tu configuración
-
-	// Hacer solicitud a Baileys para obtener QR o estado
-	resp, err := http.Get(baileysURL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error conectando con servicio de WhatsApp",
-			"details": err.Error(),
-		})
+func GetWhatsAppQR(c *gin.Context, hub *WebSocketHub) {
+	bot, ok := resolveSessionBot(c, hub, c.Query("bot"))
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error en el servicio de WhatsApp",
-		})
-		return
+	status := sessionStatus(hub, bot)
+	if status.Status == WhatsAppStatusOffline || status.Status == WhatsAppStatusDisconnected {
+		if !sendSessionCommand(c, hub, bot.Number, SessionActionStart) {
+			return
+		}
+		status.Status = WhatsAppStatusInitializing
 	}
 
-	// Decodificar respuesta de Baileys
-	var baileysResponse WhatsAppQRResponse
-	if err := json.NewDecoder(resp.Body).Decode(&baileysResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error procesando respuesta de WhatsApp",
-			"details": err.Error(),
-		})
-		return
+	response := WhatsAppQRResponse{
+		Status:    status.Status,
+		BotID:     bot.ID,
+		BotNumber: bot.Number,
+		Connected: status.Connected,
 	}
-
-	// Retornar respuesta de Baileys tal como la recibimos
-	c.JSON(http.StatusOK, baileysResponse)
+	switch status.Status {
+	case WhatsAppStatusConnected:
+		response.Message = "WhatsApp ya está conectado"
+		response.SessionInfo.Number = bot.Number
+		response.SessionInfo.Name = status.SessionInfo.Name
+		response.SessionInfo.LastSeen = status.LastSeen
+	case WhatsAppStatusWaitingForScan:
+		state, _ := botState(hub, bot.Number)
+		response.Message = "Escanea el código QR en WhatsApp"
+		response.QRCode = state.QRCode
+		response.QRImage = state.QRImage
+	default:
+		response.Message = "Iniciando sesión de WhatsApp..."
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// DisconnectWhatsApp termina la sesión actual
+// DisconnectWhatsApp termina la sesión del bot
 // @Summary Desconectar sesión de WhatsApp
-// @Description Termina la sesión activa de WhatsApp
+// @Description Cierra la sesión de WhatsApp del bot y borra sus credenciales en Baileys
 // @Tags whatsapp
 // @Produce json
+// @Param bot query string false "Número o ID del bot (opcional si hay un solo bot conectado)"
 // @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /api/v1/whatsapp/disconnect [post]
-func DisconnectWhatsApp(c *gin.Context) {
-	// URL del servicio Baileys para desconectar
-	baileysURL := "http://baileys:3000/disconnect"
-
-	// Hacer solicitud POST a Baileys
-	resp, err := http.Post(baileysURL, "application/json", nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error conectando con servicio de WhatsApp",
-			"details": err.Error(),
-		})
+func DisconnectWhatsApp(c *gin.Context, hub *WebSocketHub) {
+	bot, ok := resolveSessionBot(c, hub, c.Query("bot"))
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error desconectando WhatsApp",
-		})
-		return
-	}
-
-	// Decodificar respuesta de Baileys
-	var baileysResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&baileysResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error procesando respuesta",
-			"details": err.Error(),
-		})
+	if !sendSessionCommand(c, hub, bot.Number, SessionActionLogout) {
 		return
 	}
-
-	c.JSON(http.StatusOK, baileysResponse)
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "Cierre de sesión enviado a WhatsApp",
+		"bot_number": bot.Number,
+	})
 }
 
 // GetSessionStatus obtiene el estado detallado de la sesión
 // @Summary Obtener estado detallado de sesión
-// @Description Retorna información detallada del estado de la sesión de WhatsApp
+// @Description Retorna el estado de la sesión de WhatsApp del bot según lo último reportado por Baileys
 // @Tags whatsapp
 // @Produce json
+// @Param bot query string false "Número o ID del bot (opcional si hay un solo bot conectado)"
 // @Success 200 {object} WhatsAppStatusResponse
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Router /api/v1/whatsapp/status [get]
-func GetSessionStatus(c *gin.Context) {
-	// URL del servicio Baileys para obtener estado
-	baileysURL := "http://baileys:3000/status"
-
-	resp, err := http.Get(baileysURL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error conectando con servicio de WhatsApp",
-			"details": err.Error(),
-		})
+func GetSessionStatus(c *gin.Context, hub *WebSocketHub) {
+	bot, ok := resolveSessionBot(c, hub, c.Query("bot"))
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
+	c.JSON(http.StatusOK, sessionStatus(hub, bot))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error obteniendo estado de WhatsApp",
-		})
-		return
+// ListWhatsAppSessions lista las sesiones de todos los bots conectados
+// @Summary Listar sesiones de WhatsApp
+// @Description Estado de la sesión de cada número con conexión de Baileys
+// @Tags whatsapp
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/whatsapp/sessions [get]
+func ListWhatsAppSessions(c *gin.Context, hub *WebSocketHub) {
+	sessions := []WhatsAppStatusResponse{}
+	for _, number := range hub.ListBots() {
+		bot, err := botRepo.GetBotByNumber(number)
+		if err != nil {
+			bot = &models.Bot{Number: number}
+		}
+		sessions = append(sessions, sessionStatus(hub, bot))
 	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "total": len(sessions)})
+}
 
-	var baileysResponse WhatsAppStatusResponse
-	if err := json.NewDecoder(resp.Body).Decode(&baileysResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error procesando respuesta",
-			"details": err.Error(),
-		})
+// GetWhatsAppSession obtiene información de la sesión de un bot
+// @Summary Obtener sesión de WhatsApp
+// @Description Obtiene el estado de la sesión de WhatsApp de un bot
+// @Tags whatsapp
+// @Produce json
+// @Param session_id path string true "Número o ID del bot"
+// @Success 200 {object} WhatsAppStatusResponse
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/whatsapp/session/{session_id} [get]
+func GetWhatsAppSession(c *gin.Context, hub *WebSocketHub) {
+	bot, ok := resolveSessionBot(c, hub, c.Param("session_id"))
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, baileysResponse)
+	c.JSON(http.StatusOK, sessionStatus(hub, bot))
 }
 
-// SendWhatsAppMessage envía un mensaje por WhatsApp
-// @Summary Enviar mensaje por WhatsApp
-// @Description Envía un mensaje de texto a un número específico
+// CreateWhatsAppSession crea o reinicia la sesión de WhatsApp de un número
+// @Summary Crear sesión de WhatsApp
+// @Description Pide a Baileys abrir la sesión de un bot registrado y activo (o reiniciarla si ya existe). El QR llega después por GET /api/v1/whatsapp/qr.
 // @Tags whatsapp
 // @Accept json
 // @Produce json
-// @Param message body SendMessageRequest true "Datos del mensaje"
+// @Param session body CreateSessionRequest true "Número del bot"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/v1/whatsapp/send [post]
-func SendWhatsAppMessage(c *gin.Context) {
-	var request SendMessageRequest
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/whatsapp/session [post]
+func CreateWhatsAppSession(c *gin.Context, hub *WebSocketHub) {
+	var request CreateSessionRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Datos inválidos",
@@ -198,129 +210,114 @@ func SendWhatsAppMessage(c *gin.Context) {
 		return
 	}
 
-	// Preparar mensaje para Baileys
-	messagePayload := map[string]string{
-		"to":      request.To,
-		"message": request.Message,
-	}
-
-	jsonData, err := json.Marshal(messagePayload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error preparando mensaje",
-		})
-		return
-	}
-
-	// Enviar a Baileys
-	baileysURL := "http://baileys:3000/send"
-	resp, err := http.Post(baileysURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error enviando mensaje",
-			"details": err.Error(),
-		})
+	bot, ok := resolveSessionBot(c, hub, request.BotNumber)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error en el servicio de WhatsApp",
-		})
+	if !bot.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El bot está inactivo"})
 		return
 	}
 
-	var baileysResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&baileysResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error procesando respuesta",
-			"details": err.Error(),
-		})
-		return
+	action := SessionActionStart
+	if _, err := hub.GetBotConnection(bot.Number); err == nil {
+		action = SessionActionRestart
 	}
-
-	c.JSON(http.StatusOK, baileysResponse)
-}
-
-// GetWhatsAppSession obtiene información de una sesión específica
-// @Summary Obtener sesión de WhatsApp
-// @Description Obtiene información de una sesión específica de WhatsApp
-// @Tags whatsapp
-// @Produce json
-// @Param session_id path string true "ID de la sesión"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/v1/whatsapp/session/{session_id} [get]
-func GetWhatsAppSession(c *gin.Context) {
-	sessionID := c.Param("session_id")
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "ID de sesión requerido",
-		})
+	if !sendSessionCommand(c, hub, bot.Number, action) {
 		return
 	}
-
-	// Por ahora retornamos el estado general ya que Baileys maneja una sola sesión
-	status := checkBaileysConnection()
 	c.JSON(http.StatusOK, gin.H{
-		"session_id": sessionID,
-		"status":     status,
+		"success":    true,
+		"message":    "Sesión creada/reiniciada correctamente",
+		"status":     WhatsAppStatusInitializing,
+		"bot_id":     bot.ID,
+		"bot_number": bot.Number,
 	})
 }
 
-// CreateWhatsAppSession crea una nueva sesión de WhatsApp
-// @Summary Crear sesión de WhatsApp
-// @Description Crea una nueva sesión de WhatsApp (reinicia Baileys)
-// @Tags whatsapp
-// @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
-// @Router /api/v1/whatsapp/session [post]
-func CreateWhatsAppSession(c *gin.Context) {
-	// URL para reiniciar/crear sesión en Baileys
-	baileysURL := "http://baileys:3000/restart" // Endpoint que deberías implementar en Baileys
+// Funciones auxiliares
 
-	resp, err := http.Post(baileysURL, "application/json", nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error creando sesión",
-			"details": err.Error(),
-		})
-		return
+// resolveSessionBot busca el bot por número o ID; sin referencia usa el único bot conectado
+func resolveSessionBot(c *gin.Context, hub *WebSocketHub, ref string) (*models.Bot, bool) {
+	if ref == "" {
+		connected := hub.ListBots()
+		if len(connected) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Indica el bot (número o ID): hay " + strconv.Itoa(len(connected)) + " bots conectados"})
+			return nil, false
+		}
+		ref = connected[0]
 	}
-	defer resp.Body.Close()
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Sesión creada/reiniciada correctamente",
-		"status":  "restarting",
-	})
+	bot, err := lookupBot(ref)
+	if errors.Is(err, repositories.ErrBotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bot no encontrado; regístralo en /api/v1/bots"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el bot", "details": err.Error()})
+		return nil, false
+	}
+	return bot, true
 }
 
-// Funciones auxiliares
-
-func checkBaileysConnection() map[string]interface{} {
-	// Verificar conexión con Baileys vía HTTP
-	baileysURL := "http://baileys:3000/health"
+// lookupBot busca primero por número y, si la referencia es numérica, por ID
+func lookupBot(ref string) (*models.Bot, error) {
+	bot, err := botRepo.GetBotByNumber(normalizeBotNumber(ref))
+	if !errors.Is(err, repositories.ErrBotNotFound) {
+		return bot, err
+	}
+	if id, convErr := strconv.ParseUint(ref, 10, 64); convErr == nil {
+		return botRepo.GetBotByID(uint(id))
+	}
+	return nil, err
+}
 
-	resp, err := http.Get(baileysURL)
+func botState(hub *WebSocketHub, botNumber string) (BotState, bool) {
+	botConn, err := hub.GetBotConnection(botNumber)
 	if err != nil {
-		return map[string]interface{}{
-			"connected": false,
-			"error":     err.Error(),
-		}
+		return BotState{}, false
 	}
-	defer resp.Body.Close()
+	return botConn.State(), true
+}
 
-	var healthResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&healthResponse); err != nil {
-		return map[string]interface{}{
-			"connected": false,
-			"error":     "Error parsing response",
-		}
+// sessionStatus estado de la sesión según el último connection_state/qr_update del bot
+func sessionStatus(hub *WebSocketHub, bot *models.Bot) WhatsAppStatusResponse {
+	response := WhatsAppStatusResponse{BotID: bot.ID, BotNumber: bot.Number}
+	state, online := botState(hub, bot.Number)
+	response.SessionInfo.Name = state.Name
+	response.Reason = state.Reason
+	response.LastSeen = state.UpdatedAt
+
+	switch {
+	case !online:
+		response.Status = WhatsAppStatusOffline
+		response.Message = "Baileys no tiene conexión para este número"
+	case state.Connection == "open":
+		response.Status = WhatsAppStatusConnected
+		response.Connected = true
+	case state.QRCode != "":
+		response.Status = WhatsAppStatusWaitingForScan
+		response.Message = "Escanea el código QR en WhatsApp"
+	case state.Connection == "close":
+		response.Status = WhatsAppStatusDisconnected
+	default:
+		response.Status = WhatsAppStatusInitializing
 	}
+	return response
+}
 
-	return healthResponse
+// sendSessionCommand envía la orden a Baileys y responde el error si no se pudo
+func sendSessionCommand(c *gin.Context, hub *WebSocketHub, botNumber, action string) bool {
+	id, err := hub.SendSessionCommand(botNumber, action)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		message := "El número no tiene conexión con Baileys"
+		if errors.Is(err, ErrNoBaileysConnection) {
+			message = "No hay ningún gestor de sesiones de Baileys conectado"
+		}
+		c.JSON(status, gin.H{"error": message, "details": err.Error()})
+		return false
+	}
+	log.Printf("📲 Orden %s (%s) enviada a la sesión del bot %s", action, id, botNumber)
+	return true
 }
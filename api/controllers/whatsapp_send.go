@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+// Límites de WhatsApp para los títulos de las opciones
+const (
+	maxButtonTitleLength  = 20
+	maxListRowTitleLength = 24
+)
+
+// SendMessageRequest mensaje a enviar por WhatsApp desde el API o el dashboard.
+// Los archivos se referencian con media_id (subido con POST /api/v1/media) o
+// document_id (documento generado por el bot). El texto puede venir de una plantilla.
+type SendMessageRequest struct {
+	BotNumber  string                  `json:"bot_number,omitempty"` // bot que envía; opcional si hay un solo bot conectado
+	To         string                  `json:"to" binding:"required"`
+	Type       string                  `json:"type,omitempty"`    // text (por defecto), image, document, audio, location, buttons o list
+	Message    string                  `json:"message,omitempty"` // texto, caption o cuerpo de botones/lista
+	MediaID    string                  `json:"media_id,omitempty"`
+	DocumentID string                  `json:"document_id,omitempty"`
+	FileName   string                  `json:"file_name,omitempty"` // nombre con el que se envía un documento
+	Buttons    []models.MessageButton  `json:"buttons,omitempty"`
+	Location   *models.MessageLocation `json:"location,omitempty"`
+	Template   string                  `json:"template,omitempty"`  // plantilla que reemplaza a message
+	Language   string                  `json:"language,omitempty"`  // idioma de la plantilla; vacío = el del bot
+	Variables  map[string]string       `json:"variables,omitempty"` // valores propios para la plantilla
+}
+
+// hasText indica si el mensaje trae texto propio o de una plantilla
+func (r *SendMessageRequest) hasText() bool {
+	return strings.TrimSpace(r.Message) != "" || r.Template != ""
+}
+
+// Validate verifica que el mensaje tenga los datos que exige su tipo
+func (r *SendMessageRequest) Validate() error {
+	if r.Type == "" {
+		r.Type = models.MessageTypeText
+	}
+	isMedia := r.Type == models.MessageTypeImage || r.Type == models.MessageTypeDocument || r.Type == models.MessageTypeAudio
+	isChoice := r.Type == models.MessageTypeButtons || r.Type == models.MessageTypeList
+
+	if !isMedia && (r.MediaID != "" || r.DocumentID != "") {
+		return fmt.Errorf("media_id and document_id are only allowed in image, document and audio messages")
+	}
+	if !isChoice && len(r.Buttons) > 0 {
+		return fmt.Errorf("buttons are only allowed in buttons and list messages")
+	}
+	if r.Type != models.MessageTypeLocation && r.Location != nil {
+		return fmt.Errorf("location is only allowed in location messages")
+	}
+	if r.Template != "" {
+		if r.Message != "" {
+			return fmt.Errorf("message and template cannot be used together")
+		}
+		if r.Type == models.MessageTypeLocation || r.Type == models.MessageTypeAudio {
+			return fmt.Errorf("templates are not supported in %s messages", r.Type)
+		}
+	}
+
+	switch {
+	case r.Type == models.MessageTypeText:
+		if !r.hasText() {
+			return fmt.Errorf("message is required")
+		}
+	case isMedia:
+		if (r.MediaID == "") == (r.DocumentID == "") {
+			return fmt.Errorf("exactly one of media_id or document_id is required")
+		}
+		if r.DocumentID != "" && r.Type != models.MessageTypeDocument {
+			return fmt.Errorf("document_id can only be sent as a document")
+		}
+		if r.Type == models.MessageTypeAudio && r.Message != "" {
+			return fmt.Errorf("audio messages do not support a caption")
+		}
+	case r.Type == models.MessageTypeLocation:
+		if r.Location == nil {
+			return fmt.Errorf("location is required")
+		}
+		if r.Location.Latitude < -90 || r.Location.Latitude > 90 || r.Location.Longitude < -180 || r.Location.Longitude > 180 {
+			return fmt.Errorf("location coordinates out of range")
+		}
+	case isChoice:
+		return r.validateChoices()
+	default:
+		return fmt.Errorf("unsupported message type %q", r.Type)
+	}
+	return nil
+}
+
+func (r *SendMessageRequest) validateChoices() error {
+	if !r.hasText() {
+		return fmt.Errorf("message is required")
+	}
+	maxOptions, maxTitle := models.MaxWhatsAppButtons, maxButtonTitleLength
+	if r.Type == models.MessageTypeList {
+		maxOptions, maxTitle = models.MaxWhatsAppListRows, maxListRowTitleLength
+	}
+	if len(r.Buttons) == 0 || len(r.Buttons) > maxOptions {
+		return fmt.Errorf("%s messages need between 1 and %d options", r.Type, maxOptions)
+	}
+	for i, button := range r.Buttons {
+		if button.Title == "" || button.Payload == "" {
+			return fmt.Errorf("option %d needs a title and a payload", i+1)
+		}
+		if utf8.RuneCountInString(button.Title) > maxTitle {
+			return fmt.Errorf("option %d title exceeds %d characters", i+1, maxTitle)
+		}
+	}
+	return nil
+}
+
+// SendWhatsAppMessage envía un mensaje por WhatsApp a través del bot conectado
+// @Summary Enviar mensaje por WhatsApp
+// @Description Envía texto (propio o de una plantilla), imagen, documento, audio, ubicación, botones o lista. El mensaje se guarda en la conversación y se entrega por el WebSocket del bot (queda en cola si está desconectado). Si el bot no acepta el mensaje responde 502 con success false y el estado failed.
+// @Tags whatsapp
+// @Accept json
+// @Produce json
+// @Param message body SendMessageRequest true "Datos del mensaje"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]interface{}
+// @Router /api/v1/whatsapp/send [post]
+func SendWhatsAppMessage(c *gin.Context, hub *WebSocketHub) {
+	var request SendMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Datos inválidos",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mensaje inválido", "details": err.Error()})
+		return
+	}
+
+	bot, ok := resolveSessionBot(c, hub, request.BotNumber)
+	if !ok {
+		return
+	}
+	client, err := clientRepo.GetOrCreateClient(normalizeBotNumber(request.To), "", "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registrando el destinatario", "details": err.Error()})
+		return
+	}
+
+	message := models.Message{
+		ClientID:   client.ID,
+		BotID:      bot.ID,
+		Sender:     models.MessageSenderOperator,
+		OperatorID: currentOperatorID(c),
+		Type:       request.Type,
+		Text:       request.Message,
+		Buttons:    request.Buttons,
+		Location:   request.Location,
+	}
+	if request.Template != "" {
+		text, ok := sendTemplateText(c, &request, client, bot)
+		if !ok {
+			return
+		}
+		message.Text = text
+	}
+	if request.MediaID != "" || request.DocumentID != "" {
+		attachment, ok := sendAttachment(c, &request)
+		if !ok {
+			return
+		}
+		message.Attachments = []models.MessageAttachment{attachment}
+	}
+
+	sent, err := sendOutgoingMessage(hub, bot.Number, request.To, message, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando el mensaje", "details": err.Error()})
+		return
+	}
+	// El mensaje quedó guardado, pero el bot no lo aceptó (sin conexión ni cola)
+	if sent.Status == models.MessageStatusFailed {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success":    false,
+			"error":      "El bot no pudo enviar el mensaje",
+			"details":    sent.StatusError,
+			"status":     sent.Status,
+			"bot_number": bot.Number,
+			"type":       request.Type,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "Mensaje enviado al bot",
+		"status":     sent.Status,
+		"bot_number": bot.Number,
+		"type":       request.Type,
+	})
+}
+
+// sendTemplateText arma el texto de la plantilla con los datos del destinatario, del
+// documento adjunto y las variables de la solicitud
+func sendTemplateText(c *gin.Context, request *SendMessageRequest, client *models.Client, bot *models.Bot) (string, bool) {
+	template, ok := templateByName(c, request.Template)
+	if !ok {
+		return "", false
+	}
+	variables, err := templateVariables(c.Request.Context(), client, request.DocumentID)
+	if !foundForSend(c, err, "Documento no encontrado") {
+		return "", false
+	}
+	rendered := renderTemplate(template, request.Language, bot.Language, services.MergeVariables(variables, request.Variables))
+	if len(rendered.Missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Faltan variables de la plantilla", "missing": rendered.Missing})
+		return "", false
+	}
+	return rendered.Text, true
+}
+
+// sendAttachment arma el adjunto con un enlace firmado desde el que Baileys descarga el archivo
+func sendAttachment(c *gin.Context, request *SendMessageRequest) (models.MessageAttachment, bool) {
+	expiresAt := time.Now().Add(documentLinkTTL)
+
+	if request.DocumentID != "" {
+		document, err := documentRepo.GetDocumentByID(c.Request.Context(), request.DocumentID)
+		if !foundForSend(c, err, "Documento no encontrado") {
+			return models.MessageAttachment{}, false
+		}
+		url, _ := signDocumentURL(document, expiresAt)
+		return signedAttachment(c, models.MessageAttachment{
+			Type:     models.MessageTypeDocument,
+			URL:      url,
+			FileName: firstNonEmpty(request.FileName, document.FileName),
+			MimeType: document.MimeType,
+			Size:     document.Size,
+		})
+	}
+
+	file, err := mediaRepo.GetMediaByID(c.Request.Context(), request.MediaID)
+	if !foundForSend(c, err, "Archivo no encontrado") {
+		return models.MessageAttachment{}, false
+	}
+	if request.Type != models.MessageTypeDocument && mediaTypeForMime(file.MimeType) != request.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El archivo no corresponde al tipo de mensaje", "details": fmt.Sprintf("%s cannot be sent as %s", file.MimeType, request.Type)})
+		return models.MessageAttachment{}, false
+	}
+	url, _ := signDownloadURL(mediaDownloadPath(file.ID.Hex()), file.Checksum, expiresAt)
+	return signedAttachment(c, models.MessageAttachment{
+		Type:     request.Type,
+		URL:      url,
+		FileName: firstNonEmpty(request.FileName, file.FileName),
+		MimeType: file.MimeType,
+		Size:     file.Size,
+		MediaID:  file.ID.Hex(),
+	})
+}
+
+func signedAttachment(c *gin.Context, attachment models.MessageAttachment) (models.MessageAttachment, bool) {
+	if attachment.URL == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Firmado de enlaces no configurado"})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+func foundForSend(c *gin.Context, err error, notFound string) bool {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error consultando el archivo", "details": err.Error()})
+		return false
+	}
+	return true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
+)
+
+func TestSendMessageRequestValidate(t *testing.T) {
+	buttons := func(n int, title string) []models.MessageButton {
+		var list []models.MessageButton
+		for i := 0; i < n; i++ {
+			list = append(list, models.MessageButton{Title: title, Payload: "/opcion"})
+		}
+		return list
+	}
+
+	valid := []SendMessageRequest{
+		{To: "1", Message: "hola"},
+		{To: "1", Type: "image", MediaID: "abc", Message: "foto"},
+		{To: "1", Type: "document", DocumentID: "abc"},
+		{To: "1", Type: "audio", MediaID: "abc"},
+		{To: "1", Type: "location", Location: &models.MessageLocation{Latitude: 4.6, Longitude: -74.08}},
+		{To: "1", Type: "buttons", Message: "¿Confirmas?", Buttons: buttons(3, "Sí")},
+		{To: "1", Type: "list", Message: "Elige", Buttons: buttons(10, "Opción de la lista")},
+	}
+	for _, request := range valid {
+		assert.NoError(t, request.Validate(), request.Type)
+	}
+
+	invalid := []SendMessageRequest{
+		{To: "1"},
+		{To: "1", Type: "sticker", MediaID: "abc"},
+		{To: "1", Type: "image"},
+		{To: "1", Type: "image", MediaID: "abc", DocumentID: "def"},
+		{To: "1", Type: "image", DocumentID: "abc"},
+		{To: "1", Type: "audio", MediaID: "abc", Message: "caption"},
+		{To: "1", Message: "hola", MediaID: "abc"},
+		{To: "1", Type: "location"},
+		{To: "1", Type: "location", Location: &models.MessageLocation{Latitude: 91}},
+		{To: "1", Type: "buttons", Message: "¿Confirmas?", Buttons: buttons(4, "Sí")},
+		{To: "1", Type: "buttons", Message: "¿Confirmas?", Buttons: buttons(1, "Un título demasiado largo")},
+		{To: "1", Type: "list", Buttons: buttons(2, "Opción")},
+		{To: "1", Message: "hola", Buttons: buttons(1, "Sí")},
+	}
+	for _, request := range invalid {
+		assert.Error(t, request.Validate(), "%+v", request)
+	}
+}
+
+func TestSendUploadedImageThroughHub(t *testing.T) {
+	files := map[string]*models.MediaFile{}
+
+	m := setupInboundMocks(t)
+	SetMediaRepo(&mocks.MockMediaRepo{
+		CreateMediaFunc: func(ctx context.Context, media *models.MediaFile) error {
+			files[media.ID.Hex()] = media
+			return nil
+		},
+		GetMediaByIDFunc: func(ctx context.Context, id string) (*models.MediaFile, error) {
+			if media, ok := files[id]; ok {
+				return media, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+	})
+	storage, err := services.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	SetMediaStorage(storage)
+	SetDocumentPipeline(nil, nil, services.NewURLSigner([]byte("secreto-de-prueba")), "http://api:8080")
+	t.Cleanup(func() {
+		SetMediaStorage(nil)
+		SetDocumentPipeline(nil, nil, nil, "")
+	})
+
+	hub := NewWebSocketHub()
+	server, conn := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+
+	router := gin.New()
+	router.POST("/api/v1/media", UploadMedia)
+	router.POST("/api/v1/whatsapp/send", func(c *gin.Context) { SendWhatsAppMessage(c, hub) })
+	router.GET("/media/:id/download", DownloadSignedMedia)
+
+	// Subir la imagen
+	photo := []byte("\x89PNG\r\n\x1a\n imagen de prueba")
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "promo.png")
+	part.Write(photo)
+	form.Close()
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/media", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	router.ServeHTTP(w, request)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var uploaded models.MediaFile
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+	assert.Equal(t, models.MessageTypeImage, uploaded.Type)
+
+	// Un archivo de imagen no se puede enviar como audio
+	send := func(payload string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/whatsapp/send", strings.NewReader(payload)))
+		return w
+	}
+	w = send(`{"to": "573001112233", "type": "audio", "media_id": "` + uploaded.ID.Hex() + `"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Se envía sin bot_number porque hay un solo bot conectado
+	w = send(`{"to": "573001112233", "type": "image", "media_id": "` + uploaded.ID.Hex() + `", "message": "Promoción"}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	outgoing := readOutgoing(t, conn)
+	assert.Equal(t, models.MessageTypeImage, outgoing.Type)
+	assert.Equal(t, "Promoción", outgoing.Message)
+	assert.True(t, strings.HasPrefix(outgoing.MediaURL, "http://api:8080/media/"+uploaded.ID.Hex()+"/download?"), outgoing.MediaURL)
+
+	if assert.Len(t, m.Saved, 1) {
+		assert.Equal(t, models.MessageSenderOperator, m.Saved[0].Sender)
+		assert.Equal(t, uploaded.ID.Hex(), m.Saved[0].Attachments[0].MediaID)
+	}
+
+	// Baileys descarga el archivo con el enlace firmado
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(outgoing.MediaURL, "http://api:8080"), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, photo, w.Body.Bytes())
+}
+
+func TestSendReportsFailedDelivery(t *testing.T) {
+	m := setupInboundMocks(t)
+	hub := NewWebSocketHub()
+	router := gin.New()
+	router.POST("/api/v1/whatsapp/send", func(c *gin.Context) { SendWhatsAppMessage(c, hub) })
+
+	// Sin conexión del bot ni cola persistente el mensaje no sale
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/whatsapp/send",
+		strings.NewReader(`{"bot_number": "573009998877", "to": "573001112233", "message": "hola"}`)))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	var response struct {
+		Success bool   `json:"success"`
+		Status  string `json:"status"`
+		Details string `json:"details"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, models.MessageStatusFailed, response.Status)
+	assert.NotEmpty(t, response.Details)
+	if assert.Len(t, m.Updates, 1) {
+		assert.Equal(t, models.MessageStatusFailed, m.Updates[0].Status)
+	}
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+func TestWhatsAppSessionsAreRoutedByBot(t *testing.T) {
+	bots := map[string]*models.Bot{
+		"573009998877": {ID: 3, Number: "573009998877"},
+		"573005554433": {ID: 4, Number: "573005554433"},
+	}
+	SetBotRepo(&mocks.MockBotRepo{
+		GetBotByNumberFunc: func(number string) (*models.Bot, error) {
+			if bot, ok := bots[number]; ok {
+				return bot, nil
+			}
+			return nil, repositories.ErrBotNotFound
+		},
+		GetBotByIDFunc: func(id uint) (*models.Bot, error) {
+			for _, bot := range bots {
+				if bot.ID == id {
+					return bot, nil
+				}
+			}
+			return nil, repositories.ErrBotNotFound
+		},
+	})
+
+	hub := NewWebSocketHub()
+	serverA, botA := dialTestBot(t, hub, "573009998877")
+	defer serverA.Close()
+	defer botA.Close()
+	serverB, botB := dialTestBot(t, hub, "573005554433@s.whatsapp.net")
+	defer serverB.Close()
+	defer botB.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 2 }, time.Second, 10*time.Millisecond)
+
+	router := gin.New()
+	router.GET("/qr", func(c *gin.Context) { GetWhatsAppQR(c, hub) })
+	router.GET("/status", func(c *gin.Context) { GetSessionStatus(c, hub) })
+	router.POST("/disconnect", func(c *gin.Context) { DisconnectWhatsApp(c, hub) })
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// Con varios bots hay que indicar cuál
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/status").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/status?bot=573000000000").Code)
+
+	// El QR del bot A llega por su conexión y solo se muestra para él
+	qr, _ := NewEnvelope(FrameQRUpdate, QRUpdatePayload{QRCode: "2@abc"})
+	assert.NoError(t, botA.WriteJSON(qr))
+	assert.Eventually(t, func() bool {
+		var response WhatsAppQRResponse
+		json.Unmarshal(request(http.MethodGet, "/qr?bot=573009998877").Body.Bytes(), &response)
+		return response.Status == WhatsAppStatusWaitingForScan && response.QRCode == "2@abc"
+	}, time.Second, 10*time.Millisecond)
+
+	open, _ := NewEnvelope(FrameConnectionState, ConnectionStatePayload{State: "open", Number: "573005554433", Name: "Transporte"})
+	assert.NoError(t, botB.WriteJSON(open))
+	assert.Eventually(t, func() bool {
+		var response WhatsAppStatusResponse
+		json.Unmarshal(request(http.MethodGet, "/status?bot=4").Body.Bytes(), &response)
+		return response.Connected && response.BotNumber == "573005554433" && response.SessionInfo.Name == "Transporte"
+	}, time.Second, 10*time.Millisecond)
+
+	// La orden de logout viaja solo por la conexión del bot B
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/disconnect?bot=573005554433").Code)
+	botB.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var env Envelope
+		if !assert.NoError(t, botB.ReadJSON(&env)) {
+			return
+		}
+		if env.Type != FrameSessionCommand {
+			continue // ack del connection_state
+		}
+		var command SessionCommandPayload
+		assert.NoError(t, env.DecodePayload(&command))
+		assert.Equal(t, SessionCommandPayload{Action: SessionActionLogout, BotNumber: "573005554433"}, command)
+		break
+	}
+}
+
+func TestSessionStartForNewNumberGoesToSessionManager(t *testing.T) {
+	hub := NewWebSocketHub()
+	server, botA := dialTestBot(t, hub, "573009998877")
+	defer server.Close()
+	defer botA.Close()
+	assert.Eventually(t, func() bool { return len(hub.ListBots()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// Sin gestor de sesiones la orden no se entrega a la conexión de otro número
+	_, err := hub.SendSessionCommand("573001112222", SessionActionStart)
+	assert.ErrorIs(t, err, ErrNoBaileysConnection)
+
+	manager, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?role="+SessionManagerRole, baileysHeader())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer manager.Close()
+	assert.Eventually(t, func() bool { return hub.SessionManager() != nil }, time.Second, 10*time.Millisecond)
+	assert.Len(t, hub.ListBots(), 1, "el gestor no cuenta como bot")
+
+	id, err := hub.SendSessionCommand("573001112222", SessionActionStart)
+	assert.NoError(t, err)
+	env := readEnvelope(t, manager)
+	assert.Equal(t, FrameSessionCommand, env.Type)
+	assert.Equal(t, id, env.ID)
+	var command SessionCommandPayload
+	assert.NoError(t, env.DecodePayload(&command))
+	assert.Equal(t, SessionCommandPayload{Action: SessionActionStart, BotNumber: "573001112222"}, command)
+
+	// Las demás órdenes necesitan la conexión del número
+	_, err = hub.SendSessionCommand("573001112222", SessionActionLogout)
+	assert.Error(t, err)
+}
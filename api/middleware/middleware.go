@@ -2,6 +2,9 @@ package middleware
 
 import (
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,10 +28,14 @@ func LoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
-// CORSMiddleware permite solicitudes CORS
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware permite solicitudes CORS desde los orígenes permitidos
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowed := CheckOrigin(allowedOrigins)
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := c.GetHeader("Origin"); origin != "" && allowed(c.Request) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
@@ -41,3 +48,25 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// CheckOrigin valida la cabecera Origin contra los orígenes permitidos (esquema://host[:puerto]).
+// Se aceptan las solicitudes sin Origin (clientes que no son navegadores) y las del mismo
+// origen. Sirve también como CheckOrigin de los WebSocket, para evitar que otro sitio
+// abra el socket con la sesión del operador.
+func CheckOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if allowed[strings.ToLower(origin)] {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := CheckOrigin([]string{"https://panel.docubot.co/"})
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://api.docubot.co/api/v1/dashboard/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	assert.True(t, allowed(request("https://panel.docubot.co")))
+	assert.True(t, allowed(request("")), "clientes sin Origin (no navegadores)")
+	assert.True(t, allowed(request("http://api.docubot.co")), "mismo origen")
+	assert.False(t, allowed(request("https://evil.example")))
+	assert.False(t, allowed(request("http://panel.docubot.co")), "el esquema también cuenta")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware([]string{"https://panel.docubot.co"}))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	for origin, expected := range map[string]string{"https://panel.docubot.co": "https://panel.docubot.co", "https://evil.example": ""} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", origin)
+		router.ServeHTTP(w, r)
+		assert.Equal(t, expected, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestExtractStreamToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	extract := func(r *http.Request) (string, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = r
+		return extractStreamToken(c)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "bearer, v4.public.abc.def")
+	token, err := extract(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "v4.public.abc.def", token)
+
+	// El token en la URL ya no se acepta
+	_, err = extract(httptest.NewRequest(http.MethodGet, "/ws?token=v4.public.abc.def", nil))
+	assert.Error(t, err)
+}
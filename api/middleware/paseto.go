@@ -3,38 +3,70 @@ package middleware
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/o1egl/paseto"
+	"github.com/gorilla/websocket"
 
 	"github.com/brando1998/docubot-api/controllers"
 	database "github.com/brando1998/docubot-api/databases"
 	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/services"
 )
 
 var (
 	ErrInvalidToken         = errors.New("token inválido")
 	ErrInvalidTokenFormat   = errors.New("formato de token inválido")
 	ErrUnsupportedTokenType = errors.New("tipo de token no soportado")
+	ErrTokenExpired         = errors.New("token expirado")
 	// ❌ PROBLEMA: Esta línea también causaba nil pointer
 	// authDB                  = database.GetDB()
 )
 
 // PasetoAuthMiddleware verifica tokens PASETO para usuarios del sistema
 func PasetoAuthMiddleware() gin.HandlerFunc {
+	return pasetoAuth(extractToken, false)
+}
+
+// PasetoPasswordChangeMiddleware igual que PasetoAuthMiddleware, pero deja pasar a los
+// usuarios con cambio de contraseña pendiente. Solo para las rutas con que la cambian.
+func PasetoPasswordChangeMiddleware() gin.HandlerFunc {
+	return pasetoAuth(extractToken, true)
+}
+
+// PasetoStreamAuthMiddleware igual que PasetoAuthMiddleware, pero acepta el token en
+// Sec-WebSocket-Protocol ("bearer, <token>"), la única cabecera que el navegador deja
+// fijar al abrir un WebSocket. Nunca en la URL, donde quedaría en los logs de los proxies.
+func PasetoStreamAuthMiddleware() gin.HandlerFunc {
+	return pasetoAuth(extractStreamToken, false)
+}
+
+func pasetoAuth(extract func(c *gin.Context) (string, error), allowPasswordReset bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := extractToken(c)
+		token, err := extract(c)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		payload, err := verifyPasetoToken(token)
+		payload, err := controllers.VerifyAccessToken(token)
+		if errors.Is(err, services.ErrTokenExpired) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrTokenExpired.Error()})
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidToken.Error()})
+			return
+		}
+
+		// Rechazar tokens de sesiones cerradas (logout, reutilización de refresh token...)
+		revoked, err := controllers.AccessTokenRevoked(payload.TokenID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error verificando el token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revocado"})
 			return
 		}
 
@@ -58,35 +90,25 @@ func PasetoAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Almacenar datos en el contexto
+		// Un administrador forzó el cambio de contraseña
+		if user.PasswordResetRequired && !allowPasswordReset {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":                   "debe cambiar su contraseña en POST /auth/password",
+				"password_reset_required": true,
+			})
+			return
+		}
+
+		// Almacenar datos en el contexto. El rol es el vigente en la base de datos,
+		// aunque haya cambiado después de emitir el token.
 		c.Set("current_user_id", payload.UserID)
-		c.Set("current_user_role", payload.Role)
+		c.Set("current_user_role", user.Role)
+		c.Set("current_session_id", payload.SessionID)
 		c.Set("current_user", user) // También almacenar el objeto completo del usuario
 		c.Next()
 	}
 }
 
-// verifyPasetoToken verifica y decodifica el token
-func verifyPasetoToken(token string) (*controllers.PasetoPayload, error) {
-	v2 := paseto.NewV2()
-	var payload controllers.PasetoPayload
-
-	secretKey := []byte(os.Getenv("PASETO_SECRET_KEY"))
-	if len(secretKey) == 0 {
-		secretKey = []byte("default-secret-key-change-in-production-32-chars")
-	}
-
-	if err := v2.Decrypt(token, secretKey, &payload, nil); err != nil {
-		return nil, ErrInvalidToken
-	}
-
-	if time.Now().After(payload.ExpiresAt) {
-		return nil, errors.New("token expirado")
-	}
-
-	return &payload, nil
-}
-
 func extractToken(c *gin.Context) (string, error) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
@@ -100,3 +122,10 @@ func extractToken(c *gin.Context) (string, error) {
 
 	return parts[1], nil
 }
+
+func extractStreamToken(c *gin.Context) (string, error) {
+	if protocols := websocket.Subprotocols(c.Request); len(protocols) == 2 && protocols[0] == controllers.DashboardTokenProtocol {
+		return protocols[1], nil
+	}
+	return extractToken(c)
+}
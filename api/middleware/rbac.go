@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// RequirePermission deja pasar solo a los usuarios cuyo rol tiene todos los permisos
+// indicados. Va después de PasetoAuthMiddleware, que guarda el rol en el contexto.
+func RequirePermission(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("current_user_role")
+		if role == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+			return
+		}
+		for _, permission := range permissions {
+			if !models.HasPermission(role, permission) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":      "permiso denegado",
+					"permission": permission,
+					"role":       role,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	status := func(role string, permissions ...models.Permission) int {
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			if role != "" {
+				c.Set("current_user_role", role)
+			}
+		}, RequirePermission(permissions...), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, status(models.RoleAdmin, models.PermissionUsersManage, models.PermissionBotsManage))
+	assert.Equal(t, http.StatusNoContent, status(models.RoleOperator, models.PermissionWhatsAppSend))
+	assert.Equal(t, http.StatusForbidden, status(models.RoleOperator, models.PermissionBotsManage))
+	assert.Equal(t, http.StatusForbidden, status(models.RoleViewer, models.PermissionWhatsAppSend))
+	assert.Equal(t, http.StatusNoContent, status(models.RoleViewer, models.PermissionDocumentsRead))
+	assert.Equal(t, http.StatusForbidden, status("user", models.PermissionDocumentsRead))
+	assert.Equal(t, http.StatusUnauthorized, status("", models.PermissionDocumentsRead))
+}
@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockAuditRepo struct {
+	ListAuditLogsFunc func(filter models.AuditFilter) ([]models.AuditLog, int64, error)
+}
+
+func (m *MockAuditRepo) ListAuditLogs(filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	return m.ListAuditLogsFunc(filter)
+}
+
+var _ repositories.AuditRepository = &MockAuditRepo{} // asegura que implementa la interfaz
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockBotRepo struct {
+	GetBotByIDFunc     func(id uint) (*models.Bot, error)
+	GetBotByNumberFunc func(number string) (*models.Bot, error)
+	ListBotsFunc       func(filter models.BotFilter) ([]models.Bot, int64, error)
+	CreateBotFunc      func(bot *models.Bot) error
+	UpdateBotFunc      func(bot *models.Bot) error
+}
+
+func (m *MockBotRepo) GetBotByID(id uint) (*models.Bot, error) {
+	return m.GetBotByIDFunc(id)
+}
+
+func (m *MockBotRepo) GetBotByNumber(number string) (*models.Bot, error) {
+	return m.GetBotByNumberFunc(number)
+}
+
+func (m *MockBotRepo) ListBots(filter models.BotFilter) ([]models.Bot, int64, error) {
+	return m.ListBotsFunc(filter)
+}
+
+func (m *MockBotRepo) CreateBot(bot *models.Bot) error {
+	return m.CreateBotFunc(bot)
+}
+
+func (m *MockBotRepo) UpdateBot(bot *models.Bot) error {
+	return m.UpdateBotFunc(bot)
+}
+
+var _ repositories.BotRepository = &MockBotRepo{}
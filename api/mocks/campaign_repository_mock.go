@@ -0,0 +1,68 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockCampaignRepo struct {
+	CreateCampaignFunc          func(campaign *models.Campaign, recipients []models.CampaignRecipient) error
+	GetCampaignFunc             func(id uint) (*models.Campaign, error)
+	ListCampaignsFunc           func(filter models.CampaignFilter) ([]models.Campaign, int64, error)
+	TransitionCampaignFunc      func(id uint, from []string, to string, at time.Time, lastError string) (bool, error)
+	SetCampaignJobFunc          func(id uint, jobID uint) error
+	PendingRecipientsFunc       func(campaignID uint, limit int) ([]models.CampaignRecipient, error)
+	UpdateRecipientFunc         func(recipient *models.CampaignRecipient) error
+	CancelPendingRecipientsFunc func(campaignID uint) (int64, error)
+	ListRecipientsFunc          func(filter models.RecipientFilter) ([]models.CampaignRecipient, int64, error)
+	RecipientStatsFunc          func(campaignID uint) (map[string]int64, error)
+	UpdateRecipientStatusFunc   func(update models.MessageStatusUpdate) error
+}
+
+func (m *MockCampaignRepo) CreateCampaign(campaign *models.Campaign, recipients []models.CampaignRecipient) error {
+	return m.CreateCampaignFunc(campaign, recipients)
+}
+
+func (m *MockCampaignRepo) GetCampaign(id uint) (*models.Campaign, error) {
+	return m.GetCampaignFunc(id)
+}
+
+func (m *MockCampaignRepo) ListCampaigns(filter models.CampaignFilter) ([]models.Campaign, int64, error) {
+	return m.ListCampaignsFunc(filter)
+}
+
+func (m *MockCampaignRepo) TransitionCampaign(id uint, from []string, to string, at time.Time, lastError string) (bool, error) {
+	return m.TransitionCampaignFunc(id, from, to, at, lastError)
+}
+
+func (m *MockCampaignRepo) SetCampaignJob(id uint, jobID uint) error {
+	return m.SetCampaignJobFunc(id, jobID)
+}
+
+func (m *MockCampaignRepo) PendingRecipients(campaignID uint, limit int) ([]models.CampaignRecipient, error) {
+	return m.PendingRecipientsFunc(campaignID, limit)
+}
+
+func (m *MockCampaignRepo) UpdateRecipient(recipient *models.CampaignRecipient) error {
+	return m.UpdateRecipientFunc(recipient)
+}
+
+func (m *MockCampaignRepo) CancelPendingRecipients(campaignID uint) (int64, error) {
+	return m.CancelPendingRecipientsFunc(campaignID)
+}
+
+func (m *MockCampaignRepo) ListRecipients(filter models.RecipientFilter) ([]models.CampaignRecipient, int64, error) {
+	return m.ListRecipientsFunc(filter)
+}
+
+func (m *MockCampaignRepo) RecipientStats(campaignID uint) (map[string]int64, error) {
+	return m.RecipientStatsFunc(campaignID)
+}
+
+func (m *MockCampaignRepo) UpdateRecipientStatus(update models.MessageStatusUpdate) error {
+	return m.UpdateRecipientStatusFunc(update)
+}
+
+var _ repositories.CampaignRepository = &MockCampaignRepo{} // asegura que implementa la interfaz
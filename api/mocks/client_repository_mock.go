@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"time"
+
 	"github.com/brando1998/docubot-api/models"
 	"github.com/brando1998/docubot-api/repositories"
 )
@@ -10,6 +12,8 @@ type MockClientRepo struct {
 	GetClientByIDFunc     func(id uint) (*models.Client, error)
 	GetClientByPhoneFunc  func(phone string) (*models.Client, error)
 	GetOrCreateClientFunc func(phone, name, email string) (*models.Client, error)
+	ListClientsFunc       func(filter models.ClientFilter, limit int) ([]models.Client, error)
+	SetClientOptOutFunc   func(id uint, optedOut bool, at time.Time) error
 }
 
 func (m *MockClientRepo) CreateClient(user *models.Client) error {
@@ -21,11 +25,19 @@ func (m *MockClientRepo) GetClientByID(id uint) (*models.Client, error) {
 }
 
 func (m *MockClientRepo) GetClientByPhone(phone string) (*models.Client, error) {
-	return m.GetClientByPhone(phone)
+	return m.GetClientByPhoneFunc(phone)
 }
 
 func (m *MockClientRepo) GetOrCreateClient(phone, name, email string) (*models.Client, error) {
-	return m.GetOrCreateClient(phone, name, email)
+	return m.GetOrCreateClientFunc(phone, name, email)
+}
+
+func (m *MockClientRepo) ListClients(filter models.ClientFilter, limit int) ([]models.Client, error) {
+	return m.ListClientsFunc(filter, limit)
+}
+
+func (m *MockClientRepo) SetClientOptOut(id uint, optedOut bool, at time.Time) error {
+	return m.SetClientOptOutFunc(id, optedOut, at)
 }
 
 var _ repositories.ClientRepository = &MockClientRepo{} // asegura que implementa la interfaz
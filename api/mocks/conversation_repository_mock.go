@@ -0,0 +1,64 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockConversationRepo struct {
+	SaveMessageFunc             func(ctx context.Context, userID uint, botID uint, message models.Message) error
+	GetConversationByUserIDFunc func(ctx context.Context, userID uint) (*models.Conversation, error)
+	UpdateMessageStatusFunc     func(ctx context.Context, update models.MessageStatusUpdate) error
+	GetUndeliveredMessagesFunc  func(ctx context.Context, botID uint, limit int) ([]models.Message, error)
+	GetConversationModeFunc     func(ctx context.Context, userID uint, botID uint) (string, error)
+	SetConversationModeFunc     func(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error
+	GetMessagesFunc             func(ctx context.Context, userID uint, botID uint, before string, limit int) ([]models.Message, string, error)
+	GetRecentConversationsFunc  func(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error)
+	MarkConversationReadFunc    func(ctx context.Context, userID uint, botID uint, at time.Time) error
+	SearchMessagesFunc          func(ctx context.Context, query string, botID uint, limit int) ([]models.ConversationSearchResult, error)
+}
+
+func (m *MockConversationRepo) SaveMessage(ctx context.Context, userID uint, botID uint, message models.Message) error {
+	return m.SaveMessageFunc(ctx, userID, botID, message)
+}
+
+func (m *MockConversationRepo) GetConversationByUserID(ctx context.Context, userID uint) (*models.Conversation, error) {
+	return m.GetConversationByUserIDFunc(ctx, userID)
+}
+
+func (m *MockConversationRepo) UpdateMessageStatus(ctx context.Context, update models.MessageStatusUpdate) error {
+	return m.UpdateMessageStatusFunc(ctx, update)
+}
+
+func (m *MockConversationRepo) GetUndeliveredMessages(ctx context.Context, botID uint, limit int) ([]models.Message, error) {
+	return m.GetUndeliveredMessagesFunc(ctx, botID, limit)
+}
+
+func (m *MockConversationRepo) GetConversationMode(ctx context.Context, userID uint, botID uint) (string, error) {
+	return m.GetConversationModeFunc(ctx, userID, botID)
+}
+
+func (m *MockConversationRepo) SetConversationMode(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error {
+	return m.SetConversationModeFunc(ctx, userID, botID, change)
+}
+
+func (m *MockConversationRepo) GetMessages(ctx context.Context, userID uint, botID uint, before string, limit int) ([]models.Message, string, error) {
+	return m.GetMessagesFunc(ctx, userID, botID, before, limit)
+}
+
+func (m *MockConversationRepo) GetRecentConversations(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error) {
+	return m.GetRecentConversationsFunc(ctx, filter)
+}
+
+func (m *MockConversationRepo) MarkConversationRead(ctx context.Context, userID uint, botID uint, at time.Time) error {
+	return m.MarkConversationReadFunc(ctx, userID, botID, at)
+}
+
+func (m *MockConversationRepo) SearchMessages(ctx context.Context, query string, botID uint, limit int) ([]models.ConversationSearchResult, error) {
+	return m.SearchMessagesFunc(ctx, query, botID, limit)
+}
+
+var _ repositories.ConversationRepository = &MockConversationRepo{}
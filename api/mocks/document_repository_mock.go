@@ -0,0 +1,33 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockDocumentRepo struct {
+	CreateDocumentFunc        func(ctx context.Context, document *models.Document) error
+	GetDocumentByIDFunc       func(ctx context.Context, id string) (*models.Document, error)
+	GetDocumentByJobIDFunc    func(ctx context.Context, jobID uint) (*models.Document, error)
+	ListDocumentsByClientFunc func(ctx context.Context, clientID uint, offset, limit int) ([]models.Document, error)
+}
+
+func (m *MockDocumentRepo) CreateDocument(ctx context.Context, document *models.Document) error {
+	return m.CreateDocumentFunc(ctx, document)
+}
+
+func (m *MockDocumentRepo) GetDocumentByID(ctx context.Context, id string) (*models.Document, error) {
+	return m.GetDocumentByIDFunc(ctx, id)
+}
+
+func (m *MockDocumentRepo) GetDocumentByJobID(ctx context.Context, jobID uint) (*models.Document, error) {
+	return m.GetDocumentByJobIDFunc(ctx, jobID)
+}
+
+func (m *MockDocumentRepo) ListDocumentsByClient(ctx context.Context, clientID uint, offset, limit int) ([]models.Document, error) {
+	return m.ListDocumentsByClientFunc(ctx, clientID, offset, limit)
+}
+
+var _ repositories.DocumentRepository = &MockDocumentRepo{}
@@ -0,0 +1,68 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockJobRepo struct {
+	CreateJobFunc        func(job *models.Job) error
+	GetJobFunc           func(id uint) (*models.Job, error)
+	ListJobsFunc         func(filter models.JobFilter) ([]models.Job, int64, error)
+	ClaimNextJobFunc     func(workerID string, now time.Time) (*models.Job, error)
+	CompleteJobFunc      func(id uint, result string, finishedAt time.Time) (bool, error)
+	RetryJobFunc         func(id uint, nextRunAt time.Time, lastError string) (bool, error)
+	FailJobFunc          func(id uint, lastError string, finishedAt time.Time) (bool, error)
+	CancelJobFunc        func(id uint, canceledAt time.Time) (bool, error)
+	RequeueJobFunc       func(id uint, now time.Time) (bool, error)
+	RequeueStaleJobsFunc func(startedBefore time.Time, now time.Time) (int64, []models.Job, error)
+	AddJobLogFunc        func(entry *models.JobLog) error
+}
+
+func (m *MockJobRepo) CreateJob(job *models.Job) error {
+	return m.CreateJobFunc(job)
+}
+
+func (m *MockJobRepo) GetJob(id uint) (*models.Job, error) {
+	return m.GetJobFunc(id)
+}
+
+func (m *MockJobRepo) ListJobs(filter models.JobFilter) ([]models.Job, int64, error) {
+	return m.ListJobsFunc(filter)
+}
+
+func (m *MockJobRepo) ClaimNextJob(workerID string, now time.Time) (*models.Job, error) {
+	return m.ClaimNextJobFunc(workerID, now)
+}
+
+func (m *MockJobRepo) CompleteJob(id uint, result string, finishedAt time.Time) (bool, error) {
+	return m.CompleteJobFunc(id, result, finishedAt)
+}
+
+func (m *MockJobRepo) RetryJob(id uint, nextRunAt time.Time, lastError string) (bool, error) {
+	return m.RetryJobFunc(id, nextRunAt, lastError)
+}
+
+func (m *MockJobRepo) FailJob(id uint, lastError string, finishedAt time.Time) (bool, error) {
+	return m.FailJobFunc(id, lastError, finishedAt)
+}
+
+func (m *MockJobRepo) CancelJob(id uint, canceledAt time.Time) (bool, error) {
+	return m.CancelJobFunc(id, canceledAt)
+}
+
+func (m *MockJobRepo) RequeueJob(id uint, now time.Time) (bool, error) {
+	return m.RequeueJobFunc(id, now)
+}
+
+func (m *MockJobRepo) RequeueStaleJobs(startedBefore time.Time, now time.Time) (int64, []models.Job, error) {
+	return m.RequeueStaleJobsFunc(startedBefore, now)
+}
+
+func (m *MockJobRepo) AddJobLog(entry *models.JobLog) error {
+	return m.AddJobLogFunc(entry)
+}
+
+var _ repositories.JobRepository = &MockJobRepo{}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockMediaRepo struct {
+	CreateMediaFunc  func(ctx context.Context, media *models.MediaFile) error
+	GetMediaByIDFunc func(ctx context.Context, id string) (*models.MediaFile, error)
+}
+
+func (m *MockMediaRepo) CreateMedia(ctx context.Context, media *models.MediaFile) error {
+	return m.CreateMediaFunc(ctx, media)
+}
+
+func (m *MockMediaRepo) GetMediaByID(ctx context.Context, id string) (*models.MediaFile, error) {
+	return m.GetMediaByIDFunc(ctx, id)
+}
+
+var _ repositories.MediaRepository = &MockMediaRepo{}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockOutboxRepo struct {
+	EnqueueFunc                func(message *models.OutboundMessage) error
+	GetUnackedByBotFunc        func(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error)
+	GetBotsWithDueMessagesFunc func(now time.Time) ([]string, error)
+	MarkSentFunc               func(id uint, sentAt time.Time, ackDeadline time.Time) error
+	MarkRetryFunc              func(id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailedFunc             func(id uint, attempts int, lastError string) error
+	GetByEnvelopeIDFunc        func(envelopeID string) (*models.OutboundMessage, error)
+	MarkAckedFunc              func(envelopeID, whatsAppID string, ackedAt time.Time) error
+}
+
+func (m *MockOutboxRepo) Enqueue(message *models.OutboundMessage) error {
+	return m.EnqueueFunc(message)
+}
+
+func (m *MockOutboxRepo) GetUnackedByBot(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error) {
+	return m.GetUnackedByBotFunc(botNumber, afterID, limit)
+}
+
+func (m *MockOutboxRepo) GetBotsWithDueMessages(now time.Time) ([]string, error) {
+	return m.GetBotsWithDueMessagesFunc(now)
+}
+
+func (m *MockOutboxRepo) MarkSent(id uint, sentAt time.Time, ackDeadline time.Time) error {
+	return m.MarkSentFunc(id, sentAt, ackDeadline)
+}
+
+func (m *MockOutboxRepo) MarkRetry(id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return m.MarkRetryFunc(id, attempts, nextAttemptAt, lastError)
+}
+
+func (m *MockOutboxRepo) MarkFailed(id uint, attempts int, lastError string) error {
+	return m.MarkFailedFunc(id, attempts, lastError)
+}
+
+func (m *MockOutboxRepo) GetByEnvelopeID(envelopeID string) (*models.OutboundMessage, error) {
+	return m.GetByEnvelopeIDFunc(envelopeID)
+}
+
+func (m *MockOutboxRepo) MarkAcked(envelopeID, whatsAppID string, ackedAt time.Time) error {
+	return m.MarkAckedFunc(envelopeID, whatsAppID, ackedAt)
+}
+
+var _ repositories.OutboxRepository = &MockOutboxRepo{}
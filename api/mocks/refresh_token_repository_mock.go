@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockRefreshTokenRepo struct {
+	CreateRefreshTokenFunc         func(token *models.RefreshToken) error
+	GetRefreshTokenByHashFunc      func(hash string) (*models.RefreshToken, error)
+	RotateRefreshTokenFunc         func(used *models.RefreshToken, next *models.RefreshToken, at time.Time) (bool, error)
+	RevokeSessionFunc              func(sessionID string, reason string, at time.Time) (int64, error)
+	RevokeUserSessionsFunc         func(userID uint, exceptSessionID string, reason string, at time.Time) (int64, error)
+	IsAccessTokenRevokedFunc       func(tokenID string) (bool, error)
+	DeleteExpiredRefreshTokensFunc func(before time.Time) (int64, error)
+}
+
+func (m *MockRefreshTokenRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	return m.CreateRefreshTokenFunc(token)
+}
+
+func (m *MockRefreshTokenRepo) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	return m.GetRefreshTokenByHashFunc(hash)
+}
+
+func (m *MockRefreshTokenRepo) RotateRefreshToken(used *models.RefreshToken, next *models.RefreshToken, at time.Time) (bool, error) {
+	return m.RotateRefreshTokenFunc(used, next, at)
+}
+
+func (m *MockRefreshTokenRepo) RevokeSession(sessionID string, reason string, at time.Time) (int64, error) {
+	return m.RevokeSessionFunc(sessionID, reason, at)
+}
+
+func (m *MockRefreshTokenRepo) RevokeUserSessions(userID uint, exceptSessionID string, reason string, at time.Time) (int64, error) {
+	return m.RevokeUserSessionsFunc(userID, exceptSessionID, reason, at)
+}
+
+func (m *MockRefreshTokenRepo) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	return m.IsAccessTokenRevokedFunc(tokenID)
+}
+
+func (m *MockRefreshTokenRepo) DeleteExpiredRefreshTokens(before time.Time) (int64, error) {
+	return m.DeleteExpiredRefreshTokensFunc(before)
+}
+
+var _ repositories.RefreshTokenRepository = &MockRefreshTokenRepo{} // asegura que implementa la interfaz
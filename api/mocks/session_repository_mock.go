@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockSessionRepo struct {
+	GetActiveSessionFunc      func(ctx context.Context, clientID uint, botID uint) (*models.ConversationSession, error)
+	StartSessionFunc          func(ctx context.Context, clientID uint, botID uint, at time.Time) (*models.ConversationSession, error)
+	TouchSessionFunc          func(ctx context.Context, sessionID string, at time.Time) error
+	CloseSessionFunc          func(ctx context.Context, sessionID string, at time.Time, reason string) error
+	CloseInactiveSessionsFunc func(ctx context.Context, idleSince time.Time, at time.Time) (int64, error)
+	ListSessionsFunc          func(ctx context.Context, clientID uint, botID uint, limit int) ([]models.ConversationSession, error)
+}
+
+func (m *MockSessionRepo) GetActiveSession(ctx context.Context, clientID uint, botID uint) (*models.ConversationSession, error) {
+	return m.GetActiveSessionFunc(ctx, clientID, botID)
+}
+
+func (m *MockSessionRepo) StartSession(ctx context.Context, clientID uint, botID uint, at time.Time) (*models.ConversationSession, error) {
+	return m.StartSessionFunc(ctx, clientID, botID, at)
+}
+
+func (m *MockSessionRepo) TouchSession(ctx context.Context, sessionID string, at time.Time) error {
+	return m.TouchSessionFunc(ctx, sessionID, at)
+}
+
+func (m *MockSessionRepo) CloseSession(ctx context.Context, sessionID string, at time.Time, reason string) error {
+	return m.CloseSessionFunc(ctx, sessionID, at, reason)
+}
+
+func (m *MockSessionRepo) CloseInactiveSessions(ctx context.Context, idleSince time.Time, at time.Time) (int64, error) {
+	return m.CloseInactiveSessionsFunc(ctx, idleSince, at)
+}
+
+func (m *MockSessionRepo) ListSessions(ctx context.Context, clientID uint, botID uint, limit int) ([]models.ConversationSession, error) {
+	return m.ListSessionsFunc(ctx, clientID, botID, limit)
+}
+
+var _ repositories.SessionRepository = &MockSessionRepo{}
@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockSystemUserRepo struct {
+	GetSystemUserFunc     func(id uint, includeDeleted bool) (*models.SystemUser, error)
+	ListSystemUsersFunc   func(filter models.SystemUserFilter) ([]models.SystemUser, int64, error)
+	CreateSystemUserFunc  func(user *models.SystemUser, audit ...models.AuditLog) error
+	UpdateSystemUserFunc  func(user *models.SystemUser, audit ...models.AuditLog) error
+	DeleteSystemUserFunc  func(id uint, audit ...models.AuditLog) error
+	RestoreSystemUserFunc func(id uint, audit ...models.AuditLog) error
+	CountActiveAdminsFunc func(excludeID uint) (int64, error)
+}
+
+func (m *MockSystemUserRepo) GetSystemUser(id uint, includeDeleted bool) (*models.SystemUser, error) {
+	return m.GetSystemUserFunc(id, includeDeleted)
+}
+
+func (m *MockSystemUserRepo) ListSystemUsers(filter models.SystemUserFilter) ([]models.SystemUser, int64, error) {
+	return m.ListSystemUsersFunc(filter)
+}
+
+func (m *MockSystemUserRepo) CreateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error {
+	return m.CreateSystemUserFunc(user, audit...)
+}
+
+func (m *MockSystemUserRepo) UpdateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error {
+	return m.UpdateSystemUserFunc(user, audit...)
+}
+
+func (m *MockSystemUserRepo) DeleteSystemUser(id uint, audit ...models.AuditLog) error {
+	return m.DeleteSystemUserFunc(id, audit...)
+}
+
+func (m *MockSystemUserRepo) RestoreSystemUser(id uint, audit ...models.AuditLog) error {
+	return m.RestoreSystemUserFunc(id, audit...)
+}
+
+func (m *MockSystemUserRepo) CountActiveAdmins(excludeID uint) (int64, error) {
+	return m.CountActiveAdminsFunc(excludeID)
+}
+
+var _ repositories.SystemUserRepository = &MockSystemUserRepo{} // asegura que implementa la interfaz
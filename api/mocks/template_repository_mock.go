@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+type MockTemplateRepo struct {
+	GetTemplateByIDFunc   func(id uint) (*models.MessageTemplate, error)
+	GetTemplateByNameFunc func(name string) (*models.MessageTemplate, error)
+	ListTemplatesFunc     func(filter models.TemplateFilter) ([]models.MessageTemplate, int64, error)
+	CreateTemplateFunc    func(template *models.MessageTemplate) error
+	UpdateTemplateFunc    func(template *models.MessageTemplate) error
+	DeleteTemplateFunc    func(id uint) error
+}
+
+func (m *MockTemplateRepo) GetTemplateByID(id uint) (*models.MessageTemplate, error) {
+	return m.GetTemplateByIDFunc(id)
+}
+
+func (m *MockTemplateRepo) GetTemplateByName(name string) (*models.MessageTemplate, error) {
+	return m.GetTemplateByNameFunc(name)
+}
+
+func (m *MockTemplateRepo) ListTemplates(filter models.TemplateFilter) ([]models.MessageTemplate, int64, error) {
+	return m.ListTemplatesFunc(filter)
+}
+
+func (m *MockTemplateRepo) CreateTemplate(template *models.MessageTemplate) error {
+	return m.CreateTemplateFunc(template)
+}
+
+func (m *MockTemplateRepo) UpdateTemplate(template *models.MessageTemplate) error {
+	return m.UpdateTemplateFunc(template)
+}
+
+func (m *MockTemplateRepo) DeleteTemplate(id uint) error {
+	return m.DeleteTemplateFunc(id)
+}
+
+var _ repositories.TemplateRepository = &MockTemplateRepo{} // asegura que implementa la interfaz
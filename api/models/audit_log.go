@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Acciones que quedan en la auditoría de usuarios del sistema
+const (
+	AuditUserCreated         = "user.created"
+	AuditUserUpdated         = "user.updated"
+	AuditUserRoleChanged     = "user.role_changed"
+	AuditUserActivated       = "user.activated"
+	AuditUserDeactivated     = "user.deactivated"
+	AuditUserDeleted         = "user.deleted"
+	AuditUserRestored        = "user.restored"
+	AuditUserPasswordReset   = "user.password_reset"   // un administrador fijó una contraseña temporal
+	AuditUserPasswordChanged = "user.password_changed" // el usuario cambió su contraseña
+)
+
+// AuditTargetSystemUser tipo de registro afectado cuando es un usuario del sistema
+const AuditTargetSystemUser = "system_user"
+
+// AuditChange valor de un campo antes y después del cambio
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditLog cambio administrativo: quién lo hizo, sobre qué registro y qué cambió.
+// Nunca guarda contraseñas ni hashes.
+type AuditLog struct {
+	ID         uint                   `json:"id" gorm:"primaryKey"`
+	ActorID    uint                   `json:"actor_id" gorm:"index"` // usuario que hizo el cambio; 0 = el sistema
+	Action     string                 `json:"action" gorm:"index"`
+	TargetType string                 `json:"target_type" gorm:"index:idx_audit_target"`
+	TargetID   uint                   `json:"target_id" gorm:"index:idx_audit_target"`
+	Changes    map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json;type:text"`
+	IP         string                 `json:"ip,omitempty"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+}
+
+// AuditFilter filtros del listado de auditoría
+type AuditFilter struct {
+	ActorID    uint
+	TargetType string
+	TargetID   uint
+	Action     string
+	Offset     int
+	Limit      int
+}
@@ -1,9 +1,30 @@
 package models
 
+import "time"
+
+// DefaultBotLanguage idioma de los bots que no indican uno
+const DefaultBotLanguage = "es"
+
 type Bot struct {
-	ID     uint   `json:"id" gorm:"primaryKey"`
-	Name   string `json:"name"`
-	Type   string `json:"type"`   // transporte, salud, etc.
-	Number string `json:"number"` // Número de WhatsApp asociado
-	Active bool   `json:"active"`
+	ID              uint          `json:"id" gorm:"primaryKey"`
+	Name            string        `json:"name"`
+	Type            string        `json:"type"`                             // transporte, salud, etc.
+	Number          string        `json:"number" gorm:"uniqueIndex"`        // Número de WhatsApp asociado
+	Active          bool          `json:"active"`                           // los bots inactivos ignoran los mensajes
+	NLUEngine       string        `json:"nlu_engine"`                       // rasa o rules (vacío = motor por defecto)
+	NLUURL          string        `json:"nlu_url"`                          // URL propia del servidor Rasa (opcional)
+	WelcomeMessage  string        `json:"welcome_message" gorm:"type:text"` // se envía al abrir cada sesión de conversación
+	WelcomeTemplate string        `json:"welcome_template"`                 // plantilla del saludo; welcome_message queda de respaldo
+	Language        string        `json:"language" gorm:"default:es"`
+	BusinessHours   BusinessHours `json:"business_hours" gorm:"serializer:json;type:text"`
+	OperatorIDs     []uint        `json:"operator_ids" gorm:"serializer:json;type:text"` // operadores (SystemUser) asignados
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// BotFilter filtros del listado de bots
+type BotFilter struct {
+	Active *bool
+	Offset int
+	Limit  int
 }
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultOutOfHoursMessage respuesta automática fuera de horario si el bot no define una
+const DefaultOutOfHoursMessage = "En este momento estamos fuera del horario de atención. Te responderemos apenas volvamos."
+
+// holidayLayout formato de las fechas de los feriados
+const holidayLayout = "2006-01-02"
+
+// maxScheduleLookahead días que se recorren buscando la próxima apertura
+const maxScheduleLookahead = 400
+
+// BusinessHours horario de atención del bot. Sin días configurados, atiende siempre.
+type BusinessHours struct {
+	Timezone           string        `json:"timezone,omitempty"` // zona IANA (ej: America/Bogota); vacío = hora del servidor
+	Days               []BusinessDay `json:"days,omitempty"`
+	Holidays           []Holiday     `json:"holidays,omitempty"`              // días completos sin atención
+	OutOfHoursMessage  string        `json:"out_of_hours_message,omitempty"`  // respuesta automática fuera de horario
+	OutOfHoursTemplate string        `json:"out_of_hours_template,omitempty"` // plantilla de la respuesta; el texto queda de respaldo
+	QueueForOperator   bool          `json:"queue_for_operator,omitempty"`    // fuera de horario pasa la conversación a un operador
+}
+
+// BusinessDay franja de atención de un día de la semana
+type BusinessDay struct {
+	Weekday time.Weekday `json:"weekday"` // 0 = domingo ... 6 = sábado
+	Open    string       `json:"open"`    // HH:MM
+	Close   string       `json:"close"`   // HH:MM, posterior a Open
+}
+
+// Holiday día sin atención del calendario del bot
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD en la zona del horario
+	Name string `json:"name,omitempty"`
+}
+
+// BusinessHoursState estado del horario en un momento dado
+type BusinessHoursState struct {
+	Open       bool       `json:"open"`
+	Holiday    string     `json:"holiday,omitempty"`     // feriado del día, si lo es
+	NextChange *time.Time `json:"next_change,omitempty"` // cierre si está abierto, apertura si está cerrado
+}
+
+// Validate verifica la zona horaria, las franjas y los feriados del horario
+func (h BusinessHours) Validate() error {
+	if h.Timezone != "" {
+		if _, err := time.LoadLocation(h.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", h.Timezone)
+		}
+	}
+	for i, day := range h.Days {
+		if day.Weekday < time.Sunday || day.Weekday > time.Saturday {
+			return fmt.Errorf("day %d: weekday must be between 0 and 6", i+1)
+		}
+		open, err := time.Parse("15:04", day.Open)
+		if err != nil {
+			return fmt.Errorf("day %d: invalid open time %q", i+1, day.Open)
+		}
+		closing, err := time.Parse("15:04", day.Close)
+		if err != nil {
+			return fmt.Errorf("day %d: invalid close time %q", i+1, day.Close)
+		}
+		if !closing.After(open) {
+			return fmt.Errorf("day %d: close time must be after open time", i+1)
+		}
+	}
+	if len(h.Holidays) > 0 && len(h.Days) == 0 {
+		return fmt.Errorf("holidays require at least one day in the schedule")
+	}
+	for i, holiday := range h.Holidays {
+		if _, err := time.Parse(holidayLayout, holiday.Date); err != nil {
+			return fmt.Errorf("holiday %d: date must be YYYY-MM-DD", i+1)
+		}
+	}
+	return nil
+}
+
+// Location zona horaria del horario; la del servidor si no hay una válida
+func (h BusinessHours) Location() *time.Location {
+	if h.Timezone != "" {
+		if loc, err := time.LoadLocation(h.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// IsOpen indica si el bot atiende en ese momento
+func (h BusinessHours) IsOpen(at time.Time) bool {
+	return h.State(at).Open
+}
+
+// State calcula si el bot atiende en ese momento y cuándo cambia el estado
+func (h BusinessHours) State(at time.Time) BusinessHoursState {
+	if len(h.Days) == 0 {
+		return BusinessHoursState{Open: true}
+	}
+
+	local := at.In(h.Location())
+	state := BusinessHoursState{Holiday: h.holidayName(local)}
+
+	today := h.slots(local)
+	for _, slot := range today {
+		if !local.Before(slot.open) && local.Before(slot.close) {
+			closing := slot.close
+			// Franjas contiguas o superpuestas cuentan como una sola
+			for _, next := range today {
+				if !next.open.After(closing) && next.close.After(closing) {
+					closing = next.close
+				}
+			}
+			state.Open = true
+			state.NextChange = &closing
+			return state
+		}
+	}
+
+	for offset := 0; offset <= maxScheduleLookahead; offset++ {
+		for _, slot := range h.slots(local.AddDate(0, 0, offset)) {
+			if slot.open.After(local) {
+				opening := slot.open
+				state.NextChange = &opening
+				return state
+			}
+		}
+	}
+	return state
+}
+
+type businessSlot struct {
+	open, close time.Time
+}
+
+// slots franjas de atención del día de date (vacío si es feriado), ordenadas por apertura
+func (h BusinessHours) slots(date time.Time) []businessSlot {
+	if h.holidayName(date) != "" {
+		return nil
+	}
+	var slots []businessSlot
+	for _, day := range h.Days {
+		if day.Weekday != date.Weekday() {
+			continue
+		}
+		open, errOpen := time.Parse("15:04", day.Open)
+		closing, errClose := time.Parse("15:04", day.Close)
+		if errOpen != nil || errClose != nil {
+			continue
+		}
+		slots = append(slots, businessSlot{
+			open:  atClock(date, open),
+			close: atClock(date, closing),
+		})
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].open.Before(slots[j].open) })
+	return slots
+}
+
+// holidayName nombre del feriado de ese día; "feriado" si no tiene nombre y vacío si no lo es
+func (h BusinessHours) holidayName(date time.Time) string {
+	day := date.Format(holidayLayout)
+	for _, holiday := range h.Holidays {
+		if holiday.Date == day {
+			if holiday.Name == "" {
+				return "feriado"
+			}
+			return holiday.Name
+		}
+	}
+	return ""
+}
+
+func atClock(date, clock time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location())
+}
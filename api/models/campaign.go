@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Estados de una campaña de difusión
+const (
+	CampaignStatusScheduled = "scheduled" // esperando la hora programada
+	CampaignStatusRunning   = "running"   // enviando mensajes
+	CampaignStatusCompleted = "completed" // se procesaron todos los destinatarios
+	CampaignStatusCanceled  = "canceled"  // cancelada desde el API
+	CampaignStatusFailed    = "failed"    // no se pudo ejecutar (ej: bot inactivo)
+)
+
+// Estados de un destinatario de campaña. Después de enviado sigue los estados de
+// entrega del mensaje (sent, delivered, read, failed).
+const (
+	RecipientStatusPending  = "pending"   // aún no se le envía
+	RecipientStatusQueued   = "queued"    // entregado al bot, esperando confirmación de WhatsApp
+	RecipientStatusOptedOut = "opted_out" // pidió no recibir campañas
+	RecipientStatusCanceled = "canceled"  // la campaña se canceló antes de enviarle
+)
+
+// Campaign difusión de un mensaje a un grupo de clientes a través de un bot
+type Campaign struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	Name          string       `json:"name"`
+	BotID         uint         `json:"bot_id" gorm:"index"`
+	Message       string       `json:"message" gorm:"type:text"` // admite {{nombre}}, {{telefono}}, {{empresa}} y {{correo}}
+	TemplateID    uint         `json:"template_id,omitempty"`    // plantilla de la que se copió el mensaje
+	Language      string       `json:"language,omitempty"`       // variante de la plantilla
+	Audience      ClientFilter `json:"audience" gorm:"serializer:json;type:text"`
+	Status        string       `json:"status" gorm:"index;default:scheduled"`
+	ScheduledAt   time.Time    `json:"scheduled_at"`
+	RatePerMinute int          `json:"rate_per_minute"`      // mensajes por minuto del bot
+	JobID         uint         `json:"job_id,omitempty"`     // trabajo que la está enviando
+	CreatedBy     uint         `json:"created_by,omitempty"` // usuario del sistema
+	LastError     string       `json:"last_error,omitempty" gorm:"type:text"`
+	StartedAt     *time.Time   `json:"started_at,omitempty"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// IsFinished indica si la campaña ya no enviará más mensajes
+func (c Campaign) IsFinished() bool {
+	switch c.Status {
+	case CampaignStatusCompleted, CampaignStatusCanceled, CampaignStatusFailed:
+		return true
+	}
+	return false
+}
+
+// CampaignRecipient cliente incluido en una campaña y el estado de su mensaje
+type CampaignRecipient struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CampaignID uint       `json:"campaign_id" gorm:"uniqueIndex:idx_campaign_recipient;index:idx_campaign_recipient_status"`
+	ClientID   uint       `json:"client_id" gorm:"uniqueIndex:idx_campaign_recipient"`
+	Phone      string     `json:"phone"`
+	Status     string     `json:"status" gorm:"index:idx_campaign_recipient_status;default:pending"`
+	OutboundID string     `json:"outbound_id,omitempty" gorm:"index"` // frame outbound_message enviado al bot
+	WhatsAppID string     `json:"whatsapp_id,omitempty" gorm:"index"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CampaignFilter filtros del listado de campañas
+type CampaignFilter struct {
+	Status string
+	BotID  uint
+	Offset int
+	Limit  int
+}
+
+// RecipientFilter filtros del listado de destinatarios de una campaña
+type RecipientFilter struct {
+	CampaignID uint
+	Status     string
+	Offset     int
+	Limit      int
+}
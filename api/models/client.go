@@ -5,12 +5,24 @@ import (
 )
 
 type Client struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name"`
-	Email     *string   `json:"email" gorm:"uniqueIndex"` // Cambiado a puntero para permitir nil
-	Phone     string    `json:"phone" gorm:"uniqueIndex"` // importante para identificar desde WhatsApp
-	Company   string    `json:"company"`                  // para cuando agregues login
-	BotID     uint      `json:"bot_id"`                   // para cuando agregues login
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name"`
+	Email      *string    `json:"email" gorm:"uniqueIndex"`       // Cambiado a puntero para permitir nil
+	Phone      string     `json:"phone" gorm:"uniqueIndex"`       // importante para identificar desde WhatsApp
+	Company    string     `json:"company"`                        // para cuando agregues login
+	BotID      uint       `json:"bot_id"`                         // para cuando agregues login
+	OptedOut   bool       `json:"opted_out" gorm:"default:false"` // pidió no recibir campañas (STOP, BAJA)
+	OptedOutAt *time.Time `json:"opted_out_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ClientFilter selección de clientes; los criterios vacíos no filtran
+type ClientFilter struct {
+	ClientIDs     []uint     `json:"client_ids,omitempty"`
+	Phones        []string   `json:"phones,omitempty"`
+	Company       string     `json:"company,omitempty"`
+	BotID         uint       `json:"bot_id,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
 }
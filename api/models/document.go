@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tipos de documento generados por el bot
+const (
+	DocumentTypeManifiesto = "manifiesto"
+)
+
+// Document archivo generado para un cliente (ej: manifiesto de carga en PDF)
+type Document struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID   uint               `bson:"client_id" json:"client_id"`
+	BotID      uint               `bson:"bot_id,omitempty" json:"bot_id,omitempty"`
+	SessionID  string             `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	JobID      uint               `bson:"job_id,omitempty" json:"job_id,omitempty"` // trabajo que lo generó
+	FileName   string             `bson:"file_name" json:"file_name"`
+	MimeType   string             `bson:"mime_type,omitempty" json:"mime_type,omitempty"`
+	Size       int64              `bson:"size" json:"size"`
+	Checksum   string             `bson:"checksum,omitempty" json:"checksum,omitempty"` // SHA-256 del archivo
+	URL        string             `bson:"-" json:"url,omitempty"`                       // descarga firmada, se genera en cada respuesta
+	Type       string             `bson:"type" json:"type"`                             // Ej: "manifiesto", "certificado"
+	Data       map[string]string  `bson:"data,omitempty" json:"data,omitempty"`
+	Storage    string             `bson:"storage,omitempty" json:"storage,omitempty"` // backend donde se guardó: local o s3
+	StorageKey string             `bson:"storage_key" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
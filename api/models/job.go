@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Estados de un trabajo en segundo plano
+const (
+	JobStatusQueued    = "queued"    // esperando un worker (o el próximo reintento)
+	JobStatusRunning   = "running"   // un worker lo está ejecutando
+	JobStatusSucceeded = "succeeded" // terminó bien
+	JobStatusFailed    = "failed"    // se agotaron los intentos
+	JobStatusCanceled  = "canceled"  // cancelado desde el API
+)
+
+// Tipos de trabajo
+const (
+	JobTypeManifest = "document.manifiesto" // generar y enviar un manifiesto
+	JobTypeCampaign = "campaign.broadcast"  // enviar una campaña de difusión
+)
+
+// Niveles de los registros de un trabajo
+const (
+	JobLogInfo  = "info"
+	JobLogError = "error"
+)
+
+// Job trabajo persistente procesado por la cola de workers
+type Job struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"index;not null"`
+	Status      string     `json:"status" gorm:"index:idx_job_status_next_run;default:queued"`
+	Payload     string     `json:"payload" gorm:"type:text"`          // JSON con los datos del trabajo
+	Result      string     `json:"result,omitempty" gorm:"type:text"` // JSON con el resultado
+	ClientID    uint       `json:"client_id,omitempty" gorm:"index"`
+	BotID       uint       `json:"bot_id,omitempty"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	NextRunAt   time.Time  `json:"next_run_at" gorm:"index:idx_job_status_next_run"`
+	WorkerID    string     `json:"worker_id,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Logs        []JobLog   `json:"logs,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// JobLog línea del registro de ejecución de un trabajo
+type JobLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JobID     uint      `json:"job_id" gorm:"index;not null"`
+	Attempt   int       `json:"attempt"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// JobFilter filtros del listado de trabajos
+type JobFilter struct {
+	Status   string
+	Type     string
+	ClientID uint
+	Offset   int
+	Limit    int
+}
+
+// IsFinished indica si el trabajo ya no se volverá a ejecutar por sí solo
+func (j Job) IsFinished() bool {
+	switch j.Status {
+	case JobStatusSucceeded, JobStatusFailed, JobStatusCanceled:
+		return true
+	}
+	return false
+}
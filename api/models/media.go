@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MediaFile archivo enviado por un cliente en WhatsApp (foto de la licencia o de la tarjeta
+// de propiedad, PDF, nota de voz) o subido desde el dashboard para enviarlo. El contenido
+// vive en el almacenamiento de archivos.
+type MediaFile struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID   uint               `bson:"client_id,omitempty" json:"client_id,omitempty"`
+	BotID      uint               `bson:"bot_id,omitempty" json:"bot_id,omitempty"`
+	SessionID  string             `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	UploadedBy uint               `bson:"uploaded_by,omitempty" json:"uploaded_by,omitempty"` // usuario del sistema que lo subió
+	Type       string             `bson:"type" json:"type"`                                   // image, document o audio
+	FileName   string             `bson:"file_name" json:"file_name"`
+	MimeType   string             `bson:"mime_type" json:"mime_type"`
+	Size       int64              `bson:"size" json:"size"`
+	Checksum   string             `bson:"checksum" json:"checksum"` // SHA-256 del archivo
+	Storage    string             `bson:"storage" json:"storage"`   // backend donde se guardó: local o s3
+	StorageKey string             `bson:"storage_key" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// SlotValue referencia al archivo que recibe el motor NLU como valor de slot (ej: tarjeta)
+func (m MediaFile) SlotValue() string {
+	return "media:" + m.ID.Hex()
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	templateNamePattern     = regexp.MustCompile(`^[a-z0-9_]{3,64}$`)
+	templateLanguagePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+)
+
+// MessageTemplate plantilla de mensaje reutilizable con una variante por idioma.
+// Los textos admiten variables {{nombre}} que se llenan con datos del cliente, del
+// documento o enviados en la solicitud.
+type MessageTemplate struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	Name        string            `json:"name" gorm:"uniqueIndex"` // identificador para usarla (ej: aviso_cierre_via)
+	Description string            `json:"description"`
+	Variants    map[string]string `json:"variants" gorm:"serializer:json;type:text"` // idioma → texto
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// TemplateFilter filtros del listado de plantillas
+type TemplateFilter struct {
+	Search string // parte del nombre o la descripción
+	Offset int
+	Limit  int
+}
+
+// Validate verifica el nombre y que haya al menos una variante con texto
+func (t MessageTemplate) Validate() error {
+	if !templateNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name must have 3 to 64 lowercase letters, digits or underscores")
+	}
+	if len(t.Variants) == 0 {
+		return fmt.Errorf("at least one language variant is required")
+	}
+	for language, body := range t.Variants {
+		if !templateLanguagePattern.MatchString(language) {
+			return fmt.Errorf("variant %q: language must be an ISO 639-1 code (e.g. es or es-CO)", language)
+		}
+		if strings.TrimSpace(body) == "" {
+			return fmt.Errorf("variant %q: text is required", language)
+		}
+	}
+	return nil
+}
+
+// Variant elige el texto para el idioma pedido: el idioma exacto, su idioma base
+// (es-CO → es), el idioma de respaldo y por último cualquier variante.
+// Devuelve el idioma elegido junto al texto.
+func (t MessageTemplate) Variant(language, fallback string) (string, string) {
+	candidates := []string{language, baseLanguage(language), fallback, baseLanguage(fallback), DefaultBotLanguage}
+	for _, candidate := range candidates {
+		if body, ok := t.Variants[candidate]; ok && candidate != "" {
+			return candidate, body
+		}
+	}
+	languages := make([]string, 0, len(t.Variants))
+	for candidate := range t.Variants {
+		languages = append(languages, candidate)
+	}
+	if len(languages) == 0 {
+		return "", ""
+	}
+	sort.Strings(languages)
+	return languages[0], t.Variants[languages[0]]
+}
+
+func baseLanguage(language string) string {
+	base, _, _ := strings.Cut(language, "-")
+	return base
+}
@@ -6,29 +6,196 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Tipos de mensaje de WhatsApp
+const (
+	MessageTypeText     = "text"
+	MessageTypeButtons  = "buttons"  // hasta 3 botones de respuesta rápida
+	MessageTypeList     = "list"     // más de 3 opciones
+	MessageTypeImage    = "image"    // imagen con caption opcional
+	MessageTypeDocument = "document" // PDF u otro archivo
+	MessageTypeAudio    = "audio"
+	MessageTypeLocation = "location" // ubicación con coordenadas
+	MessageTypeCustom   = "custom"   // payload personalizado sin traducción directa
+)
+
+// MaxWhatsAppButtons es el máximo de botones que admite un mensaje de botones de WhatsApp
+const MaxWhatsAppButtons = 3
+
+// MaxWhatsAppListRows es el máximo de opciones de un mensaje de lista de WhatsApp
+const MaxWhatsAppListRows = 10
+
+// Estados de entrega de los mensajes enviados por el bot
+const (
+	MessageStatusPending   = "pending"   // en cola hacia Baileys
+	MessageStatusSent      = "sent"      // Baileys lo envió a WhatsApp (ack)
+	MessageStatusDelivered = "delivered" // llegó al teléfono del cliente
+	MessageStatusRead      = "read"      // el cliente lo leyó
+	MessageStatusFailed    = "failed"    // no se pudo entregar
+)
+
+// Remitentes de los mensajes salientes; los entrantes llevan el teléfono del cliente
+const (
+	MessageSenderBot      = "bot"
+	MessageSenderOperator = "operator"
+)
+
+// Modos de atención de una conversación
+const (
+	ConversationModeBot    = "bot"    // responde el motor NLU
+	ConversationModeHuman  = "human"  // un operador atiende desde el dashboard
+	ConversationModePaused = "paused" // se guardan los mensajes pero nadie responde
+)
+
+// IsValidConversationMode indica si el modo es uno de los soportados
+func IsValidConversationMode(mode string) bool {
+	switch mode {
+	case ConversationModeBot, ConversationModeHuman, ConversationModePaused:
+		return true
+	}
+	return false
+}
+
+// Message mensaje de una conversación; se guarda como documento propio en la colección messages
 type Message struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	ClientID  uint               `bson:"client_id"`
-	BotID     uint               `bson:"bot_id"`
-	Sender    string             `bson:"sender"` // "user" o "bot"
-	Text      string             `bson:"text"`
-	Timestamp time.Time          `bson:"timestamp"`
-	SessionID string             `bson:"session_id,omitempty"`
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ConversationID primitive.ObjectID     `bson:"conversation_id,omitempty" json:"conversation_id,omitempty"`
+	ClientID       uint                   `bson:"client_id" json:"client_id"`
+	BotID          uint                   `bson:"bot_id" json:"bot_id"`
+	Sender         string                 `bson:"sender" json:"sender"`                               // "user", "bot" u "operator"
+	OperatorID     uint                   `bson:"operator_id,omitempty" json:"operator_id,omitempty"` // usuario del sistema que respondió
+	Type           string                 `bson:"type,omitempty" json:"type,omitempty"`
+	Text           string                 `bson:"text" json:"text"`
+	Buttons        []MessageButton        `bson:"buttons,omitempty" json:"buttons,omitempty"`
+	Attachments    []MessageAttachment    `bson:"attachments,omitempty" json:"attachments,omitempty"`
+	Location       *MessageLocation       `bson:"location,omitempty" json:"location,omitempty"`
+	Custom         map[string]interface{} `bson:"custom,omitempty" json:"custom,omitempty"`
+	Timestamp      time.Time              `bson:"timestamp" json:"timestamp"`
+	SessionID      string                 `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	CampaignID     uint                   `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"` // campaña de difusión que lo envió
+
+	// Seguimiento de entrega (solo mensajes salientes)
+	Status      string     `bson:"status,omitempty" json:"status,omitempty"`
+	OutboundIDs []string   `bson:"outbound_ids,omitempty" json:"outbound_ids,omitempty"` // ids de los frames outbound_message
+	WhatsAppIDs []string   `bson:"whatsapp_ids,omitempty" json:"whatsapp_ids,omitempty"` // ids asignados por WhatsApp
+	SentAt      *time.Time `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	DeliveredAt *time.Time `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	FailedAt    *time.Time `bson:"failed_at,omitempty" json:"failed_at,omitempty"`
+	StatusError string     `bson:"status_error,omitempty" json:"status_error,omitempty"`
 }
 
+// IsInbound indica si el mensaje lo escribió el cliente
+func (m Message) IsInbound() bool {
+	return m.Sender != MessageSenderBot && m.Sender != MessageSenderOperator
+}
+
+// MessageStatusUpdate cambio de estado reportado para un mensaje saliente.
+// Se identifica por el id del frame o por el id de WhatsApp.
+type MessageStatusUpdate struct {
+	OutboundID string
+	WhatsAppID string
+	Status     string
+	At         time.Time
+	Error      string
+}
+
+// MessageStatusRank orden de avance de los estados; un recibo nunca hace retroceder el estado
+func MessageStatusRank(status string) int {
+	switch status {
+	case MessageStatusPending:
+		return 1
+	case MessageStatusSent:
+		return 2
+	case MessageStatusDelivered:
+		return 3
+	case MessageStatusRead:
+		return 4
+	}
+	return 0
+}
+
+// MessageButton opción de respuesta rápida ofrecida al cliente
+type MessageButton struct {
+	Title   string `bson:"title" json:"title"`
+	Payload string `bson:"payload" json:"payload"`
+}
+
+// MessageLocation ubicación enviada en un mensaje
+type MessageLocation struct {
+	Latitude  float64 `bson:"latitude" json:"latitude"`
+	Longitude float64 `bson:"longitude" json:"longitude"`
+	Name      string  `bson:"name,omitempty" json:"name,omitempty"`
+	Address   string  `bson:"address,omitempty" json:"address,omitempty"`
+}
+
+// MessageAttachment archivo asociado a un mensaje (imagen, documento, audio)
+type MessageAttachment struct {
+	Type     string `bson:"type" json:"type"`
+	URL      string `bson:"url" json:"url"`
+	FileName string `bson:"file_name,omitempty" json:"file_name,omitempty"`
+	MimeType string `bson:"mime_type,omitempty" json:"mime_type,omitempty"`
+	Size     int64  `bson:"size,omitempty" json:"size,omitempty"`
+	MediaID  string `bson:"media_id,omitempty" json:"media_id,omitempty"` // archivo enviado por el cliente
+	Failed   bool   `bson:"failed,omitempty" json:"failed,omitempty"`     // el archivo no se pudo descargar ni guardar
+}
+
+// Conversation resumen de la conversación de un cliente con un bot. Los mensajes viven en
+// la colección messages; Messages solo viene en documentos anteriores a la migración
+// (cmd/migrate-conversations) o cuando se cargan explícitamente.
 type Conversation struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserID    uint               `bson:"client_id"`
-	BotID     uint               `bson:"bot_id"`
-	Messages  []Message          `bson:"messages"`
-	CreatedAt time.Time          `bson:"created_at"`
-}
-
-type Document struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	ClientID  uint               `bson:"client_id"`
-	FileName  string             `bson:"file_name"`
-	URL       string             `bson:"url"`
-	Type      string             `bson:"type"` // Ej: "manifiesto", "certificado"
-	CreatedAt time.Time          `bson:"created_at"`
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	UserID       uint               `bson:"client_id"`
+	BotID        uint               `bson:"bot_id"`
+	Messages     []Message          `bson:"messages,omitempty"`
+	MessageCount int                `bson:"message_count"`
+	CreatedAt    time.Time          `bson:"created_at"`
+
+	// Atención humana
+	Mode          string     `bson:"mode,omitempty"`           // bot (por defecto), human o paused
+	AssignedTo    uint       `bson:"assigned_to,omitempty"`    // operador a cargo en modo human
+	HandoffReason string     `bson:"handoff_reason,omitempty"` // motivo del último cambio de modo
+	ModeUpdatedAt *time.Time `bson:"mode_updated_at,omitempty"`
+
+	// Resumen para el listado de conversaciones recientes
+	LastMessageAt *time.Time `bson:"last_message_at,omitempty"`
+	LastMessage   *Message   `bson:"last_message,omitempty"`
+	UnreadCount   int        `bson:"unread_count"`           // mensajes del cliente sin leer por un operador
+	LastReadAt    *time.Time `bson:"last_read_at,omitempty"` // última vez que un operador la leyó
+}
+
+// ConversationFilter filtros del listado de conversaciones recientes
+type ConversationFilter struct {
+	ClientID   uint // 0 = todos los clientes
+	BotID      uint // 0 = todos los bots
+	UnreadOnly bool
+	Offset     int
+	Limit      int
+}
+
+// ConversationSummary conversación resumida para el listado del dashboard
+type ConversationSummary struct {
+	ClientID      uint       `bson:"client_id" json:"client_id"`
+	BotID         uint       `bson:"bot_id" json:"bot_id"`
+	Mode          string     `bson:"mode,omitempty" json:"mode"`
+	LastMessageAt *time.Time `bson:"last_message_at,omitempty" json:"last_message_at,omitempty"`
+	LastMessage   *Message   `bson:"last_message,omitempty" json:"last_message,omitempty"`
+	Preview       string     `bson:"-" json:"preview"`
+	UnreadCount   int        `bson:"unread_count" json:"unread_count"`
+	MessageCount  int        `bson:"message_count" json:"message_count"`
+}
+
+// ConversationSearchResult conversación que coincide con una búsqueda, con los mensajes que coinciden
+type ConversationSearchResult struct {
+	ClientID uint      `bson:"client_id" json:"client_id"`
+	BotID    uint      `bson:"bot_id" json:"bot_id"`
+	Score    float64   `bson:"score" json:"score"`
+	Messages []Message `bson:"messages" json:"messages"`
+}
+
+// ConversationModeChange cambio del modo de atención de una conversación
+type ConversationModeChange struct {
+	Mode       string
+	OperatorID uint // 0 cuando el cambio lo pide el bot
+	Reason     string
+	At         time.Time
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Estados de entrega de un mensaje saliente
+const (
+	OutboundStatusPending = "pending" // en cola, esperando conexión del bot o reintento
+	OutboundStatusSent    = "sent"    // escrito en el WebSocket del bot, esperando el ack; sin ack se reenvía
+	OutboundStatusAcked   = "acked"   // Baileys confirmó el frame (ack)
+	OutboundStatusFailed  = "failed"  // se agotaron los reintentos
+)
+
+// OutboundMessage mensaje pendiente de entrega a un bot de Baileys (outbox persistente)
+type OutboundMessage struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	EnvelopeID    string     `json:"envelope_id" gorm:"uniqueIndex:idx_outbound_envelope_id,where:envelope_id <> ''"` // id del frame outbound_message; índice parcial porque las filas anteriores a los envelopes no lo tienen
+	BotNumber     string     `json:"bot_number" gorm:"index:idx_outbound_bot_status;not null"`
+	Recipient     string     `json:"recipient"`
+	Payload       string     `json:"payload" gorm:"type:text;not null"` // JSON tal como se escribe en el WebSocket
+	Status        string     `json:"status" gorm:"index:idx_outbound_bot_status;default:pending"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"` // próximo reintento o, si ya se escribió, plazo del ack
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	AckedAt       *time.Time `json:"acked_at,omitempty"`
+	WhatsAppID    string     `json:"whatsapp_id,omitempty" gorm:"column:whatsapp_id;index"` // id asignado por WhatsApp al confirmar
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
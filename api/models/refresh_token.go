@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Motivos de revocación de una sesión
+const (
+	TokenRevokedLogout         = "logout"
+	TokenRevokedLogoutAll      = "logout_all"
+	TokenRevokedReuseDetected  = "reuse_detected" // se presentó un refresh token ya rotado
+	TokenRevokedPasswordReset  = "password_reset"
+	TokenRevokedPasswordChange = "password_change"
+	TokenRevokedUserDisabled   = "user_disabled"
+)
+
+// RefreshToken refresh token emitido a un usuario del sistema. Solo se guarda su hash.
+// Cada uso lo rota: queda marcado como usado y se emite otro de la misma sesión. Cada
+// fila registra también el ID del access token emitido junto a ella, para poder
+// rechazarlo al revocar la sesión.
+type RefreshToken struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"index"`
+	SessionID     string     `json:"session_id" gorm:"index"`       // mismo valor para toda la cadena de rotaciones de un login
+	TokenHash     string     `json:"-" gorm:"uniqueIndex;not null"` // SHA-256 del token
+	AccessTokenID string     `json:"-" gorm:"uniqueIndex;not null"` // jti del access token emitido junto a este
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	IP            string     `json:"ip,omitempty"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
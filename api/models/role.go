@@ -0,0 +1,59 @@
+package models
+
+import "sort"
+
+// Roles de los usuarios del sistema
+const (
+	RoleAdmin    = "admin"    // administra todo, incluidos los usuarios
+	RoleOperator = "operator" // atiende conversaciones y envía mensajes
+	RoleViewer   = "viewer"   // solo consulta
+)
+
+// Permission acción que un rol puede realizar
+type Permission string
+
+// Permisos con que se protegen las rutas del API
+const (
+	PermissionWhatsAppSend  Permission = "whatsapp:send"  // enviar mensajes, responder conversaciones y lanzar campañas
+	PermissionBotsManage    Permission = "bots:manage"    // configurar bots, sus sesiones de WhatsApp, plantillas y trabajos
+	PermissionUsersManage   Permission = "users:manage"   // administrar usuarios del sistema y registros de clientes
+	PermissionDocumentsRead Permission = "documents:read" // ver documentos generados y archivos recibidos
+)
+
+// rolePermissions permisos de cada rol
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:    {PermissionWhatsAppSend, PermissionBotsManage, PermissionUsersManage, PermissionDocumentsRead},
+	RoleOperator: {PermissionWhatsAppSend, PermissionDocumentsRead},
+	RoleViewer:   {PermissionDocumentsRead},
+}
+
+// Roles roles válidos, en orden alfabético
+func Roles() []string {
+	roles := make([]string, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// IsValidRole indica si el rol existe
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions permisos del rol; ninguno si el rol no existe
+func RolePermissions(role string) []Permission {
+	return append([]Permission(nil), rolePermissions[role]...)
+}
+
+// HasPermission indica si el rol tiene el permiso
+func HasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Motivos de cierre de una sesión de conversación
+const (
+	SessionCloseInactivity = "inactivity" // venció la ventana de inactividad
+)
+
+// ConversationSession tramo de una conversación entre un cliente y un bot. Se abre con
+// el primer mensaje entrante y se cierra tras un período sin actividad; cada mensaje
+// guarda el id de su sesión en Message.SessionID.
+type ConversationSession struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID       uint               `bson:"client_id" json:"client_id"`
+	BotID          uint               `bson:"bot_id" json:"bot_id"`
+	Active         bool               `bson:"active" json:"active"`
+	StartedAt      time.Time          `bson:"started_at" json:"started_at"`
+	LastActivityAt time.Time          `bson:"last_activity_at" json:"last_activity_at"`
+	EndedAt        *time.Time         `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+	CloseReason    string             `bson:"close_reason,omitempty" json:"close_reason,omitempty"`
+	MessageCount   int                `bson:"message_count" json:"message_count"`
+}
+
+// SessionID identificador que se guarda en los mensajes de la sesión
+func (s ConversationSession) SessionID() string {
+	return s.ID.Hex()
+}
+
+// ExpiredAt indica si la sesión lleva más de timeout sin actividad en el instante at
+func (s ConversationSession) ExpiredAt(at time.Time, timeout time.Duration) bool {
+	return at.Sub(s.LastActivityAt) >= timeout
+}
@@ -1,20 +1,64 @@
 package models
 
 import (
+	"fmt"
+	"net/mail"
+	"regexp"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Límites de las contraseñas de los usuarios del sistema (bcrypt ignora lo que pase de 72 bytes)
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,50}$`)
+
 type SystemUser struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Username     string         `json:"username" gorm:"uniqueIndex;not null"`
-	Email        string         `json:"email" gorm:"uniqueIndex;not null"`
-	PasswordHash string         `json:"-" gorm:"not null"`        // Nunca exponer esto en JSON
-	Role         string         `json:"role" gorm:"default:user"` // user, admin, etc.
-	IsActive     bool           `json:"is_active" gorm:"default:true"`
-	LastLogin    *time.Time     `json:"last_login"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                    uint           `json:"id" gorm:"primaryKey"`
+	Username              string         `json:"username" gorm:"uniqueIndex;not null"`
+	Email                 string         `json:"email" gorm:"uniqueIndex;not null"`
+	PasswordHash          string         `json:"-" gorm:"not null"`          // Nunca exponer esto en JSON
+	Role                  string         `json:"role" gorm:"default:viewer"` // admin, operator o viewer (ver role.go)
+	IsActive              bool           `json:"is_active" gorm:"default:true"`
+	PasswordResetRequired bool           `json:"password_reset_required"` // debe cambiar la contraseña antes de usar el API
+	LastLogin             *time.Time     `json:"last_login"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `json:"deleted_at" gorm:"index"` // eliminado (se puede restaurar)
+}
+
+// SystemUserFilter filtros del listado de usuarios del sistema
+type SystemUserFilter struct {
+	Role    string
+	Active  *bool
+	Deleted bool   // solo los eliminados
+	Search  string // parte del usuario o del correo
+	Offset  int
+	Limit   int
+}
+
+// Validate verifica el usuario, el correo y el rol
+func (u SystemUser) Validate() error {
+	if !usernamePattern.MatchString(u.Username) {
+		return fmt.Errorf("username must have 3 to 50 letters, digits, dots, dashes or underscores")
+	}
+	if address, err := mail.ParseAddress(u.Email); err != nil || address.Address != u.Email {
+		return fmt.Errorf("email must be a valid address")
+	}
+	if !IsValidRole(u.Role) {
+		return fmt.Errorf("role must be one of %v", Roles())
+	}
+	return nil
+}
+
+// ValidatePassword verifica el largo de una contraseña nueva
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must have %d to %d characters", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
 }
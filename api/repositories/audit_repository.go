@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+type AuditRepository interface {
+	ListAuditLogs(filter models.AuditFilter) ([]models.AuditLog, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db}
+}
+
+// ListAuditLogs devuelve los registros más recientes primero y el total del filtro
+func (r *auditRepository) ListAuditLogs(filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	logs := []models.AuditLog{}
+	err := query.Order("id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&logs).Error
+	return logs, total, err
+}
+
+// recordAudit guarda los registros de auditoría dentro de la transacción del cambio.
+// Los registros sin destino quedan asociados a targetID (el ID recién creado).
+func recordAudit(tx *gorm.DB, targetID uint, entries []models.AuditLog) error {
+	for i := range entries {
+		if entries[i].TargetID == 0 {
+			entries[i].TargetID = targetID
+		}
+		if err := tx.Create(&entries[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
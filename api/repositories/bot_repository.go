@@ -2,14 +2,26 @@
 package repositories
 
 import (
+	"errors"
+
 	"gorm.io/gorm"
 
 	"github.com/brando1998/docubot-api/models"
 )
 
+var (
+	// ErrBotNotFound el bot no está registrado
+	ErrBotNotFound = errors.New("bot not found")
+	// ErrBotNumberTaken ya hay un bot registrado con ese número
+	ErrBotNumberTaken = errors.New("bot number already registered")
+)
+
 type BotRepository interface {
+	GetBotByID(id uint) (*models.Bot, error)
 	GetBotByNumber(number string) (*models.Bot, error)
-	GetOrCreateBot(number string, name string) (*models.Bot, error)
+	ListBots(filter models.BotFilter) ([]models.Bot, int64, error)
+	CreateBot(bot *models.Bot) error
+	UpdateBot(bot *models.Bot) error
 }
 
 type botRepository struct {
@@ -20,22 +32,83 @@ func NewBotRepository(db *gorm.DB) BotRepository {
 	return &botRepository{db}
 }
 
-func (r *botRepository) GetBotByNumber(number string) (*models.Bot, error) {
+func (r *botRepository) GetBotByID(id uint) (*models.Bot, error) {
 	var bot models.Bot
-	err := r.db.Where("number = ?", number).First(&bot).Error
-	return &bot, err
+	return botOrNotFound(&bot, r.db.First(&bot, id).Error)
 }
 
-// get or create bot by number
-func (r *botRepository) GetOrCreateBot(number string, name string) (*models.Bot, error) {
+func (r *botRepository) GetBotByNumber(number string) (*models.Bot, error) {
 	var bot models.Bot
-	err := r.db.Where("number = ?", number).First(&bot).Error
-	if err == gorm.ErrRecordNotFound {
-		bot = models.Bot{
-			Number: number,
-			Name:   name,
-		}
-		err = r.db.Create(&bot).Error
-	}
-	return &bot, err
+	return botOrNotFound(&bot, r.db.Where("number = ?", number).First(&bot).Error)
+}
+
+// ListBots devuelve los bots por ID y el total del filtro
+func (r *botRepository) ListBots(filter models.BotFilter) ([]models.Bot, int64, error) {
+	query := r.db.Model(&models.Bot{})
+	if filter.Active != nil {
+		query = query.Where("active = ?", *filter.Active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	bots := []models.Bot{}
+	err := query.Order("id ASC").Offset(filter.Offset).Limit(filter.Limit).Find(&bots).Error
+	return bots, total, err
+}
+
+func (r *botRepository) CreateBot(bot *models.Bot) error {
+	if err := r.ensureNumberAvailable(bot.Number, 0); err != nil {
+		return err
+	}
+	return r.db.Create(bot).Error
+}
+
+// UpdateBot guarda todos los campos del bot, incluidos los valores vacíos (Active = false)
+func (r *botRepository) UpdateBot(bot *models.Bot) error {
+	if err := r.ensureNumberAvailable(bot.Number, bot.ID); err != nil {
+		return err
+	}
+	result := r.db.Model(bot).Select("*").Omit("created_at").Updates(bot)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBotNotFound
+	}
+	return nil
+}
+
+func (r *botRepository) ensureNumberAvailable(number string, botID uint) error {
+	var count int64
+	err := r.db.Model(&models.Bot{}).Where("number = ? AND id <> ?", number, botID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBotNumberTaken
+	}
+	return nil
+}
+
+func botOrNotFound(bot *models.Bot, err error) (*models.Bot, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// ActivateLegacyBots activa los bots creados automáticamente antes de existir la
+// administración de bots (se registraban con Active = false y sin fecha de creación).
+// Les asigna la fecha para que la migración se aplique una sola vez.
+func ActivateLegacyBots(db *gorm.DB) (int64, error) {
+	result := db.Model(&models.Bot{}).
+		Where("created_at IS NULL").
+		Updates(map[string]interface{}{"active": true, "created_at": gorm.Expr("NOW()"), "updated_at": gorm.Expr("NOW()")})
+	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// ErrCampaignNotFound la campaña no existe
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// campaignRecipientBatch destinatarios insertados por sentencia al crear la campaña
+const campaignRecipientBatch = 500
+
+type CampaignRepository interface {
+	CreateCampaign(campaign *models.Campaign, recipients []models.CampaignRecipient) error
+	GetCampaign(id uint) (*models.Campaign, error)
+	ListCampaigns(filter models.CampaignFilter) ([]models.Campaign, int64, error)
+	TransitionCampaign(id uint, from []string, to string, at time.Time, lastError string) (bool, error)
+	SetCampaignJob(id uint, jobID uint) error
+	PendingRecipients(campaignID uint, limit int) ([]models.CampaignRecipient, error)
+	UpdateRecipient(recipient *models.CampaignRecipient) error
+	CancelPendingRecipients(campaignID uint) (int64, error)
+	ListRecipients(filter models.RecipientFilter) ([]models.CampaignRecipient, int64, error)
+	RecipientStats(campaignID uint) (map[string]int64, error)
+	UpdateRecipientStatus(update models.MessageStatusUpdate) error
+}
+
+type campaignRepository struct {
+	db *gorm.DB
+}
+
+func NewCampaignRepository(db *gorm.DB) CampaignRepository {
+	return &campaignRepository{db}
+}
+
+// CreateCampaign guarda la campaña con su audiencia en una sola transacción
+func (r *campaignRepository) CreateCampaign(campaign *models.Campaign, recipients []models.CampaignRecipient) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(recipients, campaignRecipientBatch).Error
+	})
+}
+
+func (r *campaignRepository) GetCampaign(id uint) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := r.db.First(&campaign, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// ListCampaigns devuelve las campañas más recientes primero y el total del filtro
+func (r *campaignRepository) ListCampaigns(filter models.CampaignFilter) ([]models.Campaign, int64, error) {
+	query := r.db.Model(&models.Campaign{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BotID != 0 {
+		query = query.Where("bot_id = ?", filter.BotID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	campaigns := []models.Campaign{}
+	err := query.Order("id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+// TransitionCampaign cambia el estado solo si la campaña está en uno de los estados from.
+// Al pasar a running registra el inicio y al terminar la fecha de fin.
+func (r *campaignRepository) TransitionCampaign(id uint, from []string, to string, at time.Time, lastError string) (bool, error) {
+	updates := map[string]interface{}{"status": to, "last_error": lastError}
+	switch to {
+	case models.CampaignStatusRunning:
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", at)
+	case models.CampaignStatusCompleted, models.CampaignStatusCanceled, models.CampaignStatusFailed:
+		updates["finished_at"] = at
+	}
+	result := r.db.Model(&models.Campaign{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *campaignRepository) SetCampaignJob(id uint, jobID uint) error {
+	return r.db.Model(&models.Campaign{}).Where("id = ?", id).Update("job_id", jobID).Error
+}
+
+func (r *campaignRepository) PendingRecipients(campaignID uint, limit int) ([]models.CampaignRecipient, error) {
+	recipients := []models.CampaignRecipient{}
+	err := r.db.Where("campaign_id = ? AND status = ?", campaignID, models.RecipientStatusPending).
+		Order("id ASC").Limit(limit).Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *campaignRepository) UpdateRecipient(recipient *models.CampaignRecipient) error {
+	return r.db.Save(recipient).Error
+}
+
+// CancelPendingRecipients marca como cancelados los destinatarios a los que no se les envió
+func (r *campaignRepository) CancelPendingRecipients(campaignID uint) (int64, error) {
+	result := r.db.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, models.RecipientStatusPending).
+		Update("status", models.RecipientStatusCanceled)
+	return result.RowsAffected, result.Error
+}
+
+func (r *campaignRepository) ListRecipients(filter models.RecipientFilter) ([]models.CampaignRecipient, int64, error) {
+	query := r.db.Model(&models.CampaignRecipient{}).Where("campaign_id = ?", filter.CampaignID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	recipients := []models.CampaignRecipient{}
+	err := query.Order("id ASC").Offset(filter.Offset).Limit(filter.Limit).Find(&recipients).Error
+	return recipients, total, err
+}
+
+// RecipientStats cantidad de destinatarios de la campaña por estado
+func (r *campaignRepository) RecipientStats(campaignID uint) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&models.CampaignRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		stats[row.Status] = row.Count
+	}
+	return stats, nil
+}
+
+// UpdateRecipientStatus aplica un recibo de entrega al destinatario que recibió el mensaje.
+// Como en los mensajes, el estado nunca retrocede.
+func (r *campaignRepository) UpdateRecipientStatus(update models.MessageStatusUpdate) error {
+	if update.OutboundID == "" && update.WhatsAppID == "" {
+		return nil
+	}
+	query := r.db.Model(&models.CampaignRecipient{})
+	switch {
+	case update.OutboundID != "" && update.WhatsAppID != "":
+		query = query.Where("outbound_id = ? OR whatsapp_id = ?", update.OutboundID, update.WhatsAppID)
+	case update.OutboundID != "":
+		query = query.Where("outbound_id = ?", update.OutboundID)
+	default:
+		query = query.Where("whatsapp_id = ?", update.WhatsAppID)
+	}
+
+	updates := map[string]interface{}{"status": update.Status}
+	if update.WhatsAppID != "" {
+		updates["whatsapp_id"] = update.WhatsAppID
+	}
+	if update.Error != "" {
+		updates["error"] = update.Error
+	}
+	return query.Where("status IN ?", recipientStatusesBefore(update.Status)).Updates(updates).Error
+}
+
+// recipientStatusesBefore estados desde los que un destinatario puede pasar a status
+func recipientStatusesBefore(status string) []string {
+	if status == models.MessageStatusFailed {
+		return []string{models.RecipientStatusQueued, models.MessageStatusSent}
+	}
+	before := []string{models.RecipientStatusQueued}
+	for _, candidate := range []string{models.MessageStatusSent, models.MessageStatusDelivered} {
+		if models.MessageStatusRank(candidate) < models.MessageStatusRank(status) {
+			before = append(before, candidate)
+		}
+	}
+	return before
+}
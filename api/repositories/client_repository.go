@@ -3,6 +3,7 @@ package repositories
 import (
 	"errors"
 	"log"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -14,6 +15,8 @@ type ClientRepository interface {
 	GetClientByID(id uint) (*models.Client, error)
 	GetClientByPhone(phone string) (*models.Client, error)
 	GetOrCreateClient(phone, name, email string) (*models.Client, error)
+	ListClients(filter models.ClientFilter, limit int) ([]models.Client, error)
+	SetClientOptOut(id uint, optedOut bool, at time.Time) error
 }
 
 type userRepository struct {
@@ -72,3 +75,42 @@ func (r *userRepository) GetOrCreateClient(phone, name, email string) (*models.C
 	log.Printf("Error buscando cliente: %v", err)
 	return nil, err
 }
+
+// ListClients devuelve por ID los clientes que cumplen el filtro, hasta limit (0 = sin límite)
+func (r *userRepository) ListClients(filter models.ClientFilter, limit int) ([]models.Client, error) {
+	query := r.db.Model(&models.Client{})
+	if len(filter.ClientIDs) > 0 {
+		query = query.Where("id IN ?", filter.ClientIDs)
+	}
+	if len(filter.Phones) > 0 {
+		query = query.Where("phone IN ?", filter.Phones)
+	}
+	if filter.Company != "" {
+		query = query.Where("company ILIKE ?", filter.Company)
+	}
+	if filter.BotID != 0 {
+		query = query.Where("bot_id = ?", filter.BotID)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	clients := []models.Client{}
+	err := query.Order("id ASC").Find(&clients).Error
+	return clients, err
+}
+
+// SetClientOptOut registra si el cliente pidió no recibir campañas
+func (r *userRepository) SetClientOptOut(id uint, optedOut bool, at time.Time) error {
+	updates := map[string]interface{}{"opted_out": optedOut, "opted_out_at": nil}
+	if optedOut {
+		updates["opted_out_at"] = at
+	}
+	return r.db.Model(&models.Client{}).Where("id = ?", id).Updates(updates).Error
+}
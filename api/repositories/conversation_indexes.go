@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// MigrateConversations normaliza conversaciones guardadas por versiones anteriores:
+// renombra user_id a client_id y completa el resumen (último mensaje) que usa el
+// listado de recientes. Es idempotente. Mover los mensajes embebidos a su propia
+// colección es más costoso y lo hace SplitConversationMessages (cmd/migrate-conversations).
+func MigrateConversations(ctx context.Context, client *mongo.Client) error {
+	collection := conversationsCollection(client)
+
+	renamed, err := collection.UpdateMany(ctx,
+		bson.M{"user_id": bson.M{"$exists": true}, "client_id": bson.M{"$exists": false}},
+		bson.M{"$rename": bson.M{"user_id": "client_id"}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rename user_id: %w", err)
+	}
+
+	summarized, err := collection.UpdateMany(ctx,
+		bson.M{"last_message_at": bson.M{"$exists": false}, "messages.0": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"last_message_at": bson.M{"$max": "$messages.timestamp"},
+			"last_message":    bson.M{"$arrayElemAt": bson.A{"$messages", -1}},
+			"unread_count":    bson.M{"$ifNull": bson.A{"$unread_count", 0}},
+			"message_count":   bson.M{"$size": "$messages"},
+		}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill conversation summaries: %w", err)
+	}
+
+	if renamed.ModifiedCount > 0 || summarized.ModifiedCount > 0 {
+		log.Printf("🔄 Conversaciones migradas: %d con client_id, %d con resumen", renamed.ModifiedCount, summarized.ModifiedCount)
+	}
+	return nil
+}
+
+// SplitStats resultado de mover los mensajes embebidos a la colección messages
+type SplitStats struct {
+	Conversations int // conversaciones con arreglo de mensajes encontradas
+	Messages      int // mensajes insertados en la colección messages
+	Skipped       int // mensajes que ya existían (ejecución anterior interrumpida)
+}
+
+// SplitConversationMessages mueve los mensajes embebidos en cada conversación a la
+// colección messages y deja solo el resumen. Primero fija un _id a cada mensaje en
+// el documento original, de modo que si se interrumpe puede volver a ejecutarse sin
+// duplicar mensajes. Con dryRun solo cuenta lo que haría.
+func SplitConversationMessages(ctx context.Context, client *mongo.Client, dryRun bool) (SplitStats, error) {
+	var stats SplitStats
+	conversations := conversationsCollection(client)
+	messages := messagesCollection(client)
+
+	cursor, err := conversations.Find(ctx, bson.M{"messages": bson.M{"$exists": true}})
+	if err != nil {
+		return stats, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var conversation models.Conversation
+		if err := cursor.Decode(&conversation); err != nil {
+			return stats, fmt.Errorf("failed to decode conversation: %w", err)
+		}
+		stats.Conversations++
+
+		if dryRun {
+			stats.Messages += len(conversation.Messages)
+			continue
+		}
+
+		// 1. Fijar ids en el documento original
+		assigned := false
+		for i := range conversation.Messages {
+			if conversation.Messages[i].ID.IsZero() {
+				conversation.Messages[i].ID = primitive.NewObjectID()
+				assigned = true
+			}
+		}
+		if assigned {
+			if _, err := conversations.UpdateOne(ctx,
+				bson.M{"_id": conversation.ID},
+				bson.M{"$set": bson.M{"messages": conversation.Messages}},
+			); err != nil {
+				return stats, fmt.Errorf("failed to assign message ids in %s: %w", conversation.ID.Hex(), err)
+			}
+		}
+
+		// 2. Copiar los mensajes; los que ya estaban se omiten
+		for _, message := range conversation.Messages {
+			message.ConversationID = conversation.ID
+			message.ClientID = conversation.UserID
+			message.BotID = conversation.BotID
+			if _, err := messages.InsertOne(ctx, message); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					stats.Skipped++
+					continue
+				}
+				return stats, fmt.Errorf("failed to insert message %s: %w", message.ID.Hex(), err)
+			}
+			stats.Messages++
+		}
+
+		// 3. Dejar solo el resumen
+		count, err := messages.CountDocuments(ctx, bson.M{"conversation_id": conversation.ID})
+		if err != nil {
+			return stats, fmt.Errorf("failed to count messages of %s: %w", conversation.ID.Hex(), err)
+		}
+		summary := bson.M{"message_count": count}
+		if last := lastMessage(conversation.Messages); last != nil {
+			last.ConversationID = conversation.ID
+			summary["last_message"] = last
+			summary["last_message_at"] = last.Timestamp
+		}
+		if _, err := conversations.UpdateOne(ctx,
+			bson.M{"_id": conversation.ID},
+			bson.M{"$set": summary, "$unset": bson.M{"messages": ""}},
+		); err != nil {
+			return stats, fmt.Errorf("failed to summarize conversation %s: %w", conversation.ID.Hex(), err)
+		}
+	}
+	return stats, cursor.Err()
+}
+
+func lastMessage(messages []models.Message) *models.Message {
+	var last *models.Message
+	for i := range messages {
+		if last == nil || !messages[i].Timestamp.Before(last.Timestamp) {
+			last = &messages[i]
+		}
+	}
+	return last
+}
+
+// Índices que existieron sobre el arreglo embebido de mensajes
+var legacyConversationIndexes = []string{"message_outbound_ids", "message_whatsapp_ids", "message_text"}
+
+// EnsureConversationIndexes crea los índices que usan las consultas de conversaciones, mensajes y sesiones
+func EnsureConversationIndexes(ctx context.Context, client *mongo.Client) error {
+	conversations := conversationsCollection(client)
+	for _, name := range legacyConversationIndexes {
+		// Si el índice no existe el servidor responde IndexNotFound; no es un error
+		if _, err := conversations.Indexes().DropOne(ctx, name); err != nil && !isIndexNotFound(err) {
+			return fmt.Errorf("failed to drop legacy index %s: %w", name, err)
+		}
+	}
+
+	conversationIndexes := []mongo.IndexModel{
+		{
+			// Una conversación por cliente y bot (clave de los upsert)
+			Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "bot_id", Value: 1}},
+			Options: options.Index().SetName("client_bot").SetUnique(true),
+		},
+		{
+			// Listado de conversaciones recientes, general o por bot
+			Keys:    bson.D{{Key: "last_message_at", Value: -1}},
+			Options: options.Index().SetName("last_message_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "bot_id", Value: 1}, {Key: "last_message_at", Value: -1}},
+			Options: options.Index().SetName("bot_last_message_at"),
+		},
+	}
+	if _, err := conversations.Indexes().CreateMany(ctx, conversationIndexes); err != nil {
+		return fmt.Errorf("failed to create conversation indexes: %w", err)
+	}
+
+	messageIndexes := []mongo.IndexModel{
+		{
+			// Historial paginado por cursor
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("conversation_timestamp"),
+		},
+		{
+			// Recibos de entrega
+			Keys:    bson.D{{Key: "outbound_ids", Value: 1}},
+			Options: options.Index().SetName("outbound_ids"),
+		},
+		{
+			Keys:    bson.D{{Key: "whatsapp_ids", Value: 1}},
+			Options: options.Index().SetName("whatsapp_ids"),
+		},
+		{
+			// Mensajes sin entregar por bot
+			Keys:    bson.D{{Key: "bot_id", Value: 1}, {Key: "status", Value: 1}, {Key: "timestamp", Value: -1}},
+			Options: options.Index().SetName("bot_status_timestamp"),
+		},
+		{
+			// Búsqueda de texto completo
+			Keys:    bson.D{{Key: "text", Value: "text"}},
+			Options: options.Index().SetName("text").SetDefaultLanguage("spanish"),
+		},
+	}
+	if _, err := messagesCollection(client).Indexes().CreateMany(ctx, messageIndexes); err != nil {
+		return fmt.Errorf("failed to create message indexes: %w", err)
+	}
+
+	sessionIndexes := []mongo.IndexModel{
+		{
+			// Una sola sesión activa por cliente y bot
+			Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "bot_id", Value: 1}},
+			Options: options.Index().SetName("client_bot_active").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			// Listado de sesiones de una conversación
+			Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "bot_id", Value: 1}, {Key: "started_at", Value: -1}},
+			Options: options.Index().SetName("client_bot_started_at"),
+		},
+		{
+			// Cierre de sesiones inactivas
+			Keys:    bson.D{{Key: "active", Value: 1}, {Key: "last_activity_at", Value: 1}},
+			Options: options.Index().SetName("active_last_activity_at"),
+		},
+	}
+	if _, err := sessionsCollection(client).Indexes().CreateMany(ctx, sessionIndexes); err != nil {
+		return fmt.Errorf("failed to create session indexes: %w", err)
+	}
+	return nil
+}
+
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		// 26: NamespaceNotFound (colección aún no creada), 27: IndexNotFound
+		return cmdErr.Code == 26 || cmdErr.Code == 27
+	}
+	return false
+}
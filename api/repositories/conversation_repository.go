@@ -2,39 +2,250 @@ package repositories
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/brando1998/docubot-api/models"
 )
 
+// Máximo de mensajes que se agrupan por conversación al buscar
+const maxSearchMessages = 500
+
+// ErrInvalidCursor cursor de paginación mal formado
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
 type ConversationRepository interface {
 	SaveMessage(ctx context.Context, userID uint, botID uint, message models.Message) error
 	GetConversationByUserID(ctx context.Context, userID uint) (*models.Conversation, error)
+	UpdateMessageStatus(ctx context.Context, update models.MessageStatusUpdate) error
+	GetUndeliveredMessages(ctx context.Context, botID uint, limit int) ([]models.Message, error)
+	GetConversationMode(ctx context.Context, userID uint, botID uint) (string, error)
+	SetConversationMode(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error
+	GetMessages(ctx context.Context, userID uint, botID uint, before string, limit int) ([]models.Message, string, error)
+	GetRecentConversations(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error)
+	MarkConversationRead(ctx context.Context, userID uint, botID uint, at time.Time) error
+	SearchMessages(ctx context.Context, query string, botID uint, limit int) ([]models.ConversationSearchResult, error)
 }
 
+// conversationRepository guarda un documento resumen por cliente y bot en
+// conversations y cada mensaje como documento propio en messages
 type conversationRepository struct {
-	collection *mongo.Collection
+	collection *mongo.Collection // conversations
+	messages   *mongo.Collection
 }
 
 // Constructor
 func NewConversationRepository(client *mongo.Client) ConversationRepository {
-	collection := client.Database(os.Getenv("MONGO_DB")).Collection("conversations")
-	return &conversationRepository{collection}
+	return &conversationRepository{
+		collection: conversationsCollection(client),
+		messages:   messagesCollection(client),
+	}
+}
+
+func conversationsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(os.Getenv("MONGO_DB")).Collection("conversations")
+}
+
+func messagesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(os.Getenv("MONGO_DB")).Collection("messages")
 }
 
-// Implementación de SaveMessage
+// Implementación de SaveMessage. Guarda el mensaje en su propia colección y después
+// actualiza el resumen de la conversación (último mensaje, contadores): si la inserción
+// falla, los contadores no cambian.
 func (r *conversationRepository) SaveMessage(ctx context.Context, userID uint, botID uint, message models.Message) error {
-	filter := bson.M{"user_id": userID, "bot_id": botID}
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+	message.ClientID = userID
+	message.BotID = botID
+
+	// Id de la conversación; si aún no existe se reserva uno y se crea con el resumen
+	filter := bson.M{"client_id": userID, "bot_id": botID}
+	var existing models.Conversation
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&existing)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		message.ConversationID = primitive.NewObjectID()
+	case err != nil:
+		return fmt.Errorf("failed to find conversation: %w", err)
+	default:
+		message.ConversationID = existing.ID
+	}
+
+	if _, err := r.messages.InsertOne(ctx, message); err != nil {
+		return fmt.Errorf("failed to insert message: %w", err)
+	}
+
+	set := bson.M{
+		"last_message_at": message.Timestamp,
+		"last_message":    message,
+	}
+	inc := bson.M{"message_count": 1}
+	switch {
+	case message.IsInbound():
+		inc["unread_count"] = 1
+	case message.Sender == models.MessageSenderOperator:
+		// Quien responde ya leyó la conversación
+		set["unread_count"] = 0
+		set["last_read_at"] = message.Timestamp
+	}
 	update := bson.M{
-		"$push": bson.M{"messages": message},
+		"$set":         set,
+		"$inc":         inc,
+		"$setOnInsert": bson.M{"_id": message.ConversationID, "created_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"_id": 1})
+
+	var conversation models.Conversation
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&conversation); err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	// Otro mensaje creó la conversación al mismo tiempo con otro id
+	if conversation.ID != message.ConversationID {
+		if _, err := r.messages.UpdateByID(ctx, message.ID, bson.M{"$set": bson.M{"conversation_id": conversation.ID}}); err != nil {
+			return fmt.Errorf("failed to link message to conversation: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetConversationByUserID devuelve la conversación más reciente del cliente con todos sus mensajes
+func (r *conversationRepository) GetConversationByUserID(ctx context.Context, userID uint) (*models.Conversation, error) {
+	filter := bson.M{"client_id": userID}
+	opts := options.FindOne().SetSort(bson.D{{Key: "last_message_at", Value: -1}})
+
+	var conversation models.Conversation
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(&conversation); err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.messages.Find(ctx,
+		bson.M{"conversation_id": conversation.ID},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	conversation.Messages = []models.Message{}
+	if err := cursor.All(ctx, &conversation.Messages); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// UpdateMessageStatus aplica un recibo de entrega al mensaje que lo originó.
+// Solo avanza el estado (sent → delivered → read); failed no pisa delivered/read.
+func (r *conversationRepository) UpdateMessageStatus(ctx context.Context, update models.MessageStatusUpdate) error {
+	var filter bson.M
+	switch {
+	case update.OutboundID != "":
+		filter = bson.M{"outbound_ids": update.OutboundID}
+	case update.WhatsAppID != "":
+		filter = bson.M{"whatsapp_ids": update.WhatsAppID}
+	default:
+		return fmt.Errorf("status update without message reference")
+	}
+
+	previous := previousStatuses(update.Status)
+	if previous == nil {
+		return fmt.Errorf("unknown message status %q", update.Status)
+	}
+	filter["status"] = bson.M{"$in": previous}
+
+	set := bson.M{"status": update.Status}
+	switch update.Status {
+	case models.MessageStatusSent:
+		set["sent_at"] = update.At
+	case models.MessageStatusDelivered:
+		set["delivered_at"] = update.At
+	case models.MessageStatusRead:
+		set["read_at"] = update.At
+	case models.MessageStatusFailed:
+		set["failed_at"] = update.At
+		set["status_error"] = update.Error
+	}
+	updateDoc := bson.M{"$set": set}
+	if update.WhatsAppID != "" {
+		updateDoc["$addToSet"] = bson.M{"whatsapp_ids": update.WhatsAppID}
+	}
+
+	_, err := r.messages.UpdateOne(ctx, filter, updateDoc)
+	return err
+}
+
+// GetUndeliveredMessages lista los mensajes del bot (y de sus operadores) que aún no llegan al cliente, del más reciente al más antiguo
+func (r *conversationRepository) GetUndeliveredMessages(ctx context.Context, botID uint, limit int) ([]models.Message, error) {
+	filter := bson.M{
+		"bot_id": botID,
+		"status": bson.M{"$in": []string{models.MessageStatusPending, models.MessageStatusSent, models.MessageStatusFailed}},
+		"sender": bson.M{"$in": []string{models.MessageSenderBot, models.MessageSenderOperator}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := r.messages.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetConversationMode devuelve el modo de atención; las conversaciones nuevas o sin modo están en modo bot
+func (r *conversationRepository) GetConversationMode(ctx context.Context, userID uint, botID uint) (string, error) {
+	filter := bson.M{"client_id": userID, "bot_id": botID}
+	opts := options.FindOne().SetProjection(bson.M{"mode": 1})
+
+	var conversation models.Conversation
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&conversation)
+	if err == mongo.ErrNoDocuments {
+		return models.ConversationModeBot, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if conversation.Mode == "" {
+		return models.ConversationModeBot, nil
+	}
+	return conversation.Mode, nil
+}
+
+// SetConversationMode cambia el modo de atención, creando la conversación si aún no existe
+func (r *conversationRepository) SetConversationMode(ctx context.Context, userID uint, botID uint, change models.ConversationModeChange) error {
+	if !models.IsValidConversationMode(change.Mode) {
+		return fmt.Errorf("unknown conversation mode %q", change.Mode)
+	}
+
+	filter := bson.M{"client_id": userID, "bot_id": botID}
+	update := bson.M{
+		"$set": bson.M{
+			"mode":            change.Mode,
+			"assigned_to":     change.OperatorID,
+			"handoff_reason":  change.Reason,
+			"mode_updated_at": change.At,
+		},
 		"$setOnInsert": bson.M{
-			"user_id":    userID,
-			"bot_id":     botID,
 			"created_at": time.Now(),
 		},
 	}
@@ -43,13 +254,182 @@ func (r *conversationRepository) SaveMessage(ctx context.Context, userID uint, b
 	return err
 }
 
-// Implementación de GetConversationByUserID
-func (r *conversationRepository) GetConversationByUserID(ctx context.Context, userID uint) (*models.Conversation, error) {
-	filter := bson.M{"user_id": userID}
+// GetMessages devuelve hasta limit mensajes anteriores al cursor before (vacío = los más
+// recientes) en orden cronológico, junto con el cursor de la página siguiente (vacío si no hay más)
+func (r *conversationRepository) GetMessages(ctx context.Context, userID uint, botID uint, before string, limit int) ([]models.Message, string, error) {
 	var conversation models.Conversation
-	err := r.collection.FindOne(ctx, filter).Decode(&conversation)
+	err := r.collection.FindOne(ctx,
+		bson.M{"client_id": userID, "bot_id": botID},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Decode(&conversation)
+	if err == mongo.ErrNoDocuments {
+		return []models.Message{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	filter := bson.M{"conversation_id": conversation.ID}
+	if before != "" {
+		at, id, err := decodeMessageCursor(before)
+		if err != nil {
+			return nil, "", err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{"$lt": at}},
+			bson.M{"timestamp": at, "_id": bson.M{"$lt": id}},
+		}
+	}
+
+	// Se pide uno más para saber si hay otra página
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := r.messages.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		oldest := messages[limit-1]
+		next = encodeMessageCursor(oldest.Timestamp, oldest.ID)
+	}
+
+	// De más antiguo a más reciente
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, next, nil
+}
+
+// GetRecentConversations lista las conversaciones de la más reciente a la más antigua
+func (r *conversationRepository) GetRecentConversations(ctx context.Context, filter models.ConversationFilter) ([]models.ConversationSummary, error) {
+	match := bson.M{}
+	if filter.ClientID != 0 {
+		match["client_id"] = filter.ClientID
+	}
+	if filter.BotID != 0 {
+		match["bot_id"] = filter.BotID
+	}
+	if filter.UnreadOnly {
+		match["unread_count"] = bson.M{"$gt": 0}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "last_message_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(filter.Offset)).
+		SetLimit(int64(filter.Limit)).
+		SetProjection(bson.M{"messages": 0})
+
+	cursor, err := r.collection.Find(ctx, match, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &conversation, nil
+	defer cursor.Close(ctx)
+
+	summaries := []models.ConversationSummary{}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// MarkConversationRead deja en cero los mensajes sin leer de la conversación
+func (r *conversationRepository) MarkConversationRead(ctx context.Context, userID uint, botID uint, at time.Time) error {
+	filter := bson.M{"client_id": userID, "bot_id": botID}
+	update := bson.M{"$set": bson.M{"unread_count": 0, "last_read_at": at}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SearchMessages busca texto en los mensajes usando el índice de texto y los agrupa
+// por conversación, de la conversación con mejor puntaje a la de menor
+func (r *conversationRepository) SearchMessages(ctx context.Context, query string, botID uint, limit int) ([]models.ConversationSearchResult, error) {
+	match := bson.M{"$text": bson.M{"$search": query}}
+	if botID != 0 {
+		match["bot_id"] = botID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "timestamp", Value: -1}}}},
+		{{Key: "$limit", Value: maxSearchMessages}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$conversation_id",
+			"client_id": bson.M{"$first": "$client_id"},
+			"bot_id":    bson.M{"$first": "$bot_id"},
+			"score":     bson.M{"$max": "$score"},
+			"messages":  bson.M{"$push": "$$ROOT"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.messages.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.ConversationSearchResult{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// encodeMessageCursor arma el cursor opaco de paginación (fecha en ms + id del mensaje)
+func encodeMessageCursor(at time.Time, id primitive.ObjectID) string {
+	raw := strconv.FormatInt(at.UnixMilli(), 10) + ":" + id.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeMessageCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	millis, hex, found := strings.Cut(string(raw), ":")
+	if !found {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	return time.UnixMilli(ms), id, nil
+}
+
+// previousStatuses estados desde los que se puede pasar al estado indicado
+func previousStatuses(status string) []string {
+	switch status {
+	case models.MessageStatusSent:
+		return []string{models.MessageStatusPending}
+	case models.MessageStatusDelivered:
+		return []string{models.MessageStatusPending, models.MessageStatusSent}
+	case models.MessageStatusRead:
+		return []string{models.MessageStatusPending, models.MessageStatusSent, models.MessageStatusDelivered}
+	case models.MessageStatusFailed:
+		return []string{models.MessageStatusPending, models.MessageStatusSent}
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+type DocumentRepository interface {
+	CreateDocument(ctx context.Context, document *models.Document) error
+	GetDocumentByID(ctx context.Context, id string) (*models.Document, error)
+	GetDocumentByJobID(ctx context.Context, jobID uint) (*models.Document, error)
+	ListDocumentsByClient(ctx context.Context, clientID uint, offset, limit int) ([]models.Document, error)
+}
+
+type documentRepository struct {
+	collection *mongo.Collection
+}
+
+// Constructor
+func NewDocumentRepository(client *mongo.Client) DocumentRepository {
+	return &documentRepository{collection: documentsCollection(client)}
+}
+
+func documentsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(os.Getenv("MONGO_DB")).Collection("documents")
+}
+
+func (r *documentRepository) CreateDocument(ctx context.Context, document *models.Document) error {
+	if document.ID.IsZero() {
+		document.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, document)
+	return err
+}
+
+// GetDocumentByID devuelve mongo.ErrNoDocuments si el id no existe o es inválido
+func (r *documentRepository) GetDocumentByID(ctx context.Context, id string) (*models.Document, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var document models.Document
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// GetDocumentByJobID devuelve el documento que guardó el trabajo, o mongo.ErrNoDocuments
+func (r *documentRepository) GetDocumentByJobID(ctx context.Context, jobID uint) (*models.Document, error) {
+	var document models.Document
+	if err := r.collection.FindOne(ctx, bson.M{"job_id": jobID}).Decode(&document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// ListDocumentsByClient devuelve los documentos del cliente, los más recientes primero
+func (r *documentRepository) ListDocumentsByClient(ctx context.Context, clientID uint, offset, limit int) ([]models.Document, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"client_id": clientID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	documents := []models.Document{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// EnsureDocumentIndexes crea los índices de la colección documents
+func EnsureDocumentIndexes(ctx context.Context, client *mongo.Client) error {
+	indexes := []mongo.IndexModel{
+		{
+			// Documentos por cliente
+			Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("client_created_at"),
+		},
+		{
+			// Documento de cada trabajo; los generados en el momento no tienen job_id
+			Keys:    bson.D{{Key: "job_id", Value: 1}},
+			Options: options.Index().SetName("job_id").SetSparse(true),
+		},
+	}
+	if _, err := documentsCollection(client).Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("failed to create document indexes: %w", err)
+	}
+	return nil
+}
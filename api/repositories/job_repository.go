@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// ErrJobNotFound el trabajo no existe
+var ErrJobNotFound = errors.New("job not found")
+
+type JobRepository interface {
+	CreateJob(job *models.Job) error
+	GetJob(id uint) (*models.Job, error)
+	ListJobs(filter models.JobFilter) ([]models.Job, int64, error)
+	ClaimNextJob(workerID string, now time.Time) (*models.Job, error)
+	CompleteJob(id uint, result string, finishedAt time.Time) (bool, error)
+	RetryJob(id uint, nextRunAt time.Time, lastError string) (bool, error)
+	FailJob(id uint, lastError string, finishedAt time.Time) (bool, error)
+	CancelJob(id uint, canceledAt time.Time) (bool, error)
+	RequeueJob(id uint, now time.Time) (bool, error)
+	RequeueStaleJobs(startedBefore time.Time, now time.Time) (requeued int64, failed []models.Job, err error)
+	AddJobLog(entry *models.JobLog) error
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db}
+}
+
+func (r *jobRepository) CreateJob(job *models.Job) error {
+	if job.Status == "" {
+		job.Status = models.JobStatusQueued
+	}
+	return r.db.Create(job).Error
+}
+
+// GetJob devuelve el trabajo con su registro de ejecución
+func (r *jobRepository) GetJob(id uint) (*models.Job, error) {
+	var job models.Job
+	err := r.db.Preload("Logs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs devuelve los trabajos más recientes primero, sin registros, y el total del filtro
+func (r *jobRepository) ListJobs(filter models.JobFilter) ([]models.Job, int64, error) {
+	query := r.db.Model(&models.Job{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ClientID != 0 {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	jobs := []models.Job{}
+	err := query.Order("id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// ClaimNextJob toma el trabajo vencido más antiguo y lo marca como running. Con
+// FOR UPDATE SKIP LOCKED varios workers (o instancias del API) no toman el mismo.
+// Devuelve nil si no hay trabajos pendientes.
+func (r *jobRepository) ClaimNextJob(workerID string, now time.Time) (*models.Job, error) {
+	var claimed *models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var job models.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", models.JobStatusQueued, now).
+			Order("next_run_at ASC, id ASC").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.WorkerID = workerID
+		job.StartedAt = &now
+		job.FinishedAt = nil
+		err = tx.Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":      job.Status,
+			"attempts":    job.Attempts,
+			"worker_id":   workerID,
+			"started_at":  now,
+			"finished_at": nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		claimed = &job
+		return nil
+	})
+	return claimed, err
+}
+
+// Las transiciones desde running no pisan un trabajo cancelado mientras se ejecutaba
+
+func (r *jobRepository) CompleteJob(id uint, result string, finishedAt time.Time) (bool, error) {
+	return r.transition(id, []string{models.JobStatusRunning}, map[string]interface{}{
+		"status":      models.JobStatusSucceeded,
+		"result":      result,
+		"last_error":  "",
+		"finished_at": finishedAt,
+	})
+}
+
+// RetryJob deja el trabajo en cola para un nuevo intento
+func (r *jobRepository) RetryJob(id uint, nextRunAt time.Time, lastError string) (bool, error) {
+	return r.transition(id, []string{models.JobStatusRunning}, map[string]interface{}{
+		"status":      models.JobStatusQueued,
+		"next_run_at": nextRunAt,
+		"last_error":  lastError,
+	})
+}
+
+func (r *jobRepository) FailJob(id uint, lastError string, finishedAt time.Time) (bool, error) {
+	return r.transition(id, []string{models.JobStatusRunning}, map[string]interface{}{
+		"status":      models.JobStatusFailed,
+		"last_error":  lastError,
+		"finished_at": finishedAt,
+	})
+}
+
+// CancelJob cancela un trabajo en cola o en ejecución
+func (r *jobRepository) CancelJob(id uint, canceledAt time.Time) (bool, error) {
+	return r.transition(id, []string{models.JobStatusQueued, models.JobStatusRunning}, map[string]interface{}{
+		"status":      models.JobStatusCanceled,
+		"finished_at": canceledAt,
+	})
+}
+
+// RequeueJob vuelve a encolar un trabajo terminado con los intentos en cero
+func (r *jobRepository) RequeueJob(id uint, now time.Time) (bool, error) {
+	return r.transition(id, []string{models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusCanceled}, map[string]interface{}{
+		"status":      models.JobStatusQueued,
+		"attempts":    0,
+		"next_run_at": now,
+		"last_error":  "",
+		"result":      "",
+		"started_at":  nil,
+		"finished_at": nil,
+	})
+}
+
+// RequeueStaleJobs devuelve a la cola los trabajos que quedaron en running (por ejemplo
+// porque el proceso se reinició a mitad de la ejecución). Los que ya agotaron sus
+// intentos quedan fallidos: un trabajo que tumba al worker no se reintenta sin fin.
+func (r *jobRepository) RequeueStaleJobs(startedBefore time.Time, now time.Time) (int64, []models.Job, error) {
+	const staleError = "worker stopped before finishing"
+	var requeued int64
+	var failed []models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		stale := func() *gorm.DB {
+			return tx.Model(&models.Job{}).Where("status = ? AND started_at < ?", models.JobStatusRunning, startedBefore)
+		}
+
+		if err := stale().Where("attempts >= max_attempts").Find(&failed).Error; err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			ids := make([]uint, len(failed))
+			for i := range failed {
+				ids[i] = failed[i].ID
+				failed[i].Status = models.JobStatusFailed
+				failed[i].LastError = staleError
+				failed[i].FinishedAt = &now
+			}
+			err := tx.Model(&models.Job{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+				"status":      models.JobStatusFailed,
+				"last_error":  staleError,
+				"finished_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := stale().Updates(map[string]interface{}{
+			"status":      models.JobStatusQueued,
+			"next_run_at": now,
+			"last_error":  staleError,
+		})
+		requeued = result.RowsAffected
+		return result.Error
+	})
+	return requeued, failed, err
+}
+
+func (r *jobRepository) AddJobLog(entry *models.JobLog) error {
+	return r.db.Create(entry).Error
+}
+
+// transition actualiza el trabajo solo si está en uno de los estados from
+func (r *jobRepository) transition(id uint, from []string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.Job{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
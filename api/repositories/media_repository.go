@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+type MediaRepository interface {
+	CreateMedia(ctx context.Context, media *models.MediaFile) error
+	GetMediaByID(ctx context.Context, id string) (*models.MediaFile, error)
+}
+
+type mediaRepository struct {
+	collection *mongo.Collection
+}
+
+// Constructor
+func NewMediaRepository(client *mongo.Client) MediaRepository {
+	return &mediaRepository{collection: mediaCollection(client)}
+}
+
+func mediaCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(os.Getenv("MONGO_DB")).Collection("media")
+}
+
+func (r *mediaRepository) CreateMedia(ctx context.Context, media *models.MediaFile) error {
+	if media.ID.IsZero() {
+		media.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, media)
+	return err
+}
+
+// GetMediaByID devuelve mongo.ErrNoDocuments si el id no existe o es inválido
+func (r *mediaRepository) GetMediaByID(ctx context.Context, id string) (*models.MediaFile, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var media models.MediaFile
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&media); err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// EnsureMediaIndexes crea los índices de la colección media
+func EnsureMediaIndexes(ctx context.Context, client *mongo.Client) error {
+	indexes := []mongo.IndexModel{
+		{
+			// Archivos por cliente
+			Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("client_created_at"),
+		},
+	}
+	if _, err := mediaCollection(client).Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("failed to create media indexes: %w", err)
+	}
+	return nil
+}
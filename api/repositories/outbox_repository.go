@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+type OutboxRepository interface {
+	Enqueue(message *models.OutboundMessage) error
+	GetUnackedByBot(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error)
+	GetBotsWithDueMessages(now time.Time) ([]string, error)
+	MarkSent(id uint, sentAt time.Time, ackDeadline time.Time) error
+	MarkRetry(id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(id uint, attempts int, lastError string) error
+	GetByEnvelopeID(envelopeID string) (*models.OutboundMessage, error)
+	MarkAcked(envelopeID, whatsAppID string, ackedAt time.Time) error
+}
+
+// unackedOutboundStatuses estados de los mensajes que todavía se pueden (re)entregar
+var unackedOutboundStatuses = []string{models.OutboundStatusPending, models.OutboundStatusSent}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db}
+}
+
+func (r *outboxRepository) Enqueue(message *models.OutboundMessage) error {
+	if message.Status == "" {
+		message.Status = models.OutboundStatusPending
+	}
+	return r.db.Create(message).Error
+}
+
+// GetUnackedByBot devuelve en orden de llegada, a partir del id afterID, los mensajes
+// del bot que Baileys aún no confirmó: pendientes (incluidos los que esperan su próximo
+// reintento) y escritos en el socket sin ack
+func (r *outboxRepository) GetUnackedByBot(botNumber string, afterID uint, limit int) ([]models.OutboundMessage, error) {
+	var messages []models.OutboundMessage
+	err := r.db.Where("bot_number = ? AND status IN ? AND id > ?", botNumber, unackedOutboundStatuses, afterID).
+		Order("id ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (r *outboxRepository) GetBotsWithDueMessages(now time.Time) ([]string, error) {
+	var bots []string
+	err := r.db.Model(&models.OutboundMessage{}).
+		Where("status IN ? AND next_attempt_at <= ?", unackedOutboundStatuses, now).
+		Distinct().
+		Pluck("bot_number", &bots).Error
+	return bots, err
+}
+
+// MarkSent registra que el frame se escribió en el socket; si el ack no llega antes de
+// ackDeadline el mensaje se vuelve a entregar
+func (r *outboxRepository) MarkSent(id uint, sentAt time.Time, ackDeadline time.Time) error {
+	return r.db.Model(&models.OutboundMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          models.OutboundStatusSent,
+		"sent_at":         sentAt,
+		"next_attempt_at": ackDeadline,
+		"last_error":      "",
+	}).Error
+}
+
+// MarkRetry deja el mensaje pendiente para un nuevo intento
+func (r *outboxRepository) MarkRetry(id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return r.db.Model(&models.OutboundMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          models.OutboundStatusPending,
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	}).Error
+}
+
+func (r *outboxRepository) MarkFailed(id uint, attempts int, lastError string) error {
+	return r.db.Model(&models.OutboundMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     models.OutboundStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	}).Error
+}
+
+func (r *outboxRepository) GetByEnvelopeID(envelopeID string) (*models.OutboundMessage, error) {
+	var message models.OutboundMessage
+	err := r.db.Where("envelope_id = ?", envelopeID).First(&message).Error
+	return &message, err
+}
+
+func (r *outboxRepository) MarkAcked(envelopeID, whatsAppID string, ackedAt time.Time) error {
+	return r.db.Model(&models.OutboundMessage{}).Where("envelope_id = ?", envelopeID).Updates(map[string]interface{}{
+		"status":      models.OutboundStatusAcked,
+		"acked_at":    ackedAt,
+		"whatsapp_id": whatsAppID,
+	}).Error
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// ErrRefreshTokenNotFound el refresh token no existe
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*models.RefreshToken, error)
+	RotateRefreshToken(used *models.RefreshToken, next *models.RefreshToken, at time.Time) (bool, error)
+	RevokeSession(sessionID string, reason string, at time.Time) (int64, error)
+	RevokeUserSessions(userID uint, exceptSessionID string, reason string, at time.Time) (int64, error)
+	IsAccessTokenRevoked(tokenID string) (bool, error)
+	DeleteExpiredRefreshTokens(before time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marca used como usado y guarda next en una sola transacción.
+// Devuelve false sin guardar next si used ya se había usado o revocado (otra
+// solicitud lo rotó primero).
+func (r *refreshTokenRepository) RotateRefreshToken(used *models.RefreshToken, next *models.RefreshToken, at time.Time) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", used.ID).
+			Update("used_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		rotated = true
+		return tx.Create(next).Error
+	})
+	return rotated, err
+}
+
+// RevokeSession revoca todos los tokens de la sesión, incluidos los ya rotados, para
+// que sus access tokens también dejen de aceptarse
+func (r *refreshTokenRepository) RevokeSession(sessionID string, reason string, at time.Time) (int64, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": at, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeUserSessions revoca todas las sesiones del usuario salvo exceptSessionID (vacío = todas)
+func (r *refreshTokenRepository) RevokeUserSessions(userID uint, exceptSessionID string, reason string, at time.Time) (int64, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", userID, exceptSessionID).
+		Updates(map[string]interface{}{"revoked_at": at, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// IsAccessTokenRevoked indica si el access token pertenece a una sesión revocada. Un ID
+// que no se emitió junto a un refresh token también cuenta como revocado.
+func (r *refreshTokenRepository) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	var token models.RefreshToken
+	err := r.db.Select("revoked_at").Where("access_token_id = ?", tokenID).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return token.RevokedAt != nil, nil
+}
+
+// DeleteExpiredRefreshTokens borra los tokens vencidos antes de before
+func (r *refreshTokenRepository) DeleteExpiredRefreshTokens(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
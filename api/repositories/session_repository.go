@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+type SessionRepository interface {
+	GetActiveSession(ctx context.Context, clientID uint, botID uint) (*models.ConversationSession, error)
+	StartSession(ctx context.Context, clientID uint, botID uint, at time.Time) (*models.ConversationSession, error)
+	TouchSession(ctx context.Context, sessionID string, at time.Time) error
+	CloseSession(ctx context.Context, sessionID string, at time.Time, reason string) error
+	CloseInactiveSessions(ctx context.Context, idleSince time.Time, at time.Time) (int64, error)
+	ListSessions(ctx context.Context, clientID uint, botID uint, limit int) ([]models.ConversationSession, error)
+}
+
+// sessionRepository guarda las sesiones en la colección sessions. Un índice único
+// parcial garantiza una sola sesión activa por cliente y bot.
+type sessionRepository struct {
+	collection *mongo.Collection
+}
+
+// Constructor
+func NewSessionRepository(client *mongo.Client) SessionRepository {
+	return &sessionRepository{collection: sessionsCollection(client)}
+}
+
+func sessionsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database(os.Getenv("MONGO_DB")).Collection("sessions")
+}
+
+// GetActiveSession devuelve la sesión abierta del cliente con el bot, o nil si no hay
+func (r *sessionRepository) GetActiveSession(ctx context.Context, clientID uint, botID uint) (*models.ConversationSession, error) {
+	filter := bson.M{"client_id": clientID, "bot_id": botID, "active": true}
+
+	var session models.ConversationSession
+	err := r.collection.FindOne(ctx, filter).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// StartSession abre una sesión nueva. Si otra ya está activa el índice único
+// responde con un error de clave duplicada (mongo.IsDuplicateKeyError).
+func (r *sessionRepository) StartSession(ctx context.Context, clientID uint, botID uint, at time.Time) (*models.ConversationSession, error) {
+	session := &models.ConversationSession{
+		ID:             primitive.NewObjectID(),
+		ClientID:       clientID,
+		BotID:          botID,
+		Active:         true,
+		StartedAt:      at,
+		LastActivityAt: at,
+	}
+	if _, err := r.collection.InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// TouchSession registra un mensaje en la sesión y renueva su ventana de inactividad
+func (r *sessionRepository) TouchSession(ctx context.Context, sessionID string, at time.Time) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return fmt.Errorf("invalid session id %q: %w", sessionID, err)
+	}
+
+	// $max evita que un mensaje procesado tarde haga retroceder la actividad
+	update := bson.M{
+		"$max": bson.M{"last_activity_at": at},
+		"$inc": bson.M{"message_count": 1},
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// CloseSession cierra la sesión si sigue activa
+func (r *sessionRepository) CloseSession(ctx context.Context, sessionID string, at time.Time, reason string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return fmt.Errorf("invalid session id %q: %w", sessionID, err)
+	}
+
+	update := bson.M{"$set": bson.M{"active": false, "ended_at": at, "close_reason": reason}}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id, "active": true}, update)
+	return err
+}
+
+// CloseInactiveSessions cierra por inactividad las sesiones sin mensajes desde idleSince
+func (r *sessionRepository) CloseInactiveSessions(ctx context.Context, idleSince time.Time, at time.Time) (int64, error) {
+	filter := bson.M{"active": true, "last_activity_at": bson.M{"$lt": idleSince}}
+	update := bson.M{"$set": bson.M{
+		"active":       false,
+		"ended_at":     at,
+		"close_reason": models.SessionCloseInactivity,
+	}}
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ListSessions devuelve las sesiones del cliente con el bot, las más recientes primero
+func (r *sessionRepository) ListSessions(ctx context.Context, clientID uint, botID uint, limit int) ([]models.ConversationSession, error) {
+	filter := bson.M{"client_id": clientID, "bot_id": botID}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.ConversationSession{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+var (
+	// ErrSystemUserNotFound el usuario del sistema no existe
+	ErrSystemUserNotFound = errors.New("system user not found")
+	// ErrSystemUserTaken ya hay un usuario (incluso eliminado) con ese nombre o correo
+	ErrSystemUserTaken = errors.New("username or email already in use")
+)
+
+// SystemUserRepository usuarios del sistema. Cada cambio se guarda junto con sus
+// registros de auditoría en una sola transacción.
+type SystemUserRepository interface {
+	GetSystemUser(id uint, includeDeleted bool) (*models.SystemUser, error)
+	ListSystemUsers(filter models.SystemUserFilter) ([]models.SystemUser, int64, error)
+	CreateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error
+	UpdateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error
+	DeleteSystemUser(id uint, audit ...models.AuditLog) error
+	RestoreSystemUser(id uint, audit ...models.AuditLog) error
+	CountActiveAdmins(excludeID uint) (int64, error)
+}
+
+type systemUserRepository struct {
+	db *gorm.DB
+}
+
+func NewSystemUserRepository(db *gorm.DB) SystemUserRepository {
+	return &systemUserRepository{db}
+}
+
+func (r *systemUserRepository) GetSystemUser(id uint, includeDeleted bool) (*models.SystemUser, error) {
+	query := r.db
+	if includeDeleted {
+		query = query.Unscoped()
+	}
+	var user models.SystemUser
+	err := query.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSystemUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListSystemUsers devuelve los usuarios por nombre y el total del filtro
+func (r *systemUserRepository) ListSystemUsers(filter models.SystemUserFilter) ([]models.SystemUser, int64, error) {
+	query := r.db.Model(&models.SystemUser{})
+	if filter.Deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		query = query.Where("is_active = ?", *filter.Active)
+	}
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		query = query.Where("username ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.SystemUser{}
+	err := query.Order("username ASC").Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	return users, total, err
+}
+
+func (r *systemUserRepository) CreateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error {
+	if err := r.ensureAvailable(user.Username, user.Email, 0); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, user.ID, audit)
+	})
+}
+
+// UpdateSystemUser guarda todos los campos del usuario, incluidos los valores vacíos (IsActive = false)
+func (r *systemUserRepository) UpdateSystemUser(user *models.SystemUser, audit ...models.AuditLog) error {
+	if err := r.ensureAvailable(user.Username, user.Email, user.ID); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(user).Select("*").Omit("created_at", "deleted_at").Updates(user)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSystemUserNotFound
+		}
+		return recordAudit(tx, user.ID, audit)
+	})
+}
+
+// DeleteSystemUser elimina el usuario de forma lógica (DeletedAt); se puede restaurar
+func (r *systemUserRepository) DeleteSystemUser(id uint, audit ...models.AuditLog) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.SystemUser{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSystemUserNotFound
+		}
+		return recordAudit(tx, id, audit)
+	})
+}
+
+func (r *systemUserRepository) RestoreSystemUser(id uint, audit ...models.AuditLog) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.SystemUser{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSystemUserNotFound
+		}
+		return recordAudit(tx, id, audit)
+	})
+}
+
+// CountActiveAdmins cuenta los administradores activos sin contar a excludeID
+func (r *systemUserRepository) CountActiveAdmins(excludeID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.SystemUser{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, excludeID).
+		Count(&count).Error
+	return count, err
+}
+
+// ensureAvailable revisa también los usuarios eliminados, que conservan su nombre y correo
+func (r *systemUserRepository) ensureAvailable(username, email string, userID uint) error {
+	var count int64
+	err := r.db.Unscoped().Model(&models.SystemUser{}).
+		Where("(username = ? OR email = ?) AND id <> ?", username, email, userID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSystemUserTaken
+	}
+	return nil
+}
+
+// AssignLegacyRoles pasa a viewer (solo consulta) los usuarios con roles anteriores al
+// control de acceso por permisos (el antiguo "user" o cualquier texto libre), que de otro
+// modo quedarían sin permisos. Devuelve esos usuarios con el rol que tenían, para que un
+// admin les asigne a propósito el rol que corresponda.
+func AssignLegacyRoles(db *gorm.DB) ([]models.SystemUser, error) {
+	var users []models.SystemUser
+	err := db.Transaction(func(tx *gorm.DB) error {
+		legacy := tx.Unscoped().Model(&models.SystemUser{}).Where("role NOT IN ? OR role IS NULL", models.Roles())
+		if err := legacy.Session(&gorm.Session{}).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+		}
+		return tx.Unscoped().Model(&models.SystemUser{}).Where("id IN ?", ids).Update("role", models.RoleViewer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+var (
+	// ErrTemplateNotFound la plantilla no existe
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrTemplateNameTaken ya hay una plantilla con ese nombre
+	ErrTemplateNameTaken = errors.New("template name already in use")
+)
+
+type TemplateRepository interface {
+	GetTemplateByID(id uint) (*models.MessageTemplate, error)
+	GetTemplateByName(name string) (*models.MessageTemplate, error)
+	ListTemplates(filter models.TemplateFilter) ([]models.MessageTemplate, int64, error)
+	CreateTemplate(template *models.MessageTemplate) error
+	UpdateTemplate(template *models.MessageTemplate) error
+	DeleteTemplate(id uint) error
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db}
+}
+
+func (r *templateRepository) GetTemplateByID(id uint) (*models.MessageTemplate, error) {
+	var template models.MessageTemplate
+	return templateOrNotFound(&template, r.db.First(&template, id).Error)
+}
+
+func (r *templateRepository) GetTemplateByName(name string) (*models.MessageTemplate, error) {
+	var template models.MessageTemplate
+	return templateOrNotFound(&template, r.db.Where("name = ?", name).First(&template).Error)
+}
+
+// ListTemplates devuelve las plantillas por nombre y el total del filtro
+func (r *templateRepository) ListTemplates(filter models.TemplateFilter) ([]models.MessageTemplate, int64, error) {
+	query := r.db.Model(&models.MessageTemplate{})
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	templates := []models.MessageTemplate{}
+	err := query.Order("name ASC").Offset(filter.Offset).Limit(filter.Limit).Find(&templates).Error
+	return templates, total, err
+}
+
+func (r *templateRepository) CreateTemplate(template *models.MessageTemplate) error {
+	if err := r.ensureNameAvailable(template.Name, 0); err != nil {
+		return err
+	}
+	return r.db.Create(template).Error
+}
+
+func (r *templateRepository) UpdateTemplate(template *models.MessageTemplate) error {
+	if err := r.ensureNameAvailable(template.Name, template.ID); err != nil {
+		return err
+	}
+	result := r.db.Model(template).Select("*").Omit("created_at").Updates(template)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+func (r *templateRepository) DeleteTemplate(id uint) error {
+	result := r.db.Delete(&models.MessageTemplate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+func (r *templateRepository) ensureNameAvailable(name string, templateID uint) error {
+	var count int64
+	err := r.db.Model(&models.MessageTemplate{}).Where("name = ? AND id <> ?", name, templateID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTemplateNameTaken
+	}
+	return nil
+}
+
+func templateOrNotFound(template *models.MessageTemplate, err error) (*models.MessageTemplate, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
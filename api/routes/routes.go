@@ -10,11 +10,13 @@ import (
 	"github.com/brando1998/docubot-api/controllers"
 	_ "github.com/brando1998/docubot-api/docs"
 	"github.com/brando1998/docubot-api/middleware"
+	"github.com/brando1998/docubot-api/models"
 )
 
 type RouterConfig struct {
-	WSHub    *controllers.WebSocketHub
-	Upgrader *websocket.Upgrader
+	WSHub          *controllers.WebSocketHub
+	Upgrader       *websocket.Upgrader
+	AllowedOrigins []string // orígenes del dashboard para CORS y su WebSocket
 }
 
 func SetupRoutes(r *gin.Engine, config *RouterConfig) {
@@ -23,7 +25,7 @@ func SetupRoutes(r *gin.Engine, config *RouterConfig) {
 	// =============================================
 	r.Use(
 		middleware.LoggerMiddleware(),
-		middleware.CORSMiddleware(),
+		middleware.CORSMiddleware(config.AllowedOrigins),
 	)
 
 	// =============================================
@@ -39,6 +41,13 @@ func SetupRoutes(r *gin.Engine, config *RouterConfig) {
 			controllers.HandleWebSocket(c, config.WSHub, *config.Upgrader)
 		})
 
+		// Descarga con enlace firmado: Baileys adjunta el archivo en WhatsApp y no tiene
+		// sesión, así que estas rutas son públicas y el enlace es la credencial. La firma
+		// cubre la ruta, el vencimiento (2h para WhatsApp, 24h como máximo) y el checksum
+		// del archivo.
+		public.GET("/documents/:id/download", controllers.DownloadPublicDocument)
+		public.GET("/media/:id/download", controllers.DownloadSignedMedia)
+
 		// Debug: listar bots conectados
 		public.GET("/debug/bots", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -55,9 +64,20 @@ func SetupRoutes(r *gin.Engine, config *RouterConfig) {
 	{
 		authGroup.POST("/login", controllers.LoginWithPaseto)
 		authGroup.POST("/refresh", controllers.RefreshPasetoToken)
-		authGroup.GET("/me", middleware.PasetoAuthMiddleware(), controllers.GetCurrentSystemUser)
+		authGroup.GET("/keys", controllers.GetTokenKeys) // Claves públicas para verificar los tokens
+		authGroup.GET("/me", middleware.PasetoPasswordChangeMiddleware(), controllers.GetCurrentSystemUser)
+		authGroup.POST("/password", middleware.PasetoPasswordChangeMiddleware(), controllers.ChangePassword)
+		authGroup.POST("/logout", middleware.PasetoPasswordChangeMiddleware(), controllers.Logout)        // Cierra la sesión actual
+		authGroup.POST("/logout-all", middleware.PasetoPasswordChangeMiddleware(), controllers.LogoutAll) // Cierra todas las sesiones
 	}
 
+	// Permisos por ruta; las consultas sin permiso indicado están abiertas a todo
+	// usuario autenticado (ver models/role.go)
+	canSend := middleware.RequirePermission(models.PermissionWhatsAppSend)
+	canManageBots := middleware.RequirePermission(models.PermissionBotsManage)
+	canManageUsers := middleware.RequirePermission(models.PermissionUsersManage)
+	canReadDocuments := middleware.RequirePermission(models.PermissionDocumentsRead)
+
 	// =============================================
 	// Rutas Protegidas con PASETO
 	// =============================================
@@ -70,10 +90,23 @@ func SetupRoutes(r *gin.Engine, config *RouterConfig) {
 		userGroup := api.Group("/users")
 		{
 			userGroup.GET("/me", controllers.GetCurrentUser)
-			userGroup.POST("", controllers.CreateClient)
-			userGroup.GET("/id/:id", controllers.GetClientByID)
-			userGroup.GET("/phone/:phone", controllers.GetClientByPhone)
-			userGroup.POST("/get-or-create", controllers.GetOrCreateClient)
+			userGroup.POST("", canManageUsers, controllers.CreateClient)
+			userGroup.GET("/id/:id", canManageUsers, controllers.GetClientByID)
+			userGroup.GET("/phone/:phone", canManageUsers, controllers.GetClientByPhone)
+			userGroup.POST("/get-or-create", canManageUsers, controllers.GetOrCreateClient)
+		}
+
+		// --------------------------
+		// Bots (administración)
+		// --------------------------
+		botGroup := api.Group("/bots")
+		{
+			botGroup.GET("", controllers.ListBots)
+			botGroup.POST("", canManageBots, controllers.CreateBot)
+			botGroup.GET("/:id", controllers.GetBot)
+			botGroup.PUT("/:id", canManageBots, controllers.UpdateBot)
+			botGroup.GET("/:id/hours/status", controllers.GetBotHoursStatus)  // Abierto o cerrado según el horario
+			botGroup.DELETE("/:id", canManageBots, controllers.DeactivateBot) // Desactiva, no borra
 		}
 
 		// --------------------------
@@ -81,35 +114,143 @@ func SetupRoutes(r *gin.Engine, config *RouterConfig) {
 		// --------------------------
 		whatsappGroup := api.Group("/whatsapp")
 		{
-			// 🆕 Endpoints principales para el dashboard
-			whatsappGroup.GET("/qr", controllers.GetWhatsAppQR)               // Obtener QR o estado
-			whatsappGroup.POST("/disconnect", controllers.DisconnectWhatsApp) // Finalizar sesión
-			whatsappGroup.GET("/status", controllers.GetSessionStatus)        // Estado detallado
+			// 🆕 Endpoints principales para el dashboard (?bot=<número o ID>)
+			whatsappGroup.GET("/qr", canManageBots, func(c *gin.Context) {
+				controllers.GetWhatsAppQR(c, config.WSHub) // Obtener QR o estado
+			})
+			whatsappGroup.POST("/disconnect", canManageBots, func(c *gin.Context) {
+				controllers.DisconnectWhatsApp(c, config.WSHub) // Finalizar sesión
+			})
+			whatsappGroup.GET("/status", func(c *gin.Context) {
+				controllers.GetSessionStatus(c, config.WSHub) // Estado detallado
+			})
+			whatsappGroup.GET("/sessions", func(c *gin.Context) {
+				controllers.ListWhatsAppSessions(c, config.WSHub) // Sesiones de todos los bots
+			})
 
 			// Endpoints para manejo de mensajes y sesiones
-			whatsappGroup.POST("/send", controllers.SendWhatsAppMessage)              // Enviar mensaje
-			whatsappGroup.GET("/session/:session_id", controllers.GetWhatsAppSession) // Obtener sesión específica
-			whatsappGroup.POST("/session", controllers.CreateWhatsAppSession)         // Crear nueva sesión
+			whatsappGroup.POST("/send", canSend, func(c *gin.Context) {
+				controllers.SendWhatsAppMessage(c, config.WSHub) // Enviar mensaje
+			})
+			whatsappGroup.GET("/session/:session_id", func(c *gin.Context) {
+				controllers.GetWhatsAppSession(c, config.WSHub) // Sesión de un bot (número o ID)
+			})
+			whatsappGroup.POST("/session", canManageBots, func(c *gin.Context) {
+				controllers.CreateWhatsAppSession(c, config.WSHub) // Crear o reiniciar sesión
+			})
+
+			// Seguimiento de entrega
+			whatsappGroup.GET("/bots/:bot_id/undelivered", controllers.GetUndeliveredMessages) // Mensajes sin entregar
 		}
 
 		// --------------------------
 		// Conversaciones (ya implementado)
 		// --------------------------
-		// convGroup := api.Group("/conversations")
-		// {
-		// 	convGroup.GET("/history/:client_id", controllers.GetConversationHistory)
-		// 	convGroup.GET("/recent", controllers.GetRecentConversations)
-		// }
+		convGroup := api.Group("/conversations")
+		{
+			convGroup.GET("/history/:client_id", controllers.GetConversationHistory)
+			convGroup.GET("/recent", controllers.GetRecentConversations)
+			convGroup.GET("/search", controllers.SearchConversations)
+			convGroup.POST("/:client_id/bots/:bot_id/read", canSend, controllers.MarkConversationRead)
+			convGroup.GET("/:client_id/bots/:bot_id/sessions", controllers.GetConversationSessions)
+
+			// Atención humana
+			convGroup.PUT("/:client_id/bots/:bot_id/mode", canSend, func(c *gin.Context) {
+				controllers.UpdateConversationMode(c, config.WSHub)
+			})
+			convGroup.POST("/:client_id/bots/:bot_id/reply", canSend, func(c *gin.Context) {
+				controllers.ReplyToConversation(c, config.WSHub)
+			})
+		}
+
+		// --------------------------
+		// Documentos generados
+		// --------------------------
+		docGroup := api.Group("/documents", canReadDocuments)
+		{
+			docGroup.GET("/client/:client_id", controllers.ListClientDocuments)
+			docGroup.GET("/:id", controllers.GetDocument)
+			docGroup.GET("/:id/file", controllers.DownloadDocument)
+			docGroup.GET("/:id/url", controllers.GetDocumentURL)
+		}
+
+		// --------------------------
+		// Archivos enviados por los clientes
+		// --------------------------
+		mediaGroup := api.Group("/media")
+		{
+			mediaGroup.POST("", canSend, controllers.UploadMedia)
+			mediaGroup.GET("/:id", canReadDocuments, controllers.GetMedia)
+			mediaGroup.GET("/:id/file", canReadDocuments, controllers.DownloadMedia)
+		}
+
+		// --------------------------
+		// Campañas de difusión
+		// --------------------------
+		campaignGroup := api.Group("/campaigns")
+		{
+			campaignGroup.GET("", controllers.ListCampaigns)
+			campaignGroup.POST("", canSend, controllers.CreateCampaign)
+			campaignGroup.GET("/:id", controllers.GetCampaign)
+			campaignGroup.GET("/:id/recipients", controllers.ListCampaignRecipients) // Estado de entrega por cliente
+			campaignGroup.POST("/:id/cancel", canSend, controllers.CancelCampaign)
+		}
+
+		// --------------------------
+		// Plantillas de mensajes
+		// --------------------------
+		templateGroup := api.Group("/templates")
+		{
+			templateGroup.GET("", controllers.ListTemplates)
+			templateGroup.POST("", canManageBots, controllers.CreateTemplate)
+			templateGroup.GET("/:id", controllers.GetTemplate)
+			templateGroup.PUT("/:id", canManageBots, controllers.UpdateTemplate)
+			templateGroup.DELETE("/:id", canManageBots, controllers.DeleteTemplate)
+			templateGroup.POST("/:id/preview", canReadDocuments, controllers.PreviewTemplate) // Texto final con datos de ejemplo
+		}
+
+		// --------------------------
+		// Trabajos en segundo plano
+		// --------------------------
+		jobGroup := api.Group("/jobs")
+		{
+			jobGroup.GET("", controllers.ListJobs)
+			jobGroup.GET("/:id", controllers.GetJob)
+			jobGroup.POST("/:id/cancel", canManageBots, controllers.CancelJob)
+			jobGroup.POST("/:id/rerun", canManageBots, controllers.RerunJob)
+		}
+
+		// --------------------------
+		// Administración de usuarios del sistema
+		// --------------------------
+		adminGroup := api.Group("/admin", canManageUsers)
+		{
+			adminGroup.GET("/users", controllers.ListSystemUsers)
+			adminGroup.POST("/users", controllers.CreateSystemUser)
+			adminGroup.GET("/users/:id", controllers.GetSystemUser)
+			adminGroup.PUT("/users/:id", controllers.UpdateSystemUser)
+			adminGroup.DELETE("/users/:id", controllers.DeleteSystemUser) // Eliminación lógica
+			adminGroup.POST("/users/:id/restore", controllers.RestoreSystemUser)
+			adminGroup.POST("/users/:id/activate", controllers.ActivateSystemUser)
+			adminGroup.POST("/users/:id/deactivate", controllers.DeactivateSystemUser)
+			adminGroup.POST("/users/:id/password-reset", controllers.ResetSystemUserPassword) // Contraseña temporal
+			adminGroup.GET("/audit", controllers.ListAuditLogs)
+		}
 	}
 
 	// =============================================
-	// Rutas de Administración (futuro)
+	// Eventos en tiempo real del dashboard (token por cabecera o Sec-WebSocket-Protocol)
 	// =============================================
-	// admin := r.Group("/admin")
-	// admin.Use(middleware.PasetoAdminMiddleware())
-	// {
-	// 	admin.GET("/metrics", controllers.GetMetrics)
-	// 	admin.GET("/users", controllers.GetAllUsers)
-	// 	admin.DELETE("/users/:id", controllers.DeleteUser)
-	// }
+	dashboardUpgrader := *config.Upgrader
+	dashboardUpgrader.CheckOrigin = middleware.CheckOrigin(config.AllowedOrigins)
+	dashboard := r.Group("/api/v1/dashboard")
+	dashboard.Use(middleware.PasetoStreamAuthMiddleware())
+	{
+		dashboard.GET("/ws", func(c *gin.Context) {
+			controllers.HandleDashboardWebSocket(c, config.WSHub, dashboardUpgrader)
+		})
+		dashboard.GET("/events", func(c *gin.Context) {
+			controllers.StreamDashboardEvents(c, config.WSHub)
+		})
+	}
 }
@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/term"
+	"gorm.io/gorm"
 
 	"github.com/brando1998/docubot-api/config"
 	database "github.com/brando1998/docubot-api/databases"
@@ -33,7 +34,7 @@ func main() {
 
 	// Listar usuarios administradores existentes
 	var admins []models.SystemUser
-	if err := db.Where("role = ?", "admin").Find(&admins).Error; err != nil {
+	if err := db.Where("role = ?", models.RoleAdmin).Find(&admins).Error; err != nil {
 		log.Fatalf("❌ Error al buscar administradores: %v", err)
 	}
 
@@ -82,8 +83,8 @@ func main() {
 	password := string(passwordBytes)
 	fmt.Println() // Nueva línea
 
-	if len(password) < 6 {
-		log.Fatal("❌ La contraseña debe tener al menos 6 caracteres")
+	if err := models.ValidatePassword(password); err != nil {
+		log.Fatalf("❌ La contraseña debe tener entre %d y %d caracteres", models.MinPasswordLength, models.MaxPasswordLength)
 	}
 
 	// Confirmar contraseña
@@ -105,9 +106,20 @@ func main() {
 		log.Fatalf("❌ Error al hash la contraseña: %v", err)
 	}
 
-	// Actualizar en la base de datos
+	// Actualizar en la base de datos y dejarlo en la auditoría (actor 0 = el sistema)
 	selectedAdmin.PasswordHash = string(hashedPassword)
-	if err := db.Save(selectedAdmin).Error; err != nil {
+	selectedAdmin.PasswordResetRequired = false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(selectedAdmin).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{
+			Action:     models.AuditUserPasswordReset,
+			TargetType: models.AuditTargetSystemUser,
+			TargetID:   selectedAdmin.ID,
+		}).Error
+	})
+	if err != nil {
 		log.Fatalf("❌ Error al actualizar la contraseña: %v", err)
 	}
 
@@ -32,7 +32,7 @@ func EnsureDefaultAdminUser(db *gorm.DB) error {
 
 	// Verificar si ya existe un usuario con rol admin
 	var adminExists int64
-	err := db.Model(&models.SystemUser{}).Where("role = ?", "admin").Count(&adminExists).Error
+	err := db.Model(&models.SystemUser{}).Where("role = ?", models.RoleAdmin).Count(&adminExists).Error
 	if err != nil {
 		return err
 	}
@@ -66,12 +66,27 @@ func EnsureDefaultAdminUser(db *gorm.DB) error {
 		Username:     creds.Username,
 		Email:        creds.Email,
 		PasswordHash: string(hashedPassword),
-		Role:         "admin",
+		Role:         models.RoleAdmin,
 		IsActive:     true,
 	}
 
-	// Guardar en la base de datos
-	if err := db.Create(&adminUser).Error; err != nil {
+	// Guardar en la base de datos junto con su registro de auditoría (actor 0 = el sistema)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&adminUser).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{
+			Action:     models.AuditUserCreated,
+			TargetType: models.AuditTargetSystemUser,
+			TargetID:   adminUser.ID,
+			Changes: map[string]models.AuditChange{
+				"username": {To: adminUser.Username},
+				"email":    {To: adminUser.Email},
+				"role":     {To: adminUser.Role},
+			},
+		}).Error
+	})
+	if err != nil {
 		return err
 	}
 
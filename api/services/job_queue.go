@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+const (
+	DefaultJobWorkers     = 2
+	defaultJobMaxAttempts = 3
+	jobPollInterval       = 2 * time.Second
+	jobTimeout            = 5 * time.Minute
+	jobBaseBackoff        = 30 * time.Second
+	jobMaxBackoff         = 10 * time.Minute
+	jobStaleCheckInterval = time.Minute
+)
+
+var (
+	ErrUnknownJobType   = errors.New("unknown job type")
+	ErrJobNotCancelable = errors.New("job is not queued or running")
+	ErrJobNotRerunnable = errors.New("job has not finished")
+)
+
+// JobLogger escribe en el registro del trabajo que se está ejecutando
+type JobLogger interface {
+	Logf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// JobHandler ejecuta los trabajos de un tipo. El resultado se guarda como JSON en el trabajo.
+type JobHandler interface {
+	RunJob(ctx context.Context, job *models.Job, logger JobLogger) (interface{}, error)
+}
+
+// JobFailureHandler lo implementan los manejadores que deben reaccionar cuando un
+// trabajo falla definitivamente (ej: avisar al cliente)
+type JobFailureHandler interface {
+	JobFailed(job *models.Job, err error)
+}
+
+// JobOptions datos opcionales del trabajo a encolar
+type JobOptions struct {
+	ClientID    uint
+	BotID       uint
+	MaxAttempts int       // 0 = 3 intentos
+	RunAt       time.Time // primera ejecución; vacío = de inmediato
+}
+
+type permanentJobError struct {
+	err error
+}
+
+func (e permanentJobError) Error() string { return e.err.Error() }
+func (e permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError marca un error que no se resuelve reintentando (ej: datos inválidos)
+func PermanentJobError(err error) error {
+	return permanentJobError{err: err}
+}
+
+// JobQueue cola persistente de trabajos con un pool de workers. Los trabajos viven en
+// la base de datos, así que sobreviven a reinicios y varias instancias pueden compartirla.
+type JobQueue struct {
+	repo     repositories.JobRepository
+	workers  int
+	handlers map[string]JobHandler
+
+	mu      sync.Mutex
+	running map[uint]context.CancelFunc // trabajos en ejecución en esta instancia
+
+	wake chan struct{}
+}
+
+// NewJobQueue crea la cola con el número de workers indicado
+func NewJobQueue(repo repositories.JobRepository, workers int) *JobQueue {
+	if workers <= 0 {
+		workers = DefaultJobWorkers
+	}
+	return &JobQueue{
+		repo:     repo,
+		workers:  workers,
+		handlers: make(map[string]JobHandler),
+		running:  make(map[uint]context.CancelFunc),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register asocia un manejador a un tipo de trabajo; se llama antes de Run
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// Enqueue persiste un trabajo nuevo y despierta a un worker
+func (q *JobQueue) Enqueue(jobType string, payload interface{}, opts JobOptions) (*models.Job, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultJobMaxAttempts
+	}
+	job := &models.Job{
+		Type:        jobType,
+		Status:      models.JobStatusQueued,
+		Payload:     string(raw),
+		ClientID:    opts.ClientID,
+		BotID:       opts.BotID,
+		MaxAttempts: maxAttempts,
+		NextRunAt:   time.Now(),
+	}
+	if opts.RunAt.After(job.NextRunAt) {
+		job.NextRunAt = opts.RunAt
+	}
+	if err := q.repo.CreateJob(job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	q.logf(job.ID, 0, models.JobLogInfo, "Trabajo %s encolado", jobType)
+	q.notify()
+	return job, nil
+}
+
+// Cancel cancela un trabajo en cola o interrumpe su ejecución
+func (q *JobQueue) Cancel(id uint) error {
+	ok, err := q.repo.CancelJob(id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := q.repo.GetJob(id); err != nil {
+			return err
+		}
+		return ErrJobNotCancelable
+	}
+
+	q.mu.Lock()
+	if cancel, running := q.running[id]; running {
+		cancel()
+	}
+	q.mu.Unlock()
+
+	q.logf(id, 0, models.JobLogInfo, "Trabajo cancelado")
+	return nil
+}
+
+// Rerun vuelve a encolar un trabajo terminado (exitoso, fallido o cancelado)
+func (q *JobQueue) Rerun(id uint) (*models.Job, error) {
+	ok, err := q.repo.RequeueJob(id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if _, err := q.repo.GetJob(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobNotRerunnable
+	}
+
+	q.logf(id, 0, models.JobLogInfo, "Re-ejecución solicitada")
+	q.notify()
+	return q.repo.GetJob(id)
+}
+
+// Run arranca los workers y bloquea hasta que ctx termine
+func (q *JobQueue) Run(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	log.Printf("🛠️  Cola de trabajos con %d workers", q.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func(workerID string) {
+			defer wg.Done()
+			q.work(ctx, workerID)
+		}(fmt.Sprintf("%s-%d", hostname, i+1))
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.recoverStaleJobs(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (q *JobQueue) work(ctx context.Context, workerID string) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := q.repo.ClaimNextJob(workerID, time.Now())
+		if err != nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+		if job != nil {
+			q.execute(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// execute corre un intento del trabajo y registra su resultado
+func (q *JobQueue) execute(ctx context.Context, job *models.Job) {
+	logger := &jobLogger{queue: q, jobID: job.ID, attempt: job.Attempts}
+	logger.Logf("Intento %d de %d", job.Attempts, job.MaxAttempts)
+
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		q.finish(job, logger, nil, PermanentJobError(fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)))
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel()
+	}()
+
+	result, err := runJob(jobCtx, handler, job, logger)
+	q.finish(job, logger, result, err)
+}
+
+// finish guarda el resultado del intento: éxito, reintento con backoff o fallo definitivo.
+// Si el trabajo se canceló durante la ejecución, la transición no aplica y se respeta la cancelación.
+func (q *JobQueue) finish(job *models.Job, logger *jobLogger, result interface{}, runErr error) {
+	now := time.Now()
+
+	if runErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			runErr = PermanentJobError(fmt.Errorf("failed to marshal job result: %w", err))
+		} else {
+			if ok, err := q.repo.CompleteJob(job.ID, string(raw), now); err != nil {
+				log.Printf("Failed to complete job %d: %v", job.ID, err)
+			} else if ok {
+				logger.Logf("Trabajo completado")
+			}
+			return
+		}
+	}
+
+	var permanent permanentJobError
+	if errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts {
+		ok, err := q.repo.FailJob(job.ID, runErr.Error(), now)
+		if err != nil {
+			log.Printf("Failed to mark job %d as failed: %v", job.ID, err)
+			return
+		}
+		if !ok {
+			logger.Logf("Ejecución interrumpida: el trabajo fue cancelado")
+			return
+		}
+		logger.Errorf("Trabajo fallido: %v", runErr)
+		if failure, ok := q.handlers[job.Type].(JobFailureHandler); ok {
+			job.Status = models.JobStatusFailed
+			job.LastError = runErr.Error()
+			failure.JobFailed(job, runErr)
+		}
+		return
+	}
+
+	next := now.Add(jobBackoff(job.Attempts))
+	ok, err := q.repo.RetryJob(job.ID, next, runErr.Error())
+	if err != nil {
+		log.Printf("Failed to schedule retry for job %d: %v", job.ID, err)
+		return
+	}
+	if !ok {
+		logger.Logf("Ejecución interrumpida: el trabajo fue cancelado")
+		return
+	}
+	logger.Errorf("Intento fallido, se reintenta a las %s: %v", next.Format(time.RFC3339), runErr)
+}
+
+// recoverStaleJobs devuelve a la cola los trabajos que quedaron en running más del
+// tiempo máximo de ejecución (el worker que los tomó ya no existe), o los marca
+// fallidos si ya agotaron sus intentos
+func (q *JobQueue) recoverStaleJobs(ctx context.Context) {
+	ticker := time.NewTicker(jobStaleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			requeued, failed, err := q.repo.RequeueStaleJobs(now.Add(-2*jobTimeout), now)
+			if err != nil {
+				log.Printf("Failed to requeue stale jobs: %v", err)
+				continue
+			}
+			for i := range failed {
+				job := &failed[i]
+				q.logf(job.ID, job.Attempts, models.JobLogError, "Trabajo fallido: el worker se detuvo en el último intento")
+				if failure, ok := q.handlers[job.Type].(JobFailureHandler); ok {
+					failure.JobFailed(job, errors.New(job.LastError))
+				}
+			}
+			if len(failed) > 0 {
+				log.Printf("❌ %d trabajos abandonados marcados como fallidos: agotaron sus intentos", len(failed))
+			}
+			if requeued > 0 {
+				log.Printf("♻️  %d trabajos abandonados devueltos a la cola", requeued)
+				q.notify()
+			}
+		}
+	}
+}
+
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) logf(jobID uint, attempt int, level, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("🛠️  Job %d: %s", jobID, message)
+	entry := &models.JobLog{JobID: jobID, Attempt: attempt, Level: level, Message: message}
+	if err := q.repo.AddJobLog(entry); err != nil {
+		log.Printf("Failed to write log for job %d: %v", jobID, err)
+	}
+}
+
+// runJob ejecuta el manejador convirtiendo un panic en error del intento
+func runJob(ctx context.Context, handler JobHandler, job *models.Job, logger JobLogger) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler.RunJob(ctx, job, logger)
+}
+
+// jobBackoff espera antes del siguiente intento, exponencial desde 30s hasta 10m
+func jobBackoff(attempts int) time.Duration {
+	backoff := jobBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= jobMaxBackoff {
+			return jobMaxBackoff
+		}
+	}
+	return backoff
+}
+
+type jobLogger struct {
+	queue   *JobQueue
+	jobID   uint
+	attempt int
+}
+
+func (l *jobLogger) Logf(format string, args ...interface{}) {
+	l.queue.logf(l.jobID, l.attempt, models.JobLogInfo, format, args...)
+}
+
+func (l *jobLogger) Errorf(format string, args ...interface{}) {
+	l.queue.logf(l.jobID, l.attempt, models.JobLogError, format, args...)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/mocks"
+	"github.com/brando1998/docubot-api/models"
+	"github.com/brando1998/docubot-api/repositories"
+)
+
+// memoryJobRepo guarda los trabajos en memoria; ignora next_run_at para no esperar el backoff
+func memoryJobRepo() (*mocks.MockJobRepo, func(id uint) models.Job) {
+	var mu sync.Mutex
+	jobs := map[uint]*models.Job{}
+	var logs []models.JobLog
+
+	transition := func(id uint, from []string, apply func(job *models.Job)) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		job, ok := jobs[id]
+		if !ok {
+			return false, nil
+		}
+		for _, status := range from {
+			if job.Status == status {
+				apply(job)
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	repo := &mocks.MockJobRepo{
+		CreateJobFunc: func(job *models.Job) error {
+			mu.Lock()
+			defer mu.Unlock()
+			job.ID = uint(len(jobs) + 1)
+			clone := *job
+			jobs[job.ID] = &clone
+			return nil
+		},
+		GetJobFunc: func(id uint) (*models.Job, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			job, ok := jobs[id]
+			if !ok {
+				return nil, repositories.ErrJobNotFound
+			}
+			clone := *job
+			for _, entry := range logs {
+				if entry.JobID == id {
+					clone.Logs = append(clone.Logs, entry)
+				}
+			}
+			return &clone, nil
+		},
+		ClaimNextJobFunc: func(workerID string, now time.Time) (*models.Job, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, job := range jobs {
+				if job.Status == models.JobStatusQueued {
+					job.Status = models.JobStatusRunning
+					job.Attempts++
+					job.WorkerID = workerID
+					clone := *job
+					return &clone, nil
+				}
+			}
+			return nil, nil
+		},
+		CompleteJobFunc: func(id uint, result string, finishedAt time.Time) (bool, error) {
+			return transition(id, []string{models.JobStatusRunning}, func(job *models.Job) {
+				job.Status = models.JobStatusSucceeded
+				job.Result = result
+			})
+		},
+		RetryJobFunc: func(id uint, nextRunAt time.Time, lastError string) (bool, error) {
+			return transition(id, []string{models.JobStatusRunning}, func(job *models.Job) {
+				job.Status = models.JobStatusQueued
+				job.LastError = lastError
+			})
+		},
+		FailJobFunc: func(id uint, lastError string, finishedAt time.Time) (bool, error) {
+			return transition(id, []string{models.JobStatusRunning}, func(job *models.Job) {
+				job.Status = models.JobStatusFailed
+				job.LastError = lastError
+			})
+		},
+		CancelJobFunc: func(id uint, canceledAt time.Time) (bool, error) {
+			return transition(id, []string{models.JobStatusQueued, models.JobStatusRunning}, func(job *models.Job) {
+				job.Status = models.JobStatusCanceled
+			})
+		},
+		RequeueJobFunc: func(id uint, now time.Time) (bool, error) {
+			return transition(id, []string{models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusCanceled}, func(job *models.Job) {
+				job.Status = models.JobStatusQueued
+				job.Attempts = 0
+			})
+		},
+		RequeueStaleJobsFunc: func(startedBefore time.Time, now time.Time) (int64, []models.Job, error) {
+			return 0, nil, nil
+		},
+		AddJobLogFunc: func(entry *models.JobLog) error {
+			mu.Lock()
+			defer mu.Unlock()
+			logs = append(logs, *entry)
+			return nil
+		},
+	}
+
+	get := func(id uint) models.Job {
+		job, _ := repo.GetJob(id)
+		return *job
+	}
+	return repo, get
+}
+
+type scriptedJob struct {
+	mu     sync.Mutex
+	errs   []error
+	failed []error
+}
+
+func (s *scriptedJob) RunJob(ctx context.Context, job *models.Job, logger JobLogger) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	logger.Logf("listo")
+	return map[string]string{"document_id": "abc"}, nil
+}
+
+func (s *scriptedJob) JobFailed(job *models.Job, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = append(s.failed, err)
+}
+
+func (s *scriptedJob) failures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.failed)
+}
+
+func TestJobQueueRetriesUntilSuccess(t *testing.T) {
+	repo, get := memoryJobRepo()
+	handler := &scriptedJob{errs: []error{errors.New("playwright timeout")}}
+	queue := NewJobQueue(repo, 1)
+	queue.Register("test", handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	job, err := queue.Enqueue("test", map[string]string{"origen": "Bogotá"}, JobOptions{ClientID: 7})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return get(job.ID).Status == models.JobStatusSucceeded
+	}, 2*time.Second, 10*time.Millisecond)
+
+	finished := get(job.ID)
+	assert.Equal(t, 2, finished.Attempts)
+	assert.JSONEq(t, `{"document_id": "abc"}`, finished.Result)
+	assert.Zero(t, handler.failures())
+
+	var levels []string
+	for _, entry := range finished.Logs {
+		levels = append(levels, entry.Level)
+	}
+	assert.Contains(t, levels, models.JobLogError)
+}
+
+func TestJobQueuePermanentErrorFailsAndCanBeRerun(t *testing.T) {
+	repo, get := memoryJobRepo()
+	handler := &scriptedJob{errs: []error{PermanentJobError(errors.New("missing manifest fields: peso"))}}
+	queue := NewJobQueue(repo, 1)
+	queue.Register("test", handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	job, err := queue.Enqueue("test", nil, JobOptions{MaxAttempts: 5})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return get(job.ID).Status == models.JobStatusFailed
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, get(job.ID).Attempts)
+	assert.Equal(t, 1, handler.failures())
+	assert.ErrorIs(t, queue.Cancel(job.ID), ErrJobNotCancelable)
+
+	_, err = queue.Rerun(job.ID)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return get(job.ID).Status == models.JobStatusSucceeded
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = queue.Enqueue("desconocido", nil, JobOptions{})
+	assert.ErrorIs(t, err, ErrUnknownJobType)
+}
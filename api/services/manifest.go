@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Tiempo máximo de una generación; el navegador headless puede tardar
+const playwrightTimeout = 3 * time.Minute
+
+// Tamaño máximo del PDF aceptado desde Playwright
+const maxGeneratedFileSize = 20 << 20
+
+// ManifestData datos del manifiesto de carga recogidos por el formulario manifiesto_form de Rasa
+type ManifestData struct {
+	Flete          string `json:"flete"`
+	Descripcion    string `json:"descripcion,omitempty"`
+	Peso           string `json:"peso"`
+	FechaCargue    string `json:"fecha_cargue"`
+	FechaDescargue string `json:"fecha_descargue"`
+	Tarjeta        string `json:"tarjeta"`
+	Licencia       string `json:"licencia"`
+	Origen         string `json:"origen"`
+	Destino        string `json:"destino"`
+}
+
+// ManifestDataFromSlots arma los datos del manifiesto a partir de los slots del formulario
+func ManifestDataFromSlots(slots map[string]interface{}) ManifestData {
+	slot := func(name string) string {
+		if value, ok := slots[name]; ok && value != nil {
+			return strings.TrimSpace(fmt.Sprint(value))
+		}
+		return ""
+	}
+	return ManifestData{
+		Flete:          slot("flete"),
+		Descripcion:    slot("descripcion"),
+		Peso:           slot("peso"),
+		FechaCargue:    slot("fecha_cargue"),
+		FechaDescargue: slot("fecha_descargue"),
+		Tarjeta:        slot("tarjeta"),
+		Licencia:       slot("licencia"),
+		Origen:         slot("origen"),
+		Destino:        slot("destino"),
+	}
+}
+
+// Validate verifica que estén los datos obligatorios del manifiesto
+func (d ManifestData) Validate() error {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"flete", d.Flete},
+		{"peso", d.Peso},
+		{"fecha_cargue", d.FechaCargue},
+		{"fecha_descargue", d.FechaDescargue},
+		{"tarjeta", d.Tarjeta},
+		{"licencia", d.Licencia},
+		{"origen", d.Origen},
+		{"destino", d.Destino},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing manifest fields: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Map devuelve los datos como mapa para guardarlos con el documento
+func (d ManifestData) Map() map[string]string {
+	data := map[string]string{}
+	raw, _ := json.Marshal(d)
+	json.Unmarshal(raw, &data)
+	return data
+}
+
+// GeneratedFile archivo producido por el generador de documentos
+type GeneratedFile struct {
+	FileName string
+	MimeType string
+	Content  []byte
+}
+
+// ManifestGenerator genera el PDF de un manifiesto
+type ManifestGenerator interface {
+	GenerateManifest(ctx context.Context, data ManifestData) (*GeneratedFile, error)
+}
+
+// PlaywrightClient cliente del servicio playwright-bot, que llena el formulario con un
+// navegador headless y devuelve el PDF resultante
+type PlaywrightClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewPlaywrightClient crea un cliente apuntando a la URL base del servicio
+func NewPlaywrightClient(baseURL string) *PlaywrightClient {
+	return &PlaywrightClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: playwrightTimeout},
+	}
+}
+
+// GenerateManifest llama a POST /generate-manifest y devuelve el PDF generado
+func (p *PlaywrightClient) GenerateManifest(ctx context.Context, data ManifestData) (*GeneratedFile, error) {
+	body, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/generate-manifest", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/pdf")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+		return nil, fmt.Errorf("playwright returned status %d: %s", resp.StatusCode, failure.Error)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType != "application/pdf" {
+		return nil, fmt.Errorf("unexpected content type %q from playwright", mimeType)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxGeneratedFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated file: %w", err)
+	}
+	if len(content) > maxGeneratedFileSize {
+		return nil, fmt.Errorf("generated file exceeds %d bytes", maxGeneratedFileSize)
+	}
+
+	fileName := "manifiesto.pdf"
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		fileName = params["filename"]
+	}
+
+	return &GeneratedFile{FileName: fileName, MimeType: mimeType, Content: content}, nil
+}
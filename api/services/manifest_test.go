@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaywrightClientGenerateManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/generate-manifest", r.URL.Path)
+
+		var body struct {
+			Data ManifestData `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Bogotá", body.Data.Origen)
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="manifiesto-1.pdf"`)
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	data := ManifestDataFromSlots(map[string]interface{}{
+		"flete": "150000", "peso": 500, "fecha_cargue": "2024-05-01", "fecha_descargue": "2024-05-02",
+		"tarjeta": "ABC123", "licencia": "987", "origen": "Bogotá", "destino": "Cali",
+	})
+	assert.NoError(t, data.Validate())
+	assert.Equal(t, "500", data.Peso)
+
+	file, err := NewPlaywrightClient(server.URL+"/").GenerateManifest(context.Background(), data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "manifiesto-1.pdf", file.FileName)
+		assert.Equal(t, "application/pdf", file.MimeType)
+		assert.Equal(t, []byte("%PDF-1.4"), file.Content)
+	}
+}
+
+func TestPlaywrightClientReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"success": false, "error": "browser crashed"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewPlaywrightClient(server.URL).GenerateManifest(context.Background(), ManifestData{})
+	assert.ErrorContains(t, err, "browser crashed")
+}
+
+func TestManifestDataValidateListsMissingFields(t *testing.T) {
+	err := ManifestDataFromSlots(map[string]interface{}{"flete": "1", "origen": nil}).Validate()
+	assert.EqualError(t, err, "missing manifest fields: peso, fecha_cargue, fecha_descargue, tarjeta, licencia, origen, destino")
+}
+
+func TestNLUResponseDocumentRequest(t *testing.T) {
+	response := NLUResponse{
+		Text: "Procesando manifiesto...",
+		Custom: map[string]interface{}{
+			"document": map[string]interface{}{"type": "manifiesto", "data": map[string]interface{}{"origen": "Bogotá"}},
+		},
+	}
+
+	request, ok := response.DocumentRequest()
+	assert.True(t, ok)
+	assert.Equal(t, "manifiesto", request.Type)
+	assert.Equal(t, "Bogotá", request.Data["origen"])
+
+	stripped := response.WithoutDocumentRequest()
+	assert.Nil(t, stripped.Custom)
+	assert.Equal(t, "Procesando manifiesto...", stripped.Text)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// templatePlaceholderPattern variable de una plantilla: {{nombre}} o {{documento.origen}}
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// ClientTemplateVariables nombres de las variables que se llenan con los datos del cliente
+var ClientTemplateVariables = []string{"nombre", "telefono", "empresa", "correo"}
+
+// TemplatePlaceholders variables que usa el texto, sin repetir y en orden alfabético
+func TemplatePlaceholders(body string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderTemplate reemplaza las variables del texto. Las que no tienen valor se dejan
+// como están y se devuelven en missing.
+func RenderTemplate(body string, variables map[string]string) (string, []string) {
+	missingSet := map[string]bool{}
+	text := templatePlaceholderPattern.ReplaceAllStringFunc(body, func(placeholder string) string {
+		name := templatePlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		missingSet[name] = true
+		return placeholder
+	})
+
+	var missing []string
+	for name := range missingSet {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	return text, missing
+}
+
+// ClientVariables valores de las variables del cliente. Los datos vacíos no se incluyen,
+// así la plantilla los reporta como faltantes en vez de enviar "Hola ,"
+func ClientVariables(client *models.Client) map[string]string {
+	values := map[string]string{
+		"nombre":   strings.TrimSpace(client.Name),
+		"telefono": client.Phone,
+		"empresa":  strings.TrimSpace(client.Company),
+	}
+	if client.Email != nil {
+		values["correo"] = strings.TrimSpace(*client.Email)
+	}
+	variables := map[string]string{}
+	for name, value := range values {
+		if value != "" {
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// DocumentVariables valores de las variables del documento: documento.tipo,
+// documento.archivo, documento.fecha, documento.enlace (si se indica) y un
+// documento.<campo> por cada dato con que se generó (ej: documento.origen)
+func DocumentVariables(document *models.Document, url string) map[string]string {
+	variables := map[string]string{
+		"documento.tipo":    document.Type,
+		"documento.archivo": document.FileName,
+		"documento.fecha":   document.CreatedAt.Format("02/01/2006"),
+	}
+	if url != "" {
+		variables["documento.enlace"] = url
+	}
+	for key, value := range document.Data {
+		variables["documento."+key] = value
+	}
+	return variables
+}
+
+// MergeVariables combina los conjuntos de variables; los últimos tienen prioridad
+func MergeVariables(sets ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, set := range sets {
+		for name, value := range set {
+			merged[name] = value
+		}
+	}
+	return merged
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// Motores de NLU/diálogo soportados
+const (
+	NLUEngineRasa   = "rasa"
+	NLUEngineRules  = "rules"
+	NLUEngineMemory = "memory"
+)
+
+// NLUEngine abstrae el motor que interpreta los mensajes entrantes y decide la respuesta
+type NLUEngine interface {
+	// Name identifica el motor (rasa, rules, memory)
+	Name() string
+	// Send procesa el mensaje de un remitente y devuelve las respuestas a enviar
+	Send(ctx context.Context, sender, message string) ([]NLUResponse, error)
+}
+
+// NLUSessionResetter lo implementan los motores que guardan estado por remitente
+// (el tracker de Rasa). ResetSession descarta ese estado al abrir una sesión nueva.
+type NLUSessionResetter interface {
+	ResetSession(ctx context.Context, sender string) error
+}
+
+// NLURegistry resuelve qué motor debe atender a cada bot
+type NLURegistry struct {
+	mu            sync.RWMutex
+	engines       map[string]NLUEngine // key: nombre del motor
+	rasaByURL     map[string]NLUEngine // instancias de Rasa por URL configurada en el bot
+	defaultEngine string
+}
+
+// NewNLURegistry crea un registro vacío con el motor por defecto indicado
+func NewNLURegistry(defaultEngine string) *NLURegistry {
+	return &NLURegistry{
+		engines:       make(map[string]NLUEngine),
+		rasaByURL:     make(map[string]NLUEngine),
+		defaultEngine: defaultEngine,
+	}
+}
+
+// NewNLURegistryFromEnv construye el registro con los motores configurados por variables
+// de entorno. El motor en memoria es solo para pruebas y no se registra.
+func NewNLURegistryFromEnv() *NLURegistry {
+	defaultEngine := strings.ToLower(getEnvOrDefault("NLU_ENGINE", NLUEngineRasa))
+	if defaultEngine != NLUEngineRasa && defaultEngine != NLUEngineRules {
+		log.Printf("⚠️  NLU_ENGINE=%s no es un motor disponible, se usa %s", defaultEngine, NLUEngineRasa)
+		defaultEngine = NLUEngineRasa
+	}
+	registry := NewNLURegistry(defaultEngine)
+	registry.Register(NewRasaEngine(getEnvOrDefault("RASA_URL", "http://rasa:5005")))
+
+	rules := NewRuleEngine(DefaultKeywordRules(), defaultRuleFallback)
+	if path := os.Getenv("NLU_RULES_FILE"); path != "" {
+		loaded, err := LoadRuleEngine(path)
+		if err != nil {
+			log.Printf("⚠️  No se pudieron cargar las reglas NLU desde %s: %v", path, err)
+		} else {
+			rules = loaded
+		}
+	}
+	registry.Register(rules)
+
+	log.Printf("🧠 Motor NLU por defecto: %s", registry.defaultEngine)
+	return registry
+}
+
+// Register agrega (o reemplaza) un motor en el registro
+func (r *NLURegistry) Register(engine NLUEngine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.engines[engine.Name()] = engine
+}
+
+// Engine obtiene un motor registrado por nombre
+func (r *NLURegistry) Engine(name string) (NLUEngine, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if engine, ok := r.engines[name]; ok {
+		return engine, nil
+	}
+	return nil, fmt.Errorf("nlu engine %q not registered", name)
+}
+
+// EngineFor devuelve el motor configurado para el bot, o el motor por defecto
+func (r *NLURegistry) EngineFor(bot *models.Bot) (NLUEngine, error) {
+	name := r.defaultEngine
+	if bot != nil && bot.NLUEngine != "" {
+		name = strings.ToLower(bot.NLUEngine)
+	}
+
+	// Un bot puede apuntar a su propio servidor Rasa
+	if name == NLUEngineRasa && bot != nil && bot.NLUURL != "" {
+		return r.rasaEngineFor(bot.NLUURL), nil
+	}
+
+	return r.Engine(name)
+}
+
+func (r *NLURegistry) rasaEngineFor(url string) NLUEngine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if engine, ok := r.rasaByURL[url]; ok {
+		return engine
+	}
+	engine := NewRasaEngine(url)
+	r.rasaByURL[url] = engine
+	return engine
+}
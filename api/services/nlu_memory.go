@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryEngine es un motor en memoria para las pruebas: guarda todo lo que recibe, así
+// que el servidor no lo registra. Responde con las respuestas programadas para cada
+// mensaje o, si no hay, repite el mensaje.
+type MemoryEngine struct {
+	mu       sync.Mutex
+	scripted map[string][]NLUResponse
+	received []MemoryEngineCall
+	resets   []string
+}
+
+// MemoryEngineCall registra un mensaje recibido por el motor en memoria
+type MemoryEngineCall struct {
+	Sender  string
+	Message string
+}
+
+func NewMemoryEngine() *MemoryEngine {
+	return &MemoryEngine{scripted: make(map[string][]NLUResponse)}
+}
+
+func (e *MemoryEngine) Name() string {
+	return NLUEngineMemory
+}
+
+// Script programa las respuestas que se devolverán para un mensaje exacto
+func (e *MemoryEngine) Script(message string, responses ...NLUResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scripted[message] = responses
+}
+
+// Received devuelve una copia de los mensajes procesados
+func (e *MemoryEngine) Received() []MemoryEngineCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	calls := make([]MemoryEngineCall, len(e.received))
+	copy(calls, e.received)
+	return calls
+}
+
+// ResetSession registra el reinicio de sesión del remitente
+func (e *MemoryEngine) ResetSession(ctx context.Context, sender string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resets = append(e.resets, sender)
+	return nil
+}
+
+// Resets devuelve los remitentes cuya sesión se reinició, en orden
+func (e *MemoryEngine) Resets() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	resets := make([]string, len(e.resets))
+	copy(resets, e.resets)
+	return resets
+}
+
+func (e *MemoryEngine) Send(ctx context.Context, sender, message string) ([]NLUResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.received = append(e.received, MemoryEngineCall{Sender: sender, Message: message})
+
+	if responses, ok := e.scripted[message]; ok {
+		return responses, nil
+	}
+	return []NLUResponse{{Text: message}}, nil
+}
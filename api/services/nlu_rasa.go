@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// RasaEngine envía los mensajes al canal REST de un servidor Rasa
+type RasaEngine struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewRasaEngine crea un motor Rasa apuntando a la URL base (ej: http://rasa:5005)
+func NewRasaEngine(baseURL string) *RasaEngine {
+	return &RasaEngine{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *RasaEngine) Name() string {
+	return NLUEngineRasa
+}
+
+// Send envía el mensaje al webhook REST de Rasa
+func (e *RasaEngine) Send(ctx context.Context, sender, message string) ([]NLUResponse, error) {
+	payload := map[string]interface{}{
+		"sender":  sender,
+		"message": message,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	url := e.baseURL + "/webhooks/rest/webhook"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rasa returned status %d", resp.StatusCode)
+	}
+
+	var responses []NLUResponse
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return responses, nil
+}
+
+// ResetSession agrega un evento restart al tracker del remitente para que la
+// siguiente conversación empiece sin slots ni formularios pendientes
+func (e *RasaEngine) ResetSession(ctx context.Context, sender string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":     "restart",
+		"timestamp": time.Now().Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal restart event: %w", err)
+	}
+
+	url := e.baseURL + "/conversations/" + neturl.PathEscape(sender) + "/tracker/events"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rasa returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"path"
+	"strings"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+// NLUButton botón de respuesta rápida emitido por Rasa
+type NLUButton struct {
+	Title   string `json:"title"`
+	Payload string `json:"payload"`
+}
+
+// NLUResponse representa una respuesta devuelta por el motor de diálogo.
+// Sigue la forma del canal REST de Rasa: cada elemento puede traer texto,
+// botones, imagen, adjunto y/o un payload personalizado.
+type NLUResponse struct {
+	RecipientID string                 `json:"recipient_id,omitempty"`
+	Text        string                 `json:"text,omitempty"`
+	Buttons     []NLUButton            `json:"buttons,omitempty"`
+	Image       string                 `json:"image,omitempty"`
+	Attachment  interface{}            `json:"attachment,omitempty"` // URL o objeto, según la acción
+	Custom      map[string]interface{} `json:"custom,omitempty"`
+}
+
+// IsEmpty indica si la respuesta no trae nada que enviar
+func (r NLUResponse) IsEmpty() bool {
+	return r.Text == "" && len(r.Buttons) == 0 && r.Image == "" && r.Attachment == nil && len(r.Custom) == 0
+}
+
+// HandoffRequest indica si la respuesta pide pasar la conversación a un operador.
+// Rasa lo solicita con un payload personalizado {"handoff": true, "reason": "..."}
+// o {"handoff": {"reason": "..."}}.
+func (r NLUResponse) HandoffRequest() (string, bool) {
+	switch value := r.Custom["handoff"].(type) {
+	case bool:
+		return stringField(r.Custom, "reason"), value
+	case map[string]interface{}:
+		return stringField(value, "reason"), true
+	}
+	return "", false
+}
+
+// WithoutHandoff devuelve la respuesta sin la solicitud de traspaso, para enviar solo el resto al cliente
+func (r NLUResponse) WithoutHandoff() NLUResponse {
+	if _, ok := r.Custom["handoff"]; !ok {
+		return r
+	}
+	custom := make(map[string]interface{}, len(r.Custom))
+	for key, value := range r.Custom {
+		if key != "handoff" && key != "reason" {
+			custom[key] = value
+		}
+	}
+	if len(custom) == 0 {
+		custom = nil
+	}
+	r.Custom = custom
+	return r
+}
+
+// DocumentRequest solicitud de generación de un documento enviada por una acción de Rasa
+type DocumentRequest struct {
+	Type string                 // ej: "manifiesto"
+	Data map[string]interface{} // slots del formulario completado
+}
+
+// DocumentRequest indica si la respuesta pide generar un documento. Rasa lo solicita
+// al completar un formulario con {"document": {"type": "manifiesto", "data": {...}}}.
+func (r NLUResponse) DocumentRequest() (DocumentRequest, bool) {
+	value, ok := r.Custom["document"].(map[string]interface{})
+	if !ok {
+		return DocumentRequest{}, false
+	}
+	request := DocumentRequest{Type: stringField(value, "type")}
+	request.Data, _ = value["data"].(map[string]interface{})
+	return request, request.Type != ""
+}
+
+// WithoutDocumentRequest devuelve la respuesta sin la solicitud de documento
+func (r NLUResponse) WithoutDocumentRequest() NLUResponse {
+	if _, ok := r.Custom["document"]; !ok {
+		return r
+	}
+	custom := make(map[string]interface{}, len(r.Custom))
+	for key, value := range r.Custom {
+		if key != "document" {
+			custom[key] = value
+		}
+	}
+	if len(custom) == 0 {
+		custom = nil
+	}
+	r.Custom = custom
+	return r
+}
+
+// ToMessage traduce la respuesta a un mensaje de WhatsApp del bot
+func (r NLUResponse) ToMessage() models.Message {
+	msg := models.Message{
+		Type:   models.MessageTypeText,
+		Text:   r.Text,
+		Custom: r.Custom,
+	}
+
+	if r.Image != "" {
+		msg.Attachments = append(msg.Attachments, models.MessageAttachment{
+			Type: models.MessageTypeImage,
+			URL:  r.Image,
+		})
+	}
+	if attachment, ok := parseAttachment(r.Attachment); ok {
+		msg.Attachments = append(msg.Attachments, attachment)
+	}
+	if attachment, caption, ok := parseCustomMedia(r.Custom); ok {
+		msg.Attachments = append(msg.Attachments, attachment)
+		if msg.Text == "" {
+			msg.Text = caption
+		}
+	}
+
+	for _, button := range r.Buttons {
+		msg.Buttons = append(msg.Buttons, models.MessageButton{Title: button.Title, Payload: button.Payload})
+	}
+
+	switch {
+	case len(msg.Buttons) > models.MaxWhatsAppButtons:
+		msg.Type = models.MessageTypeList
+	case len(msg.Buttons) > 0:
+		msg.Type = models.MessageTypeButtons
+	case len(msg.Attachments) > 0:
+		msg.Type = msg.Attachments[0].Type
+	case len(msg.Custom) > 0 && msg.Text == "":
+		msg.Type = models.MessageTypeCustom
+	}
+
+	return msg
+}
+
+// parseAttachment interpreta el campo attachment de Rasa, que puede ser una URL
+// o un objeto ({"url": ...} o {"type": ..., "payload": {"src"|"url": ...}})
+func parseAttachment(raw interface{}) (models.MessageAttachment, bool) {
+	switch value := raw.(type) {
+	case string:
+		if value == "" {
+			return models.MessageAttachment{}, false
+		}
+		return newAttachment("", value, "", ""), true
+	case map[string]interface{}:
+		if payload, ok := value["payload"].(map[string]interface{}); ok {
+			url := stringField(payload, "src", "url")
+			if url == "" {
+				return models.MessageAttachment{}, false
+			}
+			return newAttachment(stringField(value, "type"), url, stringField(payload, "filename", "file_name"), ""), true
+		}
+		url := stringField(value, "url", "src")
+		if url == "" {
+			return models.MessageAttachment{}, false
+		}
+		return newAttachment(stringField(value, "type"), url, stringField(value, "filename", "file_name"), stringField(value, "mime_type", "mimetype")), true
+	}
+	return models.MessageAttachment{}, false
+}
+
+// parseCustomMedia reconoce payloads personalizados de la forma
+// {"type": "image|document|audio", "url": "...", "filename": "...", "caption": "..."}
+func parseCustomMedia(custom map[string]interface{}) (models.MessageAttachment, string, bool) {
+	switch stringField(custom, "type") {
+	case models.MessageTypeImage, models.MessageTypeDocument, models.MessageTypeAudio:
+	default:
+		return models.MessageAttachment{}, "", false
+	}
+
+	url := stringField(custom, "url")
+	if url == "" {
+		return models.MessageAttachment{}, "", false
+	}
+	attachment := newAttachment(stringField(custom, "type"), url, stringField(custom, "filename", "file_name"), stringField(custom, "mime_type", "mimetype"))
+	return attachment, stringField(custom, "caption"), true
+}
+
+func newAttachment(kind, url, fileName, mimeType string) models.MessageAttachment {
+	if fileName == "" {
+		fileName = path.Base(strings.SplitN(url, "?", 2)[0])
+	}
+	switch kind {
+	case models.MessageTypeImage, models.MessageTypeAudio, models.MessageTypeDocument:
+	case "file", "":
+		kind = models.MessageTypeDocument
+		if isImageFile(fileName) || strings.HasPrefix(mimeType, "image/") {
+			kind = models.MessageTypeImage
+		}
+	default:
+		kind = models.MessageTypeDocument
+	}
+	return models.MessageAttachment{Type: kind, URL: url, FileName: fileName, MimeType: mimeType}
+}
+
+func isImageFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+func stringField(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := m[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
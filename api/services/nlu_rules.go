@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const defaultRuleFallback = "🤔 No entendí tu mensaje. Escribe *manifiesto* para generar un manifiesto de carga."
+
+// KeywordRule asocia un conjunto de palabras clave con las respuestas a enviar
+type KeywordRule struct {
+	Name      string   `json:"name"`
+	Keywords  []string `json:"keywords"`
+	Responses []string `json:"responses"`
+}
+
+// RuleEngine es un motor determinista basado en palabras clave.
+// Las reglas se evalúan en orden y gana la primera que coincida.
+type RuleEngine struct {
+	rules    []KeywordRule
+	fallback string
+}
+
+// NewRuleEngine crea un motor de reglas con la respuesta por defecto indicada
+func NewRuleEngine(rules []KeywordRule, fallback string) *RuleEngine {
+	normalized := make([]KeywordRule, len(rules))
+	for i, rule := range rules {
+		normalized[i] = rule
+		normalized[i].Keywords = make([]string, len(rule.Keywords))
+		for j, keyword := range rule.Keywords {
+			normalized[i].Keywords[j] = normalizeText(keyword)
+		}
+	}
+	return &RuleEngine{rules: normalized, fallback: fallback}
+}
+
+// LoadRuleEngine carga las reglas desde un archivo JSON con la forma
+// {"fallback": "...", "rules": [{"name": "...", "keywords": [...], "responses": [...]}]}
+func LoadRuleEngine(path string) (*RuleEngine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var file struct {
+		Fallback string        `json:"fallback"`
+		Rules    []KeywordRule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode rules file: %w", err)
+	}
+
+	return NewRuleEngine(file.Rules, file.Fallback), nil
+}
+
+// DefaultKeywordRules reglas mínimas para operar sin Rasa
+func DefaultKeywordRules() []KeywordRule {
+	return []KeywordRule{
+		{
+			Name:      "greet",
+			Keywords:  []string{"hola", "buenos dias", "buenas tardes", "buenas noches"},
+			Responses: []string{"¡Hola! 👋 Soy tu asistente para generar manifiestos de carga. ¿En qué puedo ayudarte?"},
+		},
+		{
+			Name:      "solicitar_manifiesto",
+			Keywords:  []string{"manifiesto", "manifiestos"},
+			Responses: []string{"📄 En este momento la generación de manifiestos no está disponible por este canal. Un asesor te contactará pronto."},
+		},
+		{
+			Name:      "goodbye",
+			Keywords:  []string{"adios", "chao", "hasta luego", "gracias"},
+			Responses: []string{"¡Hasta luego! 👋 Que tengas un buen día."},
+		},
+	}
+}
+
+func (e *RuleEngine) Name() string {
+	return NLUEngineRules
+}
+
+// Send responde según la primera regla cuyas palabras clave aparezcan en el mensaje
+func (e *RuleEngine) Send(ctx context.Context, sender, message string) ([]NLUResponse, error) {
+	text := " " + normalizeText(message) + " "
+
+	for _, rule := range e.rules {
+		for _, keyword := range rule.Keywords {
+			if keyword == "" || !strings.Contains(text, " "+keyword+" ") {
+				continue
+			}
+			responses := make([]NLUResponse, 0, len(rule.Responses))
+			for _, response := range rule.Responses {
+				responses = append(responses, NLUResponse{Text: response})
+			}
+			return responses, nil
+		}
+	}
+
+	if e.fallback == "" {
+		return nil, nil
+	}
+	return []NLUResponse{{Text: e.fallback}}, nil
+}
+
+// normalizeText pasa a minúsculas, quita tildes y reemplaza la puntuación por espacios
+func normalizeText(s string) string {
+	replacer := strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+	s = replacer.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brando1998/docubot-api/models"
+)
+
+func TestRuleEngineMatchesFirstRule(t *testing.T) {
+	engine := NewRuleEngine(DefaultKeywordRules(), "fallback")
+
+	responses, err := engine.Send(context.Background(), "573001112233", "¡Buenos días! necesito un manifiesto")
+	assert.NoError(t, err)
+	assert.Len(t, responses, 1)
+	assert.Contains(t, responses[0].Text, "Hola")
+
+	responses, err = engine.Send(context.Background(), "573001112233", "qwerty")
+	assert.NoError(t, err)
+	assert.Equal(t, []NLUResponse{{Text: "fallback"}}, responses)
+}
+
+func TestRasaEngineDecodesResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/webhooks/rest/webhook", r.URL.Path)
+
+		var payload map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "573001112233", payload["sender"])
+
+		w.Write([]byte(`[{"recipient_id": "573001112233", "text": "hola"}]`))
+	}))
+	defer server.Close()
+
+	responses, err := NewRasaEngine(server.URL+"/").Send(context.Background(), "573001112233", "hola")
+	assert.NoError(t, err)
+	assert.Equal(t, []NLUResponse{{RecipientID: "573001112233", Text: "hola"}}, responses)
+}
+
+func TestRasaEngineResetSessionSendsRestartEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/conversations/573001112233/tracker/events", r.URL.Path)
+
+		var event map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		assert.Equal(t, "restart", event["event"])
+
+		w.Write([]byte(`{"sender_id": "573001112233", "events": []}`))
+	}))
+	defer server.Close()
+
+	var engine NLUEngine = NewRasaEngine(server.URL)
+	resetter, ok := engine.(NLUSessionResetter)
+	if assert.True(t, ok) {
+		assert.NoError(t, resetter.ResetSession(context.Background(), "573001112233"))
+	}
+}
+
+func TestNLURegistryEngineFor(t *testing.T) {
+	registry := NewNLURegistry(NLUEngineRules)
+	registry.Register(NewRuleEngine(nil, ""))
+	registry.Register(NewMemoryEngine())
+
+	engine, err := registry.EngineFor(&models.Bot{})
+	assert.NoError(t, err)
+	assert.Equal(t, NLUEngineRules, engine.Name())
+
+	engine, err = registry.EngineFor(&models.Bot{NLUEngine: "Memory"})
+	assert.NoError(t, err)
+	assert.Equal(t, NLUEngineMemory, engine.Name())
+
+	first, _ := registry.EngineFor(&models.Bot{NLUEngine: NLUEngineRasa, NLUURL: "http://rasa-salud:5005"})
+	second, _ := registry.EngineFor(&models.Bot{NLUEngine: NLUEngineRasa, NLUURL: "http://rasa-salud:5005"})
+	assert.Same(t, first, second)
+
+	_, err = registry.EngineFor(&models.Bot{NLUEngine: NLUEngineRasa})
+	assert.Error(t, err)
+}
+
+func TestNLURegistryFromEnvOmitsMemoryEngine(t *testing.T) {
+	t.Setenv("NLU_ENGINE", NLUEngineMemory)
+	registry := NewNLURegistryFromEnv()
+
+	_, err := registry.Engine(NLUEngineMemory)
+	assert.Error(t, err, "el motor de pruebas no se registra en el servidor")
+	engine, err := registry.EngineFor(&models.Bot{})
+	assert.NoError(t, err)
+	assert.Equal(t, NLUEngineRasa, engine.Name())
+}
+
+func TestNLUResponseToMessage(t *testing.T) {
+	var responses []NLUResponse
+	err := json.Unmarshal([]byte(`[
+		{"text": "¿Confirmas el origen?", "buttons": [{"title": "Sí", "payload": "/affirm"}, {"title": "No", "payload": "/deny"}]},
+		{"text": "Tu manifiesto", "attachment": "http://files/manifiesto-12.pdf"},
+		{"image": "http://files/ruta.png", "text": "Ruta sugerida"},
+		{"custom": {"type": "document", "url": "http://files/cert.pdf", "caption": "Certificado"}},
+		{"custom": {"handoff": true}}
+	]`), &responses)
+	assert.NoError(t, err)
+
+	buttons := responses[0].ToMessage()
+	assert.Equal(t, models.MessageTypeButtons, buttons.Type)
+	assert.Equal(t, []models.MessageButton{{Title: "Sí", Payload: "/affirm"}, {Title: "No", Payload: "/deny"}}, buttons.Buttons)
+
+	document := responses[1].ToMessage()
+	assert.Equal(t, models.MessageTypeDocument, document.Type)
+	assert.Equal(t, "manifiesto-12.pdf", document.Attachments[0].FileName)
+
+	image := responses[2].ToMessage()
+	assert.Equal(t, models.MessageTypeImage, image.Type)
+	assert.Equal(t, "Ruta sugerida", image.Text)
+
+	custom := responses[3].ToMessage()
+	assert.Equal(t, models.MessageTypeDocument, custom.Type)
+	assert.Equal(t, "Certificado", custom.Text)
+
+	raw := responses[4].ToMessage()
+	assert.Equal(t, models.MessageTypeCustom, raw.Type)
+	assert.Equal(t, true, raw.Custom["handoff"])
+
+	reason, ok := responses[4].HandoffRequest()
+	assert.True(t, ok)
+	assert.Empty(t, reason)
+	assert.True(t, responses[4].WithoutHandoff().IsEmpty())
+	_, ok = responses[3].HandoffRequest()
+	assert.False(t, ok)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SendRateLimiter reparte los envíos de cada bot a un ritmo fijo de mensajes por minuto.
+// Todas las campañas de un bot comparten el mismo ritmo dentro de esta instancia.
+type SendRateLimiter struct {
+	mu   sync.Mutex
+	next map[uint]time.Time // próximo turno libre de cada bot
+}
+
+func NewSendRateLimiter() *SendRateLimiter {
+	return &SendRateLimiter{next: make(map[uint]time.Time)}
+}
+
+// Wait reserva el próximo turno del bot y espera hasta que llegue o ctx termine
+func (l *SendRateLimiter) Wait(ctx context.Context, botID uint, perMinute int) error {
+	if perMinute <= 0 {
+		return nil
+	}
+	interval := time.Minute / time.Duration(perMinute)
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[botID]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[botID] = slot.Add(interval)
+	l.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
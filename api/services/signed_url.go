@@ -0,0 +1,80 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrURLExpired          = errors.New("signed url expired")
+	ErrURLInvalidSignature = errors.New("signed url signature is invalid")
+)
+
+// URLSigner firma rutas del API con HMAC-SHA256 y una fecha de expiración, para
+// entregar enlaces de descarga que funcionan sin token de sesión (ej: para Baileys)
+type URLSigner struct {
+	key []byte
+}
+
+// NewURLSigner crea el firmador con la clave secreta indicada
+func NewURLSigner(key []byte) *URLSigner {
+	return &URLSigner{key: key}
+}
+
+// Sign devuelve path con los parámetros expires y signature
+func (s *URLSigner) Sign(path string, expiresAt time.Time) string {
+	return s.SignFor(path, "", expiresAt)
+}
+
+// SignFor igual que Sign, pero la firma cubre además binding (ej: el checksum del
+// archivo), que no viaja en el enlace: si el archivo cambia, el enlace deja de valer
+func (s *URLSigner) SignFor(path, binding string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(path, binding, expires))
+	return path + "?" + query.Encode()
+}
+
+// Verify comprueba la firma y que el enlace no haya vencido en el instante now
+func (s *URLSigner) Verify(path, expires, signature string, now time.Time) error {
+	return s.VerifyFor(path, "", expires, signature, now)
+}
+
+// VerifyFor comprueba un enlace firmado con SignFor para el mismo binding
+func (s *URLSigner) VerifyFor(path, binding, expires, signature string, now time.Time) error {
+	expected, err := hex.DecodeString(s.signature(path, binding, expires))
+	if err != nil {
+		return ErrURLInvalidSignature
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, given) {
+		return ErrURLInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrURLInvalidSignature
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(path, binding, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	if binding != "" {
+		mac.Write([]byte(binding))
+		mac.Write([]byte{'\n'})
+	}
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
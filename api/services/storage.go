@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Backends de almacenamiento soportados (STORAGE_BACKEND)
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+)
+
+var (
+	ErrObjectNotFound   = errors.New("stored object not found")
+	ErrChecksumMismatch = errors.New("stored object checksum mismatch")
+)
+
+// FileStorage guarda archivos (documentos generados, adjuntos) bajo una clave.
+// Las claves usan "/" como separador, ej: manifiesto/7/<id>.pdf
+type FileStorage interface {
+	Name() string
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error) // ErrObjectNotFound si no existe
+	Delete(ctx context.Context, key string) error
+}
+
+// Checksum SHA-256 en hexadecimal del contenido
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadVerified lee el objeto completo y verifica su checksum antes de devolverlo, para no
+// servir un archivo alterado o truncado. Un checksum vacío omite la verificación.
+func ReadVerified(ctx context.Context, storage FileStorage, key, checksum string, maxSize int64) ([]byte, error) {
+	reader, err := storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(reader, maxSize+1)); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if int64(buf.Len()) > maxSize {
+		return nil, fmt.Errorf("stored object %s exceeds %d bytes", key, maxSize)
+	}
+
+	content := buf.Bytes()
+	if checksum != "" && subtle.ConstantTimeCompare([]byte(Checksum(content)), []byte(checksum)) != 1 {
+		return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, key)
+	}
+	return content, nil
+}
+
+// NewFileStorageFromEnv crea el almacenamiento configurado por variables de entorno:
+// STORAGE_BACKEND=local (DOCUMENTS_DIR) o s3 (S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY,
+// S3_SECRET_KEY, S3_REGION, S3_USE_SSL)
+func NewFileStorageFromEnv(ctx context.Context) (FileStorage, error) {
+	switch backend := getEnvOrDefault("STORAGE_BACKEND", StorageBackendLocal); backend {
+	case StorageBackendLocal:
+		dir := getEnvOrDefault("DOCUMENTS_DIR", "./storage/documents")
+		log.Printf("🗄️  Almacenamiento local en %s", dir)
+		return NewLocalStorage(dir)
+	case StorageBackendS3:
+		useSSL, _ := strconv.ParseBool(getEnvOrDefault("S3_USE_SSL", "true"))
+		config := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    useSSL,
+		}
+		log.Printf("🗄️  Almacenamiento S3 en %s/%s", config.Endpoint, config.Bucket)
+		return NewS3Storage(ctx, config)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 servidor mínimo compatible con S3 (estilo MinIO, con rutas /bucket/clave) para
+// probar S3Storage sin servicios externos
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	fake := &fakeS3{buckets: map[string]map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, exists := f.buckets[bucket]
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = map[string][]byte{}
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+	if !exists {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", r.Method)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := readS3Body(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		objects[key] = content
+		w.Header().Set("ETag", etag(content))
+	case http.MethodGet, http.MethodHead:
+		content, ok := objects[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey", r.Method)
+			return
+		}
+		w.Header().Set("ETag", etag(content))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// readS3Body devuelve el contenido subido, decodificando el formato aws-chunked que usa
+// el cliente sobre HTTP sin TLS
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var content bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return content.Bytes(), nil
+		}
+		if _, err := io.CopyN(&content, reader, size); err != nil {
+			return nil, err
+		}
+		reader.ReadString('\n')
+	}
+}
+
+func s3Error(w http.ResponseWriter, status int, code, method string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+}
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestStorageBackendsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+
+	server := newFakeS3(t)
+	s3, err := NewS3Storage(ctx, S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "documentos",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		Region:    "us-east-1",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, storage := range []FileStorage{local, s3} {
+		t.Run(storage.Name(), func(t *testing.T) {
+			content := []byte("%PDF-1.4 manifiesto")
+			key := "manifiesto/7/abc.pdf"
+			assert.NoError(t, storage.Put(ctx, key, content, "application/pdf"))
+
+			read, err := ReadVerified(ctx, storage, key, Checksum(content), 1<<20)
+			assert.NoError(t, err)
+			assert.Equal(t, content, read)
+
+			// Contenido distinto al registrado
+			_, err = ReadVerified(ctx, storage, key, Checksum([]byte("otro")), 1<<20)
+			assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+			assert.NoError(t, storage.Delete(ctx, key))
+			_, err = storage.Get(ctx, key)
+			assert.ErrorIs(t, err, ErrObjectNotFound)
+		})
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewLocalStorage(dir + "/docs")
+	assert.NoError(t, err)
+
+	// La clave se resuelve dentro del directorio aunque intente salir de él
+	assert.NoError(t, storage.Put(context.Background(), "../../fuera.pdf", []byte("x"), ""))
+	_, err = storage.Get(context.Background(), "fuera.pdf")
+	assert.NoError(t, err)
+}
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner([]byte("secreto"))
+	now := time.Now()
+	signed := signer.Sign("/documents/abc/download", now.Add(time.Hour))
+
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	query, err := url.ParseQuery(rawQuery)
+	assert.NoError(t, err)
+	expires, signature := query.Get("expires"), query.Get("signature")
+	assert.NoError(t, signer.Verify(path, expires, signature, now))
+
+	assert.ErrorIs(t, signer.Verify(path, expires, signature, now.Add(2*time.Hour)), ErrURLExpired)
+	assert.ErrorIs(t, signer.Verify("/documents/otro/download", expires, signature, now), ErrURLInvalidSignature)
+	assert.ErrorIs(t, signer.Verify(path, strconv.FormatInt(now.Add(48*time.Hour).Unix(), 10), signature, now), ErrURLInvalidSignature)
+	assert.ErrorIs(t, NewURLSigner([]byte("otra")).Verify(path, expires, signature, now), ErrURLInvalidSignature)
+
+	// Un enlace atado a un checksum solo vale para ese checksum
+	bound, _ := url.Parse(signer.SignFor(path, "abc123", now.Add(time.Hour)))
+	expires, signature = bound.Query().Get("expires"), bound.Query().Get("signature")
+	assert.NoError(t, signer.VerifyFor(path, "abc123", expires, signature, now))
+	assert.ErrorIs(t, signer.VerifyFor(path, "def456", expires, signature, now), ErrURLInvalidSignature)
+	assert.ErrorIs(t, signer.Verify(path, expires, signature, now), ErrURLInvalidSignature)
+}
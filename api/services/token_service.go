@@ -0,0 +1,341 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	pasetoV4PublicHeader = "v4.public."
+	paserkPublicPrefix   = "k4.public."
+	paserkSecretPrefix   = "k4.secret."
+	paserkPIDPrefix      = "k4.pid."
+)
+
+var (
+	ErrTokenInvalid    = errors.New("token is invalid")
+	ErrTokenExpired    = errors.New("token expired")
+	ErrTokenUnknownKey = errors.New("token signed with an unknown key")
+	ErrTokenKeyMissing = errors.New("PASETO_SIGNING_KEY or PASETO_SIGNING_KEY_FILE is required in production")
+)
+
+// TokenService firma y verifica tokens PASETO v4.public (Ed25519). El footer de cada
+// token lleva el identificador (kid) de la clave que lo firmó, así que durante una
+// rotación se aceptan a la vez los tokens de la clave nueva y de las anteriores. Con
+// solo las claves públicas otros servicios pueden verificar los tokens.
+type TokenService struct {
+	signingKey   ed25519.PrivateKey
+	signingKeyID string
+	publicKeys   map[string]ed25519.PublicKey
+}
+
+// TokenPublicKey clave pública en formato PASERK (k4.public) con su identificador
+type TokenPublicKey struct {
+	KeyID string `json:"kid"`
+	Key   string `json:"key"`
+}
+
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// tokenTimes claims de vigencia que se validan en todos los tokens
+type tokenTimes struct {
+	ExpiresAt time.Time `json:"exp"`
+	NotBefore time.Time `json:"nbf"`
+}
+
+// NewTokenService firma con signingKey y acepta además los tokens de las claves públicas
+// indicadas (claves anteriores o de la rotación en curso)
+func NewTokenService(signingKey ed25519.PrivateKey, verificationKeys ...ed25519.PublicKey) *TokenService {
+	public := signingKey.Public().(ed25519.PublicKey)
+	s := &TokenService{
+		signingKey:   signingKey,
+		signingKeyID: TokenKeyID(public),
+		publicKeys:   map[string]ed25519.PublicKey{},
+	}
+	s.publicKeys[s.signingKeyID] = public
+	for _, key := range verificationKeys {
+		s.publicKeys[TokenKeyID(key)] = key
+	}
+	return s
+}
+
+// NewTokenServiceFromEnv carga las claves desde variables de entorno o archivos:
+// PASETO_SIGNING_KEY o PASETO_SIGNING_KEY_FILE (clave privada Ed25519 en PEM PKCS #8 o
+// PASERK k4.secret) y PASETO_PUBLIC_KEYS o PASETO_PUBLIC_KEYS_FILE (claves públicas
+// adicionales en PEM o PASERK k4.public, separadas por comas). Sin clave de firma falla
+// en producción; en desarrollo usa una clave temporal.
+func NewTokenServiceFromEnv(production bool) (*TokenService, error) {
+	rawSigningKey, err := envOrFile("PASETO_SIGNING_KEY")
+	if err != nil {
+		return nil, err
+	}
+	rawPublicKeys, err := envOrFile("PASETO_PUBLIC_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := ParseTokenPublicKeys(rawPublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASETO_PUBLIC_KEYS: %w", err)
+	}
+
+	if strings.TrimSpace(rawSigningKey) == "" {
+		if production {
+			return nil, ErrTokenKeyMissing
+		}
+		_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("⚠️  PASETO_SIGNING_KEY no definida: se usa una clave temporal y los tokens dejarán de valer al reiniciar")
+		return NewTokenService(signingKey, publicKeys...), nil
+	}
+
+	signingKey, err := ParseTokenSigningKey(rawSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASETO_SIGNING_KEY: %w", err)
+	}
+	s := NewTokenService(signingKey, publicKeys...)
+	log.Printf("🔑 Tokens firmados con la clave %s (%d claves aceptadas)", s.signingKeyID, len(s.publicKeys))
+	return s, nil
+}
+
+// KeyID identificador de la clave con que se firman los tokens nuevos
+func (s *TokenService) KeyID() string {
+	return s.signingKeyID
+}
+
+// PublicKeys claves con que se verifican los tokens; primero la de firma
+func (s *TokenService) PublicKeys() []TokenPublicKey {
+	keys := make([]TokenPublicKey, 0, len(s.publicKeys))
+	for id, key := range s.publicKeys {
+		keys = append(keys, TokenPublicKey{KeyID: id, Key: paserkPublicPrefix + base64.RawURLEncoding.EncodeToString(key)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i].KeyID == s.signingKeyID) != (keys[j].KeyID == s.signingKeyID) {
+			return keys[i].KeyID == s.signingKeyID
+		}
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	return keys
+}
+
+// Sign firma los claims (serializados a JSON). Deben incluir "exp".
+func (s *TokenService) Sign(claims any) (string, error) {
+	message, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	footer, err := json.Marshal(tokenFooter{KeyID: s.signingKeyID})
+	if err != nil {
+		return "", err
+	}
+	return signV4Public(s.signingKey, message, footer, nil), nil
+}
+
+// Verify comprueba la firma y la vigencia del token en el instante now y carga sus
+// claims en claims
+func (s *TokenService) Verify(token string, claims any, now time.Time) error {
+	message, signature, footer, err := decodeV4Public(token)
+	if err != nil {
+		return err
+	}
+	if footer == nil {
+		return ErrTokenInvalid // sin footer no se sabe con qué clave verificar
+	}
+
+	var keyFooter tokenFooter
+	if err := json.Unmarshal(footer, &keyFooter); err != nil {
+		return ErrTokenInvalid
+	}
+	key, ok := s.publicKeys[keyFooter.KeyID]
+	if !ok {
+		return ErrTokenUnknownKey
+	}
+	if !verifyV4Public(key, message, signature, footer, nil) {
+		return ErrTokenInvalid
+	}
+
+	var times tokenTimes
+	if err := json.Unmarshal(message, &times); err != nil || times.ExpiresAt.IsZero() {
+		return ErrTokenInvalid
+	}
+	if now.After(times.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	if !times.NotBefore.IsZero() && now.Before(times.NotBefore) {
+		return ErrTokenInvalid
+	}
+
+	if err := json.Unmarshal(message, claims); err != nil {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+// TokenKeyID identificador PASERK (k4.pid) de una clave pública
+func TokenKeyID(key ed25519.PublicKey) string {
+	paserk := paserkPublicPrefix + base64.RawURLEncoding.EncodeToString(key)
+	hash, _ := blake2b.New(33, nil) // 264 bits; solo falla con tamaños fuera de rango
+	hash.Write([]byte(paserkPIDPrefix))
+	hash.Write([]byte(paserk))
+	return paserkPIDPrefix + base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// ParseTokenSigningKey lee una clave privada Ed25519 en PEM (PKCS #8, la que genera
+// `openssl genpkey -algorithm ed25519`) o en PASERK k4.secret
+func ParseTokenSigningKey(raw string) (ed25519.PrivateKey, error) {
+	raw = strings.TrimSpace(raw)
+	if encoded, ok := strings.CutPrefix(raw, paserkSecretPrefix); ok {
+		key, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("malformed k4.secret key")
+		}
+		private := ed25519.PrivateKey(key)
+		if !bytes.Equal(ed25519.NewKeyFromSeed(private.Seed()), private) {
+			return nil, errors.New("k4.secret public half does not match its seed")
+		}
+		return private, nil
+	}
+
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("expected a PEM private key or a k4.secret key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an Ed25519 key, got %T", parsed)
+	}
+	return private, nil
+}
+
+// ParseTokenPublicKeys lee claves públicas Ed25519: bloques PEM seguidos o claves
+// PASERK k4.public separadas por comas o espacios
+func ParseTokenPublicKeys(raw string) ([]ed25519.PublicKey, error) {
+	raw = strings.TrimSpace(raw)
+	var keys []ed25519.PublicKey
+
+	if strings.HasPrefix(raw, "-----BEGIN") {
+		rest := []byte(raw)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("expected an Ed25519 key, got %T", parsed)
+			}
+			keys = append(keys, key)
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			return nil, errors.New("unexpected data after the PEM keys")
+		}
+		return keys, nil
+	}
+
+	for _, field := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' || r == '\r' }) {
+		encoded, ok := strings.CutPrefix(field, paserkPublicPrefix)
+		if !ok {
+			return nil, fmt.Errorf("expected a PEM or k4.public key, got %q", field)
+		}
+		key, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("malformed k4.public key %q", field)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, nil
+}
+
+// signV4Public arma un token v4.public: el mensaje seguido de su firma y, si lo hay, el
+// footer, ambos en base64url. implicit es la afirmación implícita, que se firma pero no
+// viaja en el token.
+func signV4Public(key ed25519.PrivateKey, message, footer, implicit []byte) string {
+	signature := ed25519.Sign(key, pae([]byte(pasetoV4PublicHeader), message, footer, implicit))
+	token := pasetoV4PublicHeader + base64.RawURLEncoding.EncodeToString(append(append([]byte(nil), message...), signature...))
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token
+}
+
+// decodeV4Public separa el mensaje, la firma y el footer (nil si no tiene) de un token
+// v4.public sin verificarlo
+func decodeV4Public(token string) (message, signature, footer []byte, err error) {
+	body, ok := strings.CutPrefix(token, pasetoV4PublicHeader)
+	if !ok {
+		return nil, nil, nil, ErrTokenInvalid
+	}
+	encodedPayload, encodedFooter, hasFooter := strings.Cut(body, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) < ed25519.SignatureSize {
+		return nil, nil, nil, ErrTokenInvalid
+	}
+	if hasFooter {
+		if footer, err = base64.RawURLEncoding.DecodeString(encodedFooter); err != nil || len(footer) == 0 {
+			return nil, nil, nil, ErrTokenInvalid
+		}
+	}
+	split := len(payload) - ed25519.SignatureSize
+	return payload[:split], payload[split:], footer, nil
+}
+
+// verifyV4Public comprueba la firma del mensaje, el footer y la afirmación implícita
+func verifyV4Public(key ed25519.PublicKey, message, signature, footer, implicit []byte) bool {
+	return ed25519.Verify(key, pae([]byte(pasetoV4PublicHeader), message, footer, implicit), signature)
+}
+
+// pae codificación previa a la autenticación de PASETO: cantidad de piezas y cada una
+// precedida de su longitud, en enteros de 64 bits little-endian
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(pieces)))
+	for _, piece := range pieces {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(piece)))
+		buf.Write(piece)
+	}
+	return buf.Bytes()
+}
+
+// envOrFile valor de la variable name o, si no está definida, contenido del archivo
+// indicado en name_FILE
+func envOrFile(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClaims struct {
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestTokenServiceKeyRotation(t *testing.T) {
+	now := time.Now()
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	before := NewTokenService(oldKey)
+	during := NewTokenService(newKey, oldKey.Public().(ed25519.PublicKey))
+	after := NewTokenService(newKey)
+
+	oldToken, err := before.Sign(testClaims{UserID: 7, ExpiresAt: now.Add(time.Minute)})
+	assert.NoError(t, err)
+	newToken, err := during.Sign(testClaims{UserID: 8, ExpiresAt: now.Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(newToken, "v4.public."))
+
+	// El footer indica con qué clave se firmó
+	footer, err := base64.RawURLEncoding.DecodeString(newToken[strings.LastIndex(newToken, ".")+1:])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kid": "`+during.KeyID()+`"}`, string(footer))
+	assert.True(t, strings.HasPrefix(during.KeyID(), "k4.pid."))
+
+	// Durante la rotación se aceptan los tokens de ambas claves
+	var claims testClaims
+	assert.NoError(t, during.Verify(oldToken, &claims, now))
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.NoError(t, during.Verify(newToken, &claims, now))
+	assert.Equal(t, uint(8), claims.UserID)
+	assert.Len(t, during.PublicKeys(), 2)
+	assert.Equal(t, during.KeyID(), during.PublicKeys()[0].KeyID)
+
+	// Al retirar la clave anterior sus tokens dejan de valer
+	assert.ErrorIs(t, after.Verify(oldToken, &claims, now), ErrTokenUnknownKey)
+	assert.ErrorIs(t, after.Verify(newToken, &claims, now.Add(2*time.Minute)), ErrTokenExpired)
+
+	tampered := strings.Replace(newToken, "v4.public.e", "v4.public.f", 1)
+	assert.ErrorIs(t, after.Verify(tampered, &claims, now), ErrTokenInvalid)
+	withoutExpiry, _ := after.Sign(map[string]any{"user_id": 8})
+	assert.ErrorIs(t, after.Verify(withoutExpiry, &claims, now), ErrTokenInvalid)
+}
+
+func TestNewTokenServiceFromEnv(t *testing.T) {
+	signingKey, previousKey := newTestKey(t), newTestKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(signingKey)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(previousKey.Public())
+	assert.NoError(t, err)
+
+	t.Setenv("PASETO_SIGNING_KEY", "")
+	t.Setenv("PASETO_PUBLIC_KEYS", "")
+	_, err = NewTokenServiceFromEnv(true)
+	assert.ErrorIs(t, err, ErrTokenKeyMissing)
+	_, err = NewTokenServiceFromEnv(false)
+	assert.NoError(t, err, "en desarrollo se usa una clave temporal")
+
+	t.Setenv("PASETO_SIGNING_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	t.Setenv("PASETO_PUBLIC_KEYS", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})))
+	tokens, err := NewTokenServiceFromEnv(true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, TokenKeyID(signingKey.Public().(ed25519.PublicKey)), tokens.KeyID())
+
+	// Las claves publicadas en PASERK se pueden volver a cargar
+	var paserk []string
+	for _, key := range tokens.PublicKeys() {
+		paserk = append(paserk, key.Key)
+	}
+	keys, err := ParseTokenPublicKeys(strings.Join(paserk, ","))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	t.Setenv("PASETO_SIGNING_KEY", "no-es-una-clave")
+	_, err = NewTokenServiceFromEnv(true)
+	assert.Error(t, err)
+}
+
+// Vectores oficiales de PASETO v4.public (4-S-1 a 4-S-3): sin footer, con footer y con
+// footer y afirmación implícita
+func TestV4PublicTestVectors(t *testing.T) {
+	secret, _ := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	public, _ := hex.DecodeString("1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	key := ed25519.PrivateKey(secret)
+	assert.Equal(t, ed25519.PublicKey(public), key.Public())
+
+	message := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	footer := []byte(`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`)
+	vectors := []struct {
+		name     string
+		footer   []byte
+		implicit []byte
+		token    string
+	}{
+		{
+			name:  "4-S-1",
+			token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+		},
+		{
+			name:   "4-S-2",
+			footer: footer,
+			token:  "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		},
+		{
+			name:     "4-S-3",
+			footer:   footer,
+			implicit: []byte(`{"test-vector":"4-S-3"}`),
+			token:    "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9NPWciuD3d0o5eXJXG5pJy-DiVEoyPYWs1YSTwWHNJq6DZD3je5gf-0M4JR9ipdUSJbIovzmBECeaWmaqcaP0DQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		},
+	}
+
+	for _, vector := range vectors {
+		t.Run(vector.name, func(t *testing.T) {
+			assert.Equal(t, vector.token, signV4Public(key, message, vector.footer, vector.implicit))
+
+			decoded, signature, decodedFooter, err := decodeV4Public(vector.token)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, message, decoded)
+			assert.Equal(t, vector.footer, decodedFooter)
+			assert.True(t, verifyV4Public(public, decoded, signature, decodedFooter, vector.implicit))
+			// Otra afirmación implícita invalida la firma
+			assert.False(t, verifyV4Public(public, decoded, signature, decodedFooter, []byte(`{"test-vector":"otro"}`)))
+		})
+	}
+}
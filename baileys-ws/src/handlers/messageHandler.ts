@@ -1,17 +1,72 @@
 import { WebSocket } from 'ws';
+import { randomUUID } from 'crypto';
+
+// Archivo adjunto de un mensaje entrante, en el formato que espera el API
+export interface InboundMedia {
+    type: 'image' | 'document' | 'audio';
+    mime_type?: string;
+    file_name?: string;
+    size: number;
+    data?: string; // base64, para archivos pequeños
+    url?: string;  // descarga desde este servicio, para archivos grandes
+}
+
+// Los archivos mayores a este tamaño no viajan por el WebSocket (límite de 1MB por frame)
+const INLINE_MEDIA_LIMIT = 512 * 1024;
+// Tiempo que un archivo grande queda disponible para que el API lo descargue
+const MEDIA_TTL_MS = 10 * 60 * 1000;
+
+const mediaCache = new Map<string, { buffer: Buffer; mimeType: string; expiresAt: number }>();
+
+// Devuelve un archivo pendiente de descarga por el API (GET /media/:id)
+export const takeCachedMedia = (id: string) => {
+    const entry = mediaCache.get(id);
+    if (!entry) return undefined;
+    mediaCache.delete(id);
+    return entry.expiresAt > Date.now() ? entry : undefined;
+};
+
+setInterval(() => {
+    const now = Date.now();
+    for (const [id, entry] of mediaCache) {
+        if (entry.expiresAt <= now) mediaCache.delete(id);
+    }
+}, 60 * 1000).unref();
+
+// Prepara el adjunto: en línea si es pequeño o como URL de descarga si es grande
+export const buildInboundMedia = (
+    type: InboundMedia['type'],
+    buffer: Buffer,
+    mimeType?: string,
+    fileName?: string
+): InboundMedia => {
+    const media: InboundMedia = { type, mime_type: mimeType, file_name: fileName, size: buffer.length };
+    if (buffer.length <= INLINE_MEDIA_LIMIT) {
+        media.data = buffer.toString('base64');
+        return media;
+    }
+
+    const id = randomUUID();
+    mediaCache.set(id, { buffer, mimeType: mimeType || 'application/octet-stream', expiresAt: Date.now() + MEDIA_TTL_MS });
+    const baseUrl = process.env.BAILEYS_URL || 'http://baileys:3000';
+    media.url = `${baseUrl}/media/${id}`;
+    return media;
+};
 
 export const handleIncomingMessage = async (
     from: string,
     text: string,
     botNumber: string,
-    backendWS: WebSocket
+    backendWS: WebSocket,
+    media?: InboundMedia
 ) => {
     // Enviar mensaje al backend Go
     backendWS.send(JSON.stringify({
         phone: from,
         message: text,
-        botNumber
+        botNumber,
+        media
     }));
 
-    console.log(`Mensaje enviado al backend: ${from} - ${text}`);
-};
\ No newline at end of file
+    console.log(`Mensaje enviado al backend: ${from} - ${text}${media ? ` [${media.type}, ${media.size} bytes]` : ''}`);
+};
@@ -0,0 +1,100 @@
+import type { WebSocket } from 'ws';
+import { sendFrame } from '../websocket/client.js';
+
+// Mensaje que el API envía en un frame outbound_message (ver OutgoingMessage en el API)
+export interface OutgoingMessage {
+    to: string;
+    type: 'text' | 'image' | 'document' | 'audio' | 'location' | 'buttons' | 'list' | 'custom';
+    message?: string;
+    buttons?: { title: string; payload: string }[];
+    location?: { latitude: number; longitude: number; name?: string; address?: string };
+    media_url?: string;
+    file_name?: string;
+    mime_type?: string;
+    custom?: Record<string, unknown>;
+}
+
+export const toJid = (number: string) => number.includes('@') ? number : `${number}@s.whatsapp.net`;
+
+// Traduce el mensaje al contenido que recibe sock.sendMessage. Los archivos se
+// descargan desde la URL firmada del API.
+export const buildMessageContent = (out: OutgoingMessage): any => {
+    switch (out.type) {
+        case 'text':
+            return { text: out.message || '' };
+        case 'image':
+            return { image: { url: out.media_url }, caption: out.message, mimetype: out.mime_type };
+        case 'document':
+            return {
+                document: { url: out.media_url },
+                fileName: out.file_name || 'documento.pdf',
+                mimetype: out.mime_type || 'application/pdf',
+                caption: out.message
+            };
+        case 'audio':
+            return { audio: { url: out.media_url }, mimetype: out.mime_type || 'audio/ogg; codecs=opus' };
+        case 'location':
+            return {
+                location: {
+                    degreesLatitude: out.location?.latitude,
+                    degreesLongitude: out.location?.longitude,
+                    name: out.location?.name,
+                    address: out.location?.address
+                }
+            };
+        case 'buttons':
+            return {
+                text: out.message || '',
+                buttons: (out.buttons || []).map(b => ({
+                    buttonId: b.payload,
+                    buttonText: { displayText: b.title },
+                    type: 1
+                }))
+            };
+        case 'list':
+            return {
+                text: out.message || '',
+                buttonText: 'Ver opciones',
+                sections: [{
+                    title: 'Opciones',
+                    rows: (out.buttons || []).map(b => ({ title: b.title, rowId: b.payload }))
+                }]
+            };
+        case 'custom':
+            return out.custom;
+        default:
+            throw new Error(`Tipo de mensaje no soportado: ${(out as any).type}`);
+    }
+};
+
+// Frames ya enviados por WhatsApp (id del frame → id de WhatsApp). El API reenvía los
+// frames sin ack al reconectar; si ya se habían enviado solo se repite el ack
+const MAX_SENT_FRAMES = 5000;
+const sentFrames = new Map<string, string | undefined>();
+
+const rememberSentFrame = (frameId: string, whatsappId: string | undefined) => {
+    sentFrames.set(frameId, whatsappId);
+    if (sentFrames.size > MAX_SENT_FRAMES) {
+        sentFrames.delete(sentFrames.keys().next().value as string);
+    }
+};
+
+// Envía por WhatsApp un frame outbound_message del API y responde con ack (incluye el
+// id de WhatsApp) o error
+export const handleOutboundMessage = async (sock: any, ws: WebSocket, frame: any) => {
+    const out = frame.payload as OutgoingMessage;
+    if (sentFrames.has(frame.id)) {
+        sendFrame(ws, 'ack', { ref: frame.id, whatsapp_id: sentFrames.get(frame.id) });
+        return;
+    }
+    try {
+        if (!sock) throw new Error('WhatsApp no está conectado');
+        const sent = await sock.sendMessage(toJid(out.to), buildMessageContent(out));
+        rememberSentFrame(frame.id, sent?.key?.id);
+        sendFrame(ws, 'ack', { ref: frame.id, whatsapp_id: sent?.key?.id });
+        console.log(`📤 Mensaje ${out.type} enviado a ${out.to}`);
+    } catch (error: any) {
+        console.error('❌ Error enviando mensaje saliente:', error);
+        sendFrame(ws, 'error', { ref: frame.id, code: 'processing_failed', message: error.message });
+    }
+};
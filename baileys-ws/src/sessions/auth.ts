@@ -1,10 +1,56 @@
 // src/sessions/auth.ts
 import { useMultiFileAuthState } from "@whiskeysockets/baileys";
+import { existsSync, mkdirSync, readdirSync, readFileSync, renameSync, rmSync } from "fs";
+import { join } from "path";
+
+export const AUTH_DIR = "auth";
+
 /**
- * Obtiene el estado de autenticación usando múltiples archivos.
+ * Obtiene el estado de autenticación de un número usando múltiples archivos.
+ * Cada número guarda sus credenciales en auth/<número>.
  * @returns {Promise<ReturnType<typeof useMultiFileAuthState>>} El estado de autenticación.
  */
-export const getAuthState = async () => {
-  const authState = await useMultiFileAuthState("auth");
+export const getAuthState = async (number: string) => {
+  const authState = await useMultiFileAuthState(join(AUTH_DIR, number));
   return authState;
 };
+
+/**
+ * Borra las credenciales de un número (tras un logout o una sesión corrupta).
+ */
+export const clearAuthState = (number: string) => {
+  rmSync(join(AUTH_DIR, number), { recursive: true, force: true });
+};
+
+/**
+ * Números con credenciales guardadas. Las credenciales de la versión con una sola
+ * sesión (auth/creds.json) se mueven a la carpeta de su número.
+ */
+export const listSavedNumbers = (): string[] => {
+  if (!existsSync(AUTH_DIR)) return [];
+  migrateSingleSession();
+  return readdirSync(AUTH_DIR, { withFileTypes: true })
+    .filter((entry) => entry.isDirectory() && existsSync(join(AUTH_DIR, entry.name, "creds.json")))
+    .map((entry) => entry.name);
+};
+
+const migrateSingleSession = () => {
+  const legacyCreds = join(AUTH_DIR, "creds.json");
+  if (!existsSync(legacyCreds)) return;
+  try {
+    const creds = JSON.parse(readFileSync(legacyCreds, "utf-8"));
+    const number = normalizeNumber(creds?.me?.id || "");
+    if (!number) return;
+    const target = join(AUTH_DIR, number);
+    mkdirSync(target, { recursive: true });
+    for (const entry of readdirSync(AUTH_DIR, { withFileTypes: true })) {
+      if (entry.isFile()) renameSync(join(AUTH_DIR, entry.name), join(target, entry.name));
+    }
+    console.log(`📦 Credenciales de la sesión única movidas a ${target}`);
+  } catch (error) {
+    console.error("⚠️ No se pudieron migrar las credenciales de auth/creds.json:", error);
+  }
+};
+
+// "573001112233:12@s.whatsapp.net" → "573001112233"
+export const normalizeNumber = (jid: string) => jid.split("@")[0]!.split(":")[0]!.replace(/\D/g, "");
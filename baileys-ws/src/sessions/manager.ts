@@ -0,0 +1,327 @@
+// src/sessions/manager.ts - Una sesión de WhatsApp por número de bot
+import { makeWASocket, fetchLatestBaileysVersion, DisconnectReason, downloadMediaMessage } from "@whiskeysockets/baileys";
+import type { Boom } from "@hapi/boom";
+import type WebSocket from "ws";
+import P from "pino";
+import QRCode from "qrcode";
+import qrcode from "qrcode-terminal";
+import { getAuthState, clearAuthState, normalizeNumber } from "./auth.js";
+import { connectToBackendWS, connectSessionManagerWS, sendFrame } from "../websocket/client.js";
+import { handleIncomingMessage, buildInboundMedia, type InboundMedia } from "../handlers/messageHandler.js";
+import { handleOutboundMessage } from "../handlers/outboundHandler.js";
+
+const MAX_RECONNECT_ATTEMPTS = 5;
+// Cada cuánto se revisa que cada sesión tenga su conexión con el API
+const BACKEND_CHECK_INTERVAL_MS = 10 * 1000;
+
+export interface BotSession {
+    number: string;
+    sock: any;
+    connection: 'connecting' | 'open' | 'close';
+    name: string;
+    qrCode: string;
+    lastDisconnectReason: string;
+    reconnectAttempts: number;
+    stopped: boolean; // logout o cierre del servicio: no reconectar
+    backendWS?: WebSocket;
+}
+
+const sessions = new Map<string, BotSession>();
+
+export const getSession = (number: string) => sessions.get(normalizeNumber(number));
+
+export const listSessions = () => [...sessions.values()].map((session) => ({
+    number: session.number,
+    connection: session.connection,
+    name: session.name,
+    has_qr: !!session.qrCode,
+    last_disconnect_reason: session.lastDisconnectReason,
+    reconnect_attempts: session.reconnectAttempts
+}));
+
+// Conexión con el API para el número; por ella llegan los mensajes salientes y las órdenes
+const backend = (number: string) => connectToBackendWS(number, (ws, data) => handleBackendFrame(number, ws, data));
+
+const report = async (session: BotSession, type: string, payload: unknown) => {
+    try {
+        const ws = await backend(session.number);
+        session.backendWS = ws;
+        sendFrame(ws, type, payload);
+    } catch (error) {
+        console.error(`❌ No se pudo enviar ${type} del bot ${session.number}:`, error);
+    }
+};
+
+// Abre (o reabre) la sesión del número
+export const startSession = async (rawNumber: string): Promise<BotSession> => {
+    const number = normalizeNumber(rawNumber);
+    if (!number) throw new Error(`Número de bot inválido: ${rawNumber}`);
+
+    let session = sessions.get(number);
+    if (session?.sock && session.connection !== 'close') return session;
+    if (!session) {
+        session = {
+            number, sock: null, connection: 'connecting', name: '', qrCode: '',
+            lastDisconnectReason: '', reconnectAttempts: 0, stopped: false
+        };
+        sessions.set(number, session);
+    }
+    session.stopped = false;
+    await openSocket(session);
+    return session;
+};
+
+export const restartSession = async (number: string) => {
+    const session = getSession(number);
+    if (!session) return startSession(number);
+    closeSocket(session);
+    session.reconnectAttempts = 0;
+    await openSocket(session);
+    return session;
+};
+
+// Cierra la sesión en WhatsApp y borra las credenciales; un start posterior pide QR nuevo
+export const logoutSession = async (number: string) => {
+    const session = getSession(number);
+    if (!session) throw new Error(`No hay sesión para ${number}`);
+    session.stopped = true;
+    try {
+        await session.sock?.logout();
+    } catch (error) {
+        console.log(`Error durante logout de ${session.number}:`, error);
+    }
+    closeSocket(session);
+    clearAuthState(session.number);
+    session.connection = 'close';
+    session.lastDisconnectReason = 'logout';
+    await report(session, 'connection_state', { state: 'close', number: session.number, reason: 'logout' });
+};
+
+// Conexión del gestor de sesiones: el API le envía el start de números nuevos
+export const connectSessionManager = () =>
+    connectSessionManagerWS((ws, data) => handleBackendFrame('', ws, data));
+
+// Reabre la conexión con el API de cada sesión (p. ej. tras reiniciar el API) y le
+// vuelve a informar el estado, que el API solo guarda en memoria
+setInterval(async () => {
+    connectSessionManager().catch(() => {
+        // Se reintenta en la próxima revisión
+    });
+    for (const session of sessions.values()) {
+        if (session.stopped) continue;
+        try {
+            const ws = await backend(session.number);
+            if (ws === session.backendWS) continue;
+            session.backendWS = ws;
+            sendFrame(ws, 'connection_state', { state: session.connection, number: session.number, name: session.name });
+            if (session.qrCode) sendFrame(ws, 'qr_update', { qr_code: session.qrCode });
+        } catch {
+            // Se reintenta en la próxima revisión
+        }
+    }
+}, BACKEND_CHECK_INTERVAL_MS).unref();
+
+export const stopAllSessions = async () => {
+    for (const session of sessions.values()) {
+        session.stopped = true;
+        closeSocket(session);
+    }
+};
+
+const closeSocket = (session: BotSession) => {
+    if (!session.sock) return;
+    try {
+        session.sock.end(undefined);
+    } catch (e) {
+        console.log('Socket ya cerrado');
+    }
+    session.sock = null;
+};
+
+const openSocket = async (session: BotSession) => {
+    console.log(`🔄 Iniciando sesión de WhatsApp para ${session.number}...`);
+    session.qrCode = '';
+    session.connection = 'connecting';
+    await report(session, 'connection_state', { state: 'connecting', number: session.number });
+
+    try {
+        const { state, saveCreds } = await getAuthState(session.number);
+        const { version } = await fetchLatestBaileysVersion();
+
+        session.sock = makeWASocket({
+            version,
+            logger: P({ level: "silent" }),
+            auth: state,
+            defaultQueryTimeoutMs: 60000, // 60 segundos timeout
+            connectTimeoutMs: 60000,
+            keepAliveIntervalMs: 30000,
+            markOnlineOnConnect: true
+        });
+        setupEvents(session, session.sock, saveCreds);
+    } catch (error) {
+        console.error(`❌ Error iniciando la sesión de ${session.number}:`, error);
+        scheduleReconnect(session);
+    }
+};
+
+const scheduleReconnect = (session: BotSession) => {
+    if (session.stopped) return;
+    if (session.reconnectAttempts >= MAX_RECONNECT_ATTEMPTS) {
+        console.log(`❌ Máximo de reintentos alcanzado para ${session.number}. Usa POST /api/v1/whatsapp/session para reiniciarla`);
+        return;
+    }
+    session.reconnectAttempts++;
+    const delay = Math.min(3000 * session.reconnectAttempts, 30000); // Backoff progresivo
+    console.log(`⏰ Reintentando ${session.number} en ${delay / 1000} segundos... (${session.reconnectAttempts}/${MAX_RECONNECT_ATTEMPTS})`);
+    setTimeout(() => {
+        if (!session.stopped) openSocket(session);
+    }, delay);
+};
+
+const setupEvents = (session: BotSession, socket: any, saveCreds: any) => {
+    socket.ev.on("connection.update", async (update: any) => {
+        // Eventos de un socket ya reemplazado por un reinicio
+        if (socket !== session.sock) return;
+        const { connection, lastDisconnect, qr } = update;
+
+        if (qr) {
+            session.qrCode = qr;
+            qrcode.generate(qr, { small: true });
+            console.log(`📱 Nuevo QR para ${session.number}`);
+            let qrImage: string | undefined;
+            try {
+                qrImage = await QRCode.toDataURL(qr);
+            } catch (error) {
+                console.error('Error generando QR image:', error);
+            }
+            await report(session, 'qr_update', { qr_code: qr, qr_image: qrImage });
+        }
+
+        if (connection === "open") {
+            console.log(`✅ Conexión WhatsApp establecida para ${session.number}`);
+            const linked = normalizeNumber(socket.user?.id || '');
+            if (linked && linked !== session.number) {
+                console.warn(`⚠️ Se vinculó ${linked} en la sesión de ${session.number}`);
+            }
+            session.connection = 'open';
+            session.reconnectAttempts = 0;
+            session.qrCode = '';
+            session.name = socket.user?.name || 'Bot Docubot';
+            await report(session, 'connection_state', { state: 'open', number: session.number, name: session.name });
+        } else if (connection === "close") {
+            session.connection = 'close';
+            const boom = lastDisconnect?.error as Boom | undefined;
+            const statusCode = boom?.output?.statusCode;
+            session.lastDisconnectReason = `${statusCode}: ${boom?.message}`;
+            console.log(`❌ Conexión WhatsApp cerrada para ${session.number} (${session.lastDisconnectReason})`);
+            await report(session, 'connection_state', { state: 'close', number: session.number, reason: session.lastDisconnectReason });
+
+            if (shouldReconnect(session, statusCode)) scheduleReconnect(session);
+        }
+    });
+
+    socket.ev.on("creds.update", saveCreds);
+
+    socket.ev.on("messages.upsert", async ({ messages }: any) => {
+        for (const msg of messages) {
+            if (!msg.message) continue;
+            const from = msg.key.remoteJid;
+            const { imageMessage, documentMessage, audioMessage } = msg.message;
+            const text = msg.message.conversation || msg.message.extendedTextMessage?.text ||
+                imageMessage?.caption || documentMessage?.caption || '';
+
+            if (!from || msg.key.fromMe) continue;
+
+            // Fotos, PDFs y notas de voz
+            let media: InboundMedia | undefined;
+            const mediaMessage = imageMessage || documentMessage || audioMessage;
+            if (mediaMessage) {
+                try {
+                    const buffer = await downloadMediaMessage(msg, 'buffer', {}) as Buffer;
+                    const type = imageMessage ? 'image' : documentMessage ? 'document' : 'audio';
+                    media = buildInboundMedia(type, buffer, mediaMessage.mimetype, documentMessage?.fileName);
+                } catch (error) {
+                    console.error('❌ Error descargando archivo de', from, ':', error);
+                    continue;
+                }
+            }
+
+            if (!text && !media) continue;
+
+            console.log(`📨 Mensaje recibido por ${session.number} de`, from, ':', text || `[${media!.type}]`);
+
+            try {
+                await handleIncomingMessage(from, text, session.number, await backend(session.number), media);
+            } catch (error) {
+                console.error('❌ Error enviando mensaje al backend:', error);
+            }
+        }
+    });
+};
+
+// Decide si reconectar según el motivo de cierre; borra credenciales corruptas
+const shouldReconnect = (session: BotSession, statusCode?: number): boolean => {
+    if (session.stopped) return false;
+    switch (statusCode) {
+        case 401: // Credenciales corruptas
+        case DisconnectReason.badSession:
+            console.log(`🗑️ Sesión inválida para ${session.number} - limpiando credenciales`);
+            clearAuthState(session.number);
+            return true;
+        case DisconnectReason.connectionReplaced:
+            console.log(`🔄 Conexión de ${session.number} reemplazada por otra sesión`);
+            return false;
+        case 403:
+        case DisconnectReason.loggedOut:
+            console.log(`👋 Logout de ${session.number} desde el teléfono`);
+            clearAuthState(session.number);
+            return false;
+        default:
+            return true;
+    }
+};
+
+// Frames del API para la conexión de un número: mensajes salientes y órdenes de sesión
+const handleBackendFrame = async (number: string, ws: WebSocket, raw: string) => {
+    let frame: any;
+    try {
+        frame = JSON.parse(raw);
+    } catch {
+        return;
+    }
+
+    switch (frame.type) {
+        case 'outbound_message': {
+            const session = sessions.get(number);
+            await handleOutboundMessage(session?.connection === 'open' ? session.sock : null, ws, frame);
+            break;
+        }
+        case 'session_command':
+            await handleSessionCommand(ws, frame);
+            break;
+    }
+};
+
+const handleSessionCommand = async (ws: WebSocket, frame: any) => {
+    const { action, bot_number: number } = frame.payload || {};
+    console.log(`📲 Orden ${action} para la sesión de ${number}`);
+    try {
+        switch (action) {
+            case 'start':
+                await startSession(number);
+                break;
+            case 'restart':
+                await restartSession(number);
+                break;
+            case 'logout':
+                await logoutSession(number);
+                break;
+            default:
+                throw new Error(`Orden de sesión no soportada: ${action}`);
+        }
+        sendFrame(ws, 'ack', { ref: frame.id });
+    } catch (error: any) {
+        console.error(`❌ Error en la orden ${action} para ${number}:`, error);
+        sendFrame(ws, 'error', { ref: frame.id, code: 'processing_failed', message: error.message });
+    }
+};
@@ -1,25 +1,67 @@
 import WebSocket from 'ws';
+import { randomUUID } from 'crypto';
 
-export const connectToBackendWS = (phone: string): Promise<WebSocket> => {
-    return new Promise((resolve, reject) => {
+const PROTOCOL_VERSION = 1;
+
+// Una conexión por número de bot; se reutiliza para todos los mensajes y por ella
+// llegan los mensajes salientes y las órdenes de sesión del API
+const connections = new Map<string, Promise<WebSocket>>();
+
+export const connectToBackendWS = (phone: string, onMessage?: (ws: WebSocket, data: string) => void): Promise<WebSocket> =>
+    openBackendWS(phone, `phone=${encodeURIComponent(phone)}`, onMessage);
+
+// Conexión del gestor de sesiones: por ella llegan las órdenes start de números que
+// todavía no tienen conexión propia
+const SESSION_MANAGER_KEY = 'role=sessions';
+export const connectSessionManagerWS = (onMessage: (ws: WebSocket, data: string) => void): Promise<WebSocket> =>
+    openBackendWS(SESSION_MANAGER_KEY, SESSION_MANAGER_KEY, onMessage);
+
+const openBackendWS = (key: string, query: string, onMessage?: (ws: WebSocket, data: string) => void): Promise<WebSocket> => {
+    const existing = connections.get(key);
+    if (existing) return existing;
+
+    const connection = new Promise<WebSocket>((resolve, reject) => {
         // ✅ Usar variable de entorno en lugar de localhost hardcodeado
         const apiUrl = process.env.API_URL || 'http://localhost:8080';
         const wsUrl = apiUrl.replace('http:', 'ws:').replace('https:', 'wss:');
         
-        const ws = new WebSocket(`${wsUrl}/ws?phone=${phone}`);
+        // El API solo acepta a Baileys con la clave compartida BAILEYS_WS_SECRET
+        const secret = process.env.BAILEYS_WS_SECRET;
+        const ws = new WebSocket(`${wsUrl}/ws?${query}`, {
+            headers: secret ? { Authorization: `Bearer ${secret}` } : {}
+        });
 
         ws.on('open', () => {
-            console.log('✅ Conectado al backend Go');
+            console.log(`✅ Conectado al backend Go (${key})`);
             resolve(ws);
         });
 
+        ws.on('message', (data) => {
+            onMessage?.(ws, data.toString());
+        });
+
         ws.on('error', (err) => {
             console.error('Error conectando al backend:', err);
+            connections.delete(key);
             reject(err);
         });
 
         ws.on('close', () => {
-            console.log('Conexión WebSocket cerrada');
+            console.log(`Conexión WebSocket cerrada (${key})`);
+            connections.delete(key);
         });
     });
-};
\ No newline at end of file
+    connections.set(key, connection);
+    return connection;
+};
+
+// Envía un frame del protocolo (ver protocol.go en el API)
+export const sendFrame = (ws: WebSocket, type: string, payload: unknown) => {
+    ws.send(JSON.stringify({
+        type,
+        id: randomUUID(),
+        version: PROTOCOL_VERSION,
+        timestamp: new Date().toISOString(),
+        payload
+    }));
+};
@@ -11,38 +11,82 @@ app.get('/health', (req, res) => {
     res.json({ status: 'ok', service: 'playwright-bot' });
 });
 
-// Endpoint para generar manifiestos (ejemplo)
+const REQUIRED_FIELDS = ['flete', 'peso', 'fecha_cargue', 'fecha_descargue', 'tarjeta', 'licencia', 'origen', 'destino'];
+
+const escapeHtml = (value) => String(value ?? '')
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
+
+// Plantilla HTML del manifiesto de carga
+const manifestHtml = (data) => `<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<style>
+  body { font-family: Arial, sans-serif; margin: 40px; color: #222; }
+  h1 { font-size: 22px; border-bottom: 2px solid #222; padding-bottom: 8px; }
+  table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+  td { border: 1px solid #999; padding: 8px; }
+  td.label { width: 35%; background: #f2f2f2; font-weight: bold; }
+</style>
+</head>
+<body>
+  <h1>Manifiesto de carga</h1>
+  <table>
+    <tr><td class="label">Origen</td><td>${escapeHtml(data.origen)}</td></tr>
+    <tr><td class="label">Destino</td><td>${escapeHtml(data.destino)}</td></tr>
+    <tr><td class="label">Fecha de cargue</td><td>${escapeHtml(data.fecha_cargue)}</td></tr>
+    <tr><td class="label">Fecha de descargue</td><td>${escapeHtml(data.fecha_descargue)}</td></tr>
+    <tr><td class="label">Descripción de la carga</td><td>${escapeHtml(data.descripcion)}</td></tr>
+    <tr><td class="label">Peso</td><td>${escapeHtml(data.peso)}</td></tr>
+    <tr><td class="label">Flete</td><td>${escapeHtml(data.flete)}</td></tr>
+    <tr><td class="label">Tarjeta de propiedad</td><td>${escapeHtml(data.tarjeta)}</td></tr>
+    <tr><td class="label">Licencia de conducción</td><td>${escapeHtml(data.licencia)}</td></tr>
+  </table>
+  <p>Generado el ${new Date().toLocaleString('es-CO', { timeZone: 'America/Bogota' })}</p>
+</body>
+</html>`;
+
+// Genera el manifiesto y responde el PDF (application/pdf)
 app.post('/generate-manifest', async (req, res) => {
+    const { data } = req.body || {};
+    const missing = REQUIRED_FIELDS.filter((field) => !data || !data[field]);
+    if (missing.length > 0) {
+        return res.status(400).json({
+            success: false,
+            error: `Faltan datos: ${missing.join(', ')}`
+        });
+    }
+
+    let browser;
     try {
-        const { data } = req.body;
-        
         console.log('Generando manifiesto con datos:', data);
-        
-        // Aquí irá la lógica de Playwright para automatizar la generación
-        const browser = await chromium.launch({ 
+
+        browser = await chromium.launch({
             headless: true,
-            executablePath: process.env.CHROMIUM_PATH 
+            executablePath: process.env.CHROMIUM_PATH
         });
-        
         const page = await browser.newPage();
-        
-        // Ejemplo básico - aquí implementarás tu lógica específica
-        await page.goto('https://example.com');
-        
-        await browser.close();
-        
-        res.json({ 
-            success: true, 
-            message: 'Manifiesto generado',
-            file_url: 'http://example.com/manifest.pdf'
-        });
-        
+        await page.setContent(manifestHtml(data), { waitUntil: 'load' });
+        const pdf = await page.pdf({ format: 'A4', printBackground: true });
+
+        const fileName = `manifiesto-${Date.now()}.pdf`;
+        res.set('Content-Type', 'application/pdf');
+        res.set('Content-Disposition', `attachment; filename="${fileName}"`);
+        res.send(pdf);
+
     } catch (error) {
         console.error('Error generando manifiesto:', error);
-        res.status(500).json({ 
-            success: false, 
-            error: error.message 
+        res.status(500).json({
+            success: false,
+            error: error.message
         });
+    } finally {
+        if (browser) {
+            await browser.close();
+        }
     }
 });
 
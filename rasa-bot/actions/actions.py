@@ -4,6 +4,12 @@ from rasa_sdk.executor import CollectingDispatcher
 from rasa_sdk.events import AllSlotsReset, EventType
 from rasa_sdk.types import DomainDict
 
+def mostrar_valor(valor: Any) -> Text:
+    """Los archivos enviados por WhatsApp llegan como referencia media:<id>."""
+    if isinstance(valor, str) and valor.startswith("media:"):
+        return "📎 archivo recibido"
+    return f"{valor}"
+
 class ActionDefaultFallback(Action):
     def name(self) -> Text:
         return "action_default_fallback"
@@ -53,18 +59,34 @@ class ActionSubmitManifiesto(Action):
         print(f"  📍 Origen: {origen}")
         print(f"  🎯 Destino: {destino}")
         
-        # Aquí podrías hacer la llamada a tu API o Playwright
-        # Por ahora solo confirmamos que recibimos los datos
-        
+        # El API genera el PDF con Playwright y se lo envía al cliente
+        document_request = {
+            "document": {
+                "type": "manifiesto",
+                "data": {
+                    "flete": flete,
+                    "descripcion": descripcion,
+                    "peso": peso,
+                    "fecha_cargue": fecha_cargue,
+                    "fecha_descargue": fecha_descargue,
+                    "tarjeta": tarjeta,
+                    "licencia": licencia,
+                    "origen": origen,
+                    "destino": destino,
+                },
+            }
+        }
+
         dispatcher.utter_message(
+            json_message=document_request,
             text=f"✅ Perfecto! He recibido todos los datos para el manifiesto:\n\n"
                  f"💰 Flete: {flete}\n"
                  f"📦 Carga: {descripcion}\n"
                  f"⚖️ Peso: {peso}\n"
                  f"📅 Cargue: {fecha_cargue}\n"
                  f"📅 Descarga: {fecha_descargue}\n"
-                 f"🚗 Tarjeta: {tarjeta}\n"
-                 f"🪪 Licencia: {licencia}\n"
+                 f"🚗 Tarjeta: {mostrar_valor(tarjeta)}\n"
+                 f"🪪 Licencia: {mostrar_valor(licencia)}\n"
                  f"📍 Origen: {origen}\n"
                  f"🎯 Destino: {destino}\n\n"
                  f"🔄 Procesando manifiesto..."